@@ -0,0 +1,15 @@
+package audit
+
+import "time"
+
+var table = "ppt_audit_logs"
+
+type Entry struct {
+	ID         string                 `json:"id"`
+	ActorEmail string                 `json:"actor_email"`
+	Action     string                 `json:"action"`
+	Target     string                 `json:"target"`
+	Detail     map[string]interface{} `json:"detail"`
+	IPAddress  string                 `json:"ip_address"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
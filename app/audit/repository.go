@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+type (
+	AuditRepository interface {
+		Record(c context.Context, e Entry) error
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func NewRepository(db *sql.DB) AuditRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (q *repository) Record(c context.Context, e Entry) error {
+	detail := e.Detail
+	if detail == nil {
+		detail = map[string]interface{}{}
+	}
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO ` + table + ` (actor_email, action, target, detail, ip_address)
+	VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''))`
+
+	_, err = q.db.ExecContext(c, query, e.ActorEmail, e.Action, e.Target, data, e.IPAddress)
+	return err
+}
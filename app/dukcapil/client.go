@@ -0,0 +1,285 @@
+package dukcapil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+const (
+	defaultThreshold      = 100
+	defaultReviewFloor    = 80
+	defaultCacheTTL       = 24 * time.Hour
+	defaultUserDailyQuota = 10
+	defaultDailyQuota     = 1000
+)
+
+var (
+	ErrInvalidNIK    = errors.New("nik must be 16 digits with a valid region and birth date")
+	ErrNotConfigured = errors.New("dukcapil credentials are not configured")
+	ErrNotFound      = errors.New("nik not found in dukcapil")
+	ErrRejected      = errors.New("dukcapil rejected the request")
+
+	// fieldNames are the elements checked, as named in the API.
+	fieldNames = []string{"nik", "nama_lengkap", "jenis_kelamin", "tempat_lahir", "tanggal_lahir"}
+
+	nikPattern   = regexp.MustCompile(`^[0-9]{16}$`)
+	scorePattern = regexp.MustCompile(`(?i)^(Tidak\s+)?Sesuai\s*(?:\((\d+)\))?$`)
+)
+
+// Client verifies identities against Dukcapil's element verification
+// endpoint. Credentials are read on every call so a change in
+// ppt_configurations applies without a restart.
+type Client struct {
+	repo    DukcapilRepository
+	baseURL string
+}
+
+// NewClient uses baseURL when ppt_configurations has no dukcapil_url.
+func NewClient(repo DukcapilRepository, baseURL string) *Client {
+	return &Client{
+		repo:    repo,
+		baseURL: baseURL,
+	}
+}
+
+// ValidateNIK checks the shape of a NIK: 16 digits starting with a
+// province code, with a birth day (plus 40 for women) and month in
+// positions 7 to 10.
+func ValidateNIK(nik string) error {
+	if !nikPattern.MatchString(nik) {
+		return ErrInvalidNIK
+	}
+
+	province, _ := strconv.Atoi(nik[0:2])
+	day, _ := strconv.Atoi(nik[6:8])
+	month, _ := strconv.Atoi(nik[8:10])
+	if day > 40 {
+		day -= 40
+	}
+
+	if province < 11 || province > 99 || day < 1 || day > 31 || month < 1 || month > 12 {
+		return ErrInvalidNIK
+	}
+
+	return nil
+}
+
+func (cl *Client) Settings(ctx context.Context) (Settings, error) {
+	values, err := cl.repo.GetSettings(ctx)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	s := Settings{
+		URL:            strings.TrimRight(values["dukcapil_url"], "/"),
+		UserID:         values["dukcapil_user_id"],
+		Password:       values["dukcapil_password"],
+		IPUser:         values["dukcapil_ip_user"],
+		Threshold:      defaultThreshold,
+		CacheTTL:       defaultCacheTTL,
+		UserDailyQuota: defaultUserDailyQuota,
+		DailyQuota:     defaultDailyQuota,
+	}
+	if s.URL == "" {
+		s.URL = strings.TrimRight(cl.baseURL, "/")
+	}
+	// The seeded key is spelled the way the Dukcapil API spells it.
+	for _, key := range []string{"dukcapil_treshold", "dukcapil_threshold"} {
+		if t, err := strconv.Atoi(values[key]); err == nil && t > 0 && t <= 100 {
+			s.Threshold = t
+		}
+	}
+
+	// Every element may have its own thresholds; dukcapil_review_threshold
+	// sets the default review floor.
+	floor := defaultReviewFloor
+	if t, err := strconv.Atoi(values["dukcapil_review_threshold"]); err == nil && t >= 0 && t <= 100 {
+		floor = t
+	}
+	s.Thresholds = map[string]FieldThreshold{}
+	for _, field := range fieldNames {
+		ft := FieldThreshold{Accept: s.Threshold, Review: floor}
+		if t, err := strconv.Atoi(values["dukcapil_threshold_"+field]); err == nil && t > 0 && t <= 100 {
+			ft.Accept = t
+		}
+		if t, err := strconv.Atoi(values["dukcapil_review_threshold_"+field]); err == nil && t >= 0 && t <= 100 {
+			ft.Review = t
+		}
+		if ft.Review > ft.Accept {
+			ft.Review = ft.Accept
+		}
+		s.Thresholds[field] = ft
+	}
+
+	if ttl, err := time.ParseDuration(values["dukcapil_cache_ttl"]); err == nil && ttl >= 0 {
+		s.CacheTTL = ttl
+	}
+	if q, err := strconv.Atoi(values["dukcapil_user_daily_quota"]); err == nil && q >= 0 {
+		s.UserDailyQuota = q
+	}
+	if q, err := strconv.Atoi(values["dukcapil_daily_quota"]); err == nil && q >= 0 {
+		s.DailyQuota = q
+	}
+
+	if s.URL == "" || s.UserID == "" || s.Password == "" {
+		return s, ErrNotConfigured
+	}
+
+	return s, nil
+}
+
+// Verify checks id against the population register.
+func (cl *Client) Verify(ctx context.Context, id Identity) (Result, error) {
+	if err := ValidateNIK(id.NIK); err != nil {
+		return Result{}, err
+	}
+
+	settings, err := cl.Settings(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return cl.verify(ctx, settings, id)
+}
+
+func (cl *Client) verify(ctx context.Context, settings Settings, id Identity) (Result, error) {
+	body, err := json.Marshal(payload{
+		NIK:          id.NIK,
+		NamaLengkap:  strings.TrimSpace(id.NamaLengkap),
+		JenisKelamin: id.JenisKelamin,
+		TempatLahir:  strings.TrimSpace(id.TempatLahir),
+		TanggalLahir: id.TanggalLahir.Format("02-01-2006"),
+		Threshold:    settings.Threshold,
+		UserID:       settings.UserID,
+		Password:     settings.Password,
+		IPUser:       settings.IPUser,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, settings.URL+"/api/nik_verifby_elemen.php", bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	upstream := util.UpstreamFor(req.URL.String())
+	resp, err := upstream.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, upstream.Unexpected(resp)
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Result{}, &util.UpstreamError{Host: req.URL.Hostname(), StatusCode: resp.StatusCode, Kind: util.ErrUpstreamResponse, Cause: err}
+	}
+
+	return parseResponse(response, settings)
+}
+
+// parseResponse turns the first content row into a Result. Dukcapil
+// reports errors such as an unknown NIK or bad credentials in a RESPON
+// field with HTTP 200.
+func parseResponse(response Response, settings Settings) (Result, error) {
+	if len(response.Content) == 0 {
+		return Result{}, ErrNotFound
+	}
+
+	row := response.Content[0]
+	if row.Respon != "" {
+		if strings.Contains(strings.ToLower(row.Respon), "tidak ditemukan") {
+			return Result{}, ErrNotFound
+		}
+		return Result{}, fmt.Errorf("%w: %s", ErrRejected, row.Respon)
+	}
+
+	r := Result{
+		NIK:          parseMatch(row.NIK),
+		NamaLengkap:  parseMatch(row.NamaLengkap),
+		JenisKelamin: parseMatch(row.JenisKelamin),
+		TempatLahir:  parseMatch(row.TempatLahir),
+		TanggalLahir: parseMatch(row.TanggalLahir),
+	}
+	settings.Grade(&r)
+
+	return r, nil
+}
+
+// Grade sets the grade of every element and the verdict of r. A score is
+// graded against the thresholds whatever word precedes it, since Dukcapil
+// words it against the threshold sent with the request. An element without
+// a score passes when Dukcapil says it matches.
+func (s Settings) Grade(r *Result) {
+	r.Threshold = s.Threshold
+	r.Verdict = VerdictMatched
+
+	elements := map[string]*FieldMatch{
+		"nik":           &r.NIK,
+		"nama_lengkap":  &r.NamaLengkap,
+		"jenis_kelamin": &r.JenisKelamin,
+		"tempat_lahir":  &r.TempatLahir,
+		"tanggal_lahir": &r.TanggalLahir,
+	}
+	for name, f := range elements {
+		f.Grade = s.Thresholds[name].grade(*f)
+		switch {
+		case f.Grade == GradeFail:
+			r.Verdict = VerdictRejected
+		case f.Grade == GradeReview && r.Verdict == VerdictMatched:
+			r.Verdict = VerdictReview
+		}
+	}
+
+	r.Matched = r.Verdict == VerdictMatched
+}
+
+func (t FieldThreshold) grade(f FieldMatch) string {
+	if f.Score == nil {
+		if f.Match {
+			return GradePass
+		}
+		return GradeFail
+	}
+
+	switch {
+	case *f.Score >= t.Accept:
+		return GradePass
+	case *f.Score >= t.Review:
+		return GradeReview
+	default:
+		return GradeFail
+	}
+}
+
+func parseMatch(raw string) FieldMatch {
+	raw = strings.TrimSpace(raw)
+	m := FieldMatch{Raw: raw}
+
+	parts := scorePattern.FindStringSubmatch(raw)
+	if parts == nil {
+		return m
+	}
+
+	m.Match = parts[1] == ""
+	if parts[2] != "" {
+		score, _ := strconv.Atoi(parts[2])
+		m.Score = &score
+	}
+
+	return m
+}
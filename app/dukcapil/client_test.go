@@ -0,0 +1,233 @@
+package dukcapil
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/cmd/mockupstream/mock"
+	"github.com/gin-gonic/gin"
+)
+
+func score(n int) *int {
+	return &n
+}
+
+func TestValidateNIK(t *testing.T) {
+	tests := []struct {
+		nik   string
+		valid bool
+	}{
+		{"3201011501900001", true},
+		{"3201015501900001", true},   // women add 40 to the day
+		{"9201013112990001", true},   // highest province range
+		{"1101010101000001", true},   // lowest province code
+		{"320101150190000", false},   // 15 digits
+		{"32010115019000011", false}, // 17 digits
+		{"32010115019O0001", false},  // letter O
+		{"1001011501900001", false},  // province below 11
+		{"3201010001900001", false},  // day 0
+		{"3201013201900001", false},  // day 32
+		{"3201017201900001", false},  // day 72, 32 for a woman
+		{"3201011500900001", false},  // month 0
+		{"3201011513900001", false},  // month 13
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateNIK(tt.nik)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateNIK(%q) = %v, want valid %v", tt.nik, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidNIK) {
+			t.Errorf("ValidateNIK(%q) = %v, want %v", tt.nik, err, ErrInvalidNIK)
+		}
+	}
+}
+
+func TestParseMatch(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want FieldMatch
+	}{
+		{"Sesuai", FieldMatch{Raw: "Sesuai", Match: true}},
+		{"Sesuai (100)", FieldMatch{Raw: "Sesuai (100)", Match: true, Score: score(100)}},
+		{"  sesuai(87) ", FieldMatch{Raw: "sesuai(87)", Match: true, Score: score(87)}},
+		{"Tidak Sesuai", FieldMatch{Raw: "Tidak Sesuai"}},
+		{"Tidak  Sesuai (42)", FieldMatch{Raw: "Tidak  Sesuai (42)", Score: score(42)}},
+		{"TIDAK SESUAI (0)", FieldMatch{Raw: "TIDAK SESUAI (0)", Score: score(0)}},
+		{"", FieldMatch{}},
+		{"Data tidak ditemukan", FieldMatch{Raw: "Data tidak ditemukan"}},
+		{"Sesuai (abc)", FieldMatch{Raw: "Sesuai (abc)"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseMatch(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMatch(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	settings := Settings{
+		Threshold: 90,
+		Thresholds: map[string]FieldThreshold{
+			"nik":           {Accept: 100, Review: 100},
+			"nama_lengkap":  {Accept: 90, Review: 75},
+			"jenis_kelamin": {Accept: 100, Review: 100},
+			"tempat_lahir":  {Accept: 80, Review: 60},
+			"tanggal_lahir": {Accept: 100, Review: 100},
+		},
+	}
+	all := func(name, place FieldMatch) Result {
+		return Result{
+			NIK:          parseMatch("Sesuai"),
+			NamaLengkap:  name,
+			JenisKelamin: parseMatch("Sesuai"),
+			TempatLahir:  place,
+			TanggalLahir: parseMatch("Sesuai"),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		result      Result
+		verdict     string
+		nameGrade   string
+		placeGrade  string
+		wantMatched bool
+	}{
+		{"everything matches", all(parseMatch("Sesuai (100)"), parseMatch("Sesuai (100)")), VerdictMatched, GradePass, GradePass, true},
+		{"at the accept threshold", all(parseMatch("Sesuai (90)"), parseMatch("Tidak Sesuai (80)")), VerdictMatched, GradePass, GradePass, true},
+		{"name needs review", all(parseMatch("Tidak Sesuai (80)"), parseMatch("Sesuai (100)")), VerdictReview, GradeReview, GradePass, false},
+		{"score counts whatever the wording", all(parseMatch("Sesuai (70)"), parseMatch("Sesuai (100)")), VerdictRejected, GradeFail, GradePass, false},
+		{"a failure outweighs a review", all(parseMatch("Tidak Sesuai (80)"), parseMatch("Tidak Sesuai (10)")), VerdictRejected, GradeReview, GradeFail, false},
+		{"no score, no match", all(parseMatch("Tidak Sesuai"), parseMatch("Sesuai")), VerdictRejected, GradeFail, GradePass, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.result
+			settings.Grade(&r)
+
+			if r.Verdict != tt.verdict || r.Matched != tt.wantMatched {
+				t.Errorf("verdict = %s (matched %v), want %s (matched %v)", r.Verdict, r.Matched, tt.verdict, tt.wantMatched)
+			}
+			if r.NamaLengkap.Grade != tt.nameGrade || r.TempatLahir.Grade != tt.placeGrade {
+				t.Errorf("grades = %s/%s, want %s/%s", r.NamaLengkap.Grade, r.TempatLahir.Grade, tt.nameGrade, tt.placeGrade)
+			}
+			if r.Threshold != settings.Threshold {
+				t.Errorf("threshold = %d, want %d", r.Threshold, settings.Threshold)
+			}
+		})
+	}
+}
+
+type fakeSettings struct {
+	DukcapilRepository
+	values map[string]string
+}
+
+func (f fakeSettings) GetSettings(context.Context) (map[string]string, error) {
+	return f.values, nil
+}
+
+func TestSettings(t *testing.T) {
+	cl := NewClient(fakeSettings{values: map[string]string{
+		"dukcapil_user_id":                        "user",
+		"dukcapil_password":                       "secret",
+		"dukcapil_treshold":                       "95",
+		"dukcapil_review_threshold":               "70",
+		"dukcapil_threshold_tempat_lahir":         "80",
+		"dukcapil_review_threshold_nik":           "100",
+		"dukcapil_review_threshold_tanggal_lahir": "101",
+		"dukcapil_cache_ttl":                      "1h",
+		"dukcapil_daily_quota":                    "0",
+	}}, "http://dukcapil.test/")
+
+	s, err := cl.Settings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.URL != "http://dukcapil.test" {
+		t.Errorf("URL = %q, want the base URL without its trailing slash", s.URL)
+	}
+	if s.Threshold != 95 {
+		t.Errorf("Threshold = %d, want 95", s.Threshold)
+	}
+	want := map[string]FieldThreshold{
+		"nik":           {Accept: 95, Review: 95},
+		"nama_lengkap":  {Accept: 95, Review: 70},
+		"jenis_kelamin": {Accept: 95, Review: 70},
+		"tempat_lahir":  {Accept: 80, Review: 70},
+		"tanggal_lahir": {Accept: 95, Review: 70},
+	}
+	if !reflect.DeepEqual(s.Thresholds, want) {
+		t.Errorf("Thresholds = %v, want %v", s.Thresholds, want)
+	}
+	if s.CacheTTL.String() != "1h0m0s" || s.DailyQuota != 0 || s.UserDailyQuota != defaultUserDailyQuota {
+		t.Errorf("cache/quotas = %v/%d/%d", s.CacheTTL, s.DailyQuota, s.UserDailyQuota)
+	}
+
+	cl = NewClient(fakeSettings{values: map[string]string{}}, "http://dukcapil.test")
+	if _, err := cl.Settings(context.Background()); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Settings() without credentials = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestVerifyAgainstMock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		nik      string
+		behavior mock.Behavior
+		matched  bool
+		err      error
+	}{
+		{"matching identity", "3201011501900001", mock.Behavior{}, true, nil},
+		{"mismatching identity", "3201011501900000", mock.Behavior{}, false, nil},
+		{"one failure is retried", "3201011501900001", mock.Behavior{FailNext: 1}, true, nil},
+		{"invalid NIK is not sent", "3201011501", mock.Behavior{FailNext: 5}, false, ErrInvalidNIK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(mock.NewRouter(mock.Options{Behavior: tt.behavior}))
+			defer srv.Close()
+
+			cl := NewClient(fakeSettings{values: map[string]string{
+				"dukcapil_url":      srv.URL + "/dukcapil",
+				"dukcapil_user_id":  "user",
+				"dukcapil_password": "secret",
+			}}, "")
+
+			r, err := cl.Verify(context.Background(), Identity{
+				NIK:          tt.nik,
+				NamaLengkap:  "Budi Santoso",
+				JenisKelamin: "LAKI-LAKI",
+				TempatLahir:  "Bogor",
+				TanggalLahir: time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC),
+			})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Matched != tt.matched {
+				t.Errorf("matched = %v, want %v", r.Matched, tt.matched)
+			}
+			if r.NamaLengkap.Match != tt.matched {
+				t.Errorf("nama_lengkap = %+v, want match %v", r.NamaLengkap, tt.matched)
+			}
+		})
+	}
+}
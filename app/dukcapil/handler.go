@@ -1,26 +1,39 @@
 package dukcapil
 
 import (
+	"database/sql"
+
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
 
 type DukcapilHandler struct {
-	rdb *redis.Client
+	Usecase DukcapilUsecase
+	rdb     *redis.Client
 }
 
-func NewHandler(router *gin.Engine, rdb *redis.Client) {
+func NewHandler(router *gin.Engine, usecase DukcapilUsecase, rdb *redis.Client, db *sql.DB) {
 	handler := &DukcapilHandler{
-		rdb: rdb,
+		Usecase: usecase,
+		rdb:     rdb,
 	}
 
 	v1 := router.Group("/v1")
 
 	v1.POST("id-validation", util.AuthMiddleware(handler.rdb), handler.IdValidation)
-	// v1.POST("id-validation", handler.IdValidation)
+	v1.GET("dukcapil-verifications", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.GetVerifications)
+	v1.GET("dukcapil-verification-stats", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.GetVerificationStats)
 }
 
 func (handler *DukcapilHandler) IdValidation(c *gin.Context) {
-	IdValidation(c)
+	handler.Usecase.IdValidation(c)
+}
+
+func (handler *DukcapilHandler) GetVerifications(c *gin.Context) {
+	handler.Usecase.GetVerifications(c)
+}
+
+func (handler *DukcapilHandler) GetVerificationStats(c *gin.Context) {
+	handler.Usecase.GetVerificationStats(c)
 }
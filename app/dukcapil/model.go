@@ -1,11 +1,93 @@
 package dukcapil
 
+import (
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+var (
+	configTable       = "ppt_configurations"
+	verificationTable = "ppt_dukcapil_verifications"
+)
+
+// Field grades and overall verdicts of a verification.
+const (
+	GradePass   = "pass"
+	GradeReview = "review"
+	GradeFail   = "fail"
+
+	VerdictMatched  = "matched"
+	VerdictReview   = "review"
+	VerdictRejected = "rejected"
+)
+
+// Verification statuses.
+const (
+	StatusMatched       = "matched"
+	StatusReview        = "review"
+	StatusMismatch      = "mismatch"
+	StatusNotFound      = "not_found"
+	StatusInvalid       = "invalid"
+	StatusQuotaExceeded = "quota_exceeded"
+	StatusError         = "error"
+)
+
+// Verification sources.
+const (
+	SourceCompletion   = "completion"
+	SourceIDValidation = "id-validation"
+)
+
 type (
 	DataRequest struct {
-		NIK          string `json:"nik" binding:"required,min=16,max=16"`
+		NIK          string `json:"nik" binding:"required,len=16,numeric"`
 		NamaLengkap  string `json:"nama_lengkap" binding:"required"`
 		JenisKelamin string `json:"jenis_kelamin"  binding:"required"`
 		TempatLahir  string `json:"tempat_lahir" binding:"required"`
+		TanggalLahir string `json:"tanggal_lahir" binding:"required"`
+	}
+
+	// Identity is the data checked against the population register.
+	Identity struct {
+		NIK          string
+		NamaLengkap  string
+		JenisKelamin string
+		TempatLahir  string
+		TanggalLahir time.Time
+	}
+
+	// Settings are read from the dukcapil_* keys of ppt_configurations.
+	// A zero quota disables that quota.
+	Settings struct {
+		URL            string
+		UserID         string
+		Password       string
+		IPUser         string
+		Threshold      int
+		Thresholds     map[string]FieldThreshold
+		CacheTTL       time.Duration
+		UserDailyQuota int
+		DailyQuota     int
+	}
+
+	// FieldThreshold grades a similarity score: Accept and above passes,
+	// Review up to Accept goes to manual review, anything lower fails.
+	FieldThreshold struct {
+		Accept int `json:"accept"`
+		Review int `json:"review"`
+	}
+
+	payload struct {
+		NIK          string `json:"NIK"`
+		NamaLengkap  string `json:"NAMA_LGKP"`
+		JenisKelamin string `json:"JENIS_KLMIN"`
+		TempatLahir  string `json:"TMPT_LHR"`
+		TanggalLahir string `json:"TGL_LHR"`
+		Threshold    int    `json:"TRESHOLD"`
+		UserID       string `json:"user_id"`
+		Password     string `json:"password"`
+		IPUser       string `json:"ip_user"`
 	}
 
 	Content struct {
@@ -29,6 +111,7 @@ type (
 		NoKec          string `json:"NO_KEC"`
 		NoKel          string `json:"NO_KEL"`
 		NIK            string `json:"NIK"`
+		Respon         string `json:"RESPON"`
 	}
 
 	Response struct {
@@ -41,4 +124,86 @@ type (
 		Number           int       `json:"number"`
 		Size             int       `json:"size"`
 	}
+
+	// FieldMatch is the verdict on one element. Dukcapil answers "Sesuai",
+	// "Tidak Sesuai" or either followed by a similarity score such as
+	// "Sesuai (100)"; Score is nil when no score was given. Grade is ours,
+	// from the score against the field's thresholds.
+	FieldMatch struct {
+		Raw   string `json:"raw"`
+		Match bool   `json:"match"`
+		Score *int   `json:"score"`
+		Grade string `json:"grade"`
+	}
+
+	// Result is the typed answer of an element verification. Verdict is
+	// matched when every element passes, review when none fails but some
+	// need a person to look at them, and rejected otherwise.
+	Result struct {
+		Matched      bool       `json:"matched"`
+		Verdict      string     `json:"verdict"`
+		Cached       bool       `json:"cached"`
+		Threshold    int        `json:"threshold"`
+		NIK          FieldMatch `json:"nik"`
+		NamaLengkap  FieldMatch `json:"nama_lengkap"`
+		JenisKelamin FieldMatch `json:"jenis_kelamin"`
+		TempatLahir  FieldMatch `json:"tempat_lahir"`
+		TanggalLahir FieldMatch `json:"tanggal_lahir"`
+	}
+
+	// Verification is one logged attempt; Matches is nil when Dukcapil
+	// gave no verdict.
+	Verification struct {
+		HashedID   string                `json:"id"`
+		NIKHash    string                `json:"nik_hash"`
+		ActorEmail string                `json:"actor_email"`
+		Source     string                `json:"source"`
+		Requested  []string              `json:"requested"`
+		Status     string                `json:"status"`
+		Matches    map[string]FieldMatch `json:"matches"`
+		Cached     bool                  `json:"cached"`
+		Error      *string               `json:"error"`
+		CreatedAt  time.Time             `json:"created_at"`
+	}
+
+	VerificationFilter struct {
+		Status     string
+		Source     string
+		ActorEmail string
+		From       *time.Time
+		To         *time.Time
+	}
+
+	VerificationsWithPagination struct {
+		Row        []Verification          `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	// VerificationStat counts the attempts of one day. SuccessRate is the
+	// share of matched answers among those Dukcapil gave a verdict on;
+	// answers sent to review count as not matched.
+	VerificationStat struct {
+		Date          string  `json:"date"`
+		Total         int     `json:"total"`
+		Matched       int     `json:"matched"`
+		Review        int     `json:"review"`
+		Mismatch      int     `json:"mismatch"`
+		NotFound      int     `json:"not_found"`
+		Invalid       int     `json:"invalid"`
+		QuotaExceeded int     `json:"quota_exceeded"`
+		Failed        int     `json:"failed"`
+		Cached        int     `json:"cached"`
+		SuccessRate   float64 `json:"success_rate"`
+	}
 )
+
+// Fields maps the element names used in the API to their verdicts.
+func (r Result) Fields() map[string]FieldMatch {
+	return map[string]FieldMatch{
+		"nik":           r.NIK,
+		"nama_lengkap":  r.NamaLengkap,
+		"jenis_kelamin": r.JenisKelamin,
+		"tempat_lahir":  r.TempatLahir,
+		"tanggal_lahir": r.TanggalLahir,
+	}
+}
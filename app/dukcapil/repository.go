@@ -0,0 +1,196 @@
+package dukcapil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+type (
+	DukcapilRepository interface {
+		GetSettings(c context.Context) (map[string]string, error)
+
+		StoreVerification(c context.Context, v Verification) error
+		CountVerifications(c context.Context, f VerificationFilter) (int, error)
+		GetVerifications(c context.Context, f VerificationFilter, page, pageSize int) ([]Verification, error)
+		VerificationStats(c context.Context, from, to time.Time) ([]VerificationStat, error)
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func NewRepository(db *sql.DB) DukcapilRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetSettings returns every dukcapil_* configuration by name.
+func (q *repository) GetSettings(c context.Context) (map[string]string, error) {
+	query := `SELECT name, COALESCE(value, '') FROM ` + configTable + ` WHERE name LIKE 'dukcapil\_%'`
+
+	rows, err := q.db.QueryContext(c, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		items[name] = value
+	}
+
+	return items, rows.Err()
+}
+
+func (q *repository) StoreVerification(c context.Context, v Verification) error {
+	requested, err := json.Marshal(v.Requested)
+	if err != nil {
+		return err
+	}
+
+	var matches []byte
+	if v.Matches != nil {
+		if matches, err = json.Marshal(v.Matches); err != nil {
+			return err
+		}
+	}
+
+	query := `
+	INSERT INTO ` + verificationTable + ` (nik_hash, actor_email, source, requested, status, matches, cached, error)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = q.db.ExecContext(c, query, v.NIKHash, v.ActorEmail, v.Source, requested, v.Status, matches, v.Cached, v.Error)
+	return err
+}
+
+func (q *repository) CountVerifications(c context.Context, f VerificationFilter) (int, error) {
+	where, args := verificationWhere(f)
+
+	var total int
+	err := q.db.QueryRowContext(c, `SELECT COUNT(*) FROM `+verificationTable+where, args...).Scan(&total)
+	return total, err
+}
+
+func (q *repository) GetVerifications(c context.Context, f VerificationFilter, page, pageSize int) ([]Verification, error) {
+	where, args := verificationWhere(f)
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	query := `
+	SELECT id, nik_hash, actor_email, source, requested, status, matches, cached, error, created_at
+	FROM ` + verificationTable + where + `
+	ORDER BY created_at DESC, id DESC
+	LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	rows, err := q.db.QueryContext(c, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Verification{}
+	for rows.Next() {
+		var r Verification
+		var id int64
+		var requested, matches []byte
+		err := rows.Scan(&id, &r.NIKHash, &r.ActorEmail, &r.Source, &requested, &r.Status, &matches, &r.Cached, &r.Error, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(requested, &r.Requested); err != nil {
+			return nil, err
+		}
+		if matches != nil {
+			if err := json.Unmarshal(matches, &r.Matches); err != nil {
+				return nil, err
+			}
+		}
+		r.HashedID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+// VerificationStats counts attempts per day between from and to.
+func (q *repository) VerificationStats(c context.Context, from, to time.Time) ([]VerificationStat, error) {
+	query := `
+	SELECT TO_CHAR(created_at, 'YYYY-MM-DD'), COUNT(*),
+		COUNT(*) FILTER (WHERE status = $3),
+		COUNT(*) FILTER (WHERE status = $4),
+		COUNT(*) FILTER (WHERE status = $5),
+		COUNT(*) FILTER (WHERE status = $6),
+		COUNT(*) FILTER (WHERE status = $7),
+		COUNT(*) FILTER (WHERE status = $8),
+		COUNT(*) FILTER (WHERE status = $9),
+		COUNT(*) FILTER (WHERE cached)
+	FROM ` + verificationTable + `
+	WHERE created_at >= $1 AND created_at < $2
+	GROUP BY 1
+	ORDER BY 1`
+
+	rows, err := q.db.QueryContext(c, query, from, to,
+		StatusMatched, StatusReview, StatusMismatch, StatusNotFound, StatusInvalid, StatusQuotaExceeded, StatusError)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []VerificationStat{}
+	for rows.Next() {
+		var r VerificationStat
+		err := rows.Scan(&r.Date, &r.Total, &r.Matched, &r.Review, &r.Mismatch, &r.NotFound, &r.Invalid, &r.QuotaExceeded, &r.Failed, &r.Cached)
+		if err != nil {
+			return nil, err
+		}
+		if answered := r.Matched + r.Review + r.Mismatch + r.NotFound; answered > 0 {
+			r.SuccessRate = math.Round(float64(r.Matched)/float64(answered)*10000) / 100
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+func verificationWhere(f VerificationFilter) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.Source != "" {
+		add("source = $%d", f.Source)
+	}
+	if f.ActorEmail != "" {
+		add("actor_email = $%d", f.ActorEmail)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < $%d", *f.To)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
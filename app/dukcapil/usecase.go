@@ -1,49 +1,195 @@
 package dukcapil
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 )
 
-func IdValidation(c *gin.Context) {
-	payload := []byte(`{
-		"NIK": "1902011703970001",
-		"NAMA_LGKP": "Ghaly Fadhillah",
-		"JENIS_KLMIN": "Laki-laki",
-		"TMPT_LHR": "Bandar Lampung",
-		"TGL_LHR": "17-03-1997",
-		"TRESHOLD": 100,
-		"user_id": "26082022160454BADAN_PENYULUHAN_SDM8370",
-		"password": "TH854Y",
-		"ip_user": "10.160.84.10"
-	}`)
-	req, err := http.NewRequest("POST", "http://10.1.241.250/api/nik_verifby_elemen.php", bytes.NewBuffer(payload))
+type (
+	DukcapilUsecase interface {
+		IdValidation(c *gin.Context)
+		GetVerifications(c *gin.Context)
+		GetVerificationStats(c *gin.Context)
+	}
+
+	usecase struct {
+		repo     DukcapilRepository
+		verifier *Verifier
+	}
+)
+
+// maxStatsRange bounds the period of one stats request.
+const maxStatsRange = 366 * 24 * time.Hour
+
+func NewUsecase(repo DukcapilRepository, verifier *Verifier) DukcapilUsecase {
+	return &usecase{
+		repo:     repo,
+		verifier: verifier,
+	}
+}
+
+func (uc *usecase) IdValidation(c *gin.Context) {
+	var r DataRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	dob, err := time.Parse("2006-01-02", r.TanggalLahir)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, errors.New("tanggal_lahir must be YYYY-MM-DD"))
+		return
+	}
+
+	result, err := uc.verifier.Verify(c, email, SourceIDValidation, Identity{
+		NIK:          r.NIK,
+		NamaLengkap:  r.NamaLengkap,
+		JenisKelamin: r.JenisKelamin,
+		TempatLahir:  r.TempatLahir,
+		TanggalLahir: dob,
+	})
+	if err != nil {
+		util.JERR(c, HTTPStatus(err), err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, result)
+}
+
+// GetVerifications lists logged attempts, newest first, filtered by
+// status, source, email and a from/to date range.
+func (uc *usecase) GetVerifications(c *gin.Context) {
+	filter := VerificationFilter{
+		Status:     c.Query("status"),
+		Source:     c.Query("source"),
+		ActorEmail: c.Query("email"),
+	}
+	from, to, err := dateRange(c, false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+		util.JERR(c, http.StatusBadRequest, err)
 		return
 	}
+	filter.From, filter.To = from, to
 
-	req.Header.Set("Content-Type", "application/json")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	totalRecords, err := uc.repo.CountVerifications(c, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to make request"})
+		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	var response Response
+	data, err := uc.repo.GetVerifications(c, filter, page, pageSize)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
 
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(&response); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode response"})
+	util.JOK(c, http.StatusOK, VerificationsWithPagination{
+		Row: data,
+		Pagination: util.PaginationResponse{
+			CurrentPage:  page,
+			PageSize:     pageSize,
+			TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+			TotalRecords: totalRecords,
+		},
+	})
+}
+
+// GetVerificationStats reports daily attempts and success rates, for the
+// last 30 days unless from and to are given.
+func (uc *usecase) GetVerificationStats(c *gin.Context) {
+	from, to, err := dateRange(c, true)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := uc.repo.VerificationStats(c, *from, *to)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	content := response.Content
-	c.JSON(http.StatusOK, content)
+	util.JOK(c, http.StatusOK, data)
+}
+
+// dateRange reads from and to (YYYY-MM-DD, to inclusive) into a half open
+// range. With defaults a missing bound becomes the last 30 days.
+func dateRange(c *gin.Context, defaults bool) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+
+	if s := c.Query("from"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return nil, nil, errors.New("from must be YYYY-MM-DD")
+		}
+		from = &t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return nil, nil, errors.New("to must be YYYY-MM-DD")
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+
+	if defaults {
+		if to == nil {
+			now := time.Now()
+			t := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local)
+			to = &t
+		}
+		if from == nil {
+			t := to.AddDate(0, 0, -30)
+			from = &t
+		}
+		if to.Sub(*from) > maxStatsRange {
+			return nil, nil, errors.New("the period may span at most a year")
+		}
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, errors.New("from must not be after to")
+	}
+
+	return from, to, nil
+}
+
+// HTTPStatus maps a Verify error to the status answered to the caller.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidNIK):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrNotConfigured):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrRejected):
+		return http.StatusBadGateway
+	default:
+		return util.UpstreamHTTPStatus(err)
+	}
 }
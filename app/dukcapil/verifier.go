@@ -0,0 +1,209 @@
+package dukcapil
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cacheKeyPrefix = "ppt:dukcapil:"
+	quotaKeyPrefix = "ppt:dukcapil-quota:"
+	// quotaKeyTTL keeps a day's counters a little past midnight so a late
+	// request never finds the key gone and starts over.
+	quotaKeyTTL = 48 * time.Hour
+)
+
+var ErrQuotaExceeded = errors.New("daily dukcapil verification quota exceeded, try again tomorrow")
+
+// Verifier puts the Client behind a cache and daily quotas and logs every
+// attempt to ppt_dukcapil_verifications.
+type Verifier struct {
+	client *Client
+	repo   DukcapilRepository
+	rdb    *redis.Client
+	secret []byte
+}
+
+// NewVerifier keys the NIK and cache hashes with secret so they cannot be
+// reversed by hashing every possible NIK.
+func NewVerifier(client *Client, repo DukcapilRepository, rdb *redis.Client, secret string) *Verifier {
+	return &Verifier{
+		client: client,
+		repo:   repo,
+		rdb:    rdb,
+		secret: []byte(secret),
+	}
+}
+
+// Verify checks id on behalf of actor. An identical lookup within the
+// cache TTL is answered from Redis without counting against any quota.
+func (v *Verifier) Verify(ctx context.Context, actor, source string, id Identity) (Result, error) {
+	entry := Verification{
+		NIKHash:    v.hash(id.NIK),
+		ActorEmail: actor,
+		Source:     source,
+		Requested:  id.requested(),
+	}
+
+	result, err := v.verify(ctx, actor, id)
+
+	switch {
+	case err == nil && result.Verdict == VerdictMatched:
+		entry.Status = StatusMatched
+	case err == nil && result.Verdict == VerdictReview:
+		entry.Status = StatusReview
+	case err == nil:
+		entry.Status = StatusMismatch
+	case errors.Is(err, ErrInvalidNIK):
+		entry.Status = StatusInvalid
+	case errors.Is(err, ErrNotFound):
+		entry.Status = StatusNotFound
+	case errors.Is(err, ErrQuotaExceeded):
+		entry.Status = StatusQuotaExceeded
+	default:
+		entry.Status = StatusError
+		msg := err.Error()
+		entry.Error = &msg
+	}
+	if err == nil {
+		entry.Matches = result.Fields()
+		entry.Cached = result.Cached
+	}
+
+	if lerr := v.repo.StoreVerification(context.Background(), entry); lerr != nil {
+		log.Printf("logging dukcapil verification: %v", lerr)
+	}
+
+	return result, err
+}
+
+func (v *Verifier) verify(ctx context.Context, actor string, id Identity) (Result, error) {
+	if err := ValidateNIK(id.NIK); err != nil {
+		return Result{}, err
+	}
+
+	settings, err := v.client.Settings(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	key := cacheKeyPrefix + v.hash(id.cacheKey(settings.Threshold))
+	if settings.CacheTTL > 0 {
+		if raw, err := v.rdb.Get(ctx, key).Bytes(); err == nil {
+			var cached Result
+			if err := json.Unmarshal(raw, &cached); err == nil {
+				// Thresholds may have changed since the answer was cached.
+				settings.Grade(&cached)
+				cached.Cached = true
+				return cached, nil
+			}
+		}
+	}
+
+	if err := v.consumeQuota(ctx, settings, actor); err != nil {
+		return Result{}, err
+	}
+
+	result, err := v.client.verify(ctx, settings, id)
+	if err != nil {
+		return result, err
+	}
+
+	if settings.CacheTTL > 0 {
+		if raw, err := json.Marshal(result); err == nil {
+			v.rdb.Set(ctx, key, raw, settings.CacheTTL)
+		}
+	}
+
+	return result, nil
+}
+
+// consumeQuota counts one upstream call against the actor's and the
+// global quota of today. A call refused by the global quota is given back
+// to the actor.
+func (v *Verifier) consumeQuota(ctx context.Context, settings Settings, actor string) error {
+	day := time.Now().Format("20060102")
+	userKey := quotaKeyPrefix + day + ":" + actor
+	globalKey := quotaKeyPrefix + day
+
+	if settings.UserDailyQuota > 0 {
+		n, err := v.incr(ctx, userKey)
+		if err != nil {
+			return err
+		}
+		if n > int64(settings.UserDailyQuota) {
+			return ErrQuotaExceeded
+		}
+	}
+
+	if settings.DailyQuota > 0 {
+		n, err := v.incr(ctx, globalKey)
+		if err != nil {
+			return err
+		}
+		if n > int64(settings.DailyQuota) {
+			if settings.UserDailyQuota > 0 {
+				v.rdb.Decr(ctx, userKey)
+			}
+			return ErrQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+func (v *Verifier) incr(ctx context.Context, key string) (int64, error) {
+	n, err := v.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		v.rdb.Expire(ctx, key, quotaKeyTTL)
+	}
+	return n, nil
+}
+
+func (v *Verifier) hash(s string) string {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// requested lists the elements that were filled in.
+func (id Identity) requested() []string {
+	fields := []string{}
+	for _, f := range []struct{ name, value string }{
+		{"nik", id.NIK},
+		{"nama_lengkap", id.NamaLengkap},
+		{"jenis_kelamin", id.JenisKelamin},
+		{"tempat_lahir", id.TempatLahir},
+	} {
+		if strings.TrimSpace(f.value) != "" {
+			fields = append(fields, f.name)
+		}
+	}
+	if !id.TanggalLahir.IsZero() {
+		fields = append(fields, "tanggal_lahir")
+	}
+	return fields
+}
+
+// cacheKey normalises the lookup so differences in case and spacing do not
+// miss the cache.
+func (id Identity) cacheKey(threshold int) string {
+	norm := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToUpper(s)), " ")
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%d", id.NIK, norm(id.NamaLengkap), norm(id.JenisKelamin),
+		norm(id.TempatLahir), id.TanggalLahir.Format("2006-01-02"), threshold)
+}
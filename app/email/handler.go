@@ -0,0 +1,42 @@
+package email
+
+import (
+	"database/sql"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+type EmailHandler struct {
+	Usecase EmailUsecase
+	rdb     *redis.Client
+	db      *sql.DB
+}
+
+func NewHandler(router *gin.Engine, usecase EmailUsecase, rdb *redis.Client, db *sql.DB) {
+	handler := &EmailHandler{
+		Usecase: usecase,
+		rdb:     rdb,
+		db:      db,
+	}
+
+	v1 := router.Group("/v1")
+
+	// Admin
+	v1.GET("email-outbox", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.GetAll)
+	v1.GET("email-outbox/:id", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.Get)
+	v1.POST("email-outbox/:id/retry", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.Retry)
+}
+
+func (handler *EmailHandler) GetAll(c *gin.Context) {
+	handler.Usecase.GetAll(c)
+}
+
+func (handler *EmailHandler) Get(c *gin.Context) {
+	handler.Usecase.Get(c)
+}
+
+func (handler *EmailHandler) Retry(c *gin.Context) {
+	handler.Usecase.Retry(c)
+}
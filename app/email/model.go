@@ -0,0 +1,68 @@
+package email
+
+import (
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+var table = "ppt_email_outbox"
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+
+	DefaultLocale = "id"
+)
+
+type (
+	// Outgoing is an email to queue. Data fills the template; it is kept
+	// until the message is sent and then cleared, so it must not hold
+	// secrets beyond the links the message carries.
+	Outgoing struct {
+		Template  string
+		Locale    string
+		Recipient string
+		Data      map[string]interface{}
+	}
+
+	// Message is a queued email as admins see it. The template data is
+	// left out.
+	Message struct {
+		HashedID      string     `json:"id"`
+		Template      string     `json:"template"`
+		Locale        string     `json:"locale"`
+		Recipient     string     `json:"recipient"`
+		Status        string     `json:"status"`
+		Attempts      int        `json:"attempts"`
+		LastError     *string    `json:"last_error"`
+		NextAttemptAt time.Time  `json:"next_attempt_at"`
+		SentAt        *time.Time `json:"sent_at"`
+		CreatedAt     time.Time  `json:"created_at"`
+		UpdatedAt     time.Time  `json:"updated_at"`
+	}
+
+	// delivery is a claimed message on its way out.
+	delivery struct {
+		ID        int64
+		Template  string
+		Locale    string
+		Recipient string
+		Data      map[string]interface{}
+		Attempts  int
+	}
+
+	MessageFilter struct {
+		Status    string
+		Recipient string
+		Page      int
+		PageSize  int
+	}
+
+	MessagesWithPagination struct {
+		Row        []Message               `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+)
@@ -0,0 +1,49 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// Execer is a *sql.Tx or *sql.DB. Pass the transaction that makes the
+// change the email is about, so the email is queued if and only if the
+// change is committed.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue queues messages for the worker. The templates are rendered once
+// here so a message that could never be sent fails the caller instead of
+// the worker.
+func Enqueue(ctx context.Context, exec Execer, messages ...Outgoing) error {
+	for _, m := range messages {
+		if m.Recipient == "" {
+			return errors.New("email has no recipient")
+		}
+		if m.Locale == "" {
+			m.Locale = DefaultLocale
+		}
+		if _, _, _, err := Render(m.Template, m.Locale, m.Data); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(m.Data)
+		if err != nil {
+			return err
+		}
+		if m.Data == nil {
+			data = []byte("{}")
+		}
+
+		query := `
+		INSERT INTO ` + table + ` (template, locale, recipient, data)
+		VALUES ($1, $2, $3, $4)`
+		if _, err := exec.ExecContext(ctx, query, m.Template, m.Locale, m.Recipient, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
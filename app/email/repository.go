@@ -0,0 +1,236 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+type (
+	EmailRepository interface {
+		SMTPSettings(c context.Context) (SMTPSettings, error)
+		Claim(c context.Context, limit int, lease time.Duration) ([]delivery, error)
+		MarkSent(c context.Context, id int64) error
+		MarkRetry(c context.Context, id int64, at time.Time, reason string) error
+		MarkFailed(c context.Context, id int64, reason string) error
+		CountMessages(c context.Context, arg MessageFilter) (int, error)
+		GetMessages(c context.Context, arg MessageFilter) ([]Message, error)
+		GetMessage(c context.Context, id int64) (Message, error)
+		Retry(c context.Context, id int64) error
+	}
+
+	SMTPSettings struct {
+		Server   string
+		Port     int
+		Email    string
+		Password string
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func NewRepository(db *sql.DB) EmailRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (q *repository) SMTPSettings(c context.Context) (SMTPSettings, error) {
+	var s SMTPSettings
+
+	query := `
+	SELECT name, value
+	FROM ppt_configurations
+	WHERE name IN ('smtp_server', 'smtp_port', 'smtp_email', 'smtp_email_password')`
+	rows, err := q.db.QueryContext(c, query)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return s, err
+		}
+
+		switch name {
+		case "smtp_server":
+			s.Server = value
+		case "smtp_port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return s, err
+			}
+			s.Port = port
+		case "smtp_email":
+			s.Email = value
+		case "smtp_email_password":
+			s.Password = value
+		}
+	}
+
+	return s, rows.Err()
+}
+
+// Claim takes up to limit due messages and hides them from other workers
+// for lease. A message whose worker died while sending is due again once
+// its lease runs out.
+func (q *repository) Claim(c context.Context, limit int, lease time.Duration) ([]delivery, error) {
+	query := `
+	UPDATE ` + table + `
+	SET status = $3, attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2), updated_at = NOW()
+	WHERE id IN (
+		SELECT id FROM ` + table + `
+		WHERE status IN ($4, $3) AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, template, locale, recipient, data, attempts`
+	rows, err := q.db.QueryContext(c, query, limit, lease.Seconds(), StatusSending, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []delivery{}
+	for rows.Next() {
+		var d delivery
+		var data []byte
+		if err := rows.Scan(&d.ID, &d.Template, &d.Locale, &d.Recipient, &data, &d.Attempts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &d.Data); err != nil {
+			return nil, err
+		}
+		items = append(items, d)
+	}
+
+	return items, rows.Err()
+}
+
+// MarkSent also drops the template data, which may hold live links such
+// as invitation tokens.
+func (q *repository) MarkSent(c context.Context, id int64) error {
+	query := `
+	UPDATE ` + table + `
+	SET status = $2, data = '{}', sent_at = NOW(), last_error = NULL, updated_at = NOW()
+	WHERE id = $1`
+	_, err := q.db.ExecContext(c, query, id, StatusSent)
+	return err
+}
+
+func (q *repository) MarkRetry(c context.Context, id int64, at time.Time, reason string) error {
+	query := `
+	UPDATE ` + table + `
+	SET status = $2, next_attempt_at = $3, last_error = $4, updated_at = NOW()
+	WHERE id = $1`
+	_, err := q.db.ExecContext(c, query, id, StatusPending, at, reason)
+	return err
+}
+
+func (q *repository) MarkFailed(c context.Context, id int64, reason string) error {
+	query := `
+	UPDATE ` + table + `
+	SET status = $2, last_error = $3, updated_at = NOW()
+	WHERE id = $1`
+	_, err := q.db.ExecContext(c, query, id, StatusFailed, reason)
+	return err
+}
+
+func messageWhere(arg MessageFilter) (string, []interface{}) {
+	where := ` WHERE true`
+	args := []interface{}{}
+	if arg.Status != "" {
+		args = append(args, arg.Status)
+		where += ` AND status = $` + strconv.Itoa(len(args))
+	}
+	if arg.Recipient != "" {
+		args = append(args, arg.Recipient)
+		where += ` AND lower(recipient) = lower($` + strconv.Itoa(len(args)) + `)`
+	}
+	return where, args
+}
+
+func (q *repository) CountMessages(c context.Context, arg MessageFilter) (int, error) {
+	where, args := messageWhere(arg)
+
+	var total int
+	err := q.db.QueryRowContext(c, `SELECT COUNT(*) FROM `+table+where, args...).Scan(&total)
+	return total, err
+}
+
+func (q *repository) GetMessages(c context.Context, arg MessageFilter) ([]Message, error) {
+	where, args := messageWhere(arg)
+	args = append(args, arg.PageSize, (arg.Page-1)*arg.PageSize)
+
+	query := `
+	SELECT id, template, locale, recipient, status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at
+	FROM ` + table + where + `
+	ORDER BY id DESC
+	LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+	rows, err := q.db.QueryContext(c, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Message{}
+	for rows.Next() {
+		r, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+func (q *repository) GetMessage(c context.Context, id int64) (Message, error) {
+	query := `
+	SELECT id, template, locale, recipient, status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at
+	FROM ` + table + `
+	WHERE id = $1`
+	return scanMessage(q.db.QueryRowContext(c, query, id))
+}
+
+// Retry queues a failed message again with a fresh set of attempts. It
+// returns sql.ErrNoRows when the message has not failed.
+func (q *repository) Retry(c context.Context, id int64) error {
+	query := `
+	UPDATE ` + table + `
+	SET status = $2, attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND status = $3`
+	res, err := q.db.ExecContext(c, query, id, StatusPending, StatusFailed)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row scanner) (Message, error) {
+	var r Message
+	var id int64
+
+	err := row.Scan(&id, &r.Template, &r.Locale, &r.Recipient, &r.Status, &r.Attempts, &r.LastError,
+		&r.NextAttemptAt, &r.SentAt, &r.CreatedAt, &r.UpdatedAt)
+	r.HashedID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+
+	return r, err
+}
@@ -0,0 +1,118 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+// templateFS holds one pair of files per template and locale:
+// <name>.<locale>.txt for the plain text part and <name>.<locale>.html for
+// the HTML part, which is rendered inside layout.html. Both define a
+// "subject" and a "body" template; the subject is taken from the text file.
+//
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+type pair struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates is keyed by name and then locale.
+var templates = mustParseTemplates()
+
+func mustParseTemplates() map[string]map[string]pair {
+	files, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	parsed := map[string]map[string]pair{}
+	for _, file := range files {
+		base := strings.TrimSuffix(strings.TrimPrefix(file, "templates/"), ".txt")
+		name, locale, ok := strings.Cut(base, ".")
+		if !ok {
+			panic(fmt.Sprintf("email template %s must be named <name>.<locale>.txt", file))
+		}
+
+		text := texttemplate.Must(texttemplate.New(base).Option("missingkey=error").ParseFS(templateFS, file))
+		html := htmltemplate.Must(htmltemplate.New(base).Option("missingkey=error").
+			ParseFS(templateFS, "templates/layout.html", "templates/"+base+".html"))
+
+		if parsed[name] == nil {
+			parsed[name] = map[string]pair{}
+		}
+		parsed[name][locale] = pair{text: text, html: html}
+	}
+
+	return parsed
+}
+
+// resolve finds a template, falling back to the default locale when it is
+// not translated.
+func resolve(name, locale string) (pair, string, error) {
+	locales, ok := templates[name]
+	if !ok {
+		return pair{}, "", fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	if p, ok := locales[locale]; ok {
+		return p, locale, nil
+	}
+	if p, ok := locales[DefaultLocale]; ok {
+		return p, DefaultLocale, nil
+	}
+	return pair{}, "", fmt.Errorf("%w: %s has no %s version", ErrUnknownTemplate, name, DefaultLocale)
+}
+
+// Render fills a template and returns the subject and the text and HTML
+// bodies.
+func Render(name, locale string, data map[string]interface{}) (subject, text, html string, err error) {
+	p, locale, err := resolve(name, locale)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+	values["Locale"] = locale
+
+	var buf bytes.Buffer
+	if err := p.text.ExecuteTemplate(&buf, "subject", values); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := p.text.ExecuteTemplate(&buf, "body", values); err != nil {
+		return "", "", "", err
+	}
+	text = buf.String()
+
+	buf.Reset()
+	if err := p.html.ExecuteTemplate(&buf, "layout", values); err != nil {
+		return "", "", "", err
+	}
+	html = buf.String()
+
+	return subject, text, html, nil
+}
+
+// SupportedLocale tells whether any template is translated to locale.
+func SupportedLocale(locale string) bool {
+	for _, locales := range templates {
+		if _, ok := locales[locale]; ok {
+			return true
+		}
+	}
+	return false
+}
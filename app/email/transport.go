@@ -0,0 +1,116 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"gopkg.in/gomail.v2"
+)
+
+type (
+	// Envelope is a rendered message.
+	Envelope struct {
+		To      string
+		Subject string
+		Text    string
+		HTML    string
+	}
+
+	// Transport delivers rendered messages. An error means the message
+	// should be tried again later.
+	Transport interface {
+		Send(ctx context.Context, e Envelope) error
+	}
+
+	// SMTPTransport sends through the server configured in
+	// ppt_configurations, read on every send so a change by an admin
+	// applies to the next message.
+	SMTPTransport struct {
+		repo EmailRepository
+	}
+
+	// FileTransport writes every message as an .eml file into a directory,
+	// for development and tests.
+	FileTransport struct {
+		dir  string
+		from string
+	}
+)
+
+// NewTransport picks the transport named by EMAIL_TRANSPORT.
+func NewTransport(cfg config.Config, repo EmailRepository) (Transport, error) {
+	switch cfg.EmailTransport {
+	case "smtp":
+		return &SMTPTransport{repo: repo}, nil
+	case "file":
+		return NewFileTransport(cfg.EmailFileDir, "no-reply@localhost")
+	default:
+		return nil, fmt.Errorf("unknown email transport %q, want smtp or file", cfg.EmailTransport)
+	}
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, e Envelope) error {
+	settings, err := t.repo.SMTPSettings(ctx)
+	if err != nil {
+		return fmt.Errorf("loading SMTP settings: %w", err)
+	}
+	if settings.Server == "" {
+		return fmt.Errorf("smtp_server is not configured")
+	}
+	password, _ := util.Decrypt(settings.Password, "f")
+
+	m := newMessage(settings.Email, e)
+	d := gomail.NewDialer(settings.Server, settings.Port, settings.Email, password)
+
+	return d.DialAndSend(m)
+}
+
+func NewFileTransport(dir, from string) (*FileTransport, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileTransport{dir: dir, from: from}, nil
+}
+
+func (t *FileTransport) Send(ctx context.Context, e Envelope) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitizeRecipient(e.To))
+	f, err := os.OpenFile(filepath.Join(t.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+
+	if _, err := newMessage(t.from, e).WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func newMessage(from string, e Envelope) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", e.To)
+	m.SetHeader("Subject", e.Subject)
+	m.SetBody("text/plain", e.Text)
+	m.AddAlternative("text/html", e.HTML)
+	return m
+}
+
+func sanitizeRecipient(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r == '@':
+			return '_'
+		default:
+			return -1
+		}
+	}, s)
+}
@@ -0,0 +1,155 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gigaflex-co/ppt_backend/app/audit"
+	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+var statuses = map[string]bool{StatusPending: true, StatusSending: true, StatusSent: true, StatusFailed: true}
+
+type (
+	EmailUsecase interface {
+		GetAll(c *gin.Context)
+		Get(c *gin.Context)
+		Retry(c *gin.Context)
+	}
+
+	usecase struct {
+		repo   EmailRepository
+		audit  audit.AuditRepository
+		worker *Worker
+	}
+)
+
+func NewUsecase(repo EmailRepository, auditRepo audit.AuditRepository, transport Transport, cfg config.Config) EmailUsecase {
+	return &usecase{
+		repo:   repo,
+		audit:  auditRepo,
+		worker: NewWorker(repo, transport, cfg.EmailPollInterval, cfg.EmailMaxAttempts),
+	}
+}
+
+// GetAll lists queued emails, newest first, optionally narrowed to a
+// status and a recipient.
+func (uc *usecase) GetAll(c *gin.Context) {
+	arg := MessageFilter{
+		Status:    c.Query("status"),
+		Recipient: c.Query("recipient"),
+	}
+	if arg.Status != "" && !statuses[arg.Status] {
+		util.JERR(c, http.StatusBadRequest, errors.New("status must be one of pending, sending, sent or failed"))
+		return
+	}
+
+	arg.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	arg.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if arg.Page < 1 {
+		arg.Page = 1
+	}
+	if arg.PageSize < 1 || arg.PageSize > 100 {
+		arg.PageSize = 10
+	}
+
+	totalRecords, err := uc.repo.CountMessages(c, arg)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, err := uc.repo.GetMessages(c, arg)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, MessagesWithPagination{
+		Row: data,
+		Pagination: util.PaginationResponse{
+			CurrentPage:  arg.Page,
+			PageSize:     arg.PageSize,
+			TotalPages:   int(math.Ceil(float64(totalRecords) / float64(arg.PageSize))),
+			TotalRecords: totalRecords,
+		},
+	})
+}
+
+func (uc *usecase) Get(c *gin.Context) {
+	_, data, ok := uc.message(c)
+	if !ok {
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+// Retry queues a failed email again.
+func (uc *usecase) Retry(c *gin.Context) {
+	actor, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, data, ok := uc.message(c)
+	if !ok {
+		return
+	}
+
+	if err := uc.repo.Retry(c, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusConflict, errors.New("only failed emails can be retried"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	err = uc.audit.Record(c, audit.Entry{
+		ActorEmail: actor,
+		Action:     "email.retry",
+		Target:     data.Recipient,
+		Detail:     map[string]interface{}{"template": data.Template, "attempts": data.Attempts},
+		IPAddress:  c.ClientIP(),
+	})
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, err = uc.repo.GetMessage(c, id)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+func (uc *usecase) message(c *gin.Context) (int64, Message, bool) {
+	raw, err := util.Decrypt(c.Param("id"), "f")
+	id, perr := strconv.ParseInt(raw, 10, 64)
+	if err != nil || perr != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("email not found"))
+		return 0, Message{}, false
+	}
+
+	data, err := uc.repo.GetMessage(c, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("email not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return 0, data, false
+	}
+
+	return id, data, true
+}
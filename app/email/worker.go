@@ -0,0 +1,116 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	workerBatchSize = 20
+	// sendLease is how long a claimed message stays hidden from other
+	// workers; it must comfortably exceed a slow SMTP exchange.
+	sendLease = 5 * time.Minute
+
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 2 * time.Hour
+)
+
+// Worker delivers queued messages. Several API instances may run one each;
+// claims keep them from sending a message twice.
+type Worker struct {
+	repo        EmailRepository
+	transport   Transport
+	interval    time.Duration
+	maxAttempts int
+}
+
+// NewWorker polls the outbox every interval; zero disables polling and
+// leaves delivery to explicit calls of Run.
+func NewWorker(repo EmailRepository, transport Transport, interval time.Duration, maxAttempts int) *Worker {
+	w := &Worker{
+		repo:        repo,
+		transport:   transport,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+	}
+	if interval > 0 {
+		go w.loop()
+	}
+	return w
+}
+
+func (w *Worker) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := w.Run(context.Background()); err != nil {
+			log.Printf("email outbox: %v", err)
+		}
+	}
+}
+
+// Run sends the messages that are due and returns how many were sent.
+func (w *Worker) Run(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		batch, err := w.repo.Claim(ctx, workerBatchSize, sendLease)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, d := range batch {
+			if w.deliver(ctx, d) {
+				sent++
+			}
+		}
+
+		if len(batch) < workerBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, d delivery) bool {
+	subject, text, html, err := Render(d.Template, d.Locale, d.Data)
+	if err != nil {
+		// The template changed since the message was queued; retrying
+		// will not help.
+		w.record(d.ID, w.repo.MarkFailed(ctx, d.ID, err.Error()))
+		return false
+	}
+
+	err = w.transport.Send(ctx, Envelope{To: d.Recipient, Subject: subject, Text: text, HTML: html})
+	if err == nil {
+		w.record(d.ID, w.repo.MarkSent(ctx, d.ID))
+		return true
+	}
+
+	if d.Attempts >= w.maxAttempts {
+		log.Printf("email %d to %s failed after %d attempts: %v", d.ID, d.Recipient, d.Attempts, err)
+		w.record(d.ID, w.repo.MarkFailed(ctx, d.ID, err.Error()))
+	} else {
+		w.record(d.ID, w.repo.MarkRetry(ctx, d.ID, time.Now().Add(retryDelay(d.Attempts)), err.Error()))
+	}
+	return false
+}
+
+func (w *Worker) record(id int64, err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("email %d: recording delivery: %v", id, err)
+	}
+}
+
+// retryDelay doubles with every attempt: 30s, 1m, 2m, ... up to two hours.
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
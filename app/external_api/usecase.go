@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 )
 
@@ -34,11 +35,17 @@ func (uc *usecase) GetWeather(c *gin.Context) {
 		return
 	}
 
-	url := "https://api.openweathermap.org/data/2.5/weather?lat=" + lat + "&lon=" + lng + "&appid=" + appConfig.OWM_API
+	url := appConfig.OWMBaseURL + "/data/2.5/weather?lat=" + lat + "&lon=" + lng + "&appid=" + appConfig.OWM_API
 
-	response, err := http.Get(url)
+	upstream := util.UpstreamFor(url)
+	response, err := upstream.Get(c, url)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(util.UpstreamHTTPStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if response.StatusCode != http.StatusOK {
+		err := upstream.Unexpected(response)
+		c.JSON(util.UpstreamHTTPStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	defer response.Body.Close()
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// cleanupLockKey makes sure only one API instance deletes orphans.
+	cleanupLockKey    = "ppt:file-cleanup"
+	cleanupLockTTL    = time.Hour
+	cleanupBatchSize  = 200
+	cleanupPassWindow = 30 * time.Minute
+)
+
+var ErrCleanupRunning = errors.New("orphan cleanup is already running")
+
+// cleaner deletes uploaded files no image column refers to, periodically
+// and on demand. The grace period leaves time to save the form an image
+// was uploaded for.
+type cleaner struct {
+	repo     FileRepository
+	store    storage.Store
+	rdb      *redis.Client
+	interval time.Duration
+	grace    time.Duration
+}
+
+func newCleaner(repo FileRepository, store storage.Store, rdb *redis.Client, interval, grace time.Duration) *cleaner {
+	cl := &cleaner{
+		repo:     repo,
+		store:    store,
+		rdb:      rdb,
+		interval: interval,
+		grace:    grace,
+	}
+	if interval > 0 {
+		go cl.loop()
+	}
+	return cl
+}
+
+func (cl *cleaner) loop() {
+	ticker := time.NewTicker(cl.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result, err := cl.Run(context.Background())
+		switch {
+		case errors.Is(err, ErrCleanupRunning):
+		case err != nil:
+			log.Printf("file cleanup: %v", err)
+		case result.Deleted > 0 || result.Failed > 0:
+			log.Printf("file cleanup: %d orphans deleted, %d failed", result.Deleted, result.Failed)
+		}
+	}
+}
+
+// Run deletes every orphan older than the grace period. A file whose
+// objects cannot be deleted keeps its row and is retried on the next run.
+func (cl *cleaner) Run(ctx context.Context) (CleanupResult, error) {
+	var result CleanupResult
+
+	ok, err := cl.rdb.SetNX(ctx, cleanupLockKey, time.Now().Format(time.RFC3339), cleanupLockTTL).Result()
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrCleanupRunning
+	}
+	defer cl.rdb.Del(context.Background(), cleanupLockKey)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupPassWindow)
+	defer cancel()
+
+	before := time.Now().Add(-cl.grace)
+	var afterID int64
+	for {
+		orphans, err := cl.repo.Orphans(ctx, before, afterID, cleanupBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(orphans) == 0 {
+			return result, nil
+		}
+
+		for _, o := range orphans {
+			afterID = o.ID
+			if err := cl.delete(ctx, o); err != nil {
+				log.Printf("file cleanup %s: %v", o.Key, err)
+				result.Failed++
+				continue
+			}
+			result.Deleted++
+		}
+	}
+}
+
+func (cl *cleaner) delete(ctx context.Context, o Orphan) error {
+	keys := []string{o.Key}
+	if o.ThumbKey != nil {
+		keys = append(keys, *o.ThumbKey)
+	}
+	for _, key := range keys {
+		if err := cl.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+
+	return cl.repo.Delete(ctx, o.ID)
+}
@@ -0,0 +1,49 @@
+package file
+
+import (
+	"database/sql"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+type FileHandler struct {
+	Usecase FileUsecase
+	rdb     *redis.Client
+	db      *sql.DB
+}
+
+func NewHandler(router *gin.Engine, usecase FileUsecase, rdb *redis.Client, db *sql.DB) {
+	handler := &FileHandler{
+		Usecase: usecase,
+		rdb:     rdb,
+		db:      db,
+	}
+
+	v1 := router.Group("/v1")
+
+	v1.POST("files", util.AuthMiddleware(handler.rdb), handler.Upload)
+	v1.GET("file-url", util.AuthMiddleware(handler.rdb), handler.GetURL)
+	// Signed links carry their own authorisation.
+	v1.GET("files/*key", handler.Download)
+
+	// Admin
+	v1.POST("files-cleanup", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.Cleanup)
+}
+
+func (handler *FileHandler) Upload(c *gin.Context) {
+	handler.Usecase.Upload(c)
+}
+
+func (handler *FileHandler) GetURL(c *gin.Context) {
+	handler.Usecase.GetURL(c)
+}
+
+func (handler *FileHandler) Download(c *gin.Context) {
+	handler.Usecase.Download(c)
+}
+
+func (handler *FileHandler) Cleanup(c *gin.Context) {
+	handler.Usecase.Cleanup(c)
+}
@@ -0,0 +1,75 @@
+package file
+
+import (
+	"time"
+)
+
+var table = "ppt_files"
+
+// referenceColumns are the columns that may hold a file key. A file none of
+// them refers to is an orphan.
+var referenceColumns = []struct{ table, column string }{
+	{"ppt_users", "img_user"},
+	{"ppt_users", "img_id"},
+	{"ppt_user_lands", "img"},
+	{"ppt_services", "img"},
+	{"ppt_service_details", "img"},
+	{"ppt_modules", "image"},
+}
+
+type (
+	// Purpose is what an upload is for, which sets its size limit and
+	// thumbnail size. Service and module images are uploaded by admins.
+	Purpose struct {
+		MaxSize   int64
+		ThumbSize int
+		AdminOnly bool
+	}
+
+	// File is an uploaded image. URL and ThumbURL are signed links that
+	// expire; request a fresh one through /v1/file-url.
+	File struct {
+		HashedID    string    `json:"id"`
+		Key         string    `json:"key"`
+		ThumbKey    *string   `json:"thumb_key"`
+		Purpose     string    `json:"purpose"`
+		ContentType string    `json:"content_type"`
+		Size        int64     `json:"size"`
+		Width       int       `json:"width"`
+		Height      int       `json:"height"`
+		URL         string    `json:"url"`
+		ThumbURL    *string   `json:"thumb_url"`
+		ExpiresAt   time.Time `json:"expires_at"`
+		CreatedAt   time.Time `json:"created_at"`
+
+		ownerEmail string
+	}
+
+	// Orphan is a file due for deletion.
+	Orphan struct {
+		ID       int64
+		Key      string
+		ThumbKey *string
+	}
+
+	CleanupResult struct {
+		Deleted int `json:"deleted"`
+		Failed  int `json:"failed"`
+	}
+)
+
+var purposes = map[string]Purpose{
+	"user":    {MaxSize: 2 << 20, ThumbSize: 256},
+	"land":    {MaxSize: 5 << 20, ThumbSize: 480},
+	"service": {MaxSize: 2 << 20, ThumbSize: 480, AdminOnly: true},
+	"module":  {MaxSize: 2 << 20, ThumbSize: 480, AdminOnly: true},
+}
+
+// imageTypes are the accepted formats, by sniffed content type, with the
+// extension used for the stored object.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
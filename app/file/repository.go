@@ -0,0 +1,106 @@
+package file
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+type (
+	FileRepository interface {
+		IsAdmin(c context.Context, email string) (bool, error)
+		Store(c context.Context, f File) (File, error)
+		GetByKey(c context.Context, key string) (File, error)
+		Orphans(c context.Context, before time.Time, afterID int64, limit int) ([]Orphan, error)
+		Delete(c context.Context, id int64) error
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func NewRepository(db *sql.DB) FileRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (q *repository) IsAdmin(c context.Context, email string) (bool, error) {
+	return util.IsAdmin(c, q.db, email)
+}
+
+func (q *repository) Store(c context.Context, f File) (File, error) {
+	var id int64
+	query := `
+	INSERT INTO ` + table + ` (key, thumb_key, purpose, owner_email, content_type, size, width, height)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, created_at`
+	err := q.db.QueryRowContext(c, query, f.Key, f.ThumbKey, f.Purpose, f.ownerEmail, f.ContentType,
+		f.Size, f.Width, f.Height).Scan(&id, &f.CreatedAt)
+	if err != nil {
+		return f, err
+	}
+
+	f.HashedID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+	return f, nil
+}
+
+// GetByKey finds a file by its key or the key of its thumbnail.
+func (q *repository) GetByKey(c context.Context, key string) (File, error) {
+	var r File
+	var id int64
+
+	query := `
+	SELECT id, key, thumb_key, purpose, owner_email, content_type, size, width, height, created_at
+	FROM ` + table + ` WHERE key = $1 OR thumb_key = $1`
+	err := q.db.QueryRowContext(c, query, key).Scan(&id, &r.Key, &r.ThumbKey, &r.Purpose, &r.ownerEmail,
+		&r.ContentType, &r.Size, &r.Width, &r.Height, &r.CreatedAt)
+	if err != nil {
+		return r, err
+	}
+
+	r.HashedID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+	return r, nil
+}
+
+// Orphans returns files created before the given time that no image column
+// refers to, oldest first.
+func (q *repository) Orphans(c context.Context, before time.Time, afterID int64, limit int) ([]Orphan, error) {
+	refs := make([]string, 0, len(referenceColumns))
+	for _, ref := range referenceColumns {
+		refs = append(refs, `EXISTS (SELECT 1 FROM `+ref.table+` r WHERE r.`+ref.column+` = f.key)`)
+	}
+
+	query := `
+	SELECT f.id, f.key, f.thumb_key
+	FROM ` + table + ` f
+	WHERE f.created_at < $1 AND f.id > $2 AND NOT (` + strings.Join(refs, " OR ") + `)
+	ORDER BY f.id
+	LIMIT $3`
+	rows, err := q.db.QueryContext(c, query, before, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var r []Orphan
+	for rows.Next() {
+		var o Orphan
+		if err := rows.Scan(&o.ID, &o.Key, &o.ThumbKey); err != nil {
+			return nil, err
+		}
+		r = append(r, o)
+	}
+
+	return r, rows.Err()
+}
+
+func (q *repository) Delete(c context.Context, id int64) error {
+	_, err := q.db.ExecContext(c, `DELETE FROM `+table+` WHERE id = $1`, id)
+	return err
+}
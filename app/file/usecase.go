@@ -0,0 +1,268 @@
+package file
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/storage"
+	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// downloadPrefix is where signed links are served; it must match the
+// route registered in NewHandler.
+const downloadPrefix = "/v1/files/"
+
+type (
+	FileUsecase interface {
+		Upload(c *gin.Context)
+		GetURL(c *gin.Context)
+		Download(c *gin.Context)
+		Cleanup(c *gin.Context)
+	}
+
+	usecase struct {
+		repo    FileRepository
+		store   storage.Store
+		signer  *storage.Signer
+		urlTTL  time.Duration
+		cleaner *cleaner
+	}
+)
+
+func NewUsecase(repo FileRepository, store storage.Store, rdb *redis.Client, cfg config.Config) FileUsecase {
+	return &usecase{
+		repo:    repo,
+		store:   store,
+		signer:  storage.NewSigner(cfg.SecretKey, downloadPrefix),
+		urlTTL:  cfg.FileURLTTL,
+		cleaner: newCleaner(repo, store, rdb, cfg.FileCleanupInterval, cfg.FileOrphanGrace),
+	}
+}
+
+// Upload takes a multipart upload with an image in "file" and what it is
+// for in "purpose", and stores it with a thumbnail. The returned key is
+// what goes into the image column of the record the image belongs to;
+// until then the file is an orphan.
+func (uc *usecase) Upload(c *gin.Context) {
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	name := c.PostForm("purpose")
+	purpose, ok := purposes[name]
+	if !ok {
+		util.JERR(c, http.StatusBadRequest, errors.New("purpose must be one of user, land, service or module"))
+		return
+	}
+	if purpose.AdminOnly {
+		admin, err := uc.repo.IsAdmin(c, email)
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !admin {
+			util.JERR(c, http.StatusForbidden, errors.New("only admins may upload "+name+" images"))
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, purpose.MaxSize+1<<20)
+
+	data, contentType, err := readImage(c, purpose.MaxSize)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	width, height, err := storage.ImageSize(data)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+	thumb, err := storage.Thumbnail(data, purpose.ThumbSize)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	id, err := randomID()
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+	key := "files/" + name + "/" + id + imageTypes[contentType]
+	thumbKey := "files/" + name + "/" + id + "-thumb.jpg"
+
+	if err := uc.store.Put(c, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err := uc.store.Put(c, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+		uc.deleteObjects(c, key)
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	f, err := uc.repo.Store(c, File{
+		Key:         key,
+		ThumbKey:    &thumbKey,
+		Purpose:     name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       width,
+		Height:      height,
+		ownerEmail:  email,
+	})
+	if err != nil {
+		uc.deleteObjects(c, key, thumbKey)
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusCreated, uc.sign(f))
+}
+
+// GetURL returns fresh signed links for the file with the given key, which
+// may also be the key of its thumbnail. Uploaded images are not private,
+// so any signed in user may ask.
+func (uc *usecase) GetURL(c *gin.Context) {
+	f, err := uc.repo.GetByKey(c, c.Query("key"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("file not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	util.JOK(c, http.StatusOK, uc.sign(f))
+}
+
+// Download serves a file through a signed link. It needs no session, so
+// links work in <img> tags; the browser may cache the file until the link
+// expires.
+func (uc *usecase) Download(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expires := c.Query("expires")
+
+	if err := uc.signer.Verify(key, expires, c.Query("sig")); err != nil {
+		util.JERR(c, http.StatusForbidden, err)
+		return
+	}
+
+	body, obj, err := uc.store.Get(c, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			util.JERR(c, http.StatusNotFound, errors.New("file not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	defer body.Close()
+
+	maxAge := int64(0)
+	if unix, err := strconv.ParseInt(expires, 10, 64); err == nil && unix > time.Now().Unix() {
+		maxAge = unix - time.Now().Unix()
+	}
+
+	// The CORS middleware has already set a JSON content type, which
+	// DataFromReader would keep.
+	c.Header("Content-Type", obj.ContentType)
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, body, map[string]string{
+		"Cache-Control":          fmt.Sprintf("private, max-age=%d", maxAge),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// Cleanup deletes orphaned uploads now rather than on the next tick.
+func (uc *usecase) Cleanup(c *gin.Context) {
+	result, err := uc.cleaner.Run(c)
+	if err != nil {
+		if errors.Is(err, ErrCleanupRunning) {
+			util.JERR(c, http.StatusConflict, err)
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	util.JOK(c, http.StatusOK, result)
+}
+
+func (uc *usecase) sign(f File) File {
+	f.URL = uc.signer.URL(f.Key, uc.urlTTL)
+	if f.ThumbKey != nil {
+		thumbURL := uc.signer.URL(*f.ThumbKey, uc.urlTTL)
+		f.ThumbURL = &thumbURL
+	}
+	f.ExpiresAt = time.Now().Add(uc.urlTTL).Truncate(time.Second)
+	return f
+}
+
+func (uc *usecase) deleteObjects(c *gin.Context, keys ...string) {
+	for _, key := range keys {
+		if err := uc.store.Delete(c, key); err != nil {
+			log.Printf("deleting upload %s: %v", key, err)
+		}
+	}
+}
+
+// readImage reads the uploaded file and checks its size and, by its
+// content rather than its name, its format.
+func readImage(c *gin.Context, maxSize int64) ([]byte, string, error) {
+	tooLarge := fmt.Errorf("file must not exceed %d MB", maxSize>>20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", errors.New("file is required")
+	}
+	if header.Size > maxSize {
+		return nil, "", tooLarge
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", tooLarge
+	}
+
+	contentType := storage.Sniff(data)
+	if _, ok := imageTypes[contentType]; !ok {
+		return nil, "", errors.New("file must be a JPEG, PNG, GIF or WebP image")
+	}
+
+	return data, contentType, nil
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
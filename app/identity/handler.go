@@ -0,0 +1,58 @@
+package identity
+
+import (
+	"database/sql"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+type IdentityHandler struct {
+	Usecase IdentityUsecase
+	rdb     *redis.Client
+	db      *sql.DB
+}
+
+func NewHandler(router *gin.Engine, usecase IdentityUsecase, rdb *redis.Client, db *sql.DB) {
+	handler := &IdentityHandler{
+		Usecase: usecase,
+		rdb:     rdb,
+		db:      db,
+	}
+
+	v1 := router.Group("/v1")
+
+	v1.POST("identity-verification", util.AuthMiddleware(handler.rdb), handler.Submit)
+	v1.GET("identity-verification", util.AuthMiddleware(handler.rdb), handler.GetMine)
+
+	// Verifiers
+	v1.GET("identity-verifications", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.GetAll)
+	v1.GET("identity-verification/:id", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.Get)
+	v1.GET("identity-verification/:id/document/:kind", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.GetDocument)
+	v1.PUT("identity-verification/:id/review", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.Review)
+}
+
+func (handler *IdentityHandler) Submit(c *gin.Context) {
+	handler.Usecase.Submit(c)
+}
+
+func (handler *IdentityHandler) GetMine(c *gin.Context) {
+	handler.Usecase.GetMine(c)
+}
+
+func (handler *IdentityHandler) GetAll(c *gin.Context) {
+	handler.Usecase.GetAll(c)
+}
+
+func (handler *IdentityHandler) Get(c *gin.Context) {
+	handler.Usecase.Get(c)
+}
+
+func (handler *IdentityHandler) GetDocument(c *gin.Context) {
+	handler.Usecase.GetDocument(c)
+}
+
+func (handler *IdentityHandler) Review(c *gin.Context) {
+	handler.Usecase.Review(c)
+}
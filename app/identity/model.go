@@ -0,0 +1,74 @@
+package identity
+
+import (
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+var (
+	table             = "ppt_identity_verifications"
+	userTable         = "ppt_users"
+	notificationTable = "ppt_notifications"
+)
+
+// Verification statuses.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Document kinds.
+const (
+	DocumentKTP    = "ktp"
+	DocumentSelfie = "selfie"
+)
+
+type (
+	User struct {
+		ID         int64
+		Email      string
+		IsVerified bool
+	}
+
+	// Verification is a request to verify a user by their documents. Name
+	// and NIK come decrypted from the user profile so the verifier can hold
+	// them against the KTP.
+	Verification struct {
+		HashedID   string     `json:"id"`
+		UserEmail  string     `json:"user_email"`
+		Name       string     `json:"name"`
+		NIK        string     `json:"nik"`
+		Status     string     `json:"status"`
+		Reason     *string    `json:"reason"`
+		ReviewedBy *string    `json:"reviewed_by"`
+		ReviewedAt *time.Time `json:"reviewed_at"`
+		CreatedAt  time.Time  `json:"created_at"`
+		UpdatedAt  time.Time  `json:"updated_at"`
+
+		ktpKey    string
+		selfieKey string
+	}
+
+	VerificationsWithPagination struct {
+		Row        []Verification          `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	Decision struct {
+		Decision string `json:"decision" binding:"required,oneof=approve reject"`
+		Reason   string `json:"reason" binding:"max=1000"`
+	}
+)
+
+func (v Verification) documentKey(kind string) (string, bool) {
+	switch kind {
+	case DocumentKTP:
+		return v.ktpKey, true
+	case DocumentSelfie:
+		return v.selfieKey, true
+	default:
+		return "", false
+	}
+}
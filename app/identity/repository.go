@@ -0,0 +1,210 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+type (
+	IdentityRepository interface {
+		GetUser(c context.Context, email string) (User, error)
+		StoreRequest(c context.Context, userID int64, ktpKey, selfieKey string) (replaced []string, err error)
+		GetLatest(c context.Context, userID int64) (Verification, error)
+		Count(c context.Context, status string) (int, error)
+		GetAll(c context.Context, status string, page, pageSize int) ([]Verification, error)
+		Get(c context.Context, id int64) (Verification, error)
+		Decide(c context.Context, id int64, status, reason, reviewer string) error
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func NewRepository(db *sql.DB) IdentityRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (q *repository) GetUser(c context.Context, email string) (User, error) {
+	r := User{Email: email}
+
+	query := `SELECT id, is_verified FROM ` + userTable + ` WHERE email = $1 AND is_active = true`
+	err := q.db.QueryRowContext(c, query, email).Scan(&r.ID, &r.IsVerified)
+
+	return r, err
+}
+
+// StoreRequest opens a pending request, or replaces the documents of the
+// one already pending, and points img_id and img_user at the new
+// documents. It returns the keys of the documents replaced.
+func (q *repository) StoreRequest(c context.Context, userID int64, ktpKey, selfieKey string) ([]string, error) {
+	tx, err := q.db.BeginTx(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var replaced []string
+	var oldKTP, oldSelfie string
+	query := `SELECT ktp_key, selfie_key FROM ` + table + ` WHERE user_id = $1 AND status = 'pending' FOR UPDATE`
+	switch err := tx.QueryRowContext(c, query, userID).Scan(&oldKTP, &oldSelfie); err {
+	case nil:
+		replaced = []string{oldKTP, oldSelfie}
+	case sql.ErrNoRows:
+	default:
+		return nil, err
+	}
+
+	query = `
+	INSERT INTO ` + table + ` (user_id, ktp_key, selfie_key)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) WHERE status = 'pending' DO UPDATE SET
+		ktp_key = EXCLUDED.ktp_key,
+		selfie_key = EXCLUDED.selfie_key,
+		created_at = NOW(),
+		updated_at = NOW()`
+	if _, err := tx.ExecContext(c, query, userID, ktpKey, selfieKey); err != nil {
+		return nil, err
+	}
+
+	query = `UPDATE ` + userTable + ` SET img_id = $2, img_user = $3 WHERE id = $1`
+	if _, err := tx.ExecContext(c, query, userID, ktpKey, selfieKey); err != nil {
+		return nil, err
+	}
+
+	return replaced, tx.Commit()
+}
+
+var selectVerification = `
+	SELECT v.id, u.email, COALESCE(u.name, ''), COALESCE(u.nik, ''), v.ktp_key, v.selfie_key,
+		v.status, v.reason, v.reviewed_by, v.reviewed_at, v.created_at, v.updated_at
+	FROM ` + table + ` v
+	JOIN ` + userTable + ` u ON u.id = v.user_id`
+
+func (q *repository) GetLatest(c context.Context, userID int64) (Verification, error) {
+	query := selectVerification + `
+	WHERE v.user_id = $1
+	ORDER BY v.created_at DESC, v.id DESC
+	LIMIT 1`
+
+	return scanVerification(q.db.QueryRowContext(c, query, userID))
+}
+
+func (q *repository) Count(c context.Context, status string) (int, error) {
+	query := `SELECT COUNT(*) FROM ` + table
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE status = $1`
+		args = append(args, status)
+	}
+
+	var total int
+	err := q.db.QueryRowContext(c, query, args...).Scan(&total)
+	return total, err
+}
+
+func (q *repository) GetAll(c context.Context, status string, page, pageSize int) ([]Verification, error) {
+	args := []interface{}{pageSize, (page - 1) * pageSize}
+	where := ""
+	if status != "" {
+		where = `WHERE v.status = $3`
+		args = append(args, status)
+	}
+
+	query := selectVerification + `
+	` + where + `
+	ORDER BY v.created_at, v.id
+	LIMIT $1 OFFSET $2`
+
+	rows, err := q.db.QueryContext(c, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Verification{}
+	for rows.Next() {
+		r, err := scanVerification(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+func (q *repository) Get(c context.Context, id int64) (Verification, error) {
+	query := selectVerification + `
+	WHERE v.id = $1`
+
+	return scanVerification(q.db.QueryRowContext(c, query, id))
+}
+
+// Decide closes a pending request and notifies the user; approval also
+// marks the user verified by reviewer. It returns sql.ErrNoRows when the
+// request is not pending.
+func (q *repository) Decide(c context.Context, id int64, status, reason, reviewer string) error {
+	tx, err := q.db.BeginTx(c, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	query := `
+	UPDATE ` + table + `
+	SET status = $2, reason = NULLIF($3, ''), reviewed_by = $4, reviewed_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND status = 'pending'
+	RETURNING user_id`
+	if err := tx.QueryRowContext(c, query, id, status, reason, reviewer).Scan(&userID); err != nil {
+		return err
+	}
+
+	title, message := "Verifikasi identitas disetujui", "Dokumen identitas Anda telah diverifikasi."
+	if status == StatusApproved {
+		query = `UPDATE ` + userTable + ` SET is_verified = true, verified_by = $2, verified_at = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(c, query, userID, reviewer); err != nil {
+			return err
+		}
+	} else {
+		title, message = "Verifikasi identitas ditolak", "Dokumen identitas Anda tidak dapat diverifikasi."
+		if reason != "" {
+			message += " Alasan: " + reason
+		}
+	}
+
+	query = `INSERT INTO ` + notificationTable + ` (user_id, title, message) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(c, query, userID, title, message); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanVerification(row interface{ Scan(...interface{}) error }) (Verification, error) {
+	var r Verification
+	var id int64
+	var name, nik string
+
+	err := row.Scan(&id, &r.UserEmail, &name, &nik, &r.ktpKey, &r.selfieKey,
+		&r.Status, &r.Reason, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return r, err
+	}
+
+	if name != "" {
+		r.Name, _ = util.Decrypt(name, "f")
+	}
+	if nik != "" {
+		r.NIK, _ = util.Decrypt(nik, "f")
+	}
+	r.HashedID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+
+	return r, nil
+}
@@ -0,0 +1,364 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/audit"
+	"github.com/gigaflex-co/ppt_backend/app/storage"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDocumentSize caps each uploaded photo.
+const maxDocumentSize = 5 << 20
+
+// documentTypes are the accepted photo formats, by sniffed content type,
+// with the extension used for the stored object.
+var documentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+type (
+	IdentityUsecase interface {
+		Submit(c *gin.Context)
+		GetMine(c *gin.Context)
+		GetAll(c *gin.Context)
+		Get(c *gin.Context)
+		GetDocument(c *gin.Context)
+		Review(c *gin.Context)
+	}
+
+	// UserDirectory is told about users whose verification changed, so
+	// user search sees the new status.
+	UserDirectory interface {
+		SyncEmails(ctx context.Context, emails ...string)
+	}
+
+	usecase struct {
+		repo      IdentityRepository
+		audit     audit.AuditRepository
+		store     storage.Store
+		directory UserDirectory
+	}
+)
+
+// NewUsecase expects a store that encrypts at rest, as
+// storage.NewEncryptedStore does.
+func NewUsecase(repo IdentityRepository, auditRepo audit.AuditRepository, store storage.Store, directory UserDirectory) IdentityUsecase {
+	return &usecase{
+		repo:      repo,
+		audit:     auditRepo,
+		store:     store,
+		directory: directory,
+	}
+}
+
+// Submit takes a multipart upload with a KTP photo in "ktp" and a selfie
+// holding the KTP in "selfie", and queues them for a verifier.
+func (uc *usecase) Submit(c *gin.Context) {
+	user, ok := uc.claimsUser(c)
+	if !ok {
+		return
+	}
+	if user.IsVerified {
+		util.JERR(c, http.StatusConflict, errors.New("user is already verified"))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*maxDocumentSize+1<<20)
+
+	stamp := time.Now().UnixNano()
+	keys := []string{}
+	for _, kind := range []string{DocumentKTP, DocumentSelfie} {
+		data, contentType, err := readDocument(c, kind)
+		if err != nil {
+			uc.deleteDocuments(c, keys)
+			util.JERR(c, http.StatusBadRequest, err)
+			return
+		}
+
+		key := fmt.Sprintf("identity/%d/%s-%d%s", user.ID, kind, stamp, documentTypes[contentType])
+		if err := uc.store.Put(c, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+			uc.deleteDocuments(c, keys)
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+		keys = append(keys, key)
+	}
+
+	replaced, err := uc.repo.StoreRequest(c, user.ID, keys[0], keys[1])
+	if err != nil {
+		uc.deleteDocuments(c, keys)
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+	uc.deleteDocuments(c, replaced)
+
+	data, err := uc.repo.GetLatest(c, user.ID)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusCreated, data)
+}
+
+// GetMine returns the caller's latest verification request.
+func (uc *usecase) GetMine(c *gin.Context) {
+	user, ok := uc.claimsUser(c)
+	if !ok {
+		return
+	}
+
+	data, err := uc.repo.GetLatest(c, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("no verification request"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+// GetAll lists requests for verifiers, oldest first; status defaults to
+// pending and status=all lists every request.
+func (uc *usecase) GetAll(c *gin.Context) {
+	status := c.DefaultQuery("status", StatusPending)
+	if status == "all" {
+		status = ""
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	totalRecords, err := uc.repo.Count(c, status)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, err := uc.repo.GetAll(c, status, page, pageSize)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, VerificationsWithPagination{
+		Row: data,
+		Pagination: util.PaginationResponse{
+			CurrentPage:  page,
+			PageSize:     pageSize,
+			TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+			TotalRecords: totalRecords,
+		},
+	})
+}
+
+func (uc *usecase) Get(c *gin.Context) {
+	_, data, ok := uc.verification(c)
+	if !ok {
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+// GetDocument streams a decrypted document to a verifier. Every access is
+// audited.
+func (uc *usecase) GetDocument(c *gin.Context) {
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	_, data, ok := uc.verification(c)
+	if !ok {
+		return
+	}
+
+	key, ok := data.documentKey(c.Param("kind"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("document not found"))
+		return
+	}
+
+	err = uc.audit.Record(c, audit.Entry{
+		ActorEmail: email,
+		Action:     "identity.document.view",
+		Target:     data.UserEmail,
+		Detail:     map[string]interface{}{"verification_id": data.HashedID, "kind": c.Param("kind")},
+		IPAddress:  c.ClientIP(),
+	})
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, obj, err := uc.store.Get(c, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			util.JERR(c, http.StatusNotFound, errors.New("document not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	defer body.Close()
+
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, body, map[string]string{
+		"Cache-Control": "private, no-store",
+	})
+}
+
+// Review approves or rejects a pending request. A rejection needs a reason,
+// which is passed on to the user.
+func (uc *usecase) Review(c *gin.Context) {
+	var req Decision
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Decision == "reject" && req.Reason == "" {
+		util.JERR(c, http.StatusBadRequest, errors.New("a rejection needs a reason"))
+		return
+	}
+
+	reviewer, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, _, ok := uc.verification(c)
+	if !ok {
+		return
+	}
+
+	status := StatusRejected
+	if req.Decision == "approve" {
+		status = StatusApproved
+	}
+
+	if err := uc.repo.Decide(c, id, status, req.Reason, reviewer); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusConflict, errors.New("request was already decided"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	data, err := uc.repo.Get(c, id)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+	if status == StatusApproved {
+		uc.directory.SyncEmails(c, data.UserEmail)
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+func (uc *usecase) claimsUser(c *gin.Context) (User, bool) {
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return User{}, false
+	}
+
+	user, err := uc.repo.GetUser(c, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("user not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return user, false
+	}
+
+	return user, true
+}
+
+func (uc *usecase) verification(c *gin.Context) (int64, Verification, bool) {
+	raw, err := util.Decrypt(c.Param("id"), "f")
+	id, perr := strconv.ParseInt(raw, 10, 64)
+	if err != nil || perr != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("verification request not found"))
+		return 0, Verification{}, false
+	}
+
+	data, err := uc.repo.Get(c, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("verification request not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return id, data, false
+	}
+
+	return id, data, true
+}
+
+func (uc *usecase) deleteDocuments(c *gin.Context, keys []string) {
+	for _, key := range keys {
+		if err := uc.store.Delete(c, key); err != nil {
+			log.Printf("deleting identity document %s: %v", key, err)
+		}
+	}
+}
+
+// readDocument reads one uploaded photo and checks its size and, by its
+// content rather than its name, its format.
+func readDocument(c *gin.Context, field string) ([]byte, string, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s photo is required", field)
+	}
+	if header.Size > maxDocumentSize {
+		return nil, "", fmt.Errorf("%s photo must not exceed %d MB", field, maxDocumentSize>>20)
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxDocumentSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxDocumentSize {
+		return nil, "", fmt.Errorf("%s photo must not exceed %d MB", field, maxDocumentSize>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := documentTypes[contentType]; !ok {
+		return nil, "", fmt.Errorf("%s photo must be a JPEG or PNG image", field)
+	}
+
+	return data, contentType, nil
+}
@@ -0,0 +1,168 @@
+package internal_api
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	AggSum   = "sum"
+	AggAvg   = "avg"
+	AggCount = "count"
+)
+
+type (
+	// Metric is one dashboard figure computed server side over a dataset.
+	Metric struct {
+		Slug      string `json:"slug"`
+		Label     string `json:"label"`
+		Unit      string `json:"unit"`
+		DatasetID int    `json:"-"`
+		Agg       string `json:"aggregation"`
+		ValueKey  string `json:"-"`
+	}
+
+	AggregateRow struct {
+		Label  string
+		Series string
+		Value  float64
+	}
+
+	ChartSeries struct {
+		Name string    `json:"name"`
+		Data []float64 `json:"data"`
+	}
+
+	// ChartResponse lines up with the barchart, piechart and linechart
+	// displays: one value per label in every series.
+	ChartResponse struct {
+		Metric   Metric        `json:"metric"`
+		GroupBy  string        `json:"group_by"`
+		SeriesBy string        `json:"series_by,omitempty"`
+		Labels   []string      `json:"labels"`
+		Series   []ChartSeries `json:"series"`
+	}
+)
+
+// SIPDPS puso reports carry no affected area, so puso is counted per report.
+var metrics = []Metric{
+	{Slug: "luas-tanam", Label: "Luas Tanam", Unit: "ha", DatasetID: SIPDPSTanamID, Agg: AggSum, ValueKey: "luas_area"},
+	{Slug: "luas-panen", Label: "Luas Panen", Unit: "ha", DatasetID: SIPDPSPanenID, Agg: AggSum, ValueKey: "luas"},
+	{Slug: "perkiraan-panen", Label: "Perkiraan Hasil Panen", Unit: "ton", DatasetID: SIPDPSPanenID, Agg: AggSum, ValueKey: "perkiraan"},
+	{Slug: "laporan-puso", Label: "Laporan Puso", Unit: "laporan", DatasetID: SIPDPSPusoID, Agg: AggCount},
+	{Slug: "produktivitas", Label: "Rata-rata Produktivitas", Unit: "ku/ha", DatasetID: SIPDPSProduktivitasID, Agg: AggAvg, ValueKey: "jumlah"},
+	{Slug: "stok-benih", Label: "Stok Benih", Unit: "ton", DatasetID: PerbenihanRekPenyaluranID, Agg: AggSum, ValueKey: "JUMLAH_STOK"},
+	{Slug: "penyaluran-benih", Label: "Penyaluran Benih", Unit: "ton", DatasetID: PerbenihanRekPenyaluranID, Agg: AggSum, ValueKey: "PENYALURAN"},
+	{Slug: "sisa-stok-benih", Label: "Sisa Stok Benih", Unit: "ton", DatasetID: PerbenihanRekPenyaluranID, Agg: AggSum, ValueKey: "SISA_STOK"},
+	{Slug: "penyebaran-benih", Label: "Luas Penyebaran Benih", Unit: "ha", DatasetID: PerbenihanRekPenyebaranID, Agg: AggSum, ValueKey: "TOTAL_LUAS"},
+}
+
+func MetricBySlug(slug string) (Metric, bool) {
+	for _, m := range metrics {
+		if m.Slug == slug {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// dimensionExpr returns the SQL text expression grouping ds by dim, one of
+// province, kabupaten, kecamatan, commodity, variety, year or month. Periods
+// prefer the explicit TAHUN/BULAN columns over the record timestamp.
+func dimensionExpr(ds Dataset, dim string) (string, error) {
+	key := ""
+	switch dim {
+	case "province":
+		key = ds.ProvinceKey
+	case "kabupaten":
+		key = ds.RegencyKey
+	case "kecamatan":
+		key = ds.SubdistrictKey
+	case "commodity":
+		key = ds.CommodityKey
+	case "variety":
+		key = ds.VarietyKey
+	case "year":
+		if ds.YearKey != "" {
+			return jsonPath(ds.YearKey, true), nil
+		}
+		if ds.DateKey != "" {
+			return "left(" + jsonPath(ds.DateKey, true) + ", 4)", nil
+		}
+	case "month":
+		if ds.YearKey != "" && ds.MonthKey != "" {
+			return "concat(" + jsonPath(ds.YearKey, true) + ", '-', lpad(" + jsonPath(ds.MonthKey, true) + ", 2, '0'))", nil
+		}
+		if ds.DateKey != "" {
+			return "left(" + jsonPath(ds.DateKey, true) + ", 7)", nil
+		}
+	default:
+		return "", fmt.Errorf("unknown dimension %q", dim)
+	}
+
+	if key == "" {
+		return "", fmt.Errorf("%s cannot be grouped by %s", ds.Slug, dim)
+	}
+
+	return "upper(trim(" + jsonPath(key, true) + "))", nil
+}
+
+// metricExpr aggregates the metric value, skipping values that did not
+// normalize to a JSON number instead of failing the cast.
+func metricExpr(m Metric) string {
+	value := "CASE WHEN jsonb_typeof(" + jsonPath(m.ValueKey, false) + ") = 'number' THEN (" + jsonPath(m.ValueKey, true) + ")::numeric END"
+
+	switch m.Agg {
+	case AggSum:
+		return "COALESCE(SUM(" + value + "), 0)"
+	case AggAvg:
+		return "COALESCE(AVG(" + value + "), 0)"
+	default:
+		return "COUNT(*)"
+	}
+}
+
+// chartSeries pivots grouped rows into labels and aligned series. Labels
+// follow the row order; a missing label/series combination is zero.
+func chartSeries(rows []AggregateRow, defaultName string) ([]string, []ChartSeries) {
+	labels := []string{}
+	labelIndex := map[string]int{}
+	names := []string{}
+	values := map[string]map[int]float64{}
+
+	for _, r := range rows {
+		label := r.Label
+		if label == "" {
+			label = "-"
+		}
+		idx, ok := labelIndex[label]
+		if !ok {
+			idx = len(labels)
+			labelIndex[label] = idx
+			labels = append(labels, label)
+		}
+
+		name := r.Series
+		if name == "" {
+			name = defaultName
+		}
+		if _, ok := values[name]; !ok {
+			values[name] = map[int]float64{}
+			names = append(names, name)
+		}
+		values[name][idx] += r.Value
+	}
+
+	sort.Strings(names)
+
+	series := make([]ChartSeries, 0, len(names))
+	for _, name := range names {
+		data := make([]float64, len(labels))
+		for idx, v := range values[name] {
+			data[idx] = v
+		}
+		series = append(series, ChartSeries{Name: name, Data: data})
+	}
+
+	return labels, series
+}
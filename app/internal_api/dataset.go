@@ -0,0 +1,415 @@
+package internal_api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Identifier of every dataset stored in ppt_dataset_records. The values are
+// kept identical to the identifiers used by the legacy ppt_api_data_storages
+// table so existing rows can be migrated one to one.
+const (
+	SIPDPSTanamID             = 1
+	SIPDPSProduktivitasID     = 2
+	SIPDPSPusoID              = 3
+	SIPDPSPanenID             = 4
+	PerbenihanProdusenID      = 5
+	PerbenihanRekNasID        = 6
+	PerbenihanRekBpsbID       = 7
+	PerbenihanRekLssmID       = 8
+	PerbenihanRekPenyaluranID = 9
+	PerbenihanRekPenyebaranID = 10
+	PerbenihanRekProdusenID   = 11
+)
+
+// Field types understood by the storage layer. Numbers are coerced before
+// they are written so JSONB comparisons and sorting behave numerically.
+const (
+	FieldString   = "string"
+	FieldNumber   = "number"
+	FieldDate     = "date"
+	FieldDateTime = "datetime"
+)
+
+type (
+	Field struct {
+		Key   string `json:"key"`
+		Label string `json:"label"`
+		Type  string `json:"type"`
+	}
+
+	// Dataset describes one upstream dataset. The *Key members name the
+	// fields that carry a common meaning across datasets so filters can be
+	// expressed once; an empty key means the dataset has no such field.
+	Dataset struct {
+		ID     int     `json:"identifier"`
+		Slug   string  `json:"slug"`
+		Name   string  `json:"name"`
+		Fields []Field `json:"fields"`
+
+		ProvinceKey    string `json:"-"`
+		RegencyKey     string `json:"-"`
+		SubdistrictKey string `json:"-"`
+		VillageKey     string `json:"-"`
+		CommodityKey   string `json:"-"`
+		VarietyKey     string `json:"-"`
+		DateKey        string `json:"-"`
+		YearKey        string `json:"-"`
+		MonthKey       string `json:"-"`
+		LatKey         string `json:"-"`
+		LngKey         string `json:"-"`
+
+		// IdentityKeys together identify one upstream row across ingestion
+		// runs, which lets runs be diffed even though upstream has no ids.
+		IdentityKeys []string `json:"-"`
+	}
+)
+
+var (
+	sipdpsTanamFields = []Field{
+		{"nip_reporter", "NIP Pelapor", FieldString},
+		{"nm_reporter", "Nama Pelapor", FieldString},
+		{"tgl_lapor", "Tanggal Laporan", FieldDate},
+		{"tgl_kunjungan", "Tanggal Kunjungan", FieldDate},
+		{"jenis_kelompok", "Jenis Kelompok", FieldString},
+		{"nm_prov", "Provinsi", FieldString},
+		{"nm_kab", "Kabupaten/Kota", FieldString},
+		{"nm_kec", "Kecamatan", FieldString},
+		{"nm_desa", "Desa", FieldString},
+		{"kategori_lahan", "Kategori Lahan", FieldString},
+		{"jenis_lahan", "Jenis Lahan", FieldString},
+		{"jenis_tanaman_pangan", "Jenis Tanaman Pangan", FieldString},
+		{"nm_varietas", "Varietas", FieldString},
+		{"jenis_bantuan", "Jenis Bantuan", FieldString},
+		{"sumber_bantuan", "Sumber Bantuan", FieldString},
+		{"tahun_bantuan", "Tahun Bantuan", FieldNumber},
+		{"luas_area", "Luas Area (ha)", FieldNumber},
+		{"hst", "Hari Setelah Tanam", FieldNumber},
+		{"lat", "Latitude", FieldNumber},
+		{"lng", "Longitude", FieldNumber},
+		{"photos", "Foto", FieldString},
+		{"status", "Status", FieldString},
+	}
+
+	sipdpsProduktivitasFields = []Field{
+		{"nip_reporter", "NIP Pelapor", FieldString},
+		{"nm_reporter", "Nama Pelapor", FieldString},
+		{"tgl_lapor", "Tanggal Laporan", FieldDate},
+		{"tgl_kunjungan", "Tanggal Kunjungan", FieldDate},
+		{"nm_prov", "Provinsi", FieldString},
+		{"nm_kab", "Kabupaten/Kota", FieldString},
+		{"nm_kec", "Kecamatan", FieldString},
+		{"nm_desa", "Desa", FieldString},
+		{"kategori_lahan", "Kategori Lahan", FieldString},
+		{"jenis_lahan", "Jenis Lahan", FieldString},
+		{"jenis_tanaman_pangan", "Jenis Tanaman Pangan", FieldString},
+		{"teknik_pengukuran", "Teknik Pengukuran", FieldString},
+		{"jumlah", "Produktivitas (ku/ha)", FieldNumber},
+		{"lat", "Latitude", FieldNumber},
+		{"lng", "Longitude", FieldNumber},
+		{"photos", "Foto", FieldString},
+		{"nm_verifikator", "Verifikator", FieldString},
+		{"status", "Status", FieldString},
+	}
+
+	sipdpsPusoFields = []Field{
+		{"nip_reporter", "NIP Pelapor", FieldString},
+		{"nm_reporter", "Nama Pelapor", FieldString},
+		{"tgl_lapor", "Tanggal Laporan", FieldDate},
+		{"tgl_kejadian", "Tanggal Kejadian", FieldDate},
+		{"nm_prov", "Provinsi", FieldString},
+		{"nm_kab", "Kabupaten/Kota", FieldString},
+		{"nm_kec", "Kecamatan", FieldString},
+		{"nm_desa", "Desa", FieldString},
+		{"jenis_tanaman_pangan", "Jenis Tanaman Pangan", FieldString},
+		{"penyebab_puso", "Penyebab Puso", FieldString},
+		{"lat", "Latitude", FieldNumber},
+		{"lng", "Longitude", FieldNumber},
+		{"photos", "Foto", FieldString},
+		{"nm_verifikator", "Verifikator", FieldString},
+		{"status", "Status", FieldString},
+	}
+
+	sipdpsPanenFields = []Field{
+		{"nip_reporter", "NIP Pelapor", FieldString},
+		{"nm_reporter", "Nama Pelapor", FieldString},
+		{"tgl_lapor", "Tanggal Laporan", FieldDate},
+		{"tgl_kunjungan", "Tanggal Kunjungan", FieldDate},
+		{"nm_prov", "Provinsi", FieldString},
+		{"nm_kab", "Kabupaten/Kota", FieldString},
+		{"nm_kec", "Kecamatan", FieldString},
+		{"nm_desa", "Desa", FieldString},
+		{"jenis_tanaman_pangan", "Jenis Tanaman Pangan", FieldString},
+		{"nm_varietas", "Varietas", FieldString},
+		{"kategori_pengelola", "Kategori Pengelola", FieldString},
+		{"nama_pengelola", "Nama Pengelola", FieldString},
+		{"luas", "Luas Panen (ha)", FieldNumber},
+		{"perkiraan", "Perkiraan Hasil (ton)", FieldNumber},
+		{"lat", "Latitude", FieldNumber},
+		{"lng", "Longitude", FieldNumber},
+		{"photos", "Foto", FieldString},
+		{"nm_verifikator", "Verifikator", FieldString},
+		{"status", "Status", FieldString},
+	}
+
+	perbenihanRekapFields = []Field{
+		{"NO", "No", FieldNumber},
+		{"JENIS", "Jenis", FieldString},
+		{"PROVINSI", "Provinsi", FieldString},
+		{"JENIS_BENIH", "Jenis Benih", FieldString},
+		{"KELAS_BENIH", "Kelas Benih", FieldString},
+		{"VARIETAS", "Varietas", FieldString},
+		{"REALISASI_LUAS", "Realisasi Luas (ha)", FieldNumber},
+		{"REALISASI_PRODUKSI", "Realisasi Produksi (ton)", FieldNumber},
+		{"VOLUME", "Volume (ton)", FieldNumber},
+		{"DICATAT", "Dicatat", FieldDateTime},
+		{"DIPERBARUI", "Diperbarui", FieldDateTime},
+	}
+
+	perbenihanPenyaluranFields = []Field{
+		{"NO", "No", FieldNumber},
+		{"TAHUN", "Tahun", FieldNumber},
+		{"BULAN", "Bulan", FieldNumber},
+		{"PROVINSI", "Provinsi", FieldString},
+		{"KABUPATENKOTA", "Kabupaten/Kota", FieldString},
+		{"KECAMATAN", "Kecamatan", FieldString},
+		{"PRODUSEN_BENIH", "Produsen Benih", FieldString},
+		{"KELAS_BENIH", "Kelas Benih", FieldString},
+		{"KOMODITI", "Komoditas", FieldString},
+		{"VARIETAS", "Varietas", FieldString},
+		{"STOK_LALU", "Stok Bulan Lalu (ton)", FieldNumber},
+		{"PRODUKSI_BENIH", "Produksi Benih (ton)", FieldNumber},
+		{"PENGADAAN", "Pengadaan (ton)", FieldNumber},
+		{"JUMLAH_STOK", "Jumlah Stok (ton)", FieldNumber},
+		{"PENYALURAN", "Penyaluran (ton)", FieldNumber},
+		{"APBN", "APBN (ton)", FieldNumber},
+		{"APBD", "APBD (ton)", FieldNumber},
+		{"FREE_MARKET", "Pasar Bebas (ton)", FieldNumber},
+		{"JUMLAH_SALUR", "Jumlah Salur (ton)", FieldNumber},
+		{"TOTAL", "Total (ton)", FieldNumber},
+		{"SISA_STOK", "Sisa Stok (ton)", FieldNumber},
+		{"DICATAT", "Dicatat", FieldDateTime},
+		{"DIPERBARUI", "Diperbarui", FieldDateTime},
+	}
+
+	perbenihanPenyebaranFields = []Field{
+		{"NO", "No", FieldNumber},
+		{"TAHUN", "Tahun", FieldNumber},
+		{"BULAN", "Bulan", FieldNumber},
+		{"PROVINSI", "Provinsi", FieldString},
+		{"KABUPATENKOTA", "Kabupaten/Kota", FieldString},
+		{"KECAMATAN", "Kecamatan", FieldString},
+		{"KELURAHAN", "Kelurahan", FieldString},
+		{"PETA", "Peta", FieldString},
+		{"REALISASI_TANAM_LUAS", "Realisasi Luas Tanam (ha)", FieldNumber},
+		{"BENIH", "Benih", FieldString},
+		{"JENIS_BENIH", "Jenis Benih", FieldString},
+		{"VARIETAS", "Varietas", FieldString},
+		{"TOTAL_LUAS", "Total Luas (ha)", FieldNumber},
+		{"DICATAT", "Dicatat", FieldDateTime},
+		{"DIPERBARUI", "Diperbarui", FieldDateTime},
+	}
+
+	perbenihanProdusenFields = []Field{
+		{"NO", "No", FieldNumber},
+		{"KODE_PROVINSI", "Kode Provinsi", FieldString},
+		{"PROVINSI", "Provinsi", FieldString},
+		{"KABUPATENKOTA", "Kabupaten/Kota", FieldString},
+		{"KECAMATAN", "Kecamatan", FieldString},
+		{"KELURAHAN", "Kelurahan", FieldString},
+		{"USERNAME", "Username", FieldString},
+		{"IDSIMLUH", "ID SIMLUH", FieldString},
+		{"NOMOR_REGISTRASI", "Nomor Registrasi", FieldString},
+		{"TIPE_PRODUSEN", "Tipe Produsen", FieldString},
+		{"NAMA", "Nama Produsen", FieldString},
+		{"NAMA_PIMPINAN", "Nama Pimpinan", FieldString},
+		{"ALAMAT_PIMPINAN", "Alamat Pimpinan", FieldString},
+		{"ALAMAT_PRODUSEN", "Alamat Produsen", FieldString},
+		{"TELEPON", "Telepon", FieldString},
+		{"EMAIL", "Email", FieldString},
+		{"BENIH", "Benih", FieldString},
+		{"TOTAL_LUAS_LAHAN", "Total Luas Lahan (ha)", FieldNumber},
+		{"LAT", "Latitude", FieldNumber},
+		{"LNG", "Longitude", FieldNumber},
+		{"DICATAT", "Dicatat", FieldDateTime},
+		{"DIPERBARUI", "Diperbarui", FieldDateTime},
+	}
+
+	datasets = []Dataset{
+		{
+			ID: SIPDPSTanamID, Slug: "sipdps-laporan-tanam", Name: "SIPDPS Laporan Tanam", Fields: sipdpsTanamFields,
+			ProvinceKey: "nm_prov", RegencyKey: "nm_kab", SubdistrictKey: "nm_kec", VillageKey: "nm_desa",
+			CommodityKey: "jenis_tanaman_pangan", VarietyKey: "nm_varietas", DateKey: "tgl_lapor",
+			LatKey: "lat", LngKey: "lng",
+			IdentityKeys: []string{"nip_reporter", "tgl_lapor", "tgl_kunjungan", "nm_desa", "jenis_tanaman_pangan", "nm_varietas"},
+		},
+		{
+			ID: SIPDPSProduktivitasID, Slug: "sipdps-laporan-produktivitas", Name: "SIPDPS Laporan Produktivitas", Fields: sipdpsProduktivitasFields,
+			ProvinceKey: "nm_prov", RegencyKey: "nm_kab", SubdistrictKey: "nm_kec", VillageKey: "nm_desa",
+			CommodityKey: "jenis_tanaman_pangan", DateKey: "tgl_lapor",
+			LatKey: "lat", LngKey: "lng",
+			IdentityKeys: []string{"nip_reporter", "tgl_lapor", "tgl_kunjungan", "nm_desa", "jenis_tanaman_pangan"},
+		},
+		{
+			ID: SIPDPSPusoID, Slug: "sipdps-laporan-puso", Name: "SIPDPS Laporan Puso", Fields: sipdpsPusoFields,
+			ProvinceKey: "nm_prov", RegencyKey: "nm_kab", SubdistrictKey: "nm_kec", VillageKey: "nm_desa",
+			CommodityKey: "jenis_tanaman_pangan", DateKey: "tgl_lapor",
+			LatKey: "lat", LngKey: "lng",
+			IdentityKeys: []string{"nip_reporter", "tgl_lapor", "tgl_kejadian", "nm_desa", "jenis_tanaman_pangan"},
+		},
+		{
+			ID: SIPDPSPanenID, Slug: "sipdps-laporan-panen", Name: "SIPDPS Laporan Panen", Fields: sipdpsPanenFields,
+			ProvinceKey: "nm_prov", RegencyKey: "nm_kab", SubdistrictKey: "nm_kec", VillageKey: "nm_desa",
+			CommodityKey: "jenis_tanaman_pangan", VarietyKey: "nm_varietas", DateKey: "tgl_lapor",
+			LatKey: "lat", LngKey: "lng",
+			IdentityKeys: []string{"nip_reporter", "tgl_lapor", "tgl_kunjungan", "nm_desa", "jenis_tanaman_pangan", "nm_varietas"},
+		},
+		{
+			ID: PerbenihanProdusenID, Slug: "perbenihan-produsen", Name: "Perbenihan Produsen", Fields: perbenihanProdusenFields,
+			ProvinceKey: "PROVINSI", RegencyKey: "KABUPATENKOTA", SubdistrictKey: "KECAMATAN", VillageKey: "KELURAHAN",
+			CommodityKey: "BENIH", DateKey: "DICATAT",
+			LatKey: "LAT", LngKey: "LNG",
+			IdentityKeys: []string{"PROVINSI", "NOMOR_REGISTRASI", "NAMA"},
+		},
+		{
+			ID: PerbenihanRekNasID, Slug: "perbenihan-rek-nas", Name: "Perbenihan Rekapitulasi Nasional", Fields: perbenihanRekapFields,
+			ProvinceKey: "PROVINSI", CommodityKey: "JENIS_BENIH", VarietyKey: "VARIETAS", DateKey: "DICATAT",
+			IdentityKeys: []string{"JENIS", "PROVINSI", "JENIS_BENIH", "KELAS_BENIH", "VARIETAS"},
+		},
+		{
+			ID: PerbenihanRekBpsbID, Slug: "perbenihan-rek-bpsb", Name: "Perbenihan Rekapitulasi BPSB", Fields: perbenihanRekapFields,
+			ProvinceKey: "PROVINSI", CommodityKey: "JENIS_BENIH", VarietyKey: "VARIETAS", DateKey: "DICATAT",
+			IdentityKeys: []string{"JENIS", "PROVINSI", "JENIS_BENIH", "KELAS_BENIH", "VARIETAS"},
+		},
+		{
+			ID: PerbenihanRekLssmID, Slug: "perbenihan-rek-lssm", Name: "Perbenihan Rekapitulasi LSSM", Fields: perbenihanRekapFields,
+			ProvinceKey: "PROVINSI", CommodityKey: "JENIS_BENIH", VarietyKey: "VARIETAS", DateKey: "DICATAT",
+			IdentityKeys: []string{"JENIS", "PROVINSI", "JENIS_BENIH", "KELAS_BENIH", "VARIETAS"},
+		},
+		{
+			ID: PerbenihanRekPenyaluranID, Slug: "perbenihan-rek-penyaluran", Name: "Perbenihan Rekapitulasi Penyaluran", Fields: perbenihanPenyaluranFields,
+			ProvinceKey: "PROVINSI", RegencyKey: "KABUPATENKOTA", SubdistrictKey: "KECAMATAN",
+			CommodityKey: "KOMODITI", VarietyKey: "VARIETAS", DateKey: "DICATAT",
+			YearKey: "TAHUN", MonthKey: "BULAN",
+			IdentityKeys: []string{"TAHUN", "BULAN", "PROVINSI", "KABUPATENKOTA", "KECAMATAN", "PRODUSEN_BENIH", "KELAS_BENIH", "KOMODITI", "VARIETAS"},
+		},
+		{
+			ID: PerbenihanRekPenyebaranID, Slug: "perbenihan-rek-penyebaran", Name: "Perbenihan Rekapitulasi Penyebaran", Fields: perbenihanPenyebaranFields,
+			ProvinceKey: "PROVINSI", RegencyKey: "KABUPATENKOTA", SubdistrictKey: "KECAMATAN", VillageKey: "KELURAHAN",
+			CommodityKey: "JENIS_BENIH", VarietyKey: "VARIETAS", DateKey: "DICATAT",
+			YearKey: "TAHUN", MonthKey: "BULAN",
+			IdentityKeys: []string{"TAHUN", "BULAN", "PROVINSI", "KABUPATENKOTA", "KECAMATAN", "KELURAHAN", "JENIS_BENIH", "VARIETAS"},
+		},
+		{
+			ID: PerbenihanRekProdusenID, Slug: "perbenihan-rek-produsen", Name: "Perbenihan Rekapitulasi Produsen", Fields: perbenihanProdusenFields,
+			ProvinceKey: "PROVINSI", RegencyKey: "KABUPATENKOTA", SubdistrictKey: "KECAMATAN", VillageKey: "KELURAHAN",
+			CommodityKey: "BENIH", DateKey: "DICATAT",
+			LatKey: "LAT", LngKey: "LNG",
+			IdentityKeys: []string{"PROVINSI", "NOMOR_REGISTRASI", "NAMA"},
+		},
+	}
+)
+
+func DatasetByID(id int) (Dataset, bool) {
+	for _, ds := range datasets {
+		if ds.ID == id {
+			return ds, true
+		}
+	}
+	return Dataset{}, false
+}
+
+func DatasetBySlug(slug string) (Dataset, bool) {
+	for _, ds := range datasets {
+		if ds.Slug == slug {
+			return ds, true
+		}
+	}
+	return Dataset{}, false
+}
+
+func (ds Dataset) Field(key string) (Field, bool) {
+	for _, f := range ds.Fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// JSONSchema describes a single record of the dataset. It is stored next to
+// the records in ppt_datasets so consumers outside this service can rely on
+// the shape of the JSONB documents.
+func (ds Dataset) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(ds.Fields))
+	for _, f := range ds.Fields {
+		prop := map[string]interface{}{
+			"title": f.Label,
+		}
+
+		switch f.Type {
+		case FieldNumber:
+			prop["type"] = []string{"number", "null"}
+		case FieldDate:
+			prop["type"] = []string{"string", "null"}
+			prop["format"] = "date"
+		case FieldDateTime:
+			prop["type"] = []string{"string", "null"}
+			prop["format"] = "date-time"
+		default:
+			prop["type"] = []string{"string", "null"}
+		}
+
+		properties[f.Key] = prop
+	}
+
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"$id":                  "ppt:dataset:" + ds.Slug,
+		"title":                ds.Name,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// Normalize keeps only the keys known to the dataset and converts numeric
+// strings into numbers. Values that cannot be converted are kept as they were
+// received so nothing is lost on the way into the database.
+func (ds Dataset) Normalize(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(ds.Fields))
+	for _, f := range ds.Fields {
+		v, ok := row[f.Key]
+		if !ok || v == nil {
+			out[f.Key] = nil
+			continue
+		}
+
+		if s, isString := v.(string); isString {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				out[f.Key] = nil
+				continue
+			}
+
+			if f.Type == FieldNumber {
+				num := s
+				if !strings.Contains(num, ".") && strings.Count(num, ",") == 1 {
+					num = strings.Replace(num, ",", ".", 1)
+				}
+
+				if n, err := strconv.ParseFloat(num, 64); err == nil {
+					out[f.Key] = n
+					continue
+				}
+			}
+
+			out[f.Key] = s
+			continue
+		}
+
+		out[f.Key] = v
+	}
+
+	return out
+}
@@ -0,0 +1,55 @@
+package internal_api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	ds, _ := DatasetByID(SIPDPSTanamID)
+
+	tests := []struct {
+		name string
+		key  string
+		in   interface{}
+		want interface{}
+	}{
+		{"missing", "nm_prov", nil, nil},
+		{"blank string", "nm_prov", "   ", nil},
+		{"trimmed string", "nm_prov", "  JAWA BARAT ", "JAWA BARAT"},
+		{"number field keeps floats", "luas_area", 2.5, 2.5},
+		{"numeric string", "luas_area", "12.75", 12.75},
+		{"decimal comma", "luas_area", "12,75", 12.75},
+		{"thousands comma left alone", "luas_area", "1,234.5", "1,234.5"},
+		{"unparsable number kept", "luas_area", "dua", "dua"},
+		{"string field keeps digits", "nip_reporter", "1980", "1980"},
+		{"non string kept", "photos", []interface{}{"a.jpg"}, []interface{}{"a.jpg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := map[string]interface{}{}
+			if tt.in != nil {
+				row[tt.key] = tt.in
+			}
+
+			got := ds.Normalize(row)
+			if !reflect.DeepEqual(got[tt.key], tt.want) {
+				t.Errorf("Normalize()[%q] = %#v, want %#v", tt.key, got[tt.key], tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeepsOnlyKnownFields(t *testing.T) {
+	ds, _ := DatasetByID(SIPDPSTanamID)
+
+	got := ds.Normalize(map[string]interface{}{"nm_prov": "JAWA BARAT", "unknown": "x"})
+
+	if _, ok := got["unknown"]; ok {
+		t.Error("Normalize() kept a field the dataset does not define")
+	}
+	if len(got) != len(ds.Fields) {
+		t.Errorf("Normalize() returned %d fields, want %d", len(got), len(ds.Fields))
+	}
+}
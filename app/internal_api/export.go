@@ -0,0 +1,190 @@
+package internal_api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+
+	exportSheet = "Data"
+
+	// maxXLSXRows is the worksheet row limit minus the header row.
+	maxXLSXRows = 1048575
+
+	// csvFlushEvery pushes CSV rows to the client in batches.
+	csvFlushEvery = 500
+)
+
+var ErrExportTooLarge = errors.New("export exceeds the xlsx row limit, narrow the filters or use csv")
+
+type (
+	// recordWriter writes one export format. WriteHeader is called once,
+	// before any WriteRow; Close finishes the document.
+	recordWriter interface {
+		WriteHeader(labels []string) error
+		WriteRow(values []interface{}) error
+		Close() error
+	}
+
+	csvWriter struct {
+		w    *csv.Writer
+		rows int
+	}
+
+	// xlsxWriter keeps the worksheet in excelize's stream writer, which
+	// spills to a temporary file instead of holding every cell in memory.
+	// An xlsx file is a zip archive that can only be written once it is
+	// complete, so nothing reaches the client before Close; unlike csv the
+	// download starts after every row has been read.
+	xlsxWriter struct {
+		out  io.Writer
+		file *excelize.File
+		sw   *excelize.StreamWriter
+		rows int
+	}
+)
+
+var exportContentTypes = map[string]string{
+	ExportCSV:  "text/csv; charset=utf-8",
+	ExportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+func newRecordWriter(format string, out io.Writer) (recordWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvWriter{w: csv.NewWriter(out)}, nil
+	case ExportXLSX:
+		return newXLSXWriter(out)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// exportColumns are the requested fields, or every field of the dataset.
+func exportColumns(ds Dataset, keys []string) []Field {
+	if len(keys) == 0 {
+		return ds.Fields
+	}
+
+	columns := make([]Field, 0, len(keys))
+	for _, key := range keys {
+		if f, ok := ds.Field(key); ok {
+			columns = append(columns, f)
+		}
+	}
+	return columns
+}
+
+func (w *csvWriter) WriteHeader(labels []string) error {
+	return w.w.Write(labels)
+}
+
+func (w *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = exportText(v)
+		if _, ok := v.(float64); !ok {
+			record[i] = util.EscapeCSVFormula(record[i])
+		}
+	}
+
+	if err := w.w.Write(record); err != nil {
+		return err
+	}
+
+	w.rows++
+	if w.rows%csvFlushEvery == 0 {
+		w.w.Flush()
+		return w.w.Error()
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func newXLSXWriter(out io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName(file.GetSheetName(0), exportSheet); err != nil {
+		return nil, err
+	}
+
+	sw, err := file.NewStreamWriter(exportSheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{out: out, file: file, sw: sw}, nil
+}
+
+func (w *xlsxWriter) WriteHeader(labels []string) error {
+	style, err := w.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	row := make([]interface{}, len(labels))
+	for i, label := range labels {
+		row[i] = label
+	}
+
+	return w.sw.SetRow("A1", row, excelize.RowOpts{StyleID: style})
+}
+
+func (w *xlsxWriter) WriteRow(values []interface{}) error {
+	if w.rows >= maxXLSXRows {
+		return ErrExportTooLarge
+	}
+	w.rows++
+
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil, float64, string:
+			row[i] = v
+		default:
+			row[i] = exportText(v)
+		}
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, w.rows+1)
+	if err != nil {
+		return err
+	}
+	return w.sw.SetRow(cell, row)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.sw.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
+
+func exportText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+}
@@ -0,0 +1,56 @@
+package internal_api
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestRecordWritersEscapeOnlyCSV(t *testing.T) {
+	values := []interface{}{"-", "=1+1", float64(-5), nil, []interface{}{"=a"}}
+
+	var csvOut bytes.Buffer
+	w, _ := newRecordWriter(ExportCSV, &csvOut)
+	if err := w.WriteRow(values); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := csvOut.String(), "'-,'=1+1,-5,,\"[\"\"=a\"\"]\"\n"; got != want {
+		t.Errorf("csv = %q, want %q", got, want)
+	}
+
+	var xlsxOut bytes.Buffer
+	w, err := newRecordWriter(ExportXLSX, &xlsxOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader([]string{"A", "B", "C", "D", "E"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(values); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(&xlsxOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(exportSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-", "=1+1", "-5", "", `["=a"]`}
+	for i, cell := range want {
+		if got := rows[1][i]; got != cell {
+			t.Errorf("xlsx cell %d = %q, want %q", i, got, cell)
+		}
+	}
+}
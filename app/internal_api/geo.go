@@ -0,0 +1,118 @@
+package internal_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+const (
+	// Below clusterMaxZoom points are merged per grid cell of about
+	// clusterCellPixels square on a 256 pixel web map tile.
+	clusterMaxZoom    = 15
+	clusterCellPixels = 64
+	maxGeoZoom        = 22
+
+	// maxGeoFeatures caps a single response; narrow the bbox or filters to
+	// see the rest.
+	maxGeoFeatures = 5000
+)
+
+type (
+	// GeoQuery adds the map viewport to the tabular filters. BBox is
+	// minLng, minLat, maxLng, maxLat as in GeoJSON; Zoom is -1 when the
+	// client wants every point unclustered.
+	GeoQuery struct {
+		Filter     DatasetQuery
+		BBox       []float64
+		Zoom       int
+		Properties []string
+	}
+
+	GeoGeometry struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	}
+
+	GeoFeature struct {
+		Type       string      `json:"type"`
+		Geometry   GeoGeometry `json:"geometry"`
+		Properties interface{} `json:"properties"`
+	}
+
+	GeoClusterProperties struct {
+		Cluster    bool `json:"cluster"`
+		PointCount int  `json:"point_count"`
+	}
+
+	// GeoFeatureCollection is plain GeoJSON; Truncated is a foreign member
+	// set when more than maxGeoFeatures features matched.
+	GeoFeatureCollection struct {
+		Type      string       `json:"type"`
+		BBox      []float64    `json:"bbox,omitempty"`
+		Features  []GeoFeature `json:"features"`
+		Truncated bool         `json:"truncated,omitempty"`
+	}
+
+	// GeoRow is a single point, or a grid cell of Count points. Properties
+	// is only set when the row stands for one record.
+	GeoRow struct {
+		Lng        float64
+		Lat        float64
+		Count      int
+		Properties json.RawMessage
+	}
+)
+
+func (ds Dataset) HasCoordinates() bool {
+	return ds.LatKey != "" && ds.LngKey != ""
+}
+
+// Clustered reports whether points are merged at the requested zoom.
+func (arg GeoQuery) Clustered() bool {
+	return arg.Zoom >= 0 && arg.Zoom < clusterMaxZoom
+}
+
+// CellSize is the cluster grid cell in degrees at the requested zoom.
+func (arg GeoQuery) CellSize() float64 {
+	return 360 / (256 * math.Pow(2, float64(arg.Zoom))) * clusterCellPixels
+}
+
+// geoWhere narrows datasetWhere to records with numeric coordinates inside
+// the bbox.
+func geoWhere(ds Dataset, arg GeoQuery) (string, []interface{}) {
+	where, args := datasetWhere(ds, arg.Filter)
+
+	where += fmt.Sprintf(" AND jsonb_typeof(%s) = 'number' AND jsonb_typeof(%s) = 'number'",
+		jsonPath(ds.LngKey, false), jsonPath(ds.LatKey, false))
+
+	if len(arg.BBox) == 4 {
+		args = append(args, arg.BBox[0], arg.BBox[1], arg.BBox[2], arg.BBox[3])
+		n := len(args)
+		where += fmt.Sprintf(" AND %s BETWEEN $%d AND $%d AND %s BETWEEN $%d AND $%d",
+			geoCoordinate(ds.LngKey), n-3, n-1, geoCoordinate(ds.LatKey), n-2, n)
+	}
+
+	return where, args
+}
+
+func geoCoordinate(key string) string {
+	return "(" + jsonPath(key, true) + ")::float8"
+}
+
+func geoFeatures(rows []GeoRow) []GeoFeature {
+	features := make([]GeoFeature, 0, len(rows))
+	for _, row := range rows {
+		f := GeoFeature{
+			Type:     "Feature",
+			Geometry: GeoGeometry{Type: "Point", Coordinates: [2]float64{row.Lng, row.Lat}},
+		}
+		if row.Count > 1 {
+			f.Properties = GeoClusterProperties{Cluster: true, PointCount: row.Count}
+		} else {
+			f.Properties = row.Properties
+		}
+		features = append(features, f)
+	}
+	return features
+}
@@ -1,6 +1,8 @@
 package internal_api
 
 import (
+	"database/sql"
+
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -11,7 +13,7 @@ type InternalApiHandler struct {
 	rdb     *redis.Client
 }
 
-func NewHandler(router *gin.Engine, usecase InternalApiUsecase, rdb *redis.Client) {
+func NewHandler(router *gin.Engine, usecase InternalApiUsecase, rdb *redis.Client, db *sql.DB) {
 	handler := &InternalApiHandler{
 		Usecase: usecase,
 		rdb:     rdb,
@@ -21,20 +23,35 @@ func NewHandler(router *gin.Engine, usecase InternalApiUsecase, rdb *redis.Clien
 
 	v1.GET("fetch-all", handler.GetAll)
 
-	// Fetching data and store into database
+	// Fetching data and store into database; admins only, as a fetch pulls
+	// from the upstream in the background and reports progress to admins.
 	// SIPDPS
-	v1.GET("api-sipdps-laporan-tanam-fetch", util.AuthMiddleware(rdb), handler.GetSIPDPSTanamFetch)
-	v1.GET("api-sipdps-laporan-produktivitas-fetch", util.AuthMiddleware(rdb), handler.GetSIPDPSProduktivitasFetch)
-	v1.GET("api-sipdps-laporan-puso-fetch", util.AuthMiddleware(rdb), handler.GetSIPDPSPusoFetch)
-	v1.GET("api-sipdps-laporan-panen-fetch", util.AuthMiddleware(rdb), handler.GetSIPDPSPanenFetch)
+	v1.GET("api-sipdps-laporan-tanam-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetSIPDPSTanamFetch)
+	v1.GET("api-sipdps-laporan-produktivitas-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetSIPDPSProduktivitasFetch)
+	v1.GET("api-sipdps-laporan-puso-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetSIPDPSPusoFetch)
+	v1.GET("api-sipdps-laporan-panen-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetSIPDPSPanenFetch)
 	// PERBENIHAN
-	v1.GET("api-perbenihan-produsen-fetch", util.AuthMiddleware(rdb), handler.GetPerbenihanProdusenFetch)
-	v1.GET("api-perbenihan-rek-nas-fetch", handler.GetPerbenihanRekNasFetch)
-	v1.GET("api-perbenihan-rek-bpsb-fetch", util.AuthMiddleware(rdb), handler.GetPerbenihanRekBpsbFetch)
-	v1.GET("api-perbenihan-rek-lssm-fetch", util.AuthMiddleware(rdb), handler.GetPerbenihanRekLssmFetch)
-	v1.GET("api-perbenihan-rek-penyaluran-fetch", util.AuthMiddleware(rdb), handler.GetPerbenihanRekPenyaluranFetch)
-	v1.GET("api-perbenihan-rek-penyebaran-fetch", util.AuthMiddleware(rdb), handler.GetPerbenihanRekPenyebaranFetch)
-	v1.GET("api-perbenihan-rek-produsen-fetch", util.AuthMiddleware(rdb), handler.GetPerbenihanRekProdusenFetch)
+	v1.GET("api-perbenihan-produsen-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanProdusenFetch)
+	v1.GET("api-perbenihan-rek-nas-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanRekNasFetch)
+	v1.GET("api-perbenihan-rek-bpsb-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanRekBpsbFetch)
+	v1.GET("api-perbenihan-rek-lssm-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanRekLssmFetch)
+	v1.GET("api-perbenihan-rek-penyaluran-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanRekPenyaluranFetch)
+	v1.GET("api-perbenihan-rek-penyebaran-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanRekPenyebaranFetch)
+	v1.GET("api-perbenihan-rek-produsen-fetch", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetPerbenihanRekProdusenFetch)
+
+	v1.GET("api-ingest-job/:id", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetJob)
+	v1.GET("api-upstream-status", util.AuthMiddleware(rdb), handler.GetUpstreamStatus)
+
+	// Data quality
+	v1.GET("api-quality-reports", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetQualityReports)
+	v1.GET("api-quality-report/:id", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetQualityReport)
+	v1.PUT("api-quality-report/:id/review", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.ReviewQualityReport)
+	v1.GET("api-ingest-runs", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetRuns)
+	v1.GET("api-ingest-run/:id", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetRun)
+	v1.GET("api-ingest-runs-diff", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.DiffRuns)
+	v1.GET("api-seed-anomalies", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.GetSeedAnomalies)
+	v1.PUT("api-seed-anomaly/:id/review", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.ReviewSeedAnomaly)
+	v1.POST("api-seed-checks", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.RunSeedChecks)
 
 	// Menampilkan data yang telah di fetch
 	// SIPDPS
@@ -51,11 +68,14 @@ func NewHandler(router *gin.Engine, usecase InternalApiUsecase, rdb *redis.Clien
 	v1.GET("api-perbenihan-rek-penyebaran", handler.GetPerbenihanRekPenyebaran)
 	v1.GET("api-perbenihan-rek-produsen", handler.GetPerbenihanRekProdusen)
 
-	// Langsung hit pada API
-
-	// SIMLUH
-	v1.GET("api-simluh-sertifikat", util.AuthMiddleware(handler.rdb), handler.GetSimluhSertifikat)
-	v1.GET("api-simluh-riwayat-pelatihan", util.AuthMiddleware(handler.rdb), handler.GetSimluhRiwayatPelatihan)
+	v1.GET("api-dataset-schema/:slug", util.AuthMiddleware(rdb), handler.GetSchema)
+	v1.GET("api-dataset/:slug", util.AuthMiddleware(rdb), handler.QueryDataset)
+	v1.GET("api-dataset-metrics", util.AuthMiddleware(rdb), handler.GetMetrics)
+	v1.GET("api-dataset-aggregate/:metric", util.AuthMiddleware(rdb), handler.Aggregate)
+	v1.GET("api-dataset-geojson/:slug", util.AuthMiddleware(rdb), handler.GetGeoJSON)
+	v1.GET("api-dataset-export/:slug", util.AuthMiddleware(rdb), handler.ExportDataset)
+	v1.GET("api-dataset-search", util.AuthMiddleware(rdb), handler.SearchDatasets)
+	v1.POST("api-dataset-reindex/:slug", util.AuthMiddleware(rdb), util.AdminMiddleware(db), handler.ReindexDataset)
 }
 
 func (handler *InternalApiHandler) GetAll(c *gin.Context) {
@@ -152,9 +172,78 @@ func (handler *InternalApiHandler) GetPerbenihanRekProdusen(c *gin.Context) {
 	handler.Usecase.GetPerbenihanRekProdusen(c)
 }
 
-func (handler *InternalApiHandler) GetSimluhSertifikat(c *gin.Context) {
-	handler.Usecase.GetSimluhSertifikat(c)
+func (handler *InternalApiHandler) GetJob(c *gin.Context) {
+	handler.Usecase.GetJob(c)
+}
+
+func (handler *InternalApiHandler) GetUpstreamStatus(c *gin.Context) {
+	handler.Usecase.GetUpstreamStatus(c)
+}
+
+func (handler *InternalApiHandler) GetQualityReports(c *gin.Context) {
+	handler.Usecase.GetQualityReports(c)
+}
+
+func (handler *InternalApiHandler) GetQualityReport(c *gin.Context) {
+	handler.Usecase.GetQualityReport(c)
+}
+
+func (handler *InternalApiHandler) ReviewQualityReport(c *gin.Context) {
+	handler.Usecase.ReviewQualityReport(c)
 }
-func (handler *InternalApiHandler) GetSimluhRiwayatPelatihan(c *gin.Context) {
-	handler.Usecase.GetSimluhRiwayatPelatihan(c)
+
+func (handler *InternalApiHandler) GetSchema(c *gin.Context) {
+	handler.Usecase.GetSchema(c)
+}
+
+func (handler *InternalApiHandler) QueryDataset(c *gin.Context) {
+	handler.Usecase.QueryDataset(c)
+}
+
+func (handler *InternalApiHandler) GetMetrics(c *gin.Context) {
+	handler.Usecase.GetMetrics(c)
+}
+
+func (handler *InternalApiHandler) Aggregate(c *gin.Context) {
+	handler.Usecase.Aggregate(c)
+}
+
+func (handler *InternalApiHandler) GetGeoJSON(c *gin.Context) {
+	handler.Usecase.GetGeoJSON(c)
+}
+
+func (handler *InternalApiHandler) ExportDataset(c *gin.Context) {
+	handler.Usecase.ExportDataset(c)
+}
+
+func (handler *InternalApiHandler) GetRuns(c *gin.Context) {
+	handler.Usecase.GetRuns(c)
+}
+
+func (handler *InternalApiHandler) GetRun(c *gin.Context) {
+	handler.Usecase.GetRun(c)
+}
+
+func (handler *InternalApiHandler) DiffRuns(c *gin.Context) {
+	handler.Usecase.DiffRuns(c)
+}
+
+func (handler *InternalApiHandler) GetSeedAnomalies(c *gin.Context) {
+	handler.Usecase.GetSeedAnomalies(c)
+}
+
+func (handler *InternalApiHandler) ReviewSeedAnomaly(c *gin.Context) {
+	handler.Usecase.ReviewSeedAnomaly(c)
+}
+
+func (handler *InternalApiHandler) RunSeedChecks(c *gin.Context) {
+	handler.Usecase.RunSeedChecks(c)
+}
+
+func (handler *InternalApiHandler) SearchDatasets(c *gin.Context) {
+	handler.Usecase.SearchDatasets(c)
+}
+
+func (handler *InternalApiHandler) ReindexDataset(c *gin.Context) {
+	handler.Usecase.ReindexDataset(c)
 }
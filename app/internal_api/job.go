@@ -0,0 +1,456 @@
+package internal_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/region"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+
+	jobKeyPrefix    = "ppt:ingest-job:"
+	activeKeyPrefix = "ppt:ingest-active:"
+	jobTTL          = 24 * time.Hour
+	jobTimeout      = 30 * time.Minute
+	jobQueueSize    = 64
+
+	// activeLease is how long the active key of a dataset outlives the
+	// instance holding its job. The holder renews it every
+	// activeHeartbeat; a queued or running job whose lease lapsed belonged
+	// to an instance that stopped, and is marked failed.
+	activeLease     = 2 * time.Minute
+	activeHeartbeat = 30 * time.Second
+)
+
+var ErrQueueFull = errors.New("ingestion queue is full, try again later")
+
+var (
+	// releaseLease and renewLease only touch the active key while it still
+	// names the job, so an instance whose lease lapsed never drops or
+	// extends the lease another instance took over since.
+	releaseLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	renewLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+type (
+	// Job is shared with the WebSocket server, which relays job updates.
+	Job = util.IngestJob
+
+	// jobRunner executes ingestion jobs one at a time so concurrent fetch
+	// requests never hammer the same upstream in parallel. Job state lives
+	// in Redis so any API instance can answer status requests.
+	jobRunner struct {
+		repo       InternalApiRepository
+		rdb        *redis.Client
+		reconciler *region.Reconciler
+		tokens     *tokenProvider
+		indexer    *datasetIndexer
+		queue      chan *Job
+
+		mu sync.Mutex
+		// held are the queued and running jobs of this instance, whose
+		// leases it renews.
+		held map[string]*Job
+	}
+)
+
+func newJobRunner(repo InternalApiRepository, rdb *redis.Client, indexer *datasetIndexer, reconciler *region.Reconciler) *jobRunner {
+	r := &jobRunner{
+		repo:       repo,
+		rdb:        rdb,
+		reconciler: reconciler,
+		tokens:     newTokenProvider(repo, rdb),
+		indexer:    indexer,
+		queue:      make(chan *Job, jobQueueSize),
+		held:       map[string]*Job{},
+	}
+	go r.work()
+	go r.heartbeat()
+	return r
+}
+
+// Enqueue schedules a fetch of ds. While a job for the same dataset is still
+// queued or running, that job is returned instead of starting another one.
+func (r *jobRunner) Enqueue(ctx context.Context, ds Dataset, requestedBy string) (*Job, error) {
+	job := &Job{
+		ID:          util.RandomString(24),
+		DatasetID:   ds.ID,
+		Dataset:     ds.Slug,
+		Status:      JobQueued,
+		Errors:      []string{},
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+
+	activeKey := activeKeyPrefix + ds.Slug
+	for attempt := 0; ; attempt++ {
+		ok, err := r.rdb.SetNX(ctx, activeKey, job.ID, activeLease).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+
+		activeID, err := r.rdb.Get(ctx, activeKey).Result()
+		if errors.Is(err, redis.Nil) && attempt < 3 {
+			// The active job finished in between; try to take its place.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r.Get(ctx, activeID)
+	}
+
+	r.hold(job)
+	if err := r.save(ctx, job); err != nil {
+		r.release(job)
+		return nil, err
+	}
+
+	select {
+	case r.queue <- job:
+	default:
+		r.release(job)
+		finished := time.Now()
+		job.Status = JobFailed
+		job.Errors = append(job.Errors, ErrQueueFull.Error())
+		job.FinishedAt = &finished
+		r.update(ctx, job)
+		return nil, ErrQueueFull
+	}
+
+	r.publish(ctx, job)
+
+	return job, nil
+}
+
+func (r *jobRunner) hold(job *Job) {
+	r.mu.Lock()
+	r.held[job.ID] = job
+	r.mu.Unlock()
+}
+
+// release gives up the dataset of job, so the next fetch starts a new job.
+func (r *jobRunner) release(job *Job) {
+	r.mu.Lock()
+	delete(r.held, job.ID)
+	r.mu.Unlock()
+
+	keys := []string{activeKeyPrefix + job.Dataset}
+	if err := releaseLease.Run(context.Background(), r.rdb, keys, job.ID).Err(); err != nil {
+		log.Printf("releasing ingestion job %s: %v", job.ID, err)
+	}
+}
+
+// heartbeat renews the leases of this instance's jobs and fails the jobs
+// left behind by instances that stopped, including this one before a
+// restart.
+func (r *jobRunner) heartbeat() {
+	r.failAbandoned(context.Background())
+
+	ticker := time.NewTicker(activeHeartbeat)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		r.renew(ctx)
+		r.failAbandoned(ctx)
+	}
+}
+
+// renew extends the leases of this instance's jobs. A job whose lease
+// lapsed is no longer held; failAbandoned then fails it unless another
+// instance took over.
+func (r *jobRunner) renew(ctx context.Context) {
+	r.mu.Lock()
+	jobs := make([]*Job, 0, len(r.held))
+	for _, job := range r.held {
+		jobs = append(jobs, job)
+	}
+	r.mu.Unlock()
+
+	for _, job := range jobs {
+		keys := []string{activeKeyPrefix + job.Dataset}
+		renewed, err := renewLease.Run(ctx, r.rdb, keys, job.ID, activeLease.Milliseconds()).Int()
+		if err != nil {
+			log.Printf("renewing ingestion job %s: %v", job.ID, err)
+			continue
+		}
+		if renewed == 0 {
+			// The lease lapsed and the dataset is no longer this job's.
+			log.Printf("ingestion job %s lost the lease of %s", job.ID, job.Dataset)
+			r.mu.Lock()
+			delete(r.held, job.ID)
+			r.mu.Unlock()
+		}
+	}
+}
+
+// failAbandoned marks queued and running jobs failed when no instance
+// holds their lease any more.
+func (r *jobRunner) failAbandoned(ctx context.Context) {
+	iter := r.rdb.Scan(ctx, 0, jobKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		id := iter.Val()[len(jobKeyPrefix):]
+
+		r.mu.Lock()
+		_, mine := r.held[id]
+		r.mu.Unlock()
+		if mine {
+			continue
+		}
+
+		job, err := r.Get(ctx, id)
+		if err != nil || (job.Status != JobQueued && job.Status != JobRunning) {
+			continue
+		}
+
+		activeID, err := r.rdb.Get(ctx, activeKeyPrefix+job.Dataset).Result()
+		if err == nil && activeID == job.ID {
+			continue
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			log.Printf("checking ingestion job %s: %v", id, err)
+			continue
+		}
+
+		finished := time.Now()
+		job.Status = JobFailed
+		job.Errors = append(job.Errors, "interrupted: the server running the job stopped")
+		job.FinishedAt = &finished
+		r.update(ctx, job)
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("checking ingestion jobs: %v", err)
+	}
+}
+
+func (r *jobRunner) Get(ctx context.Context, id string) (*Job, error) {
+	raw, err := r.rdb.Get(ctx, jobKeyPrefix+id).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *jobRunner) work() {
+	for job := range r.queue {
+		r.run(job)
+	}
+}
+
+func (r *jobRunner) run(job *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+	defer r.release(job)
+
+	if err := r.rdb.Set(ctx, activeKeyPrefix+job.Dataset, job.ID, activeLease).Err(); err != nil {
+		log.Printf("renewing ingestion job %s: %v", job.ID, err)
+	}
+
+	started := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &started
+	r.update(ctx, job)
+
+	run := IngestionRun{
+		JobID:       job.ID,
+		DatasetID:   job.DatasetID,
+		RequestedBy: job.RequestedBy,
+		StartedAt:   started,
+	}
+
+	fingerprints, err := r.ingest(ctx, job, &run)
+	if err != nil {
+		log.Printf("ingestion job %s (%s) failed: %v", job.ID, job.Dataset, err)
+		job.Status = JobFailed
+		job.Errors = append(job.Errors, err.Error())
+		msg := err.Error()
+		run.Error = &msg
+	} else {
+		job.Status = JobSucceeded
+	}
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+
+	run.Status = job.Status
+	run.FinishedAt = finished
+	run.DurationMS = finished.Sub(started).Milliseconds()
+
+	runID, err := r.repo.StoreRun(context.Background(), run, fingerprints)
+	if err != nil {
+		log.Printf("recording ingestion run of job %s: %v", job.ID, err)
+	} else {
+		job.RunID, _ = util.Encrypt(strconv.FormatInt(runID, 10), "f")
+	}
+
+	r.update(context.Background(), job)
+
+	if job.Status == JobSucceeded {
+		r.index(job)
+	}
+	if job.Status == JobSucceeded && seedCheckDatasets[job.DatasetID] {
+		r.checkSeedSupply(job)
+	}
+}
+
+// index rebuilds the search index of the dataset. The records are already
+// stored, so a failure only leaves search on the previous index.
+func (r *jobRunner) index(job *Job) {
+	ds, ok := DatasetByID(job.DatasetID)
+	if !ok || r.indexer.es == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	count, err := r.indexer.Reindex(ctx, ds)
+	if err != nil {
+		log.Printf("indexing %s after job %s: %v", ds.Slug, job.ID, err)
+		return
+	}
+	log.Printf("indexed %d records of %s after job %s", count, ds.Slug, job.ID)
+}
+
+// checkSeedSupply re-runs the Perbenihan reconciliation after one of its
+// datasets changed and tells admins about new high severity findings.
+func (r *jobRunner) checkSeedSupply(job *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	summary, err := runSeedChecks(ctx, r.repo)
+	if err != nil {
+		log.Printf("seed supply check after job %s: %v", job.ID, err)
+		return
+	}
+	if summary.NewHigh == 0 {
+		return
+	}
+
+	msg := fmt.Sprintf("Ditemukan %d anomali baru tingkat tinggi pada data penyaluran benih setelah pembaruan %s.", summary.NewHigh, job.Dataset)
+	if err := r.repo.NotifyAdmins(ctx, "Anomali rantai pasok benih", msg); err != nil {
+		log.Printf("notifying admins of seed anomalies: %v", err)
+	}
+}
+
+// ingest fetches, validates and stores the dataset, filling run with the
+// provenance as it goes. It returns the fingerprints of the fetched rows.
+func (r *jobRunner) ingest(ctx context.Context, job *Job, run *IngestionRun) ([]RunRow, error) {
+	ds, ok := DatasetByID(job.DatasetID)
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %d", job.DatasetID)
+	}
+
+	src, ok := SourceByDatasetID(job.DatasetID)
+	if !ok {
+		return nil, fmt.Errorf("dataset %s has no upstream source", ds.Slug)
+	}
+	run.SourceURL = src.URL
+	run.Params = src.Params
+
+	tokens := r.tokens.For(src)
+	fetched, err := src.Fetch(ctx, tokens, func(pages, rows int) {
+		job.PagesFetched = pages
+		job.RowsFetched = rows
+		r.update(ctx, job)
+	})
+	run.HTTPStatus = fetched.StatusCode
+	run.ResponseHash = fetched.ResponseHash
+	run.Pages = fetched.Pages
+	run.RowsFetched = len(fetched.Rows)
+	if !fetched.FetchedAt.IsZero() {
+		run.FetchedAt = &fetched.FetchedAt
+	}
+	if errors.Is(err, ErrCredentialsRejected) {
+		r.tokens.Alert(ctx, tokens.credential, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	varieties, err := r.repo.GetVarieties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := ds.Validate(fetched.Rows, varieties)
+	res.Report.JobID = job.ID
+
+	res.RegionCodes, job.Unresolved, err = resolveRegions(ctx, r.reconciler, ds, res.Valid)
+	if err != nil {
+		return nil, fmt.Errorf("resolving regions: %w", err)
+	}
+
+	fingerprints := ds.Fingerprints(fetched.Rows)
+
+	reportID, err := r.repo.StoreDataset(ctx, ds, res)
+	if err != nil {
+		return fingerprints, err
+	}
+
+	job.RowsStored = len(res.Valid)
+	job.Quarantined = len(res.Quarantined)
+	job.ReportID, _ = util.Encrypt(strconv.FormatInt(reportID, 10), "f")
+	run.RowsStored = job.RowsStored
+	run.RowsQuarantined = job.Quarantined
+
+	return fingerprints, nil
+}
+
+// update persists the job and pushes the new snapshot to subscribers.
+// Failures are only logged; they must not abort the ingestion itself.
+func (r *jobRunner) update(ctx context.Context, job *Job) {
+	if err := r.save(ctx, job); err != nil {
+		log.Printf("saving ingestion job %s: %v", job.ID, err)
+	}
+	r.publish(ctx, job)
+}
+
+func (r *jobRunner) save(ctx context.Context, job *Job) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, jobKeyPrefix+job.ID, raw, jobTTL).Err()
+}
+
+func (r *jobRunner) publish(ctx context.Context, job *Job) {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return
+	}
+	if err := r.rdb.Publish(ctx, util.IngestJobChannel, raw).Err(); err != nil {
+		log.Printf("publishing ingestion job %s: %v", job.ID, err)
+	}
+}
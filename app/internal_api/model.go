@@ -1,54 +1,78 @@
 package internal_api
 
-import "time"
+import (
+	"encoding/json"
+	"time"
 
-var table = "ppt_api_data_storages"
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+var (
+	recordTable     = "ppt_dataset_records"
+	datasetTable    = "ppt_datasets"
+	varietyTable    = "ppt_varieties"
+	reportTable     = "ppt_dataset_quality_reports"
+	quarantineTable = "ppt_dataset_quarantine"
+	runTable        = "ppt_ingestion_runs"
+	runRowTable     = "ppt_ingestion_run_rows"
+	anomalyTable    = "ppt_seed_anomalies"
+)
 
 type (
-	PptApiDataStorage struct {
-		ID         interface{} `json:"id"`
-		Identifier interface{} `json:"identifier"`
-		F1         interface{} `json:"f1"`
-		F2         interface{} `json:"f2"`
-		F3         interface{} `json:"f3"`
-		F4         interface{} `json:"f4"`
-		F5         interface{} `json:"f5"`
-		F6         interface{} `json:"f6"`
-		F7         interface{} `json:"f7"`
-		F8         interface{} `json:"f8"`
-		F9         interface{} `json:"f9"`
-		F10        interface{} `json:"f10"`
-		F11        interface{} `json:"f11"`
-		F12        interface{} `json:"f12"`
-		F13        interface{} `json:"f13"`
-		F14        interface{} `json:"f14"`
-		F15        interface{} `json:"f15"`
-		F16        interface{} `json:"f16"`
-		F17        interface{} `json:"f17"`
-		F18        interface{} `json:"f18"`
-		F19        interface{} `json:"f19"`
-		F20        interface{} `json:"f20"`
-		F21        interface{} `json:"f21"`
-		F22        interface{} `json:"f22"`
-		F23        interface{} `json:"f23"`
-		F24        interface{} `json:"f24"`
-		F25        interface{} `json:"f25"`
-		LongText   interface{} `json:"longtext"`
-		CreatedAt  time.Time   `json:"created_at"`
-		UpdatedAt  time.Time   `json:"updated_at"`
+	DataWithPagination struct {
+		Row        []json.RawMessage       `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	QualityReportsWithPagination struct {
+		Row        []QualityReport         `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	RunsWithPagination struct {
+		Row        []IngestionRun          `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	SeedAnomaliesWithPagination struct {
+		Row        []SeedAnomaly           `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	QuarantineWithPagination struct {
+		Report     QualityReport           `json:"report"`
+		Row        []QuarantinedRow        `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	// DatasetQuery filters, orders and projects the records of one dataset.
+	// Empty members are not applied.
+	DatasetQuery struct {
+		Province    string
+		Regency     string
+		Subdistrict string
+		Region      string
+		Commodity   string
+		Variety     string
+		DateFrom    string
+		DateTo      string
+		Sort        []SortField
+		Fields      []string
+		Page        int
+		PageSize    int
+	}
+
+	SortField struct {
+		Key  string
+		Desc bool
 	}
 
-	Pelatihan struct {
-		StsIkut      interface{} `json:"sts_ikut"`
-		IdPel        interface{} `json:"id_pel"`
-		KodePel      interface{} `json:"kode_pel"`
-		JudulPel     interface{} `json:"judul_pel"`
-		LinkMateri   interface{} `json:"link_materi"`
-		TanggalMulai interface{} `json:"tanggal_mulai"`
-		JamMulai     interface{} `json:"jam_mulai"`
-		TanggalAkhir interface{} `json:"tanggal_akhir"`
-		JamAkhir     interface{} `json:"jam_akhir"`
-		LinkFlyer    interface{} `json:"link_flyer"`
+	DatasetRecord struct {
+		ID         int64           `json:"id"`
+		Identifier int             `json:"identifier"`
+		Data       json.RawMessage `json:"data"`
+		CreatedAt  time.Time       `json:"created_at"`
+		UpdatedAt  time.Time       `json:"updated_at"`
 	}
 
 	// untuk nasional, bpsb, dan lssm karena struktur mirip
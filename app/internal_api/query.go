@@ -0,0 +1,90 @@
+package internal_api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// jsonPath renders data->'key' (or data->>'key' when text is true). Keys
+// always come from the dataset registry, never straight from the request.
+func jsonPath(key string, text bool) string {
+	op := "->"
+	if text {
+		op = "->>"
+	}
+	return "data" + op + "'" + strings.ReplaceAll(key, "'", "''") + "'"
+}
+
+// datasetWhere builds the WHERE clause shared by the record listing and its
+// count, so the reported totals always match the rows that can be paged.
+func datasetWhere(ds Dataset, arg DatasetQuery) (string, []interface{}) {
+	args := []interface{}{ds.ID}
+	where := "WHERE identifier = $1"
+
+	match := func(key, value string) {
+		if key == "" || value == "" {
+			return
+		}
+		args = append(args, value)
+		where += fmt.Sprintf(" AND lower(%s) = lower($%d)", jsonPath(key, true), len(args))
+	}
+
+	match(ds.ProvinceKey, arg.Province)
+	match(ds.RegencyKey, arg.Regency)
+	match(ds.SubdistrictKey, arg.Subdistrict)
+	match(ds.CommodityKey, arg.Commodity)
+	match(ds.VarietyKey, arg.Variety)
+
+	// region matches the resolved kode and everything below it.
+	if arg.Region != "" {
+		args = append(args, arg.Region+"%")
+		where += fmt.Sprintf(" AND region_kode LIKE $%d", len(args))
+	}
+
+	// Dates are compared on their YYYY-MM-DD prefix so a date_to of
+	// 2023-09-30 still includes records stamped later that day.
+	if ds.DateKey != "" && arg.DateFrom != "" {
+		args = append(args, arg.DateFrom)
+		where += fmt.Sprintf(" AND left(%s, 10) >= $%d", jsonPath(ds.DateKey, true), len(args))
+	}
+	if ds.DateKey != "" && arg.DateTo != "" {
+		args = append(args, arg.DateTo)
+		where += fmt.Sprintf(" AND left(%s, 10) <= $%d", jsonPath(ds.DateKey, true), len(args))
+	}
+
+	return where, args
+}
+
+// datasetOrder orders on the JSONB values themselves so numeric fields sort
+// numerically. The record id is always appended to keep paging stable.
+func datasetOrder(ds Dataset, sort []SortField) string {
+	if len(sort) == 0 && ds.DateKey != "" {
+		sort = []SortField{{Key: ds.DateKey, Desc: true}}
+	}
+
+	terms := make([]string, 0, len(sort)+1)
+	for _, s := range sort {
+		dir := "ASC"
+		if s.Desc {
+			dir = "DESC"
+		}
+		terms = append(terms, jsonPath(s.Key, false)+" "+dir+" NULLS LAST")
+	}
+	terms = append(terms, "id")
+
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
+
+// datasetProjection selects the whole document, or only the requested keys.
+func datasetProjection(fields []string) string {
+	if len(fields) == 0 {
+		return "data"
+	}
+
+	parts := make([]string, 0, len(fields)*2)
+	for _, key := range fields {
+		parts = append(parts, "'"+strings.ReplaceAll(key, "'", "''")+"'", jsonPath(key, false))
+	}
+
+	return "jsonb_build_object(" + strings.Join(parts, ", ") + ")"
+}
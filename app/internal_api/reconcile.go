@@ -0,0 +1,61 @@
+package internal_api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gigaflex-co/ppt_backend/app/region"
+)
+
+// RegionNames picks the free text region names out of a normalized row.
+func (ds Dataset) RegionNames(data map[string]interface{}) region.Names {
+	name := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		s, _ := data[key].(string)
+		return strings.TrimSpace(s)
+	}
+
+	return region.Names{
+		Province:    name(ds.ProvinceKey),
+		Regency:     name(ds.RegencyKey),
+		Subdistrict: name(ds.SubdistrictKey),
+		Village:     name(ds.VillageKey),
+	}
+}
+
+// resolveRegions returns the deepest ppt_wilayah kode for every row, "" when
+// not even the province matched, and how many rows stopped short of the
+// names they carry. Upstream rows repeat the same names a lot, so each
+// distinct combination is resolved once.
+func resolveRegions(ctx context.Context, reconciler *region.Reconciler, ds Dataset, rows []map[string]interface{}) ([]string, int, error) {
+	codes := make([]string, len(rows))
+	unresolved := 0
+	memo := map[region.Names]region.Resolution{}
+
+	for i, row := range rows {
+		names := ds.RegionNames(row)
+		if names.Province == "" {
+			unresolved++
+			continue
+		}
+
+		res, ok := memo[names]
+		if !ok {
+			var err error
+			res, err = reconciler.Resolve(ctx, names)
+			if err != nil {
+				return nil, 0, err
+			}
+			memo[names] = res
+		}
+
+		codes[i] = res.Kode
+		if res.Unresolved != 0 {
+			unresolved++
+		}
+	}
+
+	return codes, unresolved, nil
+}
@@ -3,30 +3,47 @@ package internal_api
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gigaflex-co/ppt_backend/util"
 )
 
 type (
 	InternalApiRepository interface {
-		GetAll(c context.Context) ([]PptApiDataStorage, error)
+		GetAll(c context.Context) ([]DatasetRecord, error)
 		GetToken(c context.Context, key string) (string, error)
-		StoreSIPDPSTanamFetch(c context.Context, res []SIPDPSTanam, id int) error
-		StoreSIPDPSProduktivitasFetch(c context.Context, res []SIPDPSProduktivitas, id int) error
-		StoreSIPDPSPusoFetch(c context.Context, res []SIPDPSPuso, id int) error
-		StoreSIPDPSPanenFetch(c context.Context, res []SIPDPSPanen, id int) error
-
-		StorePerbenihanProdusenFetch(c context.Context, res []PerbenihanData4, id int) error
-		StorePerbenihanRekNasFetch(c context.Context, res []PerbenihanData1, id int) error
-		StorePerbenihanRekBpsbFetch(c context.Context, res []PerbenihanData1, id int) error
-		StorePerbenihanRekLssmFetch(c context.Context, res []PerbenihanData1, id int) error
-		StorePerbenihanRekPenyaluranFetch(c context.Context, res []PerbenihanData2, id int) error
-		StorePerbenihanRekPenyebaranFetch(c context.Context, res []PerbenihanData3, id int) error
-		StorePerbenihanRekProdusenFetch(c context.Context, res []PerbenihanData4, id int) error
-
-		CountRecords(c context.Context, arg util.DataFilter, id string) (int, error)
+		NotifyAdmins(c context.Context, title, message string) error
+		StoreDataset(c context.Context, ds Dataset, res ValidationResult) (int64, error)
+		GetVarieties(c context.Context) (Varieties, error)
+		GetSchema(c context.Context, id int) (json.RawMessage, error)
+
+		QueryDataset(c context.Context, ds Dataset, arg DatasetQuery) ([]json.RawMessage, error)
+		CountDataset(c context.Context, ds Dataset, arg DatasetQuery) (int, error)
+		Aggregate(c context.Context, ds Dataset, m Metric, arg DatasetQuery, groupBy, seriesBy string) ([]AggregateRow, error)
+		GeoPoints(c context.Context, ds Dataset, arg GeoQuery) ([]GeoRow, error)
+		EachRecord(c context.Context, ds Dataset, arg DatasetQuery, fn func(data map[string]interface{}) error) error
+		EachRegionRecord(c context.Context, ds Dataset, fn func(r RegionRecord) error) error
+
+		CountQualityReports(c context.Context, datasetID int) (int, error)
+		GetQualityReports(c context.Context, datasetID int, page, pageSize int) ([]QualityReport, error)
+		GetQualityReport(c context.Context, id int64) (QualityReport, error)
+		GetQuarantinedRows(c context.Context, reportID int64, page, pageSize int) ([]QuarantinedRow, error)
+		ReviewQualityReport(c context.Context, id int64, reviewer string) error
+
+		StoreRun(c context.Context, run IngestionRun, rows []RunRow) (int64, error)
+		CountRuns(c context.Context, datasetID int) (int, error)
+		GetRuns(c context.Context, datasetID int, page, pageSize int) ([]IngestionRun, error)
+		GetRun(c context.Context, id int64) (IngestionRun, error)
+		DiffRuns(c context.Context, from, to int64) (RunDiff, error)
+
+		StoreSeedAnomalies(c context.Context, anomalies []SeedAnomaly, passStarted time.Time) (SeedCheckSummary, error)
+		CountSeedAnomalies(c context.Context, f SeedAnomalyFilter) (int, error)
+		GetSeedAnomalies(c context.Context, f SeedAnomalyFilter, page, pageSize int) ([]SeedAnomaly, error)
+		ReviewSeedAnomaly(c context.Context, id int64, review SeedAnomalyReview, reviewer string) error
 
 		SIPDPSTanamRead(c context.Context, id int) ([]SIPDPSTanam, error)
 		SIPDPSProduktivitasRead(c context.Context, id int) ([]SIPDPSProduktivitas, error)
@@ -53,54 +70,36 @@ func NewRepository(db *sql.DB) InternalApiRepository {
 	}
 }
 
-func (q *repository) GetAll(c context.Context) ([]PptApiDataStorage, error) {
-	query := "SELECT * FROM ppt_api_data_storages"
+func (q *repository) GetAll(c context.Context) ([]DatasetRecord, error) {
+	query := "SELECT id, identifier, data, created_at, updated_at FROM " + recordTable + " ORDER BY id"
 	rows, err := q.db.QueryContext(c, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var data []PptApiDataStorage
+	data := []DatasetRecord{}
 	for rows.Next() {
-		var row PptApiDataStorage
+		var row DatasetRecord
+		var raw []byte
 		err := rows.Scan(
 			&row.ID,
 			&row.Identifier,
-			&row.F1,
-			&row.F2,
-			&row.F3,
-			&row.F4,
-			&row.F5,
-			&row.F6,
-			&row.F7,
-			&row.F8,
-			&row.F9,
-			&row.F10,
-			&row.F11,
-			&row.F12,
-			&row.F13,
-			&row.F14,
-			&row.F15,
-			&row.F16,
-			&row.F17,
-			&row.F18,
-			&row.F19,
-			&row.F20,
-			&row.F21,
-			&row.F22,
-			&row.F23,
-			&row.F24,
-			&row.F25,
-			&row.LongText,
+			&raw,
 			&row.CreatedAt,
 			&row.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		row.Data = json.RawMessage(raw)
 		data = append(data, row)
 	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
 
@@ -117,879 +116,411 @@ func (q *repository) GetToken(c context.Context, key string) (string, error) {
 	return value, nil
 }
 
-func (q *repository) StoreSIPDPSTanamFetch(c context.Context, res []SIPDPSTanam, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
-
-	numFields := 22
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
+// NotifyAdmins adds an in-app notification for every active admin.
+func (q *repository) NotifyAdmins(c context.Context, title, message string) error {
+	query := `
+	INSERT INTO ppt_notifications (user_id, title, message)
+	SELECT u.id, $1, $2 FROM ppt_users u
+	JOIN ppt_roles r ON r.id = u.role_id
+	WHERE u.is_active = true AND r.name = $3`
 
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
-
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
+	_, err := q.db.ExecContext(c, query, title, message, util.AdminRole)
+	return err
+}
 
-	stmt, err := q.db.Prepare(query)
+// StoreDataset replaces every record of the dataset with the valid rows and
+// records the run's quality report and quarantined rows. Everything shares
+// one transaction so readers never observe an empty dataset while a fetch
+// is in progress. It returns the id of the quality report.
+func (q *repository) StoreDataset(c context.Context, ds Dataset, res ValidationResult) (int64, error) {
+	schema, err := json.Marshal(ds.JSONSchema())
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer stmt.Close()
 
-	tx, err := q.db.Begin()
+	violations, err := json.Marshal(res.Report.Violations)
 	if err != nil {
-		return err
-	}
-
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NIPReporter
-		values[2] = item.NamaReporter
-		values[3] = item.TanggalLaporan
-		values[4] = item.TanggalKunjungan
-		values[5] = item.JenisKelompok
-		values[6] = item.NamaProvinsi
-		values[7] = item.NamaKabupaten
-		values[8] = item.NamaKecamatan
-		values[9] = item.NamaDesa
-		values[10] = item.KategoriLahan
-		values[11] = item.JenisLahan
-		values[12] = item.JenisTanamanPangan
-		values[13] = item.NamaVarietas
-		values[14] = item.JenisBantuan
-		values[15] = item.SumberBantuan
-		values[16] = item.TahunBantuan
-		values[17] = item.LuasArea
-		values[18] = item.HST
-		values[19] = item.Latitude
-		values[20] = item.Longitude
-		values[21] = item.Photos
-		values[22] = item.Status
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+		return 0, err
 	}
 
-	err = tx.Commit()
+	tx, err := q.db.BeginTx(c, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	return nil
-}
-
-func (q *repository) StoreSIPDPSProduktivitasFetch(c context.Context, res []SIPDPSProduktivitas, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
+	schemaQuery := "UPDATE " + datasetTable + " SET json_schema = $2, updated_at = NOW() WHERE identifier = $1"
+	if _, err := tx.ExecContext(c, schemaQuery, ds.ID, schema); err != nil {
+		return 0, err
 	}
 
-	numFields := 18
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
+	deleteQuery := "DELETE FROM " + recordTable + " WHERE identifier = $1"
+	if _, err := tx.ExecContext(c, deleteQuery, ds.ID); err != nil {
+		return 0, err
 	}
 
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
+	stmt, err := tx.PrepareContext(c, "INSERT INTO "+recordTable+" (identifier, data, region_kode) VALUES ($1, $2, $3)")
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer stmt.Close()
 
-	tx, err := q.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NIPReporter
-		values[2] = item.NamaReporter
-		values[3] = item.TanggalLaporan
-		values[4] = item.TanggalKunjungan
-		values[5] = item.NamaProvinsi
-		values[6] = item.NamaKabupaten
-		values[7] = item.NamaKecamatan
-		values[8] = item.NamaDesa
-		values[9] = item.KategoriLahan
-		values[10] = item.JenisLahan
-		values[11] = item.JenisTanamanPangan
-		values[12] = item.TeknikPengukuran
-		values[13] = item.Jumlah
-		values[14] = item.Latitude
-		values[15] = item.Longitude
-		values[16] = item.Photos
-		values[17] = item.NamaVerifikator
-		values[18] = item.Status
-
-		_, err := tx.Stmt(stmt).Exec(values...)
+	for i, item := range res.Valid {
+		doc, err := json.Marshal(item)
 		if err != nil {
-			tx.Rollback()
-			return err
+			return 0, err
 		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (q *repository) StoreSIPDPSPusoFetch(c context.Context, res []SIPDPSPuso, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
 
-	numFields := 15
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
+		var regionKode sql.NullString
+		if i < len(res.RegionCodes) && res.RegionCodes[i] != "" {
+			regionKode = sql.NullString{String: res.RegionCodes[i], Valid: true}
+		}
 
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
+		if _, err := stmt.ExecContext(c, ds.ID, doc, regionKode); err != nil {
+			return 0, err
+		}
 	}
 
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
+	reportQuery := `
+	INSERT INTO ` + reportTable + ` (job_id, identifier, total_rows, valid_rows, quarantined_rows, violations)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id`
+
+	var reportID int64
+	err = tx.QueryRowContext(c, reportQuery,
+		res.Report.JobID,
+		ds.ID,
+		res.Report.TotalRows,
+		res.Report.ValidRows,
+		res.Report.QuarantinedRows,
+		violations,
+	).Scan(&reportID)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer stmt.Close()
 
-	tx, err := q.db.Begin()
+	quarantineStmt, err := tx.PrepareContext(c, "INSERT INTO "+quarantineTable+" (report_id, identifier, data, violations) VALUES ($1, $2, $3, $4)")
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer quarantineStmt.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NIPReporter
-		values[2] = item.NamaReporter
-		values[3] = item.TanggalLaporan
-		values[4] = item.TanggalKejadian
-		values[5] = item.NamaProvinsi
-		values[6] = item.NamaKabupaten
-		values[7] = item.NamaKecamatan
-		values[8] = item.NamaDesa
-		values[9] = item.JenisTanamanPangan
-		values[10] = item.PenyebabPuso
-		values[11] = item.Latitude
-		values[12] = item.Longitude
-		values[13] = item.Photos
-		values[14] = item.NamaVerifikator
-		values[15] = item.Status
-
-		_, err := tx.Stmt(stmt).Exec(values...)
+	for _, row := range res.Quarantined {
+		doc, err := json.Marshal(row.Data)
 		if err != nil {
-			tx.Rollback()
-			return err
+			return 0, err
 		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (q *repository) StoreSIPDPSPanenFetch(c context.Context, res []SIPDPSPanen, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
-
-	numFields := 19
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
 
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
-
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	tx, err := q.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NIPReporter
-		values[2] = item.NamaReporter
-		values[3] = item.TanggalLaporan
-		values[4] = item.TanggalKunjungan
-		values[5] = item.NamaProvinsi
-		values[6] = item.NamaKabupaten
-		values[7] = item.NamaKecamatan
-		values[8] = item.NamaDesa
-		values[9] = item.JenisTanamanPangan
-		values[10] = item.NamaVarietas
-		values[11] = item.KategoriPengelola
-		values[12] = item.NamaPengelola
-		values[13] = item.Luas
-		values[14] = item.Perkiraan
-		values[15] = item.Latitude
-		values[16] = item.Longitude
-		values[17] = item.Photos
-		values[18] = item.NamaVerifikator
-		values[19] = item.Status
-
-		_, err := tx.Stmt(stmt).Exec(values...)
+		reasons, err := json.Marshal(row.Violations)
 		if err != nil {
-			tx.Rollback()
-			return err
+			return 0, err
 		}
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+		if _, err := quarantineStmt.ExecContext(c, reportID, ds.ID, doc, reasons); err != nil {
+			return 0, err
+		}
 	}
 
-	return nil
+	return reportID, tx.Commit()
 }
 
-func (q *repository) StorePerbenihanProdusenFetch(c context.Context, res []PerbenihanData4, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
-
-	numFields := 22
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
-
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	tx, err := q.db.Begin()
+func (q *repository) GetVarieties(c context.Context) (Varieties, error) {
+	rows, err := q.db.QueryContext(c, "SELECT lower(commodity), lower(name) FROM "+varietyTable)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.KODE_PROVINSI
-		values[3] = item.PROVINSI
-		values[4] = item.KABUPATENKOTA
-		values[5] = item.KECAMATAN
-		values[6] = item.KELURAHAN
-		values[7] = item.USERNAME
-		values[8] = item.IDSIMLUH
-		values[9] = item.NOMOR_REGISTRASI
-		values[10] = item.TIPE_PRODUSEN
-		values[11] = item.NAMA
-		values[12] = item.NAMA_PIMPINAN
-		values[13] = item.ALAMAT_PIMPINAN
-		values[14] = item.ALAMAT_PRODUSEN
-		values[15] = item.TELEPON
-		values[16] = item.EMAIL
-		values[17] = item.BENIH
-		values[18] = item.TOTAL_LUAS_LAHAN
-		values[19] = item.LAT
-		values[20] = item.LNG
-		values[21] = item.DICATAT
-		values[22] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
-			return err
+	varieties := Varieties{}
+	for rows.Next() {
+		var commodity, name string
+		if err := rows.Scan(&commodity, &name); err != nil {
+			return nil, err
+		}
+		if varieties[commodity] == nil {
+			varieties[commodity] = map[string]bool{}
 		}
+		varieties[commodity][name] = true
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return varieties, nil
 }
 
-func (q *repository) StorePerbenihanRekNasFetch(c context.Context, res []PerbenihanData1, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
+func (q *repository) GetSchema(c context.Context, id int) (json.RawMessage, error) {
+	query := "SELECT json_schema FROM " + datasetTable + " WHERE identifier = $1"
 
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
+	var raw []byte
+	if err := q.db.QueryRowContext(c, query, id).Scan(&raw); err != nil {
+		return nil, err
 	}
 
-	numFields := 11
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
+	return json.RawMessage(raw), nil
+}
 
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
+func (q *repository) QueryDataset(c context.Context, ds Dataset, arg DatasetQuery) ([]json.RawMessage, error) {
+	where, args := datasetWhere(ds, arg)
 
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
+	offset := (arg.Page - 1) * arg.PageSize
+	args = append(args, arg.PageSize, offset)
 
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	query := fmt.Sprintf("SELECT %s FROM %s %s %s LIMIT $%d OFFSET $%d",
+		datasetProjection(arg.Fields), recordTable, where, datasetOrder(ds, arg.Sort), len(args)-1, len(args))
 
-	tx, err := q.db.Begin()
+	rows, err := q.db.QueryContext(c, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.JENIS
-		values[3] = item.PROVINSI
-		values[4] = item.JENIS_BENIH
-		values[5] = item.KELAS_BENIH
-		values[6] = item.VARIETAS
-		values[7] = item.REALISASI_LUAS
-		values[8] = item.REALISASI_PRODUKSI
-		values[9] = item.VOLUME
-		values[10] = item.DICATAT
-		values[11] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
-			return err
+	data := []json.RawMessage{}
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
 		}
+		data = append(data, json.RawMessage(raw))
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return data, nil
 }
 
-func (q *repository) StorePerbenihanRekBpsbFetch(c context.Context, res []PerbenihanData1, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
-
-	numFields := 11
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
-
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+// EachRecord calls fn for every record matching arg, in arg's order, without
+// loading the whole result into memory. Paging and projection are ignored.
+func (q *repository) EachRecord(c context.Context, ds Dataset, arg DatasetQuery, fn func(data map[string]interface{}) error) error {
+	where, args := datasetWhere(ds, arg)
+	query := fmt.Sprintf("SELECT data FROM %s %s %s", recordTable, where, datasetOrder(ds, arg.Sort))
 
-	tx, err := q.db.Begin()
+	rows, err := q.db.QueryContext(c, query, args...)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.JENIS
-		values[3] = item.PROVINSI
-		values[4] = item.JENIS_BENIH
-		values[5] = item.KELAS_BENIH
-		values[6] = item.VARIETAS
-		values[7] = item.REALISASI_LUAS
-		values[8] = item.REALISASI_PRODUKSI
-		values[9] = item.VOLUME
-		values[10] = item.DICATAT
-		values[11] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
 			return err
 		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (q *repository) StorePerbenihanRekLssmFetch(c context.Context, res []PerbenihanData1, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
 
-	numFields := 11
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
-
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	tx, err := q.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.JENIS
-		values[3] = item.PROVINSI
-		values[4] = item.JENIS_BENIH
-		values[5] = item.KELAS_BENIH
-		values[6] = item.VARIETAS
-		values[7] = item.REALISASI_LUAS
-		values[8] = item.REALISASI_PRODUKSI
-		values[9] = item.VOLUME
-		values[10] = item.DICATAT
-		values[11] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
 			return err
 		}
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+		if err := fn(data); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return rows.Err()
 }
 
-func (q *repository) StorePerbenihanRekPenyaluranFetch(c context.Context, res []PerbenihanData2, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
-
-	numFields := 23
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
-	}
-
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+// EachRegionRecord streams every record of ds with its reconciled region.
+func (q *repository) EachRegionRecord(c context.Context, ds Dataset, fn func(r RegionRecord) error) error {
+	query := "SELECT data, COALESCE(region_kode, '') FROM " + recordTable + " WHERE identifier = $1 ORDER BY id"
 
-	tx, err := q.db.Begin()
+	rows, err := q.db.QueryContext(c, query, ds.ID)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.TAHUN
-		values[3] = item.BULAN
-		values[4] = item.PROVINSI
-		values[5] = item.KABUPATENKOTA
-		values[6] = item.KECAMATAN
-		values[7] = item.PRODUSEN_BENIH
-		values[8] = item.KELAS_BENIH
-		values[9] = item.KOMODITI
-		values[10] = item.VARIETAS
-		values[11] = item.STOK_LALU
-		values[12] = item.PRODUKSI_BENIH
-		values[13] = item.PENGADAAN
-		values[14] = item.JUMLAH_STOK
-		values[15] = item.PENYALURAN
-		values[16] = item.APBN
-		values[17] = item.APBD
-		values[18] = item.FREE_MARKET
-		values[19] = item.JUMLAH_SALUR
-		values[20] = item.TOTAL
-		values[21] = item.SISA_STOK
-		values[22] = item.DICATAT
-		values[23] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
+	for rows.Next() {
+		var raw []byte
+		var r RegionRecord
+		if err := rows.Scan(&raw, &r.RegionKode); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &r.Data); err != nil {
 			return err
 		}
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+		if err := fn(r); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return rows.Err()
 }
 
-func (q *repository) StorePerbenihanRekPenyebaranFetch(c context.Context, res []PerbenihanData3, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
-	if err != nil {
-		return err
-	}
+func (q *repository) CountDataset(c context.Context, ds Dataset, arg DatasetQuery) (int, error) {
+	where, args := datasetWhere(ds, arg)
+	query := "SELECT COUNT(*) FROM " + recordTable + " " + where
 
-	numFields := 15
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
+	var totalRecords int
+	if err := q.db.QueryRowContext(c, query, args...).Scan(&totalRecords); err != nil {
+		return 0, err
 	}
 
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
-
-	stmt, err := q.db.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	return totalRecords, nil
+}
 
-	tx, err := q.db.Begin()
+// GeoPoints returns up to maxGeoFeatures+1 points, or grid cells when arg is
+// clustered, so the caller can tell whether the result was cut off.
+func (q *repository) GeoPoints(c context.Context, ds Dataset, arg GeoQuery) ([]GeoRow, error) {
+	where, args := geoWhere(ds, arg)
+	lng, lat := geoCoordinate(ds.LngKey), geoCoordinate(ds.LatKey)
+	props := datasetProjection(arg.Properties)
+
+	var query string
+	if arg.Clustered() {
+		args = append(args, arg.CellSize())
+		query = fmt.Sprintf(`
+		SELECT avg(lng), avg(lat), count(*), CASE WHEN count(*) = 1 THEN (array_agg(props))[1] END
+		FROM (SELECT %s AS lng, %s AS lat, %s AS props FROM %s %s) p
+		GROUP BY floor(lng / $%d), floor(lat / $%d)
+		ORDER BY count(*) DESC
+		LIMIT %d`, lng, lat, props, recordTable, where, len(args), len(args), maxGeoFeatures+1)
+	} else {
+		query = fmt.Sprintf(`
+		SELECT %s, %s, 1, %s FROM %s %s
+		ORDER BY id
+		LIMIT %d`, lng, lat, props, recordTable, where, maxGeoFeatures+1)
+	}
+
+	rows, err := q.db.QueryContext(c, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.TAHUN
-		values[3] = item.BULAN
-		values[4] = item.PROVINSI
-		values[5] = item.KABUPATENKOTA
-		values[6] = item.KECAMATAN
-		values[7] = item.KELURAHAN
-		values[8] = item.PETA
-		values[9] = item.REALISASI_TANAM_LUAS
-		values[10] = item.BENIH
-		values[11] = item.JENIS_BENIH
-		values[12] = item.VARIETAS
-		values[13] = item.TOTAL_LUAS
-		values[14] = item.DICATAT
-		values[15] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
-			return err
+	items := []GeoRow{}
+	for rows.Next() {
+		var r GeoRow
+		var raw []byte
+		if err := rows.Scan(&r.Lng, &r.Lat, &r.Count, &raw); err != nil {
+			return nil, err
 		}
+		if raw != nil {
+			r.Properties = json.RawMessage(raw)
+		}
+		items = append(items, r)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return items, rows.Err()
 }
 
-func (q *repository) StorePerbenihanRekProdusenFetch(c context.Context, res []PerbenihanData4, id int) error {
-	deleteQuery := "DELETE FROM " + table + " WHERE identifier = $1"
-
-	_, err := q.db.Exec(deleteQuery, id)
+// Aggregate groups the filtered records of ds by the groupBy dimension, and
+// by seriesBy as well when it is set.
+func (q *repository) Aggregate(c context.Context, ds Dataset, m Metric, arg DatasetQuery, groupBy, seriesBy string) ([]AggregateRow, error) {
+	labelExpr, err := dimensionExpr(ds, groupBy)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	numFields := 22
-	placeholders := make([]string, numFields)
-	columns := make([]string, numFields)
-	values := make([]interface{}, numFields+1)
-
-	for i := 0; i < numFields; i++ {
-		placeholders[i] = "$" + strconv.Itoa(i+2)
-		columns[i] = "f" + strconv.Itoa(i+1)
+	seriesExpr := "''"
+	if seriesBy != "" {
+		if seriesExpr, err = dimensionExpr(ds, seriesBy); err != nil {
+			return nil, err
+		}
 	}
 
-	query := "INSERT INTO " + table + " (identifier, " + strings.Join(columns, ", ") + ") " +
-		"VALUES ($1, " + strings.Join(placeholders, ", ") + ")"
+	where, args := datasetWhere(ds, arg)
+	query := fmt.Sprintf(`
+	SELECT COALESCE(%s, '') AS label, COALESCE(%s, '') AS series, %s AS value
+	FROM %s %s
+	GROUP BY 1, 2
+	ORDER BY 1, 2`, labelExpr, seriesExpr, metricExpr(m), recordTable, where)
 
-	stmt, err := q.db.Prepare(query)
+	rows, err := q.db.QueryContext(c, query, args...)
 	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	tx, err := q.db.Begin()
-	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	for _, item := range res {
-		values[0] = id
-		values[1] = item.NO
-		values[2] = item.KODE_PROVINSI
-		values[3] = item.PROVINSI
-		values[4] = item.KABUPATENKOTA
-		values[5] = item.KECAMATAN
-		values[6] = item.KELURAHAN
-		values[7] = item.USERNAME
-		values[8] = item.IDSIMLUH
-		values[9] = item.NOMOR_REGISTRASI
-		values[10] = item.TIPE_PRODUSEN
-		values[11] = item.NAMA
-		values[12] = item.NAMA_PIMPINAN
-		values[13] = item.ALAMAT_PIMPINAN
-		values[14] = item.ALAMAT_PRODUSEN
-		values[15] = item.TELEPON
-		values[16] = item.EMAIL
-		values[17] = item.BENIH
-		values[18] = item.TOTAL_LUAS_LAHAN
-		values[19] = item.LAT
-		values[20] = item.LNG
-		values[21] = item.DICATAT
-		values[22] = item.DIPERBARUI
-
-		_, err := tx.Stmt(stmt).Exec(values...)
-		if err != nil {
-			tx.Rollback()
-			return err
+	data := []AggregateRow{}
+	for rows.Next() {
+		var row AggregateRow
+		if err := rows.Scan(&row.Label, &row.Series, &row.Value); err != nil {
+			return nil, err
 		}
+		data = append(data, row)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return data, nil
 }
 
-func (q *repository) CountRecords(c context.Context, arg util.DataFilter, id string) (int, error) {
-	args := make([]interface{}, 0)
-	query := `SELECT COUNT(*) FROM ` + table + ``
-
-	if arg.Search != "" {
-		query += ` WHERE lower(` + table + `.name) LIKE CONCAT('%%',$1::text,'%%')`
-		args = append(args, arg.Search)
-	}
-
-	query += ` AND identifier = ` + id + ``
+func (q *repository) CountQualityReports(c context.Context, datasetID int) (int, error) {
+	query := "SELECT COUNT(*) FROM " + reportTable + " WHERE ($1 = 0 OR identifier = $1)"
 
 	var totalRecords int
-	if err := q.db.QueryRowContext(c, query, args...).Scan(&totalRecords); err != nil {
+	if err := q.db.QueryRowContext(c, query, datasetID).Scan(&totalRecords); err != nil {
 		return 0, err
 	}
 
 	return totalRecords, nil
 }
 
-func (q *repository) SIPDPSTanamRead(c context.Context, id int) ([]SIPDPSTanam, error) {
-	columnNames := make([]string, 0, 22)
-	for i := 1; i <= 22; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
-
-	selectedColumns := strings.Join(columnNames, ", ")
-
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f3 DESC"
-
-	rows, err := q.db.QueryContext(c, query, id)
+const reportColumns = "id, COALESCE(job_id, ''), identifier, total_rows, valid_rows, quarantined_rows, violations, reviewed_by, reviewed_at, created_at"
+
+func scanQualityReport(row interface{ Scan(...interface{}) error }) (QualityReport, error) {
+	var r QualityReport
+	var id int64
+	var violations []byte
+
+	err := row.Scan(
+		&id,
+		&r.JobID,
+		&r.DatasetID,
+		&r.TotalRows,
+		&r.ValidRows,
+		&r.QuarantinedRows,
+		&violations,
+		&r.ReviewedBy,
+		&r.ReviewedAt,
+		&r.CreatedAt,
+	)
 	if err != nil {
-		return nil, err
+		return r, err
 	}
 
-	defer rows.Close()
-
-	items := []SIPDPSTanam{}
-
-	for rows.Next() {
-		var r SIPDPSTanam
-
-		if err := rows.Scan(
-			&r.NIPReporter,
-			&r.NamaReporter,
-			&r.TanggalLaporan,
-			&r.TanggalKunjungan,
-			&r.JenisKelompok,
-			&r.NamaProvinsi,
-			&r.NamaKabupaten,
-			&r.NamaKecamatan,
-			&r.NamaDesa,
-			&r.KategoriLahan,
-			&r.JenisLahan,
-			&r.JenisTanamanPangan,
-			&r.NamaVarietas,
-			&r.JenisBantuan,
-			&r.SumberBantuan,
-			&r.TahunBantuan,
-			&r.LuasArea,
-			&r.HST,
-			&r.Latitude,
-			&r.Longitude,
-			&r.Photos,
-			&r.Status,
-		); err != nil {
-			return nil, err
-		}
-
-		items = append(items, r)
+	if err := json.Unmarshal(violations, &r.Violations); err != nil {
+		return r, err
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	if ds, ok := DatasetByID(r.DatasetID); ok {
+		r.Dataset = ds.Slug
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+	r.ID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
 
-	return items, nil
+	return r, nil
 }
 
-func (q *repository) SIPDPSProduktivitasRead(c context.Context, id int) ([]SIPDPSProduktivitas, error) {
-	columnNames := make([]string, 0, 18)
-	for i := 1; i <= 18; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
-
-	selectedColumns := strings.Join(columnNames, ", ")
+func (q *repository) GetQualityReports(c context.Context, datasetID int, page, pageSize int) ([]QualityReport, error) {
+	query := `
+	SELECT ` + reportColumns + ` FROM ` + reportTable + `
+	WHERE ($1 = 0 OR identifier = $1)
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f3 DESC"
-
-	rows, err := q.db.QueryContext(c, query, id)
+	rows, err := q.db.QueryContext(c, query, datasetID, pageSize, (page-1)*pageSize)
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	items := []SIPDPSProduktivitas{}
-
+	items := []QualityReport{}
 	for rows.Next() {
-		var r SIPDPSProduktivitas
-
-		if err := rows.Scan(
-			&r.NIPReporter,
-			&r.NamaReporter,
-			&r.TanggalLaporan,
-			&r.TanggalKunjungan,
-			&r.NamaProvinsi,
-			&r.NamaKabupaten,
-			&r.NamaKecamatan,
-			&r.NamaDesa,
-			&r.KategoriLahan,
-			&r.JenisLahan,
-			&r.JenisTanamanPangan,
-			&r.TeknikPengukuran,
-			&r.Jumlah,
-			&r.Latitude,
-			&r.Longitude,
-			&r.Photos,
-			&r.NamaVerifikator,
-			&r.Status,
-		); err != nil {
+		r, err := scanQualityReport(rows)
+		if err != nil {
 			return nil, err
 		}
-
 		items = append(items, r)
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
@@ -997,53 +528,45 @@ func (q *repository) SIPDPSProduktivitasRead(c context.Context, id int) ([]SIPDP
 	return items, nil
 }
 
-func (q *repository) SIPDPSPusoRead(c context.Context, id int) ([]SIPDPSPuso, error) {
-	columnNames := make([]string, 0, 15)
-	for i := 1; i <= 15; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
-
-	selectedColumns := strings.Join(columnNames, ", ")
+func (q *repository) GetQualityReport(c context.Context, id int64) (QualityReport, error) {
+	query := "SELECT " + reportColumns + " FROM " + reportTable + " WHERE id = $1"
+	return scanQualityReport(q.db.QueryRowContext(c, query, id))
+}
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f3 DESC"
+func (q *repository) GetQuarantinedRows(c context.Context, reportID int64, page, pageSize int) ([]QuarantinedRow, error) {
+	query := `
+	SELECT id, data, violations, created_at FROM ` + quarantineTable + `
+	WHERE report_id = $1
+	ORDER BY id
+	LIMIT $2 OFFSET $3`
 
-	rows, err := q.db.QueryContext(c, query, id)
+	rows, err := q.db.QueryContext(c, query, reportID, pageSize, (page-1)*pageSize)
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	items := []SIPDPSPuso{}
-
+	items := []QuarantinedRow{}
 	for rows.Next() {
-		var r SIPDPSPuso
-
-		if err := rows.Scan(
-			&r.NIPReporter,
-			&r.NamaReporter,
-			&r.TanggalLaporan,
-			&r.TanggalKejadian,
-			&r.NamaProvinsi,
-			&r.NamaKabupaten,
-			&r.NamaKecamatan,
-			&r.NamaDesa,
-			&r.JenisTanamanPangan,
-			&r.PenyebabPuso,
-			&r.Latitude,
-			&r.Longitude,
-			&r.Photos,
-			&r.NamaVerifikator,
-			&r.Status,
-		); err != nil {
+		var r QuarantinedRow
+		var id int64
+		var data, violations []byte
+		var createdAt time.Time
+
+		if err := rows.Scan(&id, &data, &violations, &createdAt); err != nil {
 			return nil, err
 		}
 
-		items = append(items, r)
-	}
+		if err := json.Unmarshal(data, &r.Data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(violations, &r.Violations); err != nil {
+			return nil, err
+		}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+		r.ID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+		r.CreatedAt = &createdAt
+		items = append(items, r)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -1053,49 +576,46 @@ func (q *repository) SIPDPSPusoRead(c context.Context, id int) ([]SIPDPSPuso, er
 	return items, nil
 }
 
-func (q *repository) SIPDPSPanenRead(c context.Context, id int) ([]SIPDPSPanen, error) {
-	columnNames := make([]string, 0, 19)
-	for i := 1; i <= 19; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
+func (q *repository) ReviewQualityReport(c context.Context, id int64, reviewer string) error {
+	query := "UPDATE " + reportTable + " SET reviewed_by = $2, reviewed_at = NOW() WHERE id = $1"
+
+	res, err := q.db.ExecContext(c, query, id, reviewer)
+	if err != nil {
+		return err
 	}
 
-	selectedColumns := strings.Join(columnNames, ", ")
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f3 DESC"
+	return nil
+}
 
-	rows, err := q.db.QueryContext(c, query, id)
+// readRecords decodes the JSONB documents returned by query into T. The
+// dataset structs carry the upstream JSON tags, so a document maps onto
+// them without any column bookkeeping.
+func readRecords[T any](c context.Context, db *sql.DB, query string, args ...interface{}) ([]T, error) {
+	rows, err := db.QueryContext(c, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 
-	items := []SIPDPSPanen{}
+	items := []T{}
 
 	for rows.Next() {
-		var r SIPDPSPanen
-
-		if err := rows.Scan(
-			&r.NIPReporter,
-			&r.NamaReporter,
-			&r.TanggalLaporan,
-			&r.TanggalKunjungan,
-			&r.NamaProvinsi,
-			&r.NamaKabupaten,
-			&r.NamaKecamatan,
-			&r.NamaDesa,
-			&r.JenisTanamanPangan,
-			&r.NamaVarietas,
-			&r.KategoriPengelola,
-			&r.NamaPengelola,
-			&r.Luas,
-			&r.Perkiraan,
-			&r.Latitude,
-			&r.Longitude,
-			&r.Photos,
-			&r.NamaVerifikator,
-			&r.Status,
-		); err != nil {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+
+		var r T
+		if err := json.Unmarshal(raw, &r); err != nil {
 			return nil, err
 		}
 
@@ -1113,218 +633,222 @@ func (q *repository) SIPDPSPanenRead(c context.Context, id int) ([]SIPDPSPanen,
 	return items, nil
 }
 
-func (q *repository) PerbenihanProdusenRead(c context.Context, id int) ([]PerbenihanData4, error) {
-	columnNames := make([]string, 0, 22)
-	for i := 1; i <= 22; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
+func (q *repository) SIPDPSTanamRead(c context.Context, id int) ([]SIPDPSTanam, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'tgl_lapor' DESC"
+	return readRecords[SIPDPSTanam](c, q.db, query, id)
+}
 
-	selectedColumns := strings.Join(columnNames, ", ")
+func (q *repository) SIPDPSProduktivitasRead(c context.Context, id int) ([]SIPDPSProduktivitas, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'tgl_lapor' DESC"
+	return readRecords[SIPDPSProduktivitas](c, q.db, query, id)
+}
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 AND LENGTH(f15) > 2 AND LENGTH(f15) < 15 AND f19 IS NOT NULL AND f20 IS NOT NULL ORDER BY f22 DESC LIMIT 1000"
+func (q *repository) SIPDPSPusoRead(c context.Context, id int) ([]SIPDPSPuso, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'tgl_lapor' DESC"
+	return readRecords[SIPDPSPuso](c, q.db, query, id)
+}
 
-	rows, err := q.db.QueryContext(c, query, id)
-	if err != nil {
-		return nil, err
-	}
+func (q *repository) SIPDPSPanenRead(c context.Context, id int) ([]SIPDPSPanen, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'tgl_lapor' DESC"
+	return readRecords[SIPDPSPanen](c, q.db, query, id)
+}
 
-	defer rows.Close()
+func (q *repository) PerbenihanProdusenRead(c context.Context, id int) ([]PerbenihanData4, error) {
+	query := `
+	SELECT data FROM ` + recordTable + `
+	WHERE identifier = $1
+	AND LENGTH(data->>'TELEPON') > 2
+	AND LENGTH(data->>'TELEPON') < 15
+	AND data->>'LAT' IS NOT NULL
+	AND data->>'LNG' IS NOT NULL
+	ORDER BY data->>'DIPERBARUI' DESC
+	LIMIT 1000`
+	return readRecords[PerbenihanData4](c, q.db, query, id)
+}
 
-	items := []PerbenihanData4{}
+func (q *repository) PerbenihanRekNasRead(c context.Context, id int) ([]PerbenihanData1, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'DIPERBARUI' DESC"
+	return readRecords[PerbenihanData1](c, q.db, query, id)
+}
 
-	for rows.Next() {
-		var r PerbenihanData4
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.KODE_PROVINSI,
-			&r.PROVINSI,
-			&r.KABUPATENKOTA,
-			&r.KECAMATAN,
-			&r.KELURAHAN,
-			&r.USERNAME,
-			&r.IDSIMLUH,
-			&r.NOMOR_REGISTRASI,
-			&r.TIPE_PRODUSEN,
-			&r.NAMA,
-			&r.NAMA_PIMPINAN,
-			&r.ALAMAT_PIMPINAN,
-			&r.ALAMAT_PRODUSEN,
-			&r.TELEPON,
-			&r.EMAIL,
-			&r.BENIH,
-			&r.TOTAL_LUAS_LAHAN,
-			&r.LAT,
-			&r.LNG,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
-			return nil, err
-		}
+func (q *repository) PerbenihanRekBpsbRead(c context.Context, id int) ([]PerbenihanData1, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'DIPERBARUI' DESC"
+	return readRecords[PerbenihanData1](c, q.db, query, id)
+}
 
-		items = append(items, r)
-	}
+func (q *repository) PerbenihanRekLssmRead(c context.Context, id int) ([]PerbenihanData1, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'DIPERBARUI' DESC"
+	return readRecords[PerbenihanData1](c, q.db, query, id)
+}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
+func (q *repository) PerbenihanRekPenyaluranRead(c context.Context, id int) ([]PerbenihanData2, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->'STOK_LALU' DESC"
+	return readRecords[PerbenihanData2](c, q.db, query, id)
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+func (q *repository) PerbenihanRekPenyebaranRead(c context.Context, id int) ([]PerbenihanData3, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'JENIS_BENIH' DESC"
+	return readRecords[PerbenihanData3](c, q.db, query, id)
+}
 
-	return items, nil
+func (q *repository) PerbenihanRekProdusenRead(c context.Context, id int) ([]PerbenihanData4, error) {
+	query := "SELECT data FROM " + recordTable + " WHERE identifier = $1 ORDER BY data->>'NAMA' DESC"
+	return readRecords[PerbenihanData4](c, q.db, query, id)
 }
 
-func (q *repository) PerbenihanRekNasRead(c context.Context, id int) ([]PerbenihanData1, error) {
-	columnNames := make([]string, 0, 11)
-	for i := 1; i <= 11; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
+// StoreRun records the run and the fingerprints of the rows it fetched in
+// one transaction, and drops the fingerprints of the dataset's runs beyond
+// the latest keptRunFingerprints.
+func (q *repository) StoreRun(c context.Context, run IngestionRun, rows []RunRow) (int64, error) {
+	params, err := json.Marshal(run.Params)
+	if err != nil {
+		return 0, err
 	}
 
-	selectedColumns := strings.Join(columnNames, ", ")
-
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f11 DESC"
-
-	rows, err := q.db.QueryContext(c, query, id)
+	tx, err := q.db.BeginTx(c, nil)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO ` + runTable + ` (job_id, identifier, status, error, source_url, params, http_status, response_hash,
+		pages, rows_fetched, rows_stored, rows_quarantined, requested_by, started_at, fetched_at, finished_at, duration_ms)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), NULLIF($8, ''), $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	RETURNING id`
+
+	var id int64
+	err = tx.QueryRowContext(c, query,
+		run.JobID,
+		run.DatasetID,
+		run.Status,
+		run.Error,
+		run.SourceURL,
+		params,
+		run.HTTPStatus,
+		run.ResponseHash,
+		run.Pages,
+		run.RowsFetched,
+		run.RowsStored,
+		run.RowsQuarantined,
+		run.RequestedBy,
+		run.StartedAt,
+		run.FetchedAt,
+		run.FinishedAt,
+		run.DurationMS,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
 	}
 
-	defer rows.Close()
-
-	items := []PerbenihanData1{}
-
-	for rows.Next() {
-		var r PerbenihanData1
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.JENIS,
-			&r.PROVINSI,
-			&r.JENIS_BENIH,
-			&r.KELAS_BENIH,
-			&r.VARIETAS,
-			&r.REALISASI_LUAS,
-			&r.REALISASI_PRODUKSI,
-			&r.VOLUME,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
-			return nil, err
-		}
-
-		items = append(items, r)
+	stmt, err := tx.PrepareContext(c, "INSERT INTO "+runRowTable+" (run_id, row_key, row_hash) VALUES ($1, $2, $3)")
+	if err != nil {
+		return 0, err
 	}
+	defer stmt.Close()
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(c, id, row.Key, row.Hash); err != nil {
+			return 0, err
+		}
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	pruneQuery := `
+	WITH pruned AS (
+		UPDATE ` + runTable + ` SET rows_pruned = true
+		WHERE identifier = $1 AND NOT rows_pruned AND id NOT IN (
+			SELECT id FROM ` + runTable + ` WHERE identifier = $1 ORDER BY started_at DESC, id DESC LIMIT $2
+		)
+		RETURNING id
+	)
+	DELETE FROM ` + runRowTable + ` WHERE run_id IN (SELECT id FROM pruned)`
+	if _, err := tx.ExecContext(c, pruneQuery, run.DatasetID, keptRunFingerprints); err != nil {
+		return 0, err
 	}
 
-	return items, nil
+	return id, tx.Commit()
 }
 
-func (q *repository) PerbenihanRekBpsbRead(c context.Context, id int) ([]PerbenihanData1, error) {
-	columnNames := make([]string, 0, 11)
-	for i := 1; i <= 11; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
+func (q *repository) CountRuns(c context.Context, datasetID int) (int, error) {
+	query := "SELECT COUNT(*) FROM " + runTable + " WHERE ($1 = 0 OR identifier = $1)"
 
-	selectedColumns := strings.Join(columnNames, ", ")
+	var totalRecords int
+	if err := q.db.QueryRowContext(c, query, datasetID).Scan(&totalRecords); err != nil {
+		return 0, err
+	}
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f11 DESC"
+	return totalRecords, nil
+}
 
-	rows, err := q.db.QueryContext(c, query, id)
+const runColumns = `id, COALESCE(job_id, ''), identifier, status, error, source_url, params, COALESCE(http_status, 0),
+	COALESCE(response_hash, ''), pages, rows_fetched, rows_stored, rows_quarantined, COALESCE(requested_by, ''),
+	started_at, fetched_at, finished_at, duration_ms, rows_pruned`
+
+func scanRun(row interface{ Scan(...interface{}) error }) (IngestionRun, error) {
+	var r IngestionRun
+	var id int64
+	var params []byte
+
+	err := row.Scan(
+		&id,
+		&r.JobID,
+		&r.DatasetID,
+		&r.Status,
+		&r.Error,
+		&r.SourceURL,
+		&params,
+		&r.HTTPStatus,
+		&r.ResponseHash,
+		&r.Pages,
+		&r.RowsFetched,
+		&r.RowsStored,
+		&r.RowsQuarantined,
+		&r.RequestedBy,
+		&r.StartedAt,
+		&r.FetchedAt,
+		&r.FinishedAt,
+		&r.DurationMS,
+		&r.RowsPruned,
+	)
 	if err != nil {
-		return nil, err
+		return r, err
 	}
 
-	defer rows.Close()
-
-	items := []PerbenihanData1{}
-
-	for rows.Next() {
-		var r PerbenihanData1
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.JENIS,
-			&r.PROVINSI,
-			&r.JENIS_BENIH,
-			&r.KELAS_BENIH,
-			&r.VARIETAS,
-			&r.REALISASI_LUAS,
-			&r.REALISASI_PRODUKSI,
-			&r.VOLUME,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
-			return nil, err
-		}
-
-		items = append(items, r)
+	if err := json.Unmarshal(params, &r.Params); err != nil {
+		return r, err
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	if ds, ok := DatasetByID(r.DatasetID); ok {
+		r.Dataset = ds.Slug
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+	r.ID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
 
-	return items, nil
+	return r, nil
 }
 
-func (q *repository) PerbenihanRekLssmRead(c context.Context, id int) ([]PerbenihanData1, error) {
-	columnNames := make([]string, 0, 11)
-	for i := 1; i <= 11; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
-
-	selectedColumns := strings.Join(columnNames, ", ")
-
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f11 DESC"
+func (q *repository) GetRuns(c context.Context, datasetID int, page, pageSize int) ([]IngestionRun, error) {
+	query := `
+	SELECT ` + runColumns + ` FROM ` + runTable + `
+	WHERE ($1 = 0 OR identifier = $1)
+	ORDER BY started_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
 
-	rows, err := q.db.QueryContext(c, query, id)
+	rows, err := q.db.QueryContext(c, query, datasetID, pageSize, (page-1)*pageSize)
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	items := []PerbenihanData1{}
-
+	items := []IngestionRun{}
 	for rows.Next() {
-		var r PerbenihanData1
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.JENIS,
-			&r.PROVINSI,
-			&r.JENIS_BENIH,
-			&r.KELAS_BENIH,
-			&r.VARIETAS,
-			&r.REALISASI_LUAS,
-			&r.REALISASI_PRODUKSI,
-			&r.VOLUME,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
+		r, err := scanRun(rows)
+		if err != nil {
 			return nil, err
 		}
-
 		items = append(items, r)
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
@@ -1332,185 +856,235 @@ func (q *repository) PerbenihanRekLssmRead(c context.Context, id int) ([]Perbeni
 	return items, nil
 }
 
-func (q *repository) PerbenihanRekPenyaluranRead(c context.Context, id int) ([]PerbenihanData2, error) {
-	columnNames := make([]string, 0, 23)
-	for i := 1; i <= 23; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
+func (q *repository) GetRun(c context.Context, id int64) (IngestionRun, error) {
+	query := "SELECT " + runColumns + " FROM " + runTable + " WHERE id = $1"
+	return scanRun(q.db.QueryRowContext(c, query, id))
+}
+
+// DiffRuns matches the row fingerprints of two runs on their identity. Only
+// the counts and a few sample keys of each kind are returned.
+func (q *repository) DiffRuns(c context.Context, from, to int64) (RunDiff, error) {
+	var d RunDiff
 
-	selectedColumns := strings.Join(columnNames, ", ")
+	joined := `
+	FROM (SELECT row_key, row_hash FROM ` + runRowTable + ` WHERE run_id = $1) a
+	FULL OUTER JOIN (SELECT row_key, row_hash FROM ` + runRowTable + ` WHERE run_id = $2) b ON a.row_key = b.row_key`
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f11 DESC"
+	countQuery := `
+	SELECT
+		COUNT(*) FILTER (WHERE a.row_key IS NULL),
+		COUNT(*) FILTER (WHERE b.row_key IS NULL),
+		COUNT(*) FILTER (WHERE a.row_hash <> b.row_hash),
+		COUNT(*) FILTER (WHERE a.row_hash = b.row_hash)` + joined
 
-	rows, err := q.db.QueryContext(c, query, id)
+	err := q.db.QueryRowContext(c, countQuery, from, to).Scan(&d.Added, &d.Removed, &d.Changed, &d.Unchanged)
 	if err != nil {
-		return nil, err
+		return d, err
 	}
 
-	defer rows.Close()
+	samples := func(cond string) ([]string, error) {
+		query := `SELECT COALESCE(a.row_key, b.row_key)` + joined + ` WHERE ` + cond + `
+		ORDER BY 1 LIMIT ` + strconv.Itoa(maxDiffSamples)
 
-	items := []PerbenihanData2{}
-
-	for rows.Next() {
-		var r PerbenihanData2
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.TAHUN,
-			&r.BULAN,
-			&r.PROVINSI,
-			&r.KABUPATENKOTA,
-			&r.KECAMATAN,
-			&r.PRODUSEN_BENIH,
-			&r.KELAS_BENIH,
-			&r.KOMODITI,
-			&r.VARIETAS,
-			&r.STOK_LALU,
-			&r.PRODUKSI_BENIH,
-			&r.PENGADAAN,
-			&r.JUMLAH_STOK,
-			&r.PENYALURAN,
-			&r.APBN,
-			&r.APBD,
-			&r.FREE_MARKET,
-			&r.JUMLAH_SALUR,
-			&r.TOTAL,
-			&r.SISA_STOK,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
+		rows, err := q.db.QueryContext(c, query, from, to)
+		if err != nil {
 			return nil, err
 		}
-
-		items = append(items, r)
+		defer rows.Close()
+
+		keys := []string{}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		return keys, rows.Err()
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	if d.Samples.Added, err = samples("a.row_key IS NULL"); err != nil {
+		return d, err
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
+	if d.Samples.Removed, err = samples("b.row_key IS NULL"); err != nil {
+		return d, err
+	}
+	if d.Samples.Changed, err = samples("a.row_hash <> b.row_hash"); err != nil {
+		return d, err
 	}
 
-	return items, nil
+	return d, nil
 }
 
-func (q *repository) PerbenihanRekPenyebaranRead(c context.Context, id int) ([]PerbenihanData3, error) {
-	columnNames := make([]string, 0, 15)
-	for i := 1; i <= 15; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
+// StoreSeedAnomalies upserts the findings of one reconciliation pass and
+// resolves the open ones it no longer found. Dismissed findings stay
+// dismissed; resolved ones that reappear are opened again.
+func (q *repository) StoreSeedAnomalies(c context.Context, anomalies []SeedAnomaly, passStarted time.Time) (SeedCheckSummary, error) {
+	summary := SeedCheckSummary{
+		Anomalies:  len(anomalies),
+		BySeverity: map[string]int{SeverityLow: 0, SeverityMedium: 0, SeverityHigh: 0},
 	}
 
-	selectedColumns := strings.Join(columnNames, ", ")
+	tx, err := q.db.BeginTx(c, nil)
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO ` + anomalyTable + ` (fingerprint, check_name, severity, region_kode, region, producer, commodity, variety,
+		year, month, expected, actual, difference, message, detail, detected_at, last_seen_at)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''),
+		NULLIF($9, 0), NULLIF($10, 0), $11, $12, $13, $14, $15, $16, $16)
+	ON CONFLICT (fingerprint) DO UPDATE SET
+		severity = EXCLUDED.severity,
+		region_kode = EXCLUDED.region_kode,
+		region = EXCLUDED.region,
+		expected = EXCLUDED.expected,
+		actual = EXCLUDED.actual,
+		difference = EXCLUDED.difference,
+		message = EXCLUDED.message,
+		detail = EXCLUDED.detail,
+		last_seen_at = EXCLUDED.last_seen_at,
+		status = CASE WHEN ` + anomalyTable + `.status = '` + AnomalyResolved + `' THEN '` + AnomalyOpen + `' ELSE ` + anomalyTable + `.status END,
+		resolved_at = NULL
+	RETURNING (xmax = 0)`
+
+	now := time.Now()
+	for _, a := range anomalies {
+		detail, err := json.Marshal(a.Detail)
+		if err != nil {
+			return summary, err
+		}
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f11 DESC"
+		var inserted bool
+		err = tx.QueryRowContext(c, query,
+			a.Fingerprint, a.Check, a.Severity, a.RegionKode, a.Region, a.Producer, a.Commodity, a.Variety,
+			a.Year, a.Month, a.Expected, a.Actual, a.Difference, a.Message, detail, now,
+		).Scan(&inserted)
+		if err != nil {
+			return summary, err
+		}
 
-	rows, err := q.db.QueryContext(c, query, id)
-	if err != nil {
-		return nil, err
+		summary.BySeverity[a.Severity]++
+		if inserted {
+			summary.New++
+			if a.Severity == SeverityHigh {
+				summary.NewHigh++
+			}
+		}
 	}
 
-	defer rows.Close()
+	resolveQuery := `
+	UPDATE ` + anomalyTable + ` SET status = $1, resolved_at = NOW()
+	WHERE last_seen_at < $2 AND status IN ($3, $4)`
 
-	items := []PerbenihanData3{}
+	res, err := tx.ExecContext(c, resolveQuery, AnomalyResolved, passStarted, AnomalyOpen, AnomalyAcknowledged)
+	if err != nil {
+		return summary, err
+	}
+	resolved, _ := res.RowsAffected()
+	summary.Resolved = int(resolved)
 
-	for rows.Next() {
-		var r PerbenihanData3
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.TAHUN,
-			&r.BULAN,
-			&r.PROVINSI,
-			&r.KABUPATENKOTA,
-			&r.KECAMATAN,
-			&r.KELURAHAN,
-			&r.PETA,
-			&r.REALISASI_TANAM_LUAS,
-			&r.BENIH,
-			&r.JENIS_BENIH,
-			&r.VARIETAS,
-			&r.TOTAL_LUAS,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
-			return nil, err
-		}
+	return summary, tx.Commit()
+}
 
-		items = append(items, r)
+func seedAnomalyWhere(f SeedAnomalyFilter) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	if f.Check != "" {
+		add("check_name = $%d", f.Check)
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
+	if f.Severity != "" {
+		add("severity = $%d", f.Severity)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.Region != "" {
+		add("region_kode LIKE $%d", f.Region+"%")
 	}
 
-	return items, nil
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
 }
 
-func (q *repository) PerbenihanRekProdusenRead(c context.Context, id int) ([]PerbenihanData4, error) {
-	columnNames := make([]string, 0, 22)
-	for i := 1; i <= 22; i++ {
-		columnNames = append(columnNames, "f"+strconv.Itoa(i))
-	}
+func (q *repository) CountSeedAnomalies(c context.Context, f SeedAnomalyFilter) (int, error) {
+	where, args := seedAnomalyWhere(f)
+
+	var total int
+	err := q.db.QueryRowContext(c, "SELECT COUNT(*) FROM "+anomalyTable+" "+where, args...).Scan(&total)
+	return total, err
+}
 
-	selectedColumns := strings.Join(columnNames, ", ")
+// GetSeedAnomalies lists the most severe findings first.
+func (q *repository) GetSeedAnomalies(c context.Context, f SeedAnomalyFilter, page, pageSize int) ([]SeedAnomaly, error) {
+	where, args := seedAnomalyWhere(f)
+	args = append(args, pageSize, (page-1)*pageSize)
 
-	query := "SELECT " + selectedColumns + " FROM " + table + " WHERE identifier = $1 ORDER BY f11 DESC"
+	query := fmt.Sprintf(`
+	SELECT id, check_name, severity, status, region_kode, COALESCE(region, ''), COALESCE(producer, ''),
+		COALESCE(commodity, ''), COALESCE(variety, ''), COALESCE(year, 0), COALESCE(month, 0),
+		expected, actual, difference, message, detail, note, reviewed_by, reviewed_at, detected_at, last_seen_at
+	FROM %s %s
+	ORDER BY CASE severity WHEN '%s' THEN 0 WHEN '%s' THEN 1 ELSE 2 END, last_seen_at DESC, id DESC
+	LIMIT $%d OFFSET $%d`, anomalyTable, where, SeverityHigh, SeverityMedium, len(args)-1, len(args))
 
-	rows, err := q.db.QueryContext(c, query, id)
+	rows, err := q.db.QueryContext(c, query, args...)
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	items := []PerbenihanData4{}
-
+	items := []SeedAnomaly{}
 	for rows.Next() {
-		var r PerbenihanData4
-
-		if err := rows.Scan(
-			&r.NO,
-			&r.KODE_PROVINSI,
-			&r.PROVINSI,
-			&r.KABUPATENKOTA,
-			&r.KECAMATAN,
-			&r.KELURAHAN,
-			&r.USERNAME,
-			&r.IDSIMLUH,
-			&r.NOMOR_REGISTRASI,
-			&r.TIPE_PRODUSEN,
-			&r.NAMA,
-			&r.NAMA_PIMPINAN,
-			&r.ALAMAT_PIMPINAN,
-			&r.ALAMAT_PRODUSEN,
-			&r.TELEPON,
-			&r.EMAIL,
-			&r.BENIH,
-			&r.TOTAL_LUAS_LAHAN,
-			&r.LAT,
-			&r.LNG,
-			&r.DICATAT,
-			&r.DIPERBARUI,
-		); err != nil {
+		var r SeedAnomaly
+		var id int64
+		var detail []byte
+
+		err := rows.Scan(&id, &r.Check, &r.Severity, &r.Status, &r.RegionKode, &r.Region, &r.Producer,
+			&r.Commodity, &r.Variety, &r.Year, &r.Month, &r.Expected, &r.Actual, &r.Difference, &r.Message,
+			&detail, &r.Note, &r.ReviewedBy, &r.ReviewedAt, &r.DetectedAt, &r.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(detail, &r.Detail); err != nil {
 			return nil, err
 		}
 
+		r.ID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
 		items = append(items, r)
 	}
 
-	if err := rows.Close(); err != nil {
-		return nil, err
+	return items, rows.Err()
+}
+
+// ReviewSeedAnomaly records the reviewer's verdict. Resolved findings are
+// closed by the reconciliation itself and cannot be reviewed.
+func (q *repository) ReviewSeedAnomaly(c context.Context, id int64, review SeedAnomalyReview, reviewer string) error {
+	query := `
+	UPDATE ` + anomalyTable + ` SET status = $2, note = NULLIF($3, ''), reviewed_by = $4, reviewed_at = NOW()
+	WHERE id = $1 AND status <> $5`
+
+	res, err := q.db.ExecContext(c, query, id, review.Status, review.Note, reviewer, AnomalyResolved)
+	if err != nil {
+		return err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
 	}
 
-	return items, nil
+	return nil
 }
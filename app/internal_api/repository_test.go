@@ -0,0 +1,109 @@
+package internal_api
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// testDB opens the migrated database named by PPT_TEST_PSQL_SOURCE. The
+// tests write ingestion runs and prune old ones, so point it at a
+// throwaway database.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	source := os.Getenv("PPT_TEST_PSQL_SOURCE")
+	if source == "" {
+		t.Skip("PPT_TEST_PSQL_SOURCE not set")
+	}
+
+	db, err := sql.Open("postgres", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func storeTestRun(t *testing.T, repo InternalApiRepository, db *sql.DB, datasetID int, startedAt time.Time, rows []map[string]interface{}) int64 {
+	t.Helper()
+
+	ds, _ := DatasetByID(datasetID)
+	id, err := repo.StoreRun(context.Background(), IngestionRun{
+		DatasetID:  datasetID,
+		Status:     JobSucceeded,
+		SourceURL:  "http://mock.test",
+		Params:     map[string]string{},
+		StartedAt:  startedAt,
+		FinishedAt: startedAt,
+	}, ds.Fingerprints(rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM "+runTable+" WHERE id = $1", id) })
+	return id
+}
+
+func TestDiffRuns(t *testing.T) {
+	db := testDB(t)
+	repo := NewRepository(db)
+
+	producer := func(name, luas string) map[string]interface{} {
+		return map[string]interface{}{"PROVINSI": "JAWA BARAT", "NOMOR_REGISTRASI": "32/PB/" + name, "NAMA": name, "TOTAL_LUAS_LAHAN": luas}
+	}
+	now := time.Now()
+
+	from := storeTestRun(t, repo, db, PerbenihanProdusenID, now, []map[string]interface{}{
+		producer("A", "10"), producer("B", "20"), producer("C", "30"),
+	})
+	to := storeTestRun(t, repo, db, PerbenihanProdusenID, now.Add(time.Second), []map[string]interface{}{
+		producer("A", "10"), producer("B", "25"), producer("D", "40"), producer("E", "50"),
+	})
+
+	d, err := repo.DiffRuns(context.Background(), from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Added != 2 || d.Removed != 1 || d.Changed != 1 || d.Unchanged != 1 {
+		t.Errorf("added/removed/changed/unchanged = %d/%d/%d/%d, want 2/1/1/1", d.Added, d.Removed, d.Changed, d.Unchanged)
+	}
+	if len(d.Samples.Added) != 2 || len(d.Samples.Removed) != 1 || len(d.Samples.Changed) != 1 {
+		t.Errorf("samples = %+v", d.Samples)
+	}
+	if want := "JAWA BARAT | 32/PB/B | B"; len(d.Samples.Changed) == 1 && d.Samples.Changed[0] != want {
+		t.Errorf("changed sample = %q, want %q", d.Samples.Changed[0], want)
+	}
+}
+
+func TestStoreRunPrunesOldFingerprints(t *testing.T) {
+	db := testDB(t)
+	repo := NewRepository(db)
+
+	rows := []map[string]interface{}{{"PROVINSI": "JAWA BARAT", "NOMOR_REGISTRASI": "32/PB/A", "NAMA": "A"}}
+	start := time.Now().Add(time.Hour)
+
+	ids := []int64{}
+	for i := 0; i <= keptRunFingerprints; i++ {
+		ids = append(ids, storeTestRun(t, repo, db, PerbenihanRekProdusenID, start.Add(time.Duration(i)*time.Second), rows))
+	}
+
+	for i, id := range []int64{ids[0], ids[1], ids[len(ids)-1]} {
+		var pruned bool
+		var fingerprints int
+		err := db.QueryRow("SELECT rows_pruned, (SELECT COUNT(*) FROM "+runRowTable+" WHERE run_id = $1) FROM "+runTable+" WHERE id = $1", id).
+			Scan(&pruned, &fingerprints)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantPruned := i == 0
+		if pruned != wantPruned || (fingerprints == 0) != wantPruned {
+			t.Errorf("run %d: pruned %v with %d fingerprints, want pruned %v", id, pruned, fingerprints, wantPruned)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package internal_api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDiffSamples caps the example keys listed per change kind.
+	maxDiffSamples = 20
+
+	// keptRunFingerprints is how many of a dataset's latest runs keep their
+	// row fingerprints. Older runs keep their provenance but can no longer
+	// be diffed.
+	keptRunFingerprints = 30
+)
+
+// fingerprintIgnoredKeys are left out of the content hash; NO is only the
+// position in the upstream listing and shifts whenever a row is inserted.
+var fingerprintIgnoredKeys = map[string]bool{"NO": true}
+
+type (
+	// IngestionRun is the provenance of one ingestion job, recorded whether
+	// or not it succeeded. RowsPruned is set once its row fingerprints were
+	// dropped to make room for newer runs.
+	IngestionRun struct {
+		ID              string            `json:"id"`
+		JobID           string            `json:"job_id"`
+		DatasetID       int               `json:"identifier"`
+		Dataset         string            `json:"dataset"`
+		Status          string            `json:"status"`
+		Error           *string           `json:"error"`
+		SourceURL       string            `json:"source_url"`
+		Params          map[string]string `json:"params"`
+		HTTPStatus      int               `json:"http_status"`
+		ResponseHash    string            `json:"response_hash"`
+		Pages           int               `json:"pages"`
+		RowsFetched     int               `json:"rows_fetched"`
+		RowsStored      int               `json:"rows_stored"`
+		RowsQuarantined int               `json:"rows_quarantined"`
+		RequestedBy     string            `json:"requested_by"`
+		StartedAt       time.Time         `json:"started_at"`
+		FetchedAt       *time.Time        `json:"fetched_at"`
+		FinishedAt      time.Time         `json:"finished_at"`
+		DurationMS      int64             `json:"duration_ms"`
+		RowsPruned      bool              `json:"rows_pruned"`
+	}
+
+	// RunRow fingerprints one fetched row: Key is its readable identity and
+	// Hash the digest of its content.
+	RunRow struct {
+		Key  string
+		Hash []byte
+	}
+
+	RunDiffSamples struct {
+		Added   []string `json:"added"`
+		Removed []string `json:"removed"`
+		Changed []string `json:"changed"`
+	}
+
+	// RunDiff compares the rows fetched by two runs of the same dataset.
+	RunDiff struct {
+		From      IngestionRun   `json:"from"`
+		To        IngestionRun   `json:"to"`
+		Added     int            `json:"added"`
+		Removed   int            `json:"removed"`
+		Changed   int            `json:"changed"`
+		Unchanged int            `json:"unchanged"`
+		Samples   RunDiffSamples `json:"samples"`
+	}
+)
+
+// Fingerprints normalizes, identifies and hashes every fetched row. Rows
+// sharing an identity are told apart by their upstream order.
+func (ds Dataset) Fingerprints(rows []map[string]interface{}) []RunRow {
+	out := make([]RunRow, 0, len(rows))
+	seen := map[string]int{}
+
+	for _, raw := range rows {
+		row := ds.Normalize(raw)
+		parts := make([]string, len(ds.IdentityKeys))
+		for i, key := range ds.IdentityKeys {
+			parts[i] = exportText(row[key])
+		}
+		key := strings.Join(parts, " | ")
+
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s #%d", key, n)
+		}
+
+		content := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			if !fingerprintIgnoredKeys[k] {
+				content[k] = v
+			}
+		}
+		// encoding/json sorts map keys, so equal rows hash equally.
+		doc, _ := json.Marshal(content)
+		sum := sha256.Sum256(doc)
+
+		out = append(out, RunRow{Key: key, Hash: sum[:16]})
+	}
+
+	return out
+}
@@ -0,0 +1,68 @@
+package internal_api
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFingerprints(t *testing.T) {
+	ds, _ := DatasetByID(PerbenihanProdusenID)
+	producer := func(no int, name, luas string) map[string]interface{} {
+		return map[string]interface{}{
+			"NO":               no,
+			"PROVINSI":         "JAWA BARAT",
+			"NOMOR_REGISTRASI": "32/PB/0001/2023",
+			"NAMA":             name,
+			"TOTAL_LUAS_LAHAN": luas,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		a, b     map[string]interface{}
+		sameKey  bool
+		sameHash bool
+	}{
+		{"identical rows", producer(1, "Penangkar A", "45.3"), producer(1, "Penangkar A", "45.3"), true, true},
+		{"only the listing position moved", producer(1, "Penangkar A", "45.3"), producer(7, "Penangkar A", "45.3"), true, true},
+		{"number written differently", producer(1, "Penangkar A", "45.30"), producer(1, "Penangkar A", "45,3"), true, true},
+		{"padding around text", producer(1, "Penangkar A", "45.3"), producer(1, " Penangkar A ", "45.3"), true, true},
+		{"content changed", producer(1, "Penangkar A", "45.3"), producer(1, "Penangkar A", "50"), true, false},
+		{"identity changed", producer(1, "Penangkar A", "45.3"), producer(1, "Penangkar B", "45.3"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := ds.Fingerprints([]map[string]interface{}{tt.a})[0]
+			b := ds.Fingerprints([]map[string]interface{}{tt.b})[0]
+
+			if (a.Key == b.Key) != tt.sameKey {
+				t.Errorf("keys %q and %q: same = %v, want %v", a.Key, b.Key, a.Key == b.Key, tt.sameKey)
+			}
+			if bytes.Equal(a.Hash, b.Hash) != tt.sameHash {
+				t.Errorf("hashes equal = %v, want %v", bytes.Equal(a.Hash, b.Hash), tt.sameHash)
+			}
+		})
+	}
+}
+
+func TestFingerprintsNumberDuplicateIdentities(t *testing.T) {
+	ds, _ := DatasetByID(PerbenihanProdusenID)
+	row := map[string]interface{}{"PROVINSI": "JAWA BARAT", "NOMOR_REGISTRASI": "32/PB/0001/2023", "NAMA": "Penangkar A"}
+
+	got := ds.Fingerprints([]map[string]interface{}{row, row, row})
+
+	want := []string{
+		"JAWA BARAT | 32/PB/0001/2023 | Penangkar A",
+		"JAWA BARAT | 32/PB/0001/2023 | Penangkar A #2",
+		"JAWA BARAT | 32/PB/0001/2023 | Penangkar A #3",
+	}
+	for i, r := range got {
+		if r.Key != want[i] {
+			t.Errorf("row %d key = %q, want %q", i, r.Key, want[i])
+		}
+		if len(r.Hash) != 16 {
+			t.Errorf("row %d hash is %d bytes, want 16", i, len(r.Hash))
+		}
+	}
+}
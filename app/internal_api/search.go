@@ -0,0 +1,461 @@
+package internal_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+const (
+	// searchIndexPrefix names the alias of each dataset's index; the
+	// concrete index behind it carries a build timestamp so a rebuild never
+	// leaves the alias pointing at a half filled index.
+	searchIndexPrefix = "ppt_dataset_"
+	searchBulkSize    = 1000
+	maxSearchFacets   = 20
+	maxSearchWindow   = 10000
+)
+
+// SearchFacets are the keyword fields aggregated on every search.
+var SearchFacets = []string{"province", "commodity", "variety", "status"}
+
+var ErrSearchUnavailable = errors.New("search is not available")
+
+type (
+	SearchQuery struct {
+		Text     string
+		Datasets []Dataset
+		Filters  map[string]string
+		Page     int
+		PageSize int
+	}
+
+	SearchHit struct {
+		Dataset    string                 `json:"dataset"`
+		RegionKode string                 `json:"region_kode"`
+		Score      float64                `json:"score"`
+		Data       map[string]interface{} `json:"data"`
+		Highlight  map[string][]string    `json:"highlight"`
+	}
+
+	FacetBucket struct {
+		Key   string `json:"key"`
+		Count int    `json:"count"`
+	}
+
+	SearchResult struct {
+		Row        []SearchHit              `json:"row"`
+		Facets     map[string][]FacetBucket `json:"facets"`
+		Pagination util.PaginationResponse  `json:"pagination"`
+	}
+
+	// datasetIndexer keeps one Elasticsearch index per dataset in step with
+	// ppt_dataset_records.
+	datasetIndexer struct {
+		es   *elasticsearch.Client
+		repo InternalApiRepository
+	}
+)
+
+func searchAlias(ds Dataset) string {
+	return searchIndexPrefix + strings.ReplaceAll(ds.Slug, "-", "_")
+}
+
+// mapping derives the index mapping from the dataset fields. Strings are
+// searchable text with a keyword sub-field, and every string is copied into
+// search_text so one query covers all of them. Malformed numbers and dates
+// are ignored instead of rejecting the document.
+func (ds Dataset) searchMapping() map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, f := range ds.Fields {
+		switch f.Type {
+		case FieldNumber:
+			data[f.Key] = map[string]interface{}{"type": "double", "ignore_malformed": true}
+		case FieldDate:
+			data[f.Key] = map[string]interface{}{"type": "date", "format": "yyyy-MM-dd||strict_date_optional_time", "ignore_malformed": true}
+		case FieldDateTime:
+			data[f.Key] = map[string]interface{}{"type": "date", "format": "yyyy-MM-dd HH:mm:ss||strict_date_optional_time||yyyy-MM-dd", "ignore_malformed": true}
+		default:
+			data[f.Key] = map[string]interface{}{
+				"type":    "text",
+				"copy_to": "search_text",
+				"fields":  map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256}},
+			}
+		}
+	}
+
+	keyword := map[string]interface{}{"type": "keyword"}
+	properties := map[string]interface{}{
+		"dataset":     keyword,
+		"region_kode": keyword,
+		"province":    keyword,
+		"regency":     keyword,
+		"commodity":   keyword,
+		"variety":     keyword,
+		"status":      keyword,
+		"date":        map[string]interface{}{"type": "date", "format": "yyyy-MM-dd HH:mm:ss||strict_date_optional_time||yyyy-MM-dd", "ignore_malformed": true},
+		"search_text": map[string]interface{}{"type": "text"},
+		"data":        map[string]interface{}{"dynamic": false, "properties": data},
+	}
+	if ds.LatKey != "" && ds.LngKey != "" {
+		properties["location"] = map[string]interface{}{"type": "geo_point", "ignore_malformed": true}
+	}
+
+	return map[string]interface{}{
+		"settings": map[string]interface{}{"number_of_shards": 1},
+		"mappings": map[string]interface{}{"dynamic": false, "properties": properties},
+	}
+}
+
+// searchDocument is the indexed form of one record. The facet fields are
+// upper cased so spelling differences in case do not split buckets.
+func (ds Dataset) searchDocument(r RegionRecord) map[string]interface{} {
+	text := func(key string) interface{} {
+		if key == "" {
+			return nil
+		}
+		s := seedText(r.Data, key)
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+
+	doc := map[string]interface{}{
+		"dataset":   ds.Slug,
+		"province":  text(ds.ProvinceKey),
+		"regency":   text(ds.RegencyKey),
+		"commodity": text(ds.CommodityKey),
+		"variety":   text(ds.VarietyKey),
+		"status":    text("status"),
+		"data":      r.Data,
+	}
+	if r.RegionKode != "" {
+		doc["region_kode"] = r.RegionKode
+	}
+	if ds.DateKey != "" {
+		doc["date"] = r.Data[ds.DateKey]
+	}
+	if ds.LatKey != "" && ds.LngKey != "" {
+		lat, latOK := r.Data[ds.LatKey].(float64)
+		lng, lngOK := r.Data[ds.LngKey].(float64)
+		if latOK && lngOK && lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180 {
+			doc["location"] = map[string]float64{"lat": lat, "lon": lng}
+		}
+	}
+
+	return doc
+}
+
+// Reindex rebuilds the index of ds from the stored records and points the
+// alias at it once every document is in.
+func (ix *datasetIndexer) Reindex(ctx context.Context, ds Dataset) (int, error) {
+	if ix.es == nil {
+		return 0, ErrSearchUnavailable
+	}
+
+	alias := searchAlias(ds)
+	index := alias + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	mapping, err := json.Marshal(ds.searchMapping())
+	if err != nil {
+		return 0, err
+	}
+	res, err := ix.es.Indices.Create(index, ix.es.Indices.Create.WithBody(bytes.NewReader(mapping)), ix.es.Indices.Create.WithContext(ctx))
+	if err := esError(res, err); err != nil {
+		return 0, fmt.Errorf("creating index %s: %w", index, err)
+	}
+	res.Body.Close()
+
+	count, err := ix.fill(ctx, ds, index)
+	if err != nil {
+		ix.drop(ctx, index)
+		return 0, err
+	}
+
+	previous, err := ix.aliasIndices(ctx, alias)
+	if err != nil {
+		ix.drop(ctx, index)
+		return 0, err
+	}
+
+	actions := []map[string]interface{}{{"add": map[string]string{"index": index, "alias": alias}}}
+	for _, old := range previous {
+		actions = append(actions, map[string]interface{}{"remove": map[string]string{"index": old, "alias": alias}})
+	}
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return 0, err
+	}
+	res, err = ix.es.Indices.UpdateAliases(bytes.NewReader(body), ix.es.Indices.UpdateAliases.WithContext(ctx))
+	if err := esError(res, err); err != nil {
+		ix.drop(ctx, index)
+		return 0, fmt.Errorf("switching alias %s: %w", alias, err)
+	}
+	res.Body.Close()
+
+	ix.drop(ctx, previous...)
+
+	return count, nil
+}
+
+func (ix *datasetIndexer) fill(ctx context.Context, ds Dataset, index string) (int, error) {
+	var buf bytes.Buffer
+	count, pending := 0, 0
+
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		res, err := ix.es.Bulk(bytes.NewReader(buf.Bytes()), ix.es.Bulk.WithIndex(index), ix.es.Bulk.WithContext(ctx))
+		if err := esError(res, err); err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		var out struct {
+			Errors bool `json:"errors"`
+			Items  []map[string]struct {
+				Error *struct {
+					Reason string `json:"reason"`
+				} `json:"error"`
+			} `json:"items"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+			return err
+		}
+		if out.Errors {
+			for _, item := range out.Items {
+				for _, action := range item {
+					if action.Error != nil {
+						return fmt.Errorf("bulk indexing %s: %s", index, action.Error.Reason)
+					}
+				}
+			}
+		}
+
+		buf.Reset()
+		pending = 0
+		return nil
+	}
+
+	err := ix.repo.EachRegionRecord(ctx, ds, func(r RegionRecord) error {
+		doc, err := json.Marshal(ds.searchDocument(r))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`{"index":{}}` + "\n")
+		buf.Write(doc)
+		buf.WriteByte('\n')
+		count++
+		pending++
+
+		if pending >= searchBulkSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	res, err := ix.es.Indices.Refresh(ix.es.Indices.Refresh.WithIndex(index), ix.es.Indices.Refresh.WithContext(ctx))
+	if err := esError(res, err); err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return count, nil
+}
+
+// aliasIndices lists the indices currently behind alias; none is not an
+// error, it only means the dataset was never indexed.
+func (ix *datasetIndexer) aliasIndices(ctx context.Context, alias string) ([]string, error) {
+	res, err := ix.es.Indices.GetAlias(ix.es.Indices.GetAlias.WithName(alias), ix.es.Indices.GetAlias.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, esError(res, nil)
+	}
+
+	var out map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(out))
+	for index := range out {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+func (ix *datasetIndexer) drop(ctx context.Context, indices ...string) {
+	if len(indices) == 0 {
+		return
+	}
+	res, err := ix.es.Indices.Delete(indices, ix.es.Indices.Delete.WithContext(ctx))
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+// Search runs a free text query over the given datasets with facet counts
+// and highlighted matches.
+func (ix *datasetIndexer) Search(ctx context.Context, q SearchQuery) (SearchResult, error) {
+	result := SearchResult{Row: []SearchHit{}, Facets: map[string][]FacetBucket{}}
+	if ix.es == nil {
+		return result, ErrSearchUnavailable
+	}
+
+	must := []interface{}{}
+	if strings.TrimSpace(q.Text) != "" {
+		must = append(must, map[string]interface{}{
+			"simple_query_string": map[string]interface{}{
+				"query":            q.Text,
+				"fields":           []string{"search_text"},
+				"default_operator": "and",
+			},
+		})
+	} else {
+		must = append(must, map[string]interface{}{"match_all": map[string]interface{}{}})
+	}
+
+	filters := []interface{}{}
+	for _, facet := range SearchFacets {
+		if v := strings.TrimSpace(q.Filters[facet]); v != "" {
+			filters = append(filters, map[string]interface{}{"term": map[string]string{facet: strings.ToUpper(v)}})
+		}
+	}
+	if kode := q.Filters["region"]; kode != "" {
+		filters = append(filters, map[string]interface{}{"prefix": map[string]string{"region_kode": kode}})
+	}
+
+	aggs := map[string]interface{}{}
+	for _, facet := range SearchFacets {
+		aggs[facet] = map[string]interface{}{"terms": map[string]interface{}{"field": facet, "size": maxSearchFacets}}
+	}
+	aggs["dataset"] = map[string]interface{}{"terms": map[string]interface{}{"field": "dataset", "size": len(datasets)}}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"from":             (q.Page - 1) * q.PageSize,
+		"size":             q.PageSize,
+		"track_total_hits": true,
+		"query":            map[string]interface{}{"bool": map[string]interface{}{"must": must, "filter": filters}},
+		"aggs":             aggs,
+		"highlight": map[string]interface{}{
+			"require_field_match": false,
+			"pre_tags":            []string{"<em>"},
+			"post_tags":           []string{"</em>"},
+			"fields":              map[string]interface{}{"data.*": map[string]interface{}{}},
+		},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	indices := make([]string, 0, len(q.Datasets))
+	for _, ds := range q.Datasets {
+		indices = append(indices, searchAlias(ds))
+	}
+
+	res, err := ix.es.Search(
+		ix.es.Search.WithContext(ctx),
+		ix.es.Search.WithIndex(indices...),
+		ix.es.Search.WithBody(bytes.NewReader(body)),
+		ix.es.Search.WithIgnoreUnavailable(true),
+		ix.es.Search.WithAllowNoIndices(true),
+	)
+	if err := esError(res, err); err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	var out struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Score     float64             `json:"_score"`
+				Source    searchSource        `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key   string `json:"key"`
+				Count int    `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return result, err
+	}
+
+	for _, h := range out.Hits.Hits {
+		highlight := map[string][]string{}
+		for field, fragments := range h.Highlight {
+			highlight[strings.TrimPrefix(field, "data.")] = fragments
+		}
+		result.Row = append(result.Row, SearchHit{
+			Dataset:    h.Source.Dataset,
+			RegionKode: h.Source.RegionKode,
+			Score:      h.Score,
+			Data:       h.Source.Data,
+			Highlight:  highlight,
+		})
+	}
+
+	for name, agg := range out.Aggregations {
+		buckets := make([]FacetBucket, 0, len(agg.Buckets))
+		for _, b := range agg.Buckets {
+			buckets = append(buckets, FacetBucket{Key: b.Key, Count: b.Count})
+		}
+		result.Facets[name] = buckets
+	}
+
+	result.Pagination = paginate(q.Page, q.PageSize, out.Hits.Total.Value)
+	return result, nil
+}
+
+type searchSource struct {
+	Dataset    string                 `json:"dataset"`
+	RegionKode string                 `json:"region_kode"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// esError turns a failed call or an error response into an error. The body
+// of an error response is consumed.
+func esError(res *esapi.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSearchUnavailable, err)
+	}
+	if !res.IsError() {
+		return nil
+	}
+	defer res.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("elasticsearch %s: %s", res.Status(), bytes.TrimSpace(msg))
+}
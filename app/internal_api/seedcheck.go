@@ -0,0 +1,630 @@
+package internal_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/region"
+)
+
+// Checks run by the seed supply-chain reconciliation.
+const (
+	CheckStockTotal        = "stock_total"
+	CheckStockRemaining    = "stock_remaining"
+	CheckOverdistribution  = "overdistribution"
+	CheckDistributionSplit = "distribution_split"
+	CheckStockContinuity   = "stock_continuity"
+	CheckPlantingCoverage  = "planting_coverage"
+	CheckUnknownProducer   = "unknown_producer"
+
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+
+	AnomalyOpen         = "open"
+	AnomalyAcknowledged = "acknowledged"
+	AnomalyDismissed    = "dismissed"
+	AnomalyResolved     = "resolved"
+)
+
+const (
+	// balanceTolerance absorbs the rounding of figures reported in tons
+	// with two decimals.
+	balanceTolerance = 0.05
+	// Distributed seed is compared with the seed the planted area needs;
+	// anything between these ratios is considered plausible.
+	minCoverageRatio = 0.5
+	maxCoverageRatio = 2.0
+)
+
+// seedRates is the recommended seed per hectare in kg, used to turn a
+// planted area into the tons of seed it takes.
+var seedRates = map[string]float64{
+	"PADI":         25,
+	"JAGUNG":       20,
+	"KEDELAI":      50,
+	"KACANG TANAH": 100,
+	"KACANG HIJAU": 25,
+}
+
+// seedCheckDatasets trigger a reconciliation when one of them is ingested.
+var seedCheckDatasets = map[int]bool{
+	PerbenihanProdusenID:      true,
+	PerbenihanRekPenyaluranID: true,
+	PerbenihanRekPenyebaranID: true,
+	PerbenihanRekProdusenID:   true,
+}
+
+type (
+	// RegionRecord is a stored record together with its reconciled region.
+	RegionRecord struct {
+		Data       map[string]interface{}
+		RegionKode string
+	}
+
+	SeedAnomaly struct {
+		ID          string                 `json:"id"`
+		Check       string                 `json:"check"`
+		Severity    string                 `json:"severity"`
+		Status      string                 `json:"status"`
+		RegionKode  *string                `json:"region_kode"`
+		Region      string                 `json:"region"`
+		Producer    string                 `json:"producer"`
+		Commodity   string                 `json:"commodity"`
+		Variety     string                 `json:"variety"`
+		Year        int                    `json:"year"`
+		Month       int                    `json:"month"`
+		Expected    float64                `json:"expected"`
+		Actual      float64                `json:"actual"`
+		Difference  float64                `json:"difference"`
+		Message     string                 `json:"message"`
+		Detail      map[string]interface{} `json:"detail"`
+		Note        *string                `json:"note"`
+		ReviewedBy  *string                `json:"reviewed_by"`
+		ReviewedAt  *time.Time             `json:"reviewed_at"`
+		DetectedAt  time.Time              `json:"detected_at"`
+		LastSeenAt  time.Time              `json:"last_seen_at"`
+		Fingerprint string                 `json:"-"`
+	}
+
+	SeedAnomalyFilter struct {
+		Check    string
+		Severity string
+		Status   string
+		Region   string
+	}
+
+	SeedAnomalyReview struct {
+		Status string `json:"status" binding:"required,oneof=open acknowledged dismissed"`
+		Note   string `json:"note" binding:"max=1000"`
+	}
+
+	// SeedCheckSummary reports one reconciliation pass.
+	SeedCheckSummary struct {
+		Rows       int            `json:"rows"`
+		Anomalies  int            `json:"anomalies"`
+		BySeverity map[string]int `json:"by_severity"`
+		New        int            `json:"new"`
+		NewHigh    int            `json:"new_high"`
+		Resolved   int            `json:"resolved"`
+	}
+
+	stockKey struct {
+		producer, commodity, variety string
+		year, month                  int
+	}
+
+	stockTotals struct {
+		regionKode, region                         string
+		rows                                       int
+		stokLalu, produksi, pengadaan, jumlahStok  float64
+		salur, apbn, apbd, freeMarket, sisa        float64
+		hasJumlahStok, hasSplit, hasSisa, hasSalur bool
+	}
+
+	coverageKey struct {
+		region, commodity, variety string
+		year, month                int
+	}
+
+	coverageTotals struct {
+		regionKode, region string
+		distributed, area  float64
+	}
+)
+
+// runSeedChecks reconciles the stored Perbenihan datasets and records the
+// anomalies found.
+func runSeedChecks(ctx context.Context, repo InternalApiRepository) (SeedCheckSummary, error) {
+	load := func(id int) ([]RegionRecord, error) {
+		ds, _ := DatasetByID(id)
+		rows := []RegionRecord{}
+		err := repo.EachRegionRecord(ctx, ds, func(r RegionRecord) error {
+			rows = append(rows, r)
+			return nil
+		})
+		return rows, err
+	}
+
+	penyaluran, err := load(PerbenihanRekPenyaluranID)
+	if err != nil {
+		return SeedCheckSummary{}, err
+	}
+	penyebaran, err := load(PerbenihanRekPenyebaranID)
+	if err != nil {
+		return SeedCheckSummary{}, err
+	}
+	produsen, err := load(PerbenihanProdusenID)
+	if err != nil {
+		return SeedCheckSummary{}, err
+	}
+	rekProdusen, err := load(PerbenihanRekProdusenID)
+	if err != nil {
+		return SeedCheckSummary{}, err
+	}
+
+	started := time.Now()
+	anomalies := checkSeedSupply(penyaluran, penyebaran, append(produsen, rekProdusen...))
+
+	summary, err := repo.StoreSeedAnomalies(ctx, anomalies, started)
+	if err != nil {
+		return summary, err
+	}
+	summary.Rows = len(penyaluran) + len(penyebaran)
+
+	return summary, nil
+}
+
+// checkSeedSupply runs every check over the rows and returns the anomalies.
+func checkSeedSupply(penyaluran, penyebaran, produsen []RegionRecord) []SeedAnomaly {
+	anomalies := []SeedAnomaly{}
+
+	totals := map[stockKey]*stockTotals{}
+	for _, r := range penyaluran {
+		key := stockKey{
+			producer:  seedText(r.Data, "PRODUSEN_BENIH"),
+			commodity: seedText(r.Data, "KOMODITI"),
+			variety:   seedText(r.Data, "VARIETAS"),
+			year:      seedInt(r.Data, "TAHUN"),
+			month:     seedInt(r.Data, "BULAN"),
+		}
+		t, ok := totals[key]
+		if !ok {
+			t = &stockTotals{regionKode: regencyKode(r.RegionKode), region: seedText(r.Data, "KABUPATENKOTA")}
+			totals[key] = t
+		}
+		t.add(r.Data)
+	}
+
+	keys := make([]stockKey, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	for _, key := range keys {
+		anomalies = append(anomalies, totals[key].check(key)...)
+	}
+	anomalies = append(anomalies, checkContinuity(keys, totals)...)
+	anomalies = append(anomalies, checkCoverage(penyaluran, penyebaran)...)
+	anomalies = append(anomalies, checkProducers(penyaluran, produsen)...)
+
+	for i := range anomalies {
+		anomalies[i].Fingerprint = anomalies[i].fingerprint()
+	}
+
+	return anomalies
+}
+
+func (t *stockTotals) add(data map[string]interface{}) {
+	t.rows++
+	t.stokLalu += seedNumber(data, "STOK_LALU")
+	t.produksi += seedNumber(data, "PRODUKSI_BENIH")
+	t.pengadaan += seedNumber(data, "PENGADAAN")
+
+	if v, ok := seedValue(data, "JUMLAH_STOK"); ok {
+		t.jumlahStok += v
+		t.hasJumlahStok = true
+	}
+	if v, ok := seedDistributed(data); ok {
+		t.salur += v
+		t.hasSalur = true
+	}
+	if v, ok := seedValue(data, "SISA_STOK"); ok {
+		t.sisa += v
+		t.hasSisa = true
+	}
+
+	apbn, hasAPBN := seedValue(data, "APBN")
+	apbd, hasAPBD := seedValue(data, "APBD")
+	free, hasFree := seedValue(data, "FREE_MARKET")
+	if hasAPBN || hasAPBD || hasFree {
+		t.hasSplit = true
+		t.apbn += apbn
+		t.apbd += apbd
+		t.freeMarket += free
+	}
+}
+
+func (t *stockTotals) check(key stockKey) []SeedAnomaly {
+	out := []SeedAnomaly{}
+	base := key.anomaly(t.regionKode, t.region)
+	detail := func() map[string]interface{} {
+		return map[string]interface{}{
+			"rows":           t.rows,
+			"STOK_LALU":      round2(t.stokLalu),
+			"PRODUKSI_BENIH": round2(t.produksi),
+			"PENGADAAN":      round2(t.pengadaan),
+			"JUMLAH_STOK":    round2(t.jumlahStok),
+			"JUMLAH_SALUR":   round2(t.salur),
+			"SISA_STOK":      round2(t.sisa),
+		}
+	}
+
+	available := t.stokLalu + t.produksi + t.pengadaan
+	if t.hasJumlahStok {
+		if a, ok := base.compare(CheckStockTotal, available, t.jumlahStok,
+			"JUMLAH_STOK %.2f t does not equal STOK_LALU + PRODUKSI_BENIH + PENGADAAN = %.2f t", t.jumlahStok, available); ok {
+			a.Detail = detail()
+			out = append(out, a)
+		}
+		available = t.jumlahStok
+	}
+
+	if t.hasSalur && t.salur > available+balanceTolerance {
+		a := base
+		a.Check = CheckOverdistribution
+		a.Severity = SeverityHigh
+		a.Expected, a.Actual = round2(available), round2(t.salur)
+		a.Difference = round2(t.salur - available)
+		a.Message = fmt.Sprintf("%.2f t distributed but only %.2f t in stock", t.salur, available)
+		a.Detail = detail()
+		out = append(out, a)
+	}
+
+	if t.hasSisa {
+		if a, ok := base.compare(CheckStockRemaining, available-t.salur, t.sisa,
+			"SISA_STOK %.2f t does not equal stock %.2f t minus distributed %.2f t", t.sisa, available, t.salur); ok {
+			a.Detail = detail()
+			out = append(out, a)
+		}
+	}
+
+	if t.hasSplit && t.hasSalur {
+		split := t.apbn + t.apbd + t.freeMarket
+		if a, ok := base.compare(CheckDistributionSplit, t.salur, split,
+			"APBN + APBD + FREE_MARKET = %.2f t does not equal distributed %.2f t", split, t.salur); ok {
+			a.Detail = map[string]interface{}{
+				"APBN": round2(t.apbn), "APBD": round2(t.apbd), "FREE_MARKET": round2(t.freeMarket), "JUMLAH_SALUR": round2(t.salur),
+			}
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+// checkContinuity compares the closing stock of a month with the opening
+// stock the same producer reports for the next month. keys must be sorted.
+func checkContinuity(keys []stockKey, totals map[stockKey]*stockTotals) []SeedAnomaly {
+	out := []SeedAnomaly{}
+
+	for i := 1; i < len(keys); i++ {
+		prev, cur := keys[i-1], keys[i]
+		if prev.producer != cur.producer || prev.commodity != cur.commodity || prev.variety != cur.variety {
+			continue
+		}
+		if !prev.precedes(cur) || !totals[prev].hasSisa {
+			continue
+		}
+
+		t := totals[cur]
+		base := cur.anomaly(t.regionKode, t.region)
+		a, ok := base.compare(CheckStockContinuity, totals[prev].sisa, t.stokLalu,
+			"STOK_LALU %.2f t does not match SISA_STOK %.2f t reported for %02d/%d", t.stokLalu, totals[prev].sisa, prev.month, prev.year)
+		if ok {
+			a.Detail = map[string]interface{}{"previous_year": prev.year, "previous_month": prev.month}
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+// checkCoverage compares the seed distributed into a regency with the seed
+// the area planted there that month needs.
+func checkCoverage(penyaluran, penyebaran []RegionRecord) []SeedAnomaly {
+	totals := map[coverageKey]*coverageTotals{}
+	get := func(r RegionRecord, commodityKey string) (*coverageTotals, bool) {
+		commodity := seedText(r.Data, commodityKey)
+		if seedRate(commodity) == 0 {
+			return nil, false
+		}
+
+		regionKey, kode := "name:"+seedText(r.Data, "PROVINSI")+"|"+seedText(r.Data, "KABUPATENKOTA"), regencyKode(r.RegionKode)
+		if kode != "" {
+			regionKey = kode
+		}
+
+		key := coverageKey{
+			region:    regionKey,
+			commodity: commodity,
+			variety:   seedText(r.Data, "VARIETAS"),
+			year:      seedInt(r.Data, "TAHUN"),
+			month:     seedInt(r.Data, "BULAN"),
+		}
+		t, ok := totals[key]
+		if !ok {
+			t = &coverageTotals{regionKode: kode, region: seedText(r.Data, "KABUPATENKOTA")}
+			totals[key] = t
+		}
+		return t, true
+	}
+
+	for _, r := range penyaluran {
+		if t, ok := get(r, "KOMODITI"); ok {
+			v, _ := seedDistributed(r.Data)
+			t.distributed += v
+		}
+	}
+	for _, r := range penyebaran {
+		if t, ok := get(r, "JENIS_BENIH"); ok {
+			v, has := seedValue(r.Data, "REALISASI_TANAM_LUAS")
+			if !has {
+				v = seedNumber(r.Data, "TOTAL_LUAS")
+			}
+			t.area += v
+		}
+	}
+
+	keys := make([]coverageKey, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.region != b.region {
+			return a.region < b.region
+		}
+		return stockKey{commodity: a.commodity, variety: a.variety, year: a.year, month: a.month}.
+			less(stockKey{commodity: b.commodity, variety: b.variety, year: b.year, month: b.month})
+	})
+
+	out := []SeedAnomaly{}
+	for _, key := range keys {
+		t := totals[key]
+		// Farmers plant saved seed too, so planting without recorded
+		// distribution is not suspicious on its own.
+		if t.distributed <= balanceTolerance {
+			continue
+		}
+
+		need := t.area * seedRate(key.commodity) / 1000
+		a := stockKey{commodity: key.commodity, variety: key.variety, year: key.year, month: key.month}.anomaly(t.regionKode, t.region)
+		a.Check = CheckPlantingCoverage
+		a.Expected, a.Actual = round2(need), round2(t.distributed)
+		a.Difference = round2(t.distributed - need)
+		a.Detail = map[string]interface{}{
+			"planted_area_ha":  round2(t.area),
+			"seed_rate_kg_ha":  seedRate(key.commodity),
+			"distributed_tons": round2(t.distributed),
+		}
+
+		switch {
+		case t.area <= 0:
+			a.Severity = SeverityMedium
+			a.Message = fmt.Sprintf("%.2f t of seed distributed but no planting recorded", t.distributed)
+		case t.distributed < need*minCoverageRatio || t.distributed > need*maxCoverageRatio:
+			ratio := t.distributed / need
+			a.Severity = SeverityLow
+			if ratio < minCoverageRatio/2 || ratio > maxCoverageRatio*2 {
+				a.Severity = SeverityHigh
+			} else if ratio < minCoverageRatio*0.8 || ratio > maxCoverageRatio*1.25 {
+				a.Severity = SeverityMedium
+			}
+			a.Detail["ratio"] = round2(ratio)
+			a.Message = fmt.Sprintf("%.2f t distributed for %.2f ha planted, which needs about %.2f t", t.distributed, t.area, need)
+		default:
+			continue
+		}
+		out = append(out, a)
+	}
+
+	return out
+}
+
+// checkProducers flags distributors that are missing from the producer
+// registry. Nothing is flagged while the registry has not been ingested.
+func checkProducers(penyaluran, produsen []RegionRecord) []SeedAnomaly {
+	out := []SeedAnomaly{}
+	if len(produsen) == 0 {
+		return out
+	}
+
+	known := map[string]bool{}
+	for _, r := range produsen {
+		known[seedText(r.Data, "NAMA")] = true
+	}
+
+	unknown := map[string]*SeedAnomaly{}
+	names := []string{}
+	for _, r := range penyaluran {
+		name := seedText(r.Data, "PRODUSEN_BENIH")
+		if name == "" || known[name] {
+			continue
+		}
+
+		a, ok := unknown[name]
+		if !ok {
+			a = &SeedAnomaly{
+				Check:    CheckUnknownProducer,
+				Severity: SeverityLow,
+				Producer: name,
+				Region:   seedText(r.Data, "PROVINSI"),
+				Detail:   map[string]interface{}{"rows": 0},
+			}
+			if kode := r.RegionKode; len(kode) >= 2 {
+				province := kode[:2]
+				a.RegionKode = &province
+			}
+			unknown[name] = a
+			names = append(names, name)
+		}
+		a.Detail["rows"] = a.Detail["rows"].(int) + 1
+		v, _ := seedDistributed(r.Data)
+		a.Actual += v
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		a := unknown[name]
+		a.Actual = round2(a.Actual)
+		a.Message = fmt.Sprintf("producer %q distributes seed but is not in the producer registry", name)
+		out = append(out, *a)
+	}
+
+	return out
+}
+
+func (k stockKey) less(o stockKey) bool {
+	if k.producer != o.producer {
+		return k.producer < o.producer
+	}
+	if k.commodity != o.commodity {
+		return k.commodity < o.commodity
+	}
+	if k.variety != o.variety {
+		return k.variety < o.variety
+	}
+	if k.year != o.year {
+		return k.year < o.year
+	}
+	return k.month < o.month
+}
+
+// precedes reports whether o is the calendar month right after k.
+func (k stockKey) precedes(o stockKey) bool {
+	if k.month == 12 {
+		return o.year == k.year+1 && o.month == 1
+	}
+	return o.year == k.year && o.month == k.month+1
+}
+
+func (k stockKey) anomaly(regionKode, regionName string) SeedAnomaly {
+	a := SeedAnomaly{
+		Region:    regionName,
+		Producer:  k.producer,
+		Commodity: k.commodity,
+		Variety:   k.variety,
+		Year:      k.year,
+		Month:     k.month,
+	}
+	if regionKode != "" {
+		a.RegionKode = &regionKode
+	}
+	return a
+}
+
+// compare returns a copy of a describing the mismatch between expected and
+// actual, or false when they agree within balanceTolerance.
+func (a SeedAnomaly) compare(check string, expected, actual float64, format string, args ...interface{}) (SeedAnomaly, bool) {
+	diff := actual - expected
+	if math.Abs(diff) <= balanceTolerance {
+		return a, false
+	}
+
+	a.Check = check
+	a.Severity = balanceSeverity(expected, actual)
+	a.Expected, a.Actual, a.Difference = round2(expected), round2(actual), round2(diff)
+	a.Message = fmt.Sprintf(format, args...)
+	return a, true
+}
+
+// fingerprint identifies the same finding across passes so its review
+// status survives re-ingestion.
+func (a SeedAnomaly) fingerprint() string {
+	kode := ""
+	if a.RegionKode != nil {
+		kode = *a.RegionKode
+	}
+	parts := []string{a.Check, a.Producer, a.Commodity, a.Variety, kode, a.Region, strconv.Itoa(a.Year), strconv.Itoa(a.Month)}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// balanceSeverity grades a mismatch by its size relative to the expected
+// value: under 5% is low, under 25% medium and anything above high.
+func balanceSeverity(expected, actual float64) string {
+	rel := math.Abs(actual-expected) / math.Max(math.Abs(expected), 1)
+	switch {
+	case rel < 0.05:
+		return SeverityLow
+	case rel < 0.25:
+		return SeverityMedium
+	default:
+		return SeverityHigh
+	}
+}
+
+func seedRate(commodity string) float64 {
+	if rate, ok := seedRates[commodity]; ok {
+		return rate
+	}
+	for name, rate := range seedRates {
+		if strings.HasPrefix(commodity, name+" ") {
+			return rate
+		}
+	}
+	return 0
+}
+
+// regencyKode cuts a reconciled kode down to its regency, or "" when the
+// row was not resolved that deep.
+func regencyKode(kode string) string {
+	for region.KodeLevel(kode) > region.LevelRegency {
+		kode = region.ParentKode(kode)
+	}
+	if region.KodeLevel(kode) != region.LevelRegency {
+		return ""
+	}
+	return kode
+}
+
+func seedText(data map[string]interface{}, key string) string {
+	s, _ := data[key].(string)
+	return strings.Join(strings.Fields(strings.ToUpper(s)), " ")
+}
+
+func seedValue(data map[string]interface{}, key string) (float64, bool) {
+	v, ok := data[key].(float64)
+	return v, ok
+}
+
+// seedDistributed is the tons a penyaluran row distributed. JUMLAH_SALUR
+// is preferred; older rows only carry PENYALURAN.
+func seedDistributed(data map[string]interface{}) (float64, bool) {
+	if v, ok := seedValue(data, "JUMLAH_SALUR"); ok {
+		return v, true
+	}
+	return seedValue(data, "PENYALURAN")
+}
+
+func seedNumber(data map[string]interface{}, key string) float64 {
+	v, _ := seedValue(data, key)
+	return v
+}
+
+func seedInt(data map[string]interface{}, key string) int {
+	return int(seedNumber(data, key))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,156 @@
+package internal_api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func penyaluranRow(year, month int, values map[string]float64) RegionRecord {
+	data := map[string]interface{}{
+		"TAHUN":          float64(year),
+		"BULAN":          float64(month),
+		"PROVINSI":       "JAWA BARAT",
+		"KABUPATENKOTA":  "KAB. BOGOR",
+		"PRODUSEN_BENIH": "Penangkar A",
+		"KOMODITI":       "PADI",
+		"VARIETAS":       "INPARI 32",
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+	return RegionRecord{Data: data, RegionKode: "32.01.01"}
+}
+
+// balanceFindings keeps the stock balance findings as check:severity.
+func balanceFindings(anomalies []SeedAnomaly) []string {
+	balance := map[string]bool{
+		CheckStockTotal:        true,
+		CheckStockRemaining:    true,
+		CheckOverdistribution:  true,
+		CheckDistributionSplit: true,
+		CheckStockContinuity:   true,
+	}
+
+	out := []string{}
+	for _, a := range anomalies {
+		if balance[a.Check] {
+			out = append(out, a.Check+":"+a.Severity)
+		}
+	}
+	return out
+}
+
+func TestSeedBalanceRules(t *testing.T) {
+	balanced := map[string]float64{
+		"STOK_LALU": 10, "PRODUKSI_BENIH": 5, "PENGADAAN": 5, "JUMLAH_STOK": 20,
+		"JUMLAH_SALUR": 12, "APBN": 6, "APBD": 4, "FREE_MARKET": 2, "SISA_STOK": 8,
+	}
+	with := func(changes map[string]float64) map[string]float64 {
+		out := map[string]float64{}
+		for k, v := range balanced {
+			out[k] = v
+		}
+		for k, v := range changes {
+			out[k] = v
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		rows []RegionRecord
+		want []string
+	}{
+		{"balanced", []RegionRecord{penyaluranRow(2023, 7, balanced)}, []string{}},
+		{"rounding tolerated", []RegionRecord{penyaluranRow(2023, 7, with(map[string]float64{"JUMLAH_STOK": 20.04, "SISA_STOK": 8.04}))}, []string{}},
+		{"stock total off a little", []RegionRecord{penyaluranRow(2023, 7, with(map[string]float64{"JUMLAH_STOK": 20.5, "SISA_STOK": 8.5}))},
+			[]string{"stock_total:low"}},
+		{"stock total off a lot", []RegionRecord{penyaluranRow(2023, 7, with(map[string]float64{"JUMLAH_STOK": 30, "SISA_STOK": 18}))},
+			[]string{"stock_total:high"}},
+		{"remaining stock off", []RegionRecord{penyaluranRow(2023, 7, with(map[string]float64{"SISA_STOK": 7}))},
+			[]string{"stock_remaining:medium"}},
+		{"overdistribution", []RegionRecord{penyaluranRow(2023, 7, with(map[string]float64{"JUMLAH_SALUR": 25, "APBN": 19, "SISA_STOK": -5}))},
+			[]string{"overdistribution:high"}},
+		{"split does not add up", []RegionRecord{penyaluranRow(2023, 7, with(map[string]float64{"FREE_MARKET": 5}))},
+			[]string{"distribution_split:high"}},
+		{"older rows only report PENYALURAN", []RegionRecord{penyaluranRow(2023, 7, map[string]float64{
+			"STOK_LALU": 10, "PRODUKSI_BENIH": 5, "PENGADAAN": 5, "PENYALURAN": 12, "SISA_STOK": 8,
+		})}, []string{}},
+		{"rows of one month add up", []RegionRecord{
+			penyaluranRow(2023, 7, map[string]float64{"STOK_LALU": 10, "JUMLAH_STOK": 10, "JUMLAH_SALUR": 4, "SISA_STOK": 6}),
+			penyaluranRow(2023, 7, map[string]float64{"PRODUKSI_BENIH": 10, "JUMLAH_STOK": 10, "JUMLAH_SALUR": 4, "SISA_STOK": 6}),
+		}, []string{}},
+		{"next month continues", []RegionRecord{
+			penyaluranRow(2023, 7, balanced),
+			penyaluranRow(2023, 8, with(map[string]float64{"STOK_LALU": 8, "JUMLAH_STOK": 18, "SISA_STOK": 6})),
+		}, []string{}},
+		{"next month does not continue", []RegionRecord{
+			penyaluranRow(2023, 7, balanced),
+			penyaluranRow(2023, 8, balanced),
+		}, []string{"stock_continuity:high"}},
+		{"continues across the year", []RegionRecord{
+			penyaluranRow(2022, 12, balanced),
+			penyaluranRow(2023, 1, balanced),
+		}, []string{"stock_continuity:high"}},
+		{"a gap is not compared", []RegionRecord{
+			penyaluranRow(2023, 7, balanced),
+			penyaluranRow(2023, 9, balanced),
+		}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := balanceFindings(checkSeedSupply(tt.rows, nil, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeedAnomalyFigures(t *testing.T) {
+	rows := []RegionRecord{penyaluranRow(2023, 7, map[string]float64{
+		"STOK_LALU": 10, "JUMLAH_STOK": 10, "JUMLAH_SALUR": 12.5,
+	})}
+
+	anomalies := checkSeedSupply(rows, nil, nil)
+
+	var got *SeedAnomaly
+	for i := range anomalies {
+		if anomalies[i].Check == CheckOverdistribution {
+			got = &anomalies[i]
+		}
+	}
+	if got == nil {
+		t.Fatal("no overdistribution found")
+	}
+	if got.Expected != 10 || got.Actual != 12.5 || got.Difference != 2.5 {
+		t.Errorf("expected/actual/difference = %v/%v/%v, want 10/12.5/2.5", got.Expected, got.Actual, got.Difference)
+	}
+	if got.RegionKode == nil || *got.RegionKode != "32.01" {
+		t.Errorf("region kode = %v, want the regency 32.01", got.RegionKode)
+	}
+	if got.Fingerprint == "" {
+		t.Error("fingerprint not set")
+	}
+}
+
+func TestBalanceSeverity(t *testing.T) {
+	tests := []struct {
+		expected, actual float64
+		want             string
+	}{
+		{100, 104, SeverityLow},
+		{100, 95.5, SeverityLow},
+		{100, 110, SeverityMedium},
+		{100, 125, SeverityHigh},
+		{0, 0.5, SeverityHigh},
+		{0, 0.04, SeverityLow},
+	}
+
+	for _, tt := range tests {
+		if got := balanceSeverity(tt.expected, tt.actual); got != tt.want {
+			t.Errorf("balanceSeverity(%v, %v) = %q, want %q", tt.expected, tt.actual, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package internal_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+var appConfig, _ = config.LoadConfig("./.")
+
+const (
+	// maxSourcePages stops a misbehaving upstream that never reports its
+	// last page from paging forever.
+	maxSourcePages = 1000
+)
+
+type (
+	// Source describes where a dataset is pulled from. Paged sources follow
+	// the Laravel style envelope (current_page, last_page, data) used by
+	// SIPDPS; the others return every row in one response. Sources with
+	// OAuth use client credentials once they are configured and the static
+	// token under TokenKey until then.
+	Source struct {
+		DatasetID   int
+		TokenKey    string
+		OAuth       *OAuthClient
+		URL         string
+		Params      map[string]string
+		BearerToken bool
+		Paged       bool
+	}
+
+	// FetchResult is what one Fetch pulled, with enough provenance to tell
+	// runs apart. StatusCode is the last HTTP status seen and ResponseHash
+	// the SHA-256 over every page body in order.
+	FetchResult struct {
+		URL          string
+		Params       map[string]string
+		Rows         []map[string]interface{}
+		Pages        int
+		StatusCode   int
+		ResponseHash string
+		FetchedAt    time.Time
+	}
+
+	sourcePage struct {
+		LastPage int                      `json:"last_page"`
+		Data     []map[string]interface{} `json:"data"`
+	}
+)
+
+var sources = []Source{
+	sipdpsSource(SIPDPSTanamID, "data-laporan-tanam"),
+	sipdpsSource(SIPDPSProduktivitasID, "data-laporan-produktivitas"),
+	sipdpsSource(SIPDPSPusoID, "data-laporan-puso"),
+	sipdpsSource(SIPDPSPanenID, "data-laporan-panen"),
+	perbenihanSource(PerbenihanProdusenID, "seluruh", "produsen"),
+	perbenihanSource(PerbenihanRekNasID, "rekapitulasi", "nasional"),
+	perbenihanSource(PerbenihanRekBpsbID, "rekapitulasi", "bpsb"),
+	perbenihanSource(PerbenihanRekLssmID, "rekapitulasi", "lssm"),
+	perbenihanSource(PerbenihanRekPenyaluranID, "rekapitulasi", "penyaluran"),
+	perbenihanSource(PerbenihanRekPenyebaranID, "rekapitulasi", "penyebaran"),
+	perbenihanSource(PerbenihanRekProdusenID, "rekapitulasi", "produsen"),
+}
+
+func sipdpsSource(id int, path string) Source {
+	return Source{
+		DatasetID:   id,
+		TokenKey:    "api_token_sipdps_jawa_barat",
+		OAuth:       &OAuthClient{Name: "splp", TokenURL: appConfig.SPLPTokenURL},
+		URL:         appConfig.SIPDPSBaseURL + "/" + path,
+		Params:      map[string]string{"provinsi": "12"},
+		BearerToken: true,
+		Paged:       true,
+	}
+}
+
+func perbenihanSource(id int, rekap, jenis string) Source {
+	return Source{
+		DatasetID: id,
+		TokenKey:  "api_token_perbenihan",
+		URL:       appConfig.PerbenihanBaseURL,
+		Params:    map[string]string{"rekap": rekap, "jenis": jenis},
+	}
+}
+
+func SourceByDatasetID(id int) (Source, bool) {
+	for _, src := range sources {
+		if src.DatasetID == id {
+			return src, true
+		}
+	}
+	return Source{}, false
+}
+
+// Fetch pulls every row of the source. progress is called after each page
+// with the number of pages and rows fetched so far. On error the result
+// holds whatever was fetched before the failure. Each page answered with
+// 401 is retried once with a fresh token.
+func (src Source) Fetch(ctx context.Context, tokens TokenSource, progress func(pages, rows int)) (res FetchResult, err error) {
+	res = FetchResult{
+		URL:    src.URL,
+		Params: src.Params,
+		Rows:   []map[string]interface{}{},
+	}
+
+	upstream := util.UpstreamFor(src.URL)
+	hash := sha256.New()
+	defer func() {
+		res.ResponseHash = hex.EncodeToString(hash.Sum(nil))
+	}()
+
+	retried := false
+	for page := 1; page <= maxSourcePages; page++ {
+		token, err := tokens.Token(ctx)
+		if err != nil {
+			return res, err
+		}
+
+		params := url.Values{}
+		for k, v := range src.Params {
+			params.Set(k, v)
+		}
+		if !src.BearerToken {
+			params.Set("token", token)
+		}
+		if src.Paged {
+			params.Set("page", strconv.Itoa(page))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL+"?"+params.Encode(), nil)
+		if err != nil {
+			return res, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if src.BearerToken {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		response, err := upstream.Do(req)
+		if err != nil {
+			var ue *util.UpstreamError
+			if errors.As(err, &ue) {
+				res.StatusCode = ue.StatusCode
+			}
+			return res, err
+		}
+		res.StatusCode = response.StatusCode
+
+		if response.StatusCode == http.StatusUnauthorized {
+			upstream.Unexpected(response)
+			if retried {
+				return res, fmt.Errorf("page %d: %w", page, ErrCredentialsRejected)
+			}
+			retried = true
+			tokens.Invalidate(ctx)
+			page--
+			continue
+		}
+
+		if response.StatusCode != http.StatusOK {
+			return res, fmt.Errorf("page %d: %w", page, upstream.Unexpected(response))
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return res, err
+		}
+		hash.Write(body)
+		res.FetchedAt = time.Now()
+
+		var p sourcePage
+		if err := json.Unmarshal(body, &p); err != nil {
+			return res, err
+		}
+
+		res.Pages = page
+		res.Rows = append(res.Rows, p.Data...)
+		progress(page, len(res.Rows))
+		retried = false
+
+		if !src.Paged || page >= p.LastPage {
+			return res, nil
+		}
+	}
+
+	return res, errors.New("upstream exceeded the page limit")
+}
@@ -0,0 +1,250 @@
+package internal_api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gigaflex-co/ppt_backend/cmd/mockupstream/mock"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+const mockToken = "mock-static-token"
+
+// stubTokens hands out tokens in order, moving to the next one on
+// Invalidate.
+type stubTokens struct {
+	tokens      []string
+	invalidated int
+}
+
+func (s *stubTokens) Token(context.Context) (string, error) {
+	return s.tokens[s.invalidated%len(s.tokens)], nil
+}
+
+func (s *stubTokens) Invalidate(context.Context) {
+	s.invalidated++
+}
+
+func newMockUpstream(t *testing.T, behavior mock.Behavior) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	srv := httptest.NewServer(mock.NewRouter(mock.Options{
+		Behavior:     behavior,
+		ClientID:     "mock-client",
+		ClientSecret: "mock-secret",
+		TokenTTL:     3600,
+		StaticToken:  mockToken,
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// mockSource points the source of dataset id at the mock. Without a
+// config file the configured base URLs are empty, so URL only holds the
+// path of the report.
+func mockSource(t *testing.T, srv *httptest.Server, id int) Source {
+	t.Helper()
+
+	src, ok := SourceByDatasetID(id)
+	if !ok {
+		t.Fatalf("no source for dataset %d", id)
+	}
+	if src.Paged {
+		src.URL = srv.URL + "/sipdps" + src.URL
+	} else {
+		src.URL = srv.URL + "/perbenihan"
+	}
+	return src
+}
+
+func setMockBehavior(t *testing.T, srv *httptest.Server, body string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/__mock/config", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /__mock/config: %s", resp.Status)
+	}
+}
+
+func TestFetchFromMock(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{PageSize: 5})
+
+	tests := []struct {
+		id    int
+		rows  int
+		pages int
+	}{
+		{SIPDPSTanamID, 23, 5},
+		{SIPDPSProduktivitasID, 12, 3},
+		{SIPDPSPusoID, 7, 2},
+		{SIPDPSPanenID, 15, 3},
+		{PerbenihanProdusenID, -1, 1},
+		{PerbenihanRekNasID, -1, 1},
+		{PerbenihanRekBpsbID, -1, 1},
+		{PerbenihanRekLssmID, -1, 1},
+		{PerbenihanRekPenyaluranID, -1, 1},
+		{PerbenihanRekPenyebaranID, -1, 1},
+		{PerbenihanRekProdusenID, -1, 1},
+	}
+
+	for _, tt := range tests {
+		ds, _ := DatasetByID(tt.id)
+		t.Run(ds.Slug, func(t *testing.T) {
+			src := mockSource(t, srv, tt.id)
+
+			progressed := 0
+			res, err := src.Fetch(context.Background(), &stubTokens{tokens: []string{mockToken}}, func(pages, rows int) {
+				progressed = pages
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.rows >= 0 && len(res.Rows) != tt.rows {
+				t.Errorf("fetched %d rows, want %d", len(res.Rows), tt.rows)
+			}
+			if len(res.Rows) == 0 {
+				t.Fatal("fetched no rows")
+			}
+			if res.Pages != tt.pages || progressed != tt.pages {
+				t.Errorf("pages = %d (progress %d), want %d", res.Pages, progressed, tt.pages)
+			}
+			if res.StatusCode != http.StatusOK || res.ResponseHash == "" || res.FetchedAt.IsZero() {
+				t.Errorf("provenance = %d/%q/%v", res.StatusCode, res.ResponseHash, res.FetchedAt)
+			}
+
+			again, err := src.Fetch(context.Background(), &stubTokens{tokens: []string{mockToken}}, func(int, int) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.ResponseHash != res.ResponseHash {
+				t.Error("the same responses hashed differently")
+			}
+
+			checked := ds.Validate(res.Rows, nil)
+			if got := len(checked.Valid) + len(checked.Quarantined); got != len(res.Rows) {
+				t.Errorf("validation accounted for %d of %d rows", got, len(res.Rows))
+			}
+			if len(checked.Valid) == 0 {
+				t.Errorf("no fixture row passed validation: %+v", checked.Quarantined[0].Violations)
+			}
+
+			first := ds.Fingerprints(res.Rows)
+			second := ds.Fingerprints(again.Rows)
+			if len(first) != len(res.Rows) {
+				t.Fatalf("%d fingerprints for %d rows", len(first), len(res.Rows))
+			}
+			keys := map[string]bool{}
+			for i := range first {
+				if first[i].Key != second[i].Key || !bytes.Equal(first[i].Hash, second[i].Hash) {
+					t.Errorf("row %d fingerprint changed between identical runs", i)
+				}
+				if keys[first[i].Key] {
+					t.Errorf("row %d key %q is not unique", i, first[i].Key)
+				}
+				keys[first[i].Key] = true
+			}
+		})
+	}
+}
+
+func TestFetchRetriesRejectedToken(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{PageSize: 10})
+
+	tests := []struct {
+		name        string
+		id          int
+		tokens      []string
+		invalidated int
+		err         error
+	}{
+		{"bearer token refreshed", SIPDPSTanamID, []string{"expired", mockToken}, 1, nil},
+		{"query token refreshed", PerbenihanProdusenID, []string{"expired", mockToken}, 1, nil},
+		{"refused twice", SIPDPSTanamID, []string{"expired", "revoked"}, 1, ErrCredentialsRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := &stubTokens{tokens: tt.tokens}
+			res, err := mockSource(t, srv, tt.id).Fetch(context.Background(), tokens, func(int, int) {})
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Fetch() error = %v, want %v", err, tt.err)
+			}
+			if tokens.invalidated != tt.invalidated {
+				t.Errorf("invalidated %d times, want %d", tokens.invalidated, tt.invalidated)
+			}
+			if tt.err != nil && res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestFetchKeepsRowsBeforeAFailure(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{PageSize: 5})
+	src := mockSource(t, srv, SIPDPSTanamID)
+
+	res, err := src.Fetch(context.Background(), &stubTokens{tokens: []string{mockToken}}, func(pages, rows int) {
+		if pages == 2 {
+			setMockBehavior(t, srv, `{"upstream": "sipdps", "page_size": 5, "fail_next": 1, "error_status": 404}`)
+		}
+	})
+
+	var ue *util.UpstreamError
+	if !errors.As(err, &ue) || ue.StatusCode != http.StatusNotFound {
+		t.Fatalf("Fetch() error = %v, want the upstream's 404", err)
+	}
+	if res.Pages != 2 || len(res.Rows) != 10 || res.StatusCode != http.StatusNotFound {
+		t.Errorf("pages/rows/status = %d/%d/%d, want 2/10/404", res.Pages, len(res.Rows), res.StatusCode)
+	}
+}
+
+func TestFetchRetriesServerErrors(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{FailNext: 1})
+
+	res, err := mockSource(t, srv, PerbenihanRekNasID).Fetch(context.Background(), &stubTokens{tokens: []string{mockToken}}, func(int, int) {})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v, want the failure retried", err)
+	}
+	if res.StatusCode != http.StatusOK || len(res.Rows) == 0 {
+		t.Errorf("status/rows = %d/%d", res.StatusCode, len(res.Rows))
+	}
+}
+
+func TestRequestClientCredentials(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{})
+	tokenURL := srv.URL + "/splp/oauth2/token"
+
+	res, err := requestClientCredentials(context.Background(), tokenURL, "mock-client", "mock-secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AccessToken == "" || res.ExpiresIn != 3600 {
+		t.Errorf("token response = %+v", res)
+	}
+
+	// The issued token works on a paged source.
+	_, err = mockSource(t, srv, SIPDPSPusoID).Fetch(context.Background(), &stubTokens{tokens: []string{res.AccessToken}}, func(int, int) {})
+	if err != nil {
+		t.Errorf("Fetch() with the issued token: %v", err)
+	}
+
+	_, err = requestClientCredentials(context.Background(), tokenURL, "mock-client", "wrong", "")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("requestClientCredentials() with a wrong secret = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
@@ -0,0 +1,265 @@
+package internal_api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	oauthTokenKeyPrefix = "ppt:oauth-token:"
+	credentialAlertKey  = "ppt:credential-alert:"
+
+	// tokenRefreshMargin is how long before a cached token expires a
+	// replacement is requested in the background, while requests keep
+	// using the current one. tokenExpiryMargin drops the token from the
+	// cache slightly before the upstream expires it, so a page request
+	// never goes out with a dying token.
+	tokenRefreshMargin = 2 * time.Minute
+	tokenExpiryMargin  = 30 * time.Second
+	minTokenTTL        = 30 * time.Second
+	tokenFetchTimeout  = 30 * time.Second
+
+	// credentialAlertEvery limits how often admins hear about the same
+	// broken credentials.
+	credentialAlertEvery = 6 * time.Hour
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("upstream rejected the client credentials")
+	ErrCredentialsRejected = errors.New("upstream rejected the access token")
+)
+
+type (
+	// OAuthClient names the client-credentials grant of a source. Client id,
+	// secret and optionally the token URL are read from ppt_configurations
+	// as <Name>_client_id, <Name>_client_secret and <Name>_token_url. The
+	// secret is stored encrypted, like smtp_email_password.
+	OAuthClient struct {
+		Name     string
+		TokenURL string
+		Scope    string
+	}
+
+	// TokenSource hands out the access token for one source. Invalidate
+	// drops a token the upstream refused so the next Token fetches anew.
+	TokenSource interface {
+		Token(ctx context.Context) (string, error)
+		Invalidate(ctx context.Context)
+	}
+
+	// tokenProvider issues tokens for every source: OAuth2 client
+	// credentials cached in Redis where configured, otherwise the static
+	// token from ppt_configurations.
+	tokenProvider struct {
+		repo InternalApiRepository
+		rdb  *redis.Client
+		mu   sync.Mutex
+	}
+
+	// sourceTokens remembers in credential which configuration the last
+	// token came from, so alerts name the one to fix.
+	sourceTokens struct {
+		provider   *tokenProvider
+		src        Source
+		credential string
+	}
+
+	oauthTokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+)
+
+func newTokenProvider(repo InternalApiRepository, rdb *redis.Client) *tokenProvider {
+	return &tokenProvider{repo: repo, rdb: rdb}
+}
+
+func (p *tokenProvider) For(src Source) *sourceTokens {
+	return &sourceTokens{provider: p, src: src}
+}
+
+func (t *sourceTokens) Token(ctx context.Context) (string, error) {
+	if t.src.OAuth != nil {
+		t.credential = t.src.OAuth.Name
+		token, err := t.provider.oauthToken(ctx, *t.src.OAuth)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return token, err
+		}
+		// No client credentials configured yet; use the static token.
+	}
+
+	t.credential = t.src.TokenKey
+	token, err := t.provider.repo.GetToken(ctx, t.src.TokenKey)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", t.src.TokenKey, err)
+	}
+	return token, nil
+}
+
+func (t *sourceTokens) Invalidate(ctx context.Context) {
+	if t.src.OAuth != nil {
+		t.provider.rdb.Del(ctx, oauthTokenKeyPrefix+t.src.OAuth.Name)
+	}
+}
+
+// oauthToken returns the cached token, or requests a new one. A cached
+// token close to expiry is still returned while a replacement is
+// requested in the background, so requests only wait on the token
+// endpoint when there is no usable token at all. The mutex keeps
+// concurrent callers in this process from requesting several.
+func (p *tokenProvider) oauthToken(ctx context.Context, client OAuthClient) (string, error) {
+	key := oauthTokenKeyPrefix + client.Name
+
+	pipe := p.rdb.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return "", err
+	}
+	if token, err := get.Result(); err == nil {
+		if ttl.Val() < tokenRefreshMargin {
+			p.refreshInBackground(client)
+		}
+		return token, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if token, err := p.rdb.Get(ctx, key).Result(); err == nil {
+		return token, nil
+	}
+
+	return p.requestToken(ctx, client)
+}
+
+// refreshInBackground replaces the cached token unless a request for one
+// is already under way.
+func (p *tokenProvider) refreshInBackground(client OAuthClient) {
+	if !p.mu.TryLock() {
+		return
+	}
+
+	go func() {
+		defer p.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), tokenFetchTimeout)
+		defer cancel()
+
+		if _, err := p.requestToken(ctx, client); err != nil {
+			log.Printf("refreshing %s access token: %v", client.Name, err)
+		}
+	}()
+}
+
+// requestToken gets a new token from the token endpoint and caches it.
+// The caller holds the mutex.
+func (p *tokenProvider) requestToken(ctx context.Context, client OAuthClient) (string, error) {
+	key := oauthTokenKeyPrefix + client.Name
+
+	clientID, err := p.repo.GetToken(ctx, client.Name+"_client_id")
+	if err != nil {
+		return "", err
+	}
+	encSecret, err := p.repo.GetToken(ctx, client.Name+"_client_secret")
+	if err != nil {
+		return "", err
+	}
+	clientSecret, err := util.Decrypt(encSecret, "f")
+	if err != nil {
+		return "", fmt.Errorf("%s_client_secret must be stored encrypted: %w", client.Name, err)
+	}
+	tokenURL := client.TokenURL
+	if override, err := p.repo.GetToken(ctx, client.Name+"_token_url"); err == nil && override != "" {
+		tokenURL = override
+	}
+
+	res, err := requestClientCredentials(ctx, tokenURL, clientID, clientSecret, client.Scope)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			p.Alert(ctx, client.Name, err)
+		}
+		return "", err
+	}
+
+	ttl := time.Duration(res.ExpiresIn)*time.Second - tokenExpiryMargin
+	if ttl < minTokenTTL {
+		ttl = minTokenTTL
+	}
+	if err := p.rdb.Set(ctx, key, res.AccessToken, ttl).Err(); err != nil {
+		log.Printf("caching %s access token: %v", client.Name, err)
+	}
+
+	return res.AccessToken, nil
+}
+
+func requestClientCredentials(ctx context.Context, tokenURL, clientID, clientSecret, scope string) (oauthTokenResponse, error) {
+	var res oauthTokenResponse
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	upstream := util.UpstreamFor(tokenURL)
+	response, err := upstream.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return res, err
+	}
+	_ = json.Unmarshal(body, &res)
+
+	switch {
+	case response.StatusCode == http.StatusUnauthorized,
+		response.StatusCode == http.StatusBadRequest && (res.Error == "invalid_client" || res.Error == "unauthorized_client"):
+		return res, fmt.Errorf("%w (%s)", ErrInvalidCredentials, res.Error)
+	case response.StatusCode != http.StatusOK:
+		return res, &util.UpstreamError{Host: req.URL.Hostname(), StatusCode: response.StatusCode, Kind: util.ErrUpstreamResponse}
+	case res.AccessToken == "":
+		return res, errors.New("token response has no access_token")
+	}
+
+	return res, nil
+}
+
+// Alert tells every admin that the credentials named name stopped working.
+// Repeats within credentialAlertEvery are dropped.
+func (p *tokenProvider) Alert(ctx context.Context, name string, cause error) {
+	ok, err := p.rdb.SetNX(ctx, credentialAlertKey+name, time.Now().Format(time.RFC3339), credentialAlertEvery).Result()
+	if err != nil || !ok {
+		return
+	}
+
+	title := "Kredensial API " + name + " ditolak"
+	message := fmt.Sprintf("Pengambilan data dari upstream gagal karena kredensial %s tidak valid atau kedaluwarsa: %v. Perbarui nilai di konfigurasi.", name, cause)
+	if err := p.repo.NotifyAdmins(ctx, title, message); err != nil {
+		log.Printf("alerting admins about %s credentials: %v", name, err)
+	}
+}
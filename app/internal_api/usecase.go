@@ -2,16 +2,25 @@ package internal_api
 
 import (
 	"database/sql"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/gigaflex-co/ppt_backend/app/region"
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
-	"github.com/go-resty/resty/v2"
+	"github.com/redis/go-redis/v9"
 )
 
+// maxDatasetPageSize caps page_size on the dataset query API.
+const maxDatasetPageSize = 1000
+
 type (
 	InternalApiUsecase interface {
 		GetAll(c *gin.Context)
@@ -41,211 +50,221 @@ type (
 		GetPerbenihanRekPenyebaran(c *gin.Context)
 		GetPerbenihanRekProdusen(c *gin.Context)
 
-		// SIMLUH
-		GetSimluhSertifikat(c *gin.Context)
-		GetSimluhRiwayatPelatihan(c *gin.Context)
+		GetJob(c *gin.Context)
+		GetSchema(c *gin.Context)
+		GetUpstreamStatus(c *gin.Context)
+
+		// Data quality
+		GetQualityReports(c *gin.Context)
+		GetQualityReport(c *gin.Context)
+		ReviewQualityReport(c *gin.Context)
+		GetRuns(c *gin.Context)
+		GetRun(c *gin.Context)
+		DiffRuns(c *gin.Context)
+		QueryDataset(c *gin.Context)
+		GetMetrics(c *gin.Context)
+		Aggregate(c *gin.Context)
+		GetGeoJSON(c *gin.Context)
+		ExportDataset(c *gin.Context)
+		GetSeedAnomalies(c *gin.Context)
+		ReviewSeedAnomaly(c *gin.Context)
+		RunSeedChecks(c *gin.Context)
+		SearchDatasets(c *gin.Context)
+		ReindexDataset(c *gin.Context)
 	}
 
 	usecase struct {
-		repo InternalApiRepository
+		repo    InternalApiRepository
+		jobs    *jobRunner
+		indexer *datasetIndexer
 	}
 )
 
-func NewUsecase(repo InternalApiRepository) InternalApiUsecase {
+func NewUsecase(repo InternalApiRepository, rdb *redis.Client, es *elasticsearch.Client, reconciler *region.Reconciler) InternalApiUsecase {
+	indexer := &datasetIndexer{es: es, repo: repo}
 	return &usecase{
-		repo: repo,
+		repo:    repo,
+		jobs:    newJobRunner(repo, rdb, indexer, reconciler),
+		indexer: indexer,
 	}
 }
 
-func (uc *usecase) GetAll(c *gin.Context) {
-	data, err := uc.repo.GetAll(c)
-	if err != nil {
-		// Handle the error accordingly.
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// enqueueFetch schedules an ingestion job for the dataset and answers right
+// away with the job; progress is available from GetJob and the WebSocket
+// job feed.
+func (uc *usecase) enqueueFetch(c *gin.Context, id int) {
+	ds, ok := DatasetByID(id)
+	if !ok {
+		util.JERR(c, http.StatusInternalServerError, errors.New("unknown dataset"))
 		return
 	}
-	util.JOK(c, http.StatusOK, data)
-}
 
-func (uc *usecase) GetSIPDPSTanamFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_sipdps_jawa_barat")
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+	requestedBy, _ := util.ClaimsEmail(c)
+
+	job, err := uc.jobs.Enqueue(c, ds, requestedBy)
+	if err == ErrQueueFull {
+		util.JERR(c, http.StatusServiceUnavailable, err)
 		return
 	}
-
-	response, err := resty.New().R().
-		SetHeader("Authorization", "Bearer "+token).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"provinsi": "12",
-			"page":     "1",
-		}).
-		Get("https://api-splp.layanan.go.id/t/pertanian.go.id/TP-SIPDPS/1.0/v2/data-laporan-tanam")
-
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
-	}
-
-	responseBytes := response.Body()
+	util.JOK(c, http.StatusAccepted, job)
+}
 
-	var res SIPDPS1
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+func (uc *usecase) GetJob(c *gin.Context) {
+	job, err := uc.jobs.Get(c, c.Param("id"))
+	if err == redis.Nil {
+		util.JERR(c, http.StatusNotFound, errors.New("job not found"))
 		return
 	}
-
-	err = uc.repo.StoreSIPDPSTanamFetch(c, res.Data, 1)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	util.JOK(c, http.StatusOK, job)
 }
 
-func (uc *usecase) GetSIPDPSProduktivitasFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_sipdps_jawa_barat")
+func (uc *usecase) GetAll(c *gin.Context) {
+	data, err := uc.repo.GetAll(c)
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		// Handle the error accordingly.
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	util.JOK(c, http.StatusOK, data)
+}
 
-	response, err := resty.New().R().
-		SetHeader("Authorization", "Bearer "+token).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"provinsi": "12",
-			"page":     "1",
-		}).
-		Get("https://api-splp.layanan.go.id/t/pertanian.go.id/TP-SIPDPS/1.0/v2/data-laporan-produktivitas")
+func (uc *usecase) GetSIPDPSTanamFetch(c *gin.Context) {
+	uc.enqueueFetch(c, SIPDPSTanamID)
+}
 
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
-	}
+func (uc *usecase) GetSIPDPSProduktivitasFetch(c *gin.Context) {
+	uc.enqueueFetch(c, SIPDPSProduktivitasID)
+}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
-	}
+func (uc *usecase) GetSIPDPSPusoFetch(c *gin.Context) {
+	uc.enqueueFetch(c, SIPDPSPusoID)
+}
 
-	responseBytes := response.Body()
+func (uc *usecase) GetSIPDPSPanenFetch(c *gin.Context) {
+	uc.enqueueFetch(c, SIPDPSPanenID)
+}
 
-	var res SIPDPS2
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
+func (uc *usecase) GetSIPDPSTanam(c *gin.Context) {
+	data, err := uc.repo.SIPDPSTanamRead(c, SIPDPSTanamID)
+	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	err = uc.repo.StoreSIPDPSProduktivitasFetch(c, res.Data, 2)
-	if err != nil {
+	util.JOK(c, http.StatusOK, data)
+}
+
+func (uc *usecase) GetSIPDPSProduktivitas(c *gin.Context) {
+	data, err := uc.repo.SIPDPSProduktivitasRead(c, SIPDPSProduktivitasID)
+	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetSIPDPSPusoFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_sipdps_jawa_barat")
-	if err != nil {
+func (uc *usecase) GetSIPDPSPuso(c *gin.Context) {
+	data, err := uc.repo.SIPDPSPusoRead(c, SIPDPSPusoID)
+	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Authorization", "Bearer "+token).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"provinsi": "12",
-			"page":     "1",
-		}).
-		Get("https://api-splp.layanan.go.id/t/pertanian.go.id/TP-SIPDPS/1.0/v2/data-laporan-puso")
+	util.JOK(c, http.StatusOK, data)
+}
 
-	if err != nil {
+func (uc *usecase) GetSIPDPSPanen(c *gin.Context) {
+	data, err := uc.repo.SIPDPSPanenRead(c, SIPDPSPanenID)
+	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
-	}
+	util.JOK(c, http.StatusOK, data)
+}
 
-	responseBytes := response.Body()
+func (uc *usecase) GetPerbenihanProdusenFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanProdusenID)
+}
 
-	var res SIPDPS3
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
-	}
+func (uc *usecase) GetPerbenihanRekNasFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanRekNasID)
+}
 
-	err = uc.repo.StoreSIPDPSPusoFetch(c, res.Data, 3)
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
-	}
+func (uc *usecase) GetPerbenihanRekBpsbFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanRekBpsbID)
+}
 
-	util.JOK(c, http.StatusOK, "success")
+func (uc *usecase) GetPerbenihanRekLssmFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanRekLssmID)
 }
 
-func (uc *usecase) GetSIPDPSPanenFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_sipdps_jawa_barat")
-	if err != nil {
+func (uc *usecase) GetPerbenihanRekPenyaluranFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanRekPenyaluranID)
+}
+
+func (uc *usecase) GetPerbenihanRekPenyebaranFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanRekPenyebaranID)
+}
+
+func (uc *usecase) GetPerbenihanRekProdusenFetch(c *gin.Context) {
+	uc.enqueueFetch(c, PerbenihanRekProdusenID)
+}
+
+func (uc *usecase) GetPerbenihanProdusen(c *gin.Context) {
+	data, err := uc.repo.PerbenihanProdusenRead(c, PerbenihanProdusenID)
+	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Authorization", "Bearer "+token).
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"provinsi": "12",
-			"page":     "1",
-		}).
-		Get("https://api-splp.layanan.go.id/t/pertanian.go.id/TP-SIPDPS/1.0/v2/data-laporan-panen")
+	util.JOK(c, http.StatusOK, data)
+}
 
+func (uc *usecase) GetPerbenihanRekNas(c *gin.Context) {
+	data, err := uc.repo.PerbenihanRekNasRead(c, PerbenihanRekNasID)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
-	}
-
-	responseBytes := response.Body()
+	util.JOK(c, http.StatusOK, data)
+}
 
-	var res SIPDPS4
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
+func (uc *usecase) GetPerbenihanRekBpsb(c *gin.Context) {
+	data, err := uc.repo.PerbenihanRekBpsbRead(c, PerbenihanRekBpsbID)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	err = uc.repo.StoreSIPDPSPanenFetch(c, res.Data, 4)
+	util.JOK(c, http.StatusOK, data)
+}
+
+func (uc *usecase) GetPerbenihanRekLssm(c *gin.Context) {
+	data, err := uc.repo.PerbenihanRekLssmRead(c, PerbenihanRekLssmID)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetSIPDPSTanam(c *gin.Context) {
-	data, err := uc.repo.SIPDPSTanamRead(c, 1)
-	if err != nil && err != sql.ErrNoRows {
+func (uc *usecase) GetPerbenihanRekPenyaluran(c *gin.Context) {
+	data, err := uc.repo.PerbenihanRekPenyaluranRead(c, PerbenihanRekPenyaluranID)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -253,9 +272,9 @@ func (uc *usecase) GetSIPDPSTanam(c *gin.Context) {
 	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetSIPDPSProduktivitas(c *gin.Context) {
-	data, err := uc.repo.SIPDPSProduktivitasRead(c, 2)
-	if err != nil && err != sql.ErrNoRows {
+func (uc *usecase) GetPerbenihanRekPenyebaran(c *gin.Context) {
+	data, err := uc.repo.PerbenihanRekPenyebaranRead(c, PerbenihanRekPenyebaranID)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -263,9 +282,9 @@ func (uc *usecase) GetSIPDPSProduktivitas(c *gin.Context) {
 	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetSIPDPSPuso(c *gin.Context) {
-	data, err := uc.repo.SIPDPSPusoRead(c, 3)
-	if err != nil && err != sql.ErrNoRows {
+func (uc *usecase) GetPerbenihanRekProdusen(c *gin.Context) {
+	data, err := uc.repo.PerbenihanRekProdusenRead(c, PerbenihanRekProdusenID)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -273,9 +292,15 @@ func (uc *usecase) GetSIPDPSPuso(c *gin.Context) {
 	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetSIPDPSPanen(c *gin.Context) {
-	data, err := uc.repo.SIPDPSPanenRead(c, 4)
-	if err != nil && err != sql.ErrNoRows {
+func (uc *usecase) GetSchema(c *gin.Context) {
+	ds, ok := DatasetBySlug(c.Param("slug"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
+		return
+	}
+
+	data, err := uc.repo.GetSchema(c, ds.ID)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -283,218 +308,460 @@ func (uc *usecase) GetSIPDPSPanen(c *gin.Context) {
 	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetPerbenihanProdusenFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
+// parseDatasetFilter reads the record filters shared by the query and
+// aggregation endpoints. Filters the dataset has no field for are rejected
+// rather than silently ignored.
+func parseDatasetFilter(c *gin.Context, ds Dataset) (DatasetQuery, error) {
+	arg := DatasetQuery{
+		Province:    c.Query("province"),
+		Regency:     c.Query("kabupaten"),
+		Subdistrict: c.Query("kecamatan"),
+		Region:      c.Query("region"),
+		Commodity:   c.Query("commodity"),
+		Variety:     c.Query("variety"),
+		DateFrom:    c.Query("date_from"),
+		DateTo:      c.Query("date_to"),
+	}
+
+	filters := []struct {
+		name, key, value string
+	}{
+		{"province", ds.ProvinceKey, arg.Province},
+		{"kabupaten", ds.RegencyKey, arg.Regency},
+		{"kecamatan", ds.SubdistrictKey, arg.Subdistrict},
+		{"region", ds.ProvinceKey, arg.Region},
+		{"commodity", ds.CommodityKey, arg.Commodity},
+		{"variety", ds.VarietyKey, arg.Variety},
+		{"date_from", ds.DateKey, arg.DateFrom},
+		{"date_to", ds.DateKey, arg.DateTo},
+	}
+	for _, f := range filters {
+		if f.value != "" && f.key == "" {
+			return arg, fmt.Errorf("%s filter is not supported by %s", f.name, ds.Slug)
+		}
+	}
+
+	if arg.Region != "" && region.KodeLevel(arg.Region) == 0 {
+		return arg, fmt.Errorf("invalid region %q, expected a ppt_wilayah kode", arg.Region)
+	}
+
+	for _, d := range []string{arg.DateFrom, arg.DateTo} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return arg, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+	}
+
+	return arg, nil
+}
+
+// parseDatasetQuery adds sorting, projection and paging to the filters. Sort
+// keys and projected fields must exist in the dataset.
+func parseDatasetQuery(c *gin.Context, ds Dataset) (DatasetQuery, error) {
+	arg, err := parseDatasetFilter(c, ds)
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
+		return arg, err
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "seluruh",
-			"jenis": "produsen",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
+	// sort=-tgl_lapor,nm_kab sorts by tgl_lapor descending, then nm_kab.
+	for _, key := range splitList(c.Query("sort")) {
+		sf := SortField{Key: key}
+		if strings.HasPrefix(key, "-") {
+			sf = SortField{Key: key[1:], Desc: true}
+		}
+		if _, ok := ds.Field(sf.Key); !ok {
+			return arg, fmt.Errorf("unknown sort field %q", sf.Key)
+		}
+		arg.Sort = append(arg.Sort, sf)
+	}
 
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
+	for _, key := range splitList(c.Query("fields")) {
+		if _, ok := ds.Field(key); !ok {
+			return arg, fmt.Errorf("unknown field %q", key)
+		}
+		arg.Fields = append(arg.Fields, key)
+	}
+
+	arg.Page, arg.PageSize = pageParams(c)
+
+	return arg, nil
+}
+
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
+func (uc *usecase) QueryDataset(c *gin.Context) {
+	ds, ok := DatasetBySlug(c.Param("slug"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
 		return
 	}
 
-	responseBytes := response.Body()
+	arg, err := parseDatasetQuery(c, ds)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
 
-	var res Perbenihan4
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
+	totalRecords, err := uc.repo.CountDataset(c, ds, arg)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	err = uc.repo.StorePerbenihanProdusenFetch(c, res.Data, 5)
+	data, err := uc.repo.QueryDataset(c, ds, arg)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	response := DataWithPagination{
+		Row:        data,
+		Pagination: paginate(arg.Page, arg.PageSize, totalRecords),
+	}
+
+	util.JOK(c, http.StatusOK, response)
 }
 
-func (uc *usecase) GetPerbenihanRekNasFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+func (uc *usecase) GetMetrics(c *gin.Context) {
+	util.JOK(c, http.StatusOK, metrics)
+}
+
+// Aggregate serves chart-ready series, e.g.
+// api-dataset-aggregate/luas-tanam?group_by=month&series_by=commodity.
+func (uc *usecase) Aggregate(c *gin.Context) {
+	m, ok := MetricBySlug(c.Param("metric"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("metric not found"))
 		return
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "rekapitulasi",
-			"jenis": "nasional",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
-
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+	ds, ok := DatasetByID(m.DatasetID)
+	if !ok {
+		util.JERR(c, http.StatusInternalServerError, errors.New("unknown dataset"))
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
+	arg, err := parseDatasetFilter(c, ds)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
 		return
 	}
 
-	responseBytes := response.Body()
+	groupBy := c.DefaultQuery("group_by", "province")
+	seriesBy := c.Query("series_by")
 
-	var res Perbenihan1
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+	if _, err := dimensionExpr(ds, groupBy); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
 		return
 	}
+	if seriesBy != "" {
+		if _, err := dimensionExpr(ds, seriesBy); err != nil {
+			util.JERR(c, http.StatusBadRequest, err)
+			return
+		}
+	}
 
-	err = uc.repo.StorePerbenihanRekNasFetch(c, res.Data, 6)
+	rows, err := uc.repo.Aggregate(c, ds, m, arg, groupBy, seriesBy)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	defaultName := m.Label
+	if seriesBy != "" {
+		defaultName = "-"
+	}
+	labels, series := chartSeries(rows, defaultName)
+
+	util.JOK(c, http.StatusOK, ChartResponse{
+		Metric:   m,
+		GroupBy:  groupBy,
+		SeriesBy: seriesBy,
+		Labels:   labels,
+		Series:   series,
+	})
 }
 
-func (uc *usecase) GetPerbenihanRekBpsbFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+// GetGeoJSON serves the records of a dataset with coordinates as a GeoJSON
+// FeatureCollection, e.g.
+// api-dataset-geojson/sipdps-laporan-tanam?bbox=106,-7,108,-6&zoom=9&properties=nm_desa,status.
+func (uc *usecase) GetGeoJSON(c *gin.Context) {
+	ds, ok := DatasetBySlug(c.Param("slug"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
+		return
+	}
+	if !ds.HasCoordinates() {
+		util.JERR(c, http.StatusNotFound, fmt.Errorf("%s has no coordinates", ds.Slug))
 		return
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "rekapitulasi",
-			"jenis": "bpsb",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
+	arg, err := parseGeoQuery(c, ds)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
 
+	rows, err := uc.repo.GeoPoints(c, ds, arg)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
+	response := GeoFeatureCollection{
+		Type: "FeatureCollection",
+		BBox: arg.BBox,
+	}
+	if len(rows) > maxGeoFeatures {
+		rows = rows[:maxGeoFeatures]
+		response.Truncated = true
 	}
+	response.Features = geoFeatures(rows)
 
-	responseBytes := response.Body()
+	util.JOK(c, http.StatusOK, response)
+}
 
-	var res Perbenihan1
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+// ExportDataset streams the filtered records as csv or xlsx, e.g.
+// api-dataset-export/sipdps-laporan-tanam?format=xlsx&province=jawa%20barat&fields=tgl_lapor,luas_area.
+// Csv is sent while the records are read; xlsx is assembled on disk first
+// and sent in one piece once the last record is written.
+func (uc *usecase) ExportDataset(c *gin.Context) {
+	ds, ok := DatasetBySlug(c.Param("slug"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
+		return
+	}
+
+	format := c.DefaultQuery("format", ExportCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		util.JERR(c, http.StatusBadRequest, fmt.Errorf("unsupported export format %q", format))
 		return
 	}
 
-	err = uc.repo.StorePerbenihanRekBpsbFetch(c, res.Data, 7)
+	arg, err := parseDatasetQuery(c, ds)
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusBadRequest, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	columns := exportColumns(ds, arg.Fields)
+	labels := make([]string, len(columns))
+	for i, f := range columns {
+		labels[i] = f.Label
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", ds.Slug, time.Now().Format("20060102"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	w, err := newRecordWriter(format, c.Writer)
+	if err == nil {
+		err = w.WriteHeader(labels)
+	}
+	if err == nil {
+		values := make([]interface{}, len(columns))
+		err = uc.repo.EachRecord(c, ds, arg, func(data map[string]interface{}) error {
+			for i, f := range columns {
+				values[i] = data[f.Key]
+			}
+			return w.WriteRow(values)
+		})
+	}
+	if err == nil {
+		err = w.Close()
+	}
+
+	if err != nil {
+		// Once bytes reached the client the status can no longer change;
+		// the truncated download is all that can be done.
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+		log.Printf("exporting %s: %v", ds.Slug, err)
+		c.Abort()
+	}
 }
 
-func (uc *usecase) GetPerbenihanRekLssmFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
+// parseGeoQuery reads bbox=minLng,minLat,maxLng,maxLat, zoom and properties
+// on top of the tabular filters. Without zoom every point is returned.
+func parseGeoQuery(c *gin.Context, ds Dataset) (GeoQuery, error) {
+	filter, err := parseDatasetFilter(c, ds)
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
+		return GeoQuery{}, err
 	}
+	arg := GeoQuery{Filter: filter, Zoom: -1}
 
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "rekapitulasi",
-			"jenis": "lssm",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
+	if bbox := c.Query("bbox"); bbox != "" {
+		parts := splitList(bbox)
+		if len(parts) != 4 {
+			return arg, errors.New("bbox must be minLng,minLat,maxLng,maxLat")
+		}
+		for _, p := range parts {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return arg, fmt.Errorf("invalid bbox value %q", p)
+			}
+			arg.BBox = append(arg.BBox, v)
+		}
+		if arg.BBox[0] > arg.BBox[2] || arg.BBox[1] > arg.BBox[3] {
+			return arg, errors.New("bbox minimum exceeds its maximum")
+		}
+	}
+
+	if zoom := c.Query("zoom"); zoom != "" {
+		z, err := strconv.Atoi(zoom)
+		if err != nil || z < 0 || z > maxGeoZoom {
+			return arg, fmt.Errorf("zoom must be between 0 and %d", maxGeoZoom)
+		}
+		arg.Zoom = z
+	}
+
+	for _, key := range splitList(c.Query("properties")) {
+		if _, ok := ds.Field(key); !ok {
+			return arg, fmt.Errorf("unknown field %q", key)
+		}
+		arg.Properties = append(arg.Properties, key)
+	}
+
+	return arg, nil
+}
+
+func (uc *usecase) GetUpstreamStatus(c *gin.Context) {
+	util.JOK(c, http.StatusOK, util.UpstreamStates())
+}
+
+// pageParams reads page and page_size the way the table endpoints do.
+func pageParams(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > maxDatasetPageSize {
+		pageSize = maxDatasetPageSize
+	}
+	return page, pageSize
+}
 
+func paginate(page, pageSize, totalRecords int) util.PaginationResponse {
+	return util.PaginationResponse{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
+
+func reportIDParam(c *gin.Context) (int64, error) {
+	return decryptID(c.Param("id"))
+}
+
+func decryptID(encrypted string) (int64, error) {
+	id, err := util.Decrypt(encrypted, "f")
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
+		return 0, errors.New("record not found")
 	}
+	return strconv.ParseInt(id, 10, 64)
+}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
+func (uc *usecase) GetQualityReports(c *gin.Context) {
+	datasetID := 0
+	if slug := c.Query("dataset"); slug != "" {
+		ds, ok := DatasetBySlug(slug)
+		if !ok {
+			util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
+			return
+		}
+		datasetID = ds.ID
 	}
 
-	responseBytes := response.Body()
+	page, pageSize := pageParams(c)
 
-	var res Perbenihan1
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
+	totalRecords, err := uc.repo.CountQualityReports(c, datasetID)
+	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	err = uc.repo.StorePerbenihanRekLssmFetch(c, res.Data, 8)
+	data, err := uc.repo.GetQualityReports(c, datasetID, page, pageSize)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	util.JOK(c, http.StatusOK, QualityReportsWithPagination{
+		Row:        data,
+		Pagination: paginate(page, pageSize, totalRecords),
+	})
 }
 
-func (uc *usecase) GetPerbenihanRekPenyaluranFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
+// GetQualityReport returns one report with a page of its quarantined rows.
+func (uc *usecase) GetQualityReport(c *gin.Context) {
+	id, err := reportIDParam(c)
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
+
+	report, err := uc.repo.GetQualityReport(c, id)
+	if err == sql.ErrNoRows {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "rekapitulasi",
-			"jenis": "penyaluran",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
+	page, pageSize := pageParams(c)
 
+	rows, err := uc.repo.GetQuarantinedRows(c, id, page, pageSize)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
+	util.JOK(c, http.StatusOK, QuarantineWithPagination{
+		Report:     report,
+		Row:        rows,
+		Pagination: paginate(page, pageSize, report.QuarantinedRows),
+	})
+}
+
+func (uc *usecase) ReviewQualityReport(c *gin.Context) {
+	id, err := reportIDParam(c)
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
 		return
 	}
 
-	responseBytes := response.Body()
-
-	var res Perbenihan2
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+	reviewer, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
 		return
 	}
 
-	err = uc.repo.StorePerbenihanRekPenyaluranFetch(c, res.Data, 9)
+	err = uc.repo.ReviewQualityReport(c, id, reviewer)
+	if err == sql.ErrNoRows {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
@@ -503,203 +770,243 @@ func (uc *usecase) GetPerbenihanRekPenyaluranFetch(c *gin.Context) {
 	util.JOK(c, http.StatusOK, "success")
 }
 
-func (uc *usecase) GetPerbenihanRekPenyebaranFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
+func (uc *usecase) GetRuns(c *gin.Context) {
+	datasetID := 0
+	if slug := c.Query("dataset"); slug != "" {
+		ds, ok := DatasetBySlug(slug)
+		if !ok {
+			util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
+			return
+		}
+		datasetID = ds.ID
 	}
 
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "rekapitulasi",
-			"jenis": "penyebaran",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
+	page, pageSize := pageParams(c)
 
+	totalRecords, err := uc.repo.CountRuns(c, datasetID)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
+	data, err := uc.repo.GetRuns(c, datasetID, page, pageSize)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	responseBytes := response.Body()
+	util.JOK(c, http.StatusOK, RunsWithPagination{
+		Row:        data,
+		Pagination: paginate(page, pageSize, totalRecords),
+	})
+}
 
-	var res Perbenihan3
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+func (uc *usecase) GetRun(c *gin.Context) {
+	id, err := decryptID(c.Param("id"))
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
 		return
 	}
 
-	err = uc.repo.StorePerbenihanRekPenyebaranFetch(c, res.Data, 10)
+	data, err := uc.repo.GetRun(c, id)
+	if err == sql.ErrNoRows {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, "success")
+	util.JOK(c, http.StatusOK, data)
 }
 
-func (uc *usecase) GetPerbenihanRekProdusenFetch(c *gin.Context) {
-	token, err := uc.repo.GetToken(c, "api_token_perbenihan")
+// DiffRuns summarises what changed between two runs of one dataset, e.g.
+// api-ingest-runs-diff?from=<run id>&to=<run id>.
+func (uc *usecase) DiffRuns(c *gin.Context) {
+	fromID, err := decryptID(c.Query("from"))
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusBadRequest, errors.New("from is not a valid run id"))
 		return
 	}
-
-	response, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetQueryParams(map[string]string{
-			"token": token,
-			"rekap": "rekapitulasi",
-			"jenis": "produsen",
-		}).
-		Get("https://apps.tanamanpangan.pertanian.go.id/api/perbenihan")
-
+	toID, err := decryptID(c.Query("to"))
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusBadRequest, errors.New("to is not a valid run id"))
 		return
 	}
 
-	if response.StatusCode() != http.StatusOK {
-		log.Printf("error %v", response.StatusCode())
-		util.JERR(c, http.StatusInternalServerError, errors.New("error"))
-		return
+	runs := make([]IngestionRun, 2)
+	for i, id := range []int64{fromID, toID} {
+		runs[i], err = uc.repo.GetRun(c, id)
+		if err == sql.ErrNoRows {
+			util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+			return
+		}
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
 	}
 
-	responseBytes := response.Body()
+	if runs[0].DatasetID != runs[1].DatasetID {
+		util.JERR(c, http.StatusBadRequest, errors.New("runs belong to different datasets"))
+		return
+	}
 
-	var res Perbenihan4
-	if err := json.Unmarshal(responseBytes, &res); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+	if runs[0].RowsPruned || runs[1].RowsPruned {
+		util.JERR(c, http.StatusGone, fmt.Errorf("only the latest %d runs of a dataset can be diffed", keptRunFingerprints))
 		return
 	}
 
-	err = uc.repo.StorePerbenihanRekProdusenFetch(c, res.Data, 11)
+	diff, err := uc.repo.DiffRuns(c, fromID, toID)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
+	diff.From, diff.To = runs[0], runs[1]
 
-	util.JOK(c, http.StatusOK, "success")
+	util.JOK(c, http.StatusOK, diff)
 }
 
-func (uc *usecase) GetPerbenihanProdusen(c *gin.Context) {
-	data, err := uc.repo.PerbenihanProdusenRead(c, 5)
-	if err != nil && err != sql.ErrNoRows {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
+func (uc *usecase) GetSeedAnomalies(c *gin.Context) {
+	filter := SeedAnomalyFilter{
+		Check:    c.Query("check"),
+		Severity: c.Query("severity"),
+		Status:   c.DefaultQuery("status", AnomalyOpen),
+		Region:   c.Query("region"),
+	}
+	if filter.Status == "all" {
+		filter.Status = ""
 	}
 
-	util.JOK(c, http.StatusOK, data)
-}
+	page, pageSize := pageParams(c)
 
-func (uc *usecase) GetPerbenihanRekNas(c *gin.Context) {
-	data, err := uc.repo.PerbenihanRekNasRead(c, 6)
+	totalRecords, err := uc.repo.CountSeedAnomalies(c, filter)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, data)
-}
-
-func (uc *usecase) GetPerbenihanRekBpsb(c *gin.Context) {
-	data, err := uc.repo.PerbenihanRekBpsbRead(c, 7)
+	data, err := uc.repo.GetSeedAnomalies(c, filter, page, pageSize)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, data)
+	util.JOK(c, http.StatusOK, SeedAnomaliesWithPagination{
+		Row:        data,
+		Pagination: paginate(page, pageSize, totalRecords),
+	})
 }
 
-func (uc *usecase) GetPerbenihanRekLssm(c *gin.Context) {
-	data, err := uc.repo.PerbenihanRekLssmRead(c, 8)
+func (uc *usecase) ReviewSeedAnomaly(c *gin.Context) {
+	id, err := decryptID(c.Param("id"))
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
 		return
 	}
 
-	util.JOK(c, http.StatusOK, data)
-}
+	var req SeedAnomalyReview
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
 
-func (uc *usecase) GetPerbenihanRekPenyaluran(c *gin.Context) {
-	data, err := uc.repo.PerbenihanRekPenyaluranRead(c, 9)
+	reviewer, err := util.ClaimsEmail(c)
 	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusUnauthorized, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, data)
-}
-
-func (uc *usecase) GetPerbenihanRekPenyebaran(c *gin.Context) {
-	data, err := uc.repo.PerbenihanRekPenyebaranRead(c, 10)
+	err = uc.repo.ReviewSeedAnomaly(c, id, req, reviewer)
+	if err == sql.ErrNoRows {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, data)
+	util.JOK(c, http.StatusOK, "success")
 }
 
-func (uc *usecase) GetPerbenihanRekProdusen(c *gin.Context) {
-	data, err := uc.repo.PerbenihanRekProdusenRead(c, 11)
+// RunSeedChecks reconciles the stored Perbenihan datasets right away
+// instead of waiting for the next ingestion.
+func (uc *usecase) RunSeedChecks(c *gin.Context) {
+	summary, err := runSeedChecks(c, uc.repo)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	util.JOK(c, http.StatusOK, data)
+	util.JOK(c, http.StatusOK, summary)
 }
 
-// WOKRS, DONT TOUCH V
+// SearchDatasets runs a free text search over the indexed datasets, all of
+// them unless dataset lists slugs, with province, commodity, variety,
+// status and region filters.
+func (uc *usecase) SearchDatasets(c *gin.Context) {
+	page, pageSize := pageParams(c)
+	if page*pageSize > maxSearchWindow {
+		util.JERR(c, http.StatusBadRequest, fmt.Errorf("search results are limited to the first %d matches", maxSearchWindow))
+		return
+	}
 
-func (uc *usecase) GetSimluhSertifikat(c *gin.Context) {
-	idPel := c.DefaultQuery("id_pel", "153")
-	tipe := c.DefaultQuery("tipe", "pns")
-	nik := c.DefaultQuery("nik", "")
+	q := SearchQuery{
+		Text:     c.Query("q"),
+		Filters:  map[string]string{"region": c.Query("region")},
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, facet := range SearchFacets {
+		q.Filters[facet] = c.Query(facet)
+	}
 
-	url := "http://latihanonline.pertanian.go.id/print/print_cert_penyuluh1jt.php" +
-		"?id_pel=" + idPel +
-		"&nik=" + nik +
-		"&tipe=" + tipe
+	if slugs := c.Query("dataset"); slugs != "" {
+		for _, slug := range strings.Split(slugs, ",") {
+			ds, ok := DatasetBySlug(strings.TrimSpace(slug))
+			if !ok {
+				util.JERR(c, http.StatusBadRequest, fmt.Errorf("unknown dataset %q", slug))
+				return
+			}
+			q.Datasets = append(q.Datasets, ds)
+		}
+	} else {
+		q.Datasets = datasets
+	}
 
-	resp, err := http.Get(url)
+	result, err := uc.indexer.Search(c, q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, ErrSearchUnavailable) {
+			util.JERR(c, http.StatusServiceUnavailable, err)
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
-	defer resp.Body.Close()
 
-	c.DataFromReader(http.StatusOK, resp.ContentLength, "application/pdf", resp.Body, nil)
+	util.JOK(c, http.StatusOK, result)
 }
 
-func (uc *usecase) GetSimluhRiwayatPelatihan(c *gin.Context) {
-	nik := c.DefaultQuery("nik", "")
-
-	url := "https://laporanutama.pertanian.go.id/biodata/pelatihan_status_penyuluh_json.php?nik=" + nik
-
-	resp, err := http.Get(url)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// ReindexDataset rebuilds the search index of one dataset from the stored
+// records, for datasets ingested before search existed.
+func (uc *usecase) ReindexDataset(c *gin.Context) {
+	ds, ok := DatasetBySlug(c.Param("slug"))
+	if !ok {
+		util.JERR(c, http.StatusNotFound, errors.New("dataset not found"))
 		return
 	}
-	defer resp.Body.Close()
 
-	var data []Pelatihan
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	count, err := uc.indexer.Reindex(c, ds)
+	if err != nil {
+		if errors.Is(err, ErrSearchUnavailable) {
+			util.JERR(c, http.StatusServiceUnavailable, err)
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	util.JOK(c, http.StatusOK, gin.H{"indexed": count})
 }
@@ -0,0 +1,293 @@
+package internal_api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule names recorded on violations and counted in quality reports.
+const (
+	RuleRequired       = "required"
+	RuleNumber         = "number"
+	RuleRange          = "range"
+	RuleDateFormat     = "date_format"
+	RuleDateRange      = "date_range"
+	RuleCoordinates    = "coordinates"
+	RuleUnknownVariety = "unknown_variety"
+)
+
+// Indonesia's bounding box, with a little margin for the outer islands.
+const (
+	minLatitude  = -11.5
+	maxLatitude  = 6.5
+	minLongitude = 94.5
+	maxLongitude = 141.5
+)
+
+// Upstream records older than this are assumed to be typos.
+const earliestRecordYear = 2000
+
+type (
+	Range struct {
+		Min float64
+		Max float64
+	}
+
+	// Rules lists the checks for one dataset on top of the date formats
+	// implied by the field types. Where a dataset has coordinates, a row
+	// that carries a point must place it inside Indonesia.
+	Rules struct {
+		Required []string
+		Ranges   map[string]Range
+	}
+
+	Violation struct {
+		Field   string `json:"field"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+
+	QuarantinedRow struct {
+		ID         string                 `json:"id,omitempty"`
+		Data       map[string]interface{} `json:"data"`
+		Violations []Violation            `json:"violations"`
+		CreatedAt  *time.Time             `json:"created_at,omitempty"`
+	}
+
+	QualityReport struct {
+		ID              string         `json:"id"`
+		JobID           string         `json:"job_id"`
+		DatasetID       int            `json:"identifier"`
+		Dataset         string         `json:"dataset"`
+		TotalRows       int            `json:"total_rows"`
+		ValidRows       int            `json:"valid_rows"`
+		QuarantinedRows int            `json:"quarantined_rows"`
+		Violations      map[string]int `json:"violations"`
+		ReviewedBy      *string        `json:"reviewed_by"`
+		ReviewedAt      *time.Time     `json:"reviewed_at"`
+		CreatedAt       time.Time      `json:"created_at"`
+	}
+
+	// ValidationResult splits one ingestion run into the rows to store and
+	// the rows to quarantine. RegionCodes, when set, holds the resolved
+	// ppt_wilayah kode of each valid row.
+	ValidationResult struct {
+		Valid       []map[string]interface{}
+		RegionCodes []string
+		Quarantined []QuarantinedRow
+		Report      QualityReport
+	}
+
+	// Varieties maps a lower cased commodity to its lower cased varieties.
+	Varieties map[string]map[string]bool
+)
+
+func atLeast(min float64) Range {
+	return Range{Min: min, Max: 1e15}
+}
+
+var (
+	perbenihanPeriod = map[string]Range{
+		"TAHUN": {Min: earliestRecordYear, Max: 2100},
+		"BULAN": {Min: 1, Max: 12},
+	}
+
+	validationRules = map[int]Rules{
+		SIPDPSTanamID: {
+			Required: []string{"tgl_lapor", "nm_prov", "nm_kab", "jenis_tanaman_pangan", "luas_area"},
+			Ranges: map[string]Range{
+				"luas_area":     {Min: 0, Max: 10000},
+				"hst":           {Min: 0, Max: 365},
+				"tahun_bantuan": {Min: earliestRecordYear, Max: 2100},
+			},
+		},
+		SIPDPSProduktivitasID: {
+			Required: []string{"tgl_lapor", "nm_prov", "nm_kab", "jenis_tanaman_pangan", "jumlah"},
+			Ranges:   map[string]Range{"jumlah": {Min: 0, Max: 200}},
+		},
+		SIPDPSPusoID: {
+			Required: []string{"tgl_lapor", "nm_prov", "nm_kab", "jenis_tanaman_pangan"},
+		},
+		SIPDPSPanenID: {
+			Required: []string{"tgl_lapor", "nm_prov", "nm_kab", "jenis_tanaman_pangan", "luas"},
+			Ranges: map[string]Range{
+				"luas":      {Min: 0, Max: 10000},
+				"perkiraan": atLeast(0),
+			},
+		},
+		PerbenihanProdusenID: {
+			Required: []string{"PROVINSI", "NAMA"},
+			Ranges:   map[string]Range{"TOTAL_LUAS_LAHAN": atLeast(0)},
+		},
+		PerbenihanRekNasID:  perbenihanRekapRules,
+		PerbenihanRekBpsbID: perbenihanRekapRules,
+		PerbenihanRekLssmID: perbenihanRekapRules,
+		PerbenihanRekPenyaluranID: {
+			Required: []string{"TAHUN", "BULAN", "PROVINSI", "KOMODITI"},
+			Ranges: mergeRanges(perbenihanPeriod, map[string]Range{
+				"STOK_LALU":      atLeast(0),
+				"PRODUKSI_BENIH": atLeast(0),
+				"PENGADAAN":      atLeast(0),
+				"JUMLAH_STOK":    atLeast(0),
+				"PENYALURAN":     atLeast(0),
+				"APBN":           atLeast(0),
+				"APBD":           atLeast(0),
+				"FREE_MARKET":    atLeast(0),
+				"JUMLAH_SALUR":   atLeast(0),
+				"TOTAL":          atLeast(0),
+				"SISA_STOK":      atLeast(0),
+			}),
+		},
+		PerbenihanRekPenyebaranID: {
+			Required: []string{"TAHUN", "BULAN", "PROVINSI", "JENIS_BENIH"},
+			Ranges: mergeRanges(perbenihanPeriod, map[string]Range{
+				"REALISASI_TANAM_LUAS": atLeast(0),
+				"TOTAL_LUAS":           atLeast(0),
+			}),
+		},
+		PerbenihanRekProdusenID: {
+			Required: []string{"PROVINSI", "NAMA"},
+			Ranges:   map[string]Range{"TOTAL_LUAS_LAHAN": atLeast(0)},
+		},
+	}
+
+	perbenihanRekapRules = Rules{
+		Required: []string{"PROVINSI", "JENIS_BENIH"},
+		Ranges: map[string]Range{
+			"REALISASI_LUAS":     atLeast(0),
+			"REALISASI_PRODUKSI": atLeast(0),
+			"VOLUME":             atLeast(0),
+		},
+	}
+)
+
+func mergeRanges(sets ...map[string]Range) map[string]Range {
+	out := map[string]Range{}
+	for _, set := range sets {
+		for k, v := range set {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Validate normalizes rows and checks them against the dataset rules. Rows
+// with at least one violation are quarantined; the report counts violations
+// per field and rule.
+func (ds Dataset) Validate(rows []map[string]interface{}, varieties Varieties) ValidationResult {
+	res := ValidationResult{
+		Valid:       []map[string]interface{}{},
+		Quarantined: []QuarantinedRow{},
+		Report: QualityReport{
+			DatasetID:  ds.ID,
+			Dataset:    ds.Slug,
+			TotalRows:  len(rows),
+			Violations: map[string]int{},
+		},
+	}
+
+	for _, row := range rows {
+		data := ds.Normalize(row)
+		violations := ds.check(data, varieties)
+		if len(violations) == 0 {
+			res.Valid = append(res.Valid, data)
+			continue
+		}
+
+		for _, v := range violations {
+			res.Report.Violations[v.Field+":"+v.Rule]++
+		}
+		res.Quarantined = append(res.Quarantined, QuarantinedRow{Data: data, Violations: violations})
+	}
+
+	res.Report.ValidRows = len(res.Valid)
+	res.Report.QuarantinedRows = len(res.Quarantined)
+
+	return res
+}
+
+func (ds Dataset) check(data map[string]interface{}, varieties Varieties) []Violation {
+	rules := validationRules[ds.ID]
+	violations := []Violation{}
+
+	add := func(field, rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range rules.Required {
+		if data[key] == nil {
+			add(key, RuleRequired, "%s is required", key)
+		}
+	}
+
+	latest := time.Now().AddDate(0, 0, 1)
+	for _, f := range ds.Fields {
+		v := data[f.Key]
+		if v == nil {
+			continue
+		}
+
+		switch f.Type {
+		case FieldNumber:
+			n, ok := v.(float64)
+			if !ok {
+				add(f.Key, RuleNumber, "%s is not a number: %v", f.Key, v)
+				continue
+			}
+			if r, ok := rules.Ranges[f.Key]; ok && (n < r.Min || n > r.Max) {
+				add(f.Key, RuleRange, "%s %v is outside %v..%v", f.Key, n, r.Min, r.Max)
+			}
+		case FieldDate, FieldDateTime:
+			s, _ := v.(string)
+			t, ok := parseRecordTime(s)
+			if !ok {
+				add(f.Key, RuleDateFormat, "%s has an unrecognized date %q", f.Key, s)
+				continue
+			}
+			if t.Year() < earliestRecordYear || t.After(latest) {
+				add(f.Key, RuleDateRange, "%s %s is not a plausible date", f.Key, s)
+			}
+		}
+	}
+
+	// Rows without a location, such as producers registered without one,
+	// are kept; a location that is given must be complete.
+	if ds.LatKey != "" && (data[ds.LatKey] != nil || data[ds.LngKey] != nil) {
+		lat, latOK := data[ds.LatKey].(float64)
+		lng, lngOK := data[ds.LngKey].(float64)
+		switch {
+		case !latOK || !lngOK:
+			add(ds.LatKey, RuleCoordinates, "coordinates are incomplete")
+		case lat < minLatitude || lat > maxLatitude || lng < minLongitude || lng > maxLongitude:
+			add(ds.LatKey, RuleCoordinates, "coordinates %v,%v are outside Indonesia", lat, lng)
+		}
+	}
+
+	if ds.VarietyKey != "" && ds.CommodityKey != "" {
+		commodity, _ := data[ds.CommodityKey].(string)
+		variety, _ := data[ds.VarietyKey].(string)
+		known := varieties[strings.ToLower(commodity)]
+		if variety != "" && len(known) > 0 && !known[strings.ToLower(variety)] {
+			add(ds.VarietyKey, RuleUnknownVariety, "%s is not a known %s variety", variety, commodity)
+		}
+	}
+
+	return violations
+}
+
+var recordTimeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.000000Z",
+}
+
+func parseRecordTime(s string) (time.Time, bool) {
+	for _, layout := range recordTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
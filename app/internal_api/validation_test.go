@@ -0,0 +1,121 @@
+package internal_api
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func tanamRow(changes map[string]interface{}) map[string]interface{} {
+	row := map[string]interface{}{
+		"nip_reporter":         "198001012005011001",
+		"tgl_lapor":            "2023-07-01",
+		"nm_prov":              "JAWA BARAT",
+		"nm_kab":               "KAB. BOGOR",
+		"nm_desa":              "PAKANSARI",
+		"jenis_tanaman_pangan": "PADI",
+		"nm_varietas":          "INPARI 32",
+		"luas_area":            "2.5",
+		"lat":                  -6.48,
+		"lng":                  106.84,
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(row, k)
+			continue
+		}
+		row[k] = v
+	}
+	return row
+}
+
+func violationKeys(row QuarantinedRow) []string {
+	keys := make([]string, len(row.Violations))
+	for i, v := range row.Violations {
+		keys[i] = v.Field + ":" + v.Rule
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestValidate(t *testing.T) {
+	tanam, _ := DatasetByID(SIPDPSTanamID)
+	produsen, _ := DatasetByID(PerbenihanProdusenID)
+	varieties := Varieties{"padi": {"inpari 32": true, "ciherang": true}}
+	tomorrow := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+
+	tests := []struct {
+		name string
+		ds   Dataset
+		row  map[string]interface{}
+		want []string
+	}{
+		{"valid", tanam, tanamRow(nil), nil},
+		{"required missing", tanam, tanamRow(map[string]interface{}{"nm_kab": nil, "luas_area": " "}), []string{"luas_area:required", "nm_kab:required"}},
+		{"not a number", tanam, tanamRow(map[string]interface{}{"hst": "tiga puluh"}), []string{"hst:number"}},
+		{"out of range", tanam, tanamRow(map[string]interface{}{"luas_area": "20000"}), []string{"luas_area:range"}},
+		{"negative", tanam, tanamRow(map[string]interface{}{"hst": -1.0}), []string{"hst:range"}},
+		{"unknown date format", tanam, tanamRow(map[string]interface{}{"tgl_lapor": "01/07/2023"}), []string{"tgl_lapor:date_format"}},
+		{"date too old", tanam, tanamRow(map[string]interface{}{"tgl_lapor": "1999-12-31"}), []string{"tgl_lapor:date_range"}},
+		{"date in the future", tanam, tanamRow(map[string]interface{}{"tgl_lapor": tomorrow}), []string{"tgl_lapor:date_range"}},
+		{"coordinates outside Indonesia", tanam, tanamRow(map[string]interface{}{"lat": 48.85, "lng": 2.35}), []string{"lat:coordinates"}},
+		{"coordinates incomplete", tanam, tanamRow(map[string]interface{}{"lng": nil}), []string{"lat:coordinates"}},
+		{"coordinates absent", tanam, tanamRow(map[string]interface{}{"lat": nil, "lng": nil}), nil},
+		{"unknown variety", tanam, tanamRow(map[string]interface{}{"nm_varietas": "Mekongga"}), []string{"nm_varietas:unknown_variety"}},
+		{"variety of an unlisted commodity", tanam, tanamRow(map[string]interface{}{"jenis_tanaman_pangan": "SORGUM", "nm_varietas": "Numbu"}), nil},
+		{"producer without location", produsen, map[string]interface{}{"PROVINSI": "JAWA BARAT", "NAMA": "Penangkar Benih", "LAT": "", "LNG": ""}, nil},
+		{"producer with location", produsen, map[string]interface{}{"PROVINSI": "JAWA BARAT", "NAMA": "Penangkar Benih", "LAT": "-6.5", "LNG": "106.8"}, nil},
+		{"producer on datetime", produsen, map[string]interface{}{"PROVINSI": "JAWA BARAT", "NAMA": "Penangkar Benih", "DICATAT": "2023-07-01 08:00:00"}, nil},
+		{"producer with negative land", produsen, map[string]interface{}{"PROVINSI": "JAWA BARAT", "NAMA": "Penangkar Benih", "TOTAL_LUAS_LAHAN": -3.0}, []string{"TOTAL_LUAS_LAHAN:range"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.ds.Validate([]map[string]interface{}{tt.row}, varieties)
+
+			if tt.want == nil {
+				if len(res.Quarantined) != 0 {
+					t.Fatalf("row quarantined with %v", violationKeys(res.Quarantined[0]))
+				}
+				if len(res.Valid) != 1 {
+					t.Fatalf("got %d valid rows, want 1", len(res.Valid))
+				}
+				return
+			}
+
+			if len(res.Quarantined) != 1 {
+				t.Fatalf("got %d quarantined rows, want 1", len(res.Quarantined))
+			}
+			if got := violationKeys(res.Quarantined[0]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReport(t *testing.T) {
+	ds, _ := DatasetByID(SIPDPSTanamID)
+	rows := []map[string]interface{}{
+		tanamRow(nil),
+		tanamRow(map[string]interface{}{"nm_kab": nil}),
+		tanamRow(map[string]interface{}{"nm_kab": nil, "luas_area": "20000"}),
+	}
+
+	res := ds.Validate(rows, nil)
+
+	want := QualityReport{
+		DatasetID:       SIPDPSTanamID,
+		Dataset:         ds.Slug,
+		TotalRows:       3,
+		ValidRows:       1,
+		QuarantinedRows: 2,
+		Violations:      map[string]int{"nm_kab:required": 2, "luas_area:range": 1},
+	}
+	if !reflect.DeepEqual(res.Report, want) {
+		t.Errorf("Report = %+v, want %+v", res.Report, want)
+	}
+	if got := res.Valid[0]["luas_area"]; got != 2.5 {
+		t.Errorf("valid row luas_area = %#v, want the normalized 2.5", got)
+	}
+}
@@ -9,9 +9,9 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
 )
 
 type (
@@ -43,9 +43,10 @@ func (uc *usecase) Export(c *gin.Context) {
 	}
 
 	xlsx := excelize.NewFile()
+	defer xlsx.Close()
 
 	sheet1Name := "Sheet One"
-	xlsx.SetSheetName(xlsx.GetSheetName(1), sheet1Name)
+	xlsx.SetSheetName(xlsx.GetSheetName(0), sheet1Name)
 
 	xlsx.SetCellValue(sheet1Name, "A1", "Name")
 	xlsx.SetCellValue(sheet1Name, "B1", "Slug")
@@ -54,7 +55,7 @@ func (uc *usecase) Export(c *gin.Context) {
 	xlsx.SetCellValue(sheet1Name, "E1", "Created")
 	xlsx.SetCellValue(sheet1Name, "F1", "Updated")
 
-	err = xlsx.AutoFilter(sheet1Name, "A1", "C1", "")
+	err = xlsx.AutoFilter(sheet1Name, "A1:C1", nil)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
@@ -74,12 +75,6 @@ func (uc *usecase) Export(c *gin.Context) {
 		xlsx.SetCellValue(sheet1Name, fmt.Sprintf("F%d", i+2), each.UpdatedAt)
 	}
 
-	err = xlsx.SaveAs("./data-menu.xlsx")
-	if err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
-		return
-	}
-
 	buf := new(bytes.Buffer)
 	err = xlsx.Write(buf)
 	if err != nil {
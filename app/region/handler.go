@@ -1,6 +1,8 @@
 package region
 
 import (
+	"database/sql"
+
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -9,18 +11,24 @@ import (
 type RegionHandler struct {
 	Usecase RegionUsecase
 	rdb     *redis.Client
+	db      *sql.DB
 }
 
-func NewHandler(router *gin.Engine, usecase RegionUsecase, rdb *redis.Client) {
+func NewHandler(router *gin.Engine, usecase RegionUsecase, rdb *redis.Client, db *sql.DB) {
 	handler := &RegionHandler{
 		Usecase: usecase,
 		rdb:     rdb,
+		db:      db,
 	}
 
 	v1 := router.Group("/v1")
 
 	v1.GET("region-list/:level/:parentCode", util.AuthMiddleware(handler.rdb), handler.GetList)
 	v1.GET("region-by-kode/:code", util.AuthMiddleware(handler.rdb), handler.GetRegion)
+	v1.GET("region-resolve", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.Resolve)
+	v1.GET("region-aliases", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.GetAliases)
+	v1.POST("region-alias", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.CreateAlias)
+	v1.DELETE("region-alias/:id", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.DeleteAlias)
 }
 
 func (handler *RegionHandler) GetList(c *gin.Context) {
@@ -30,3 +38,19 @@ func (handler *RegionHandler) GetList(c *gin.Context) {
 func (handler *RegionHandler) GetRegion(c *gin.Context) {
 	handler.Usecase.GetRegion(c)
 }
+
+func (handler *RegionHandler) Resolve(c *gin.Context) {
+	handler.Usecase.Resolve(c)
+}
+
+func (handler *RegionHandler) GetAliases(c *gin.Context) {
+	handler.Usecase.GetAliases(c)
+}
+
+func (handler *RegionHandler) CreateAlias(c *gin.Context) {
+	handler.Usecase.CreateAlias(c)
+}
+
+func (handler *RegionHandler) DeleteAlias(c *gin.Context) {
+	handler.Usecase.DeleteAlias(c)
+}
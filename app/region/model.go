@@ -1,6 +1,9 @@
 package region
 
+import "time"
+
 var table = "ppt_wilayah"
+var aliasTable = "ppt_region_aliases"
 
 type (
 	Wilayah struct {
@@ -8,4 +11,20 @@ type (
 		Nama string  `json:"nama"`
 		BMKG *string `json:"bmkg"`
 	}
+
+	// Alias is an admin maintained spelling of a ppt_wilayah name that the
+	// reconciler cannot match on its own.
+	Alias struct {
+		ID        string    `json:"id"`
+		Kode      string    `json:"kode"`
+		Nama      string    `json:"nama"`
+		Alias     string    `json:"alias"`
+		CreatedBy *string   `json:"created_by"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	AliasRequest struct {
+		Kode  string `json:"kode" binding:"required"`
+		Alias string `json:"alias" binding:"required"`
+	}
 )
@@ -0,0 +1,429 @@
+package region
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Levels of ppt_wilayah, identified by the length of kode.
+const (
+	LevelProvince    = 1
+	LevelRegency     = 2
+	LevelSubdistrict = 3
+	LevelVillage     = 4
+)
+
+const (
+	MatchExact = "exact"
+	MatchAlias = "alias"
+	MatchFuzzy = "fuzzy"
+
+	// fuzzyThreshold is the lowest similarity accepted as a match, and
+	// fuzzyMargin how far ahead of the runner-up a fuzzy match must be.
+	fuzzyThreshold = 0.85
+	fuzzyMargin    = 0.03
+
+	reconcilerTTL = time.Hour
+)
+
+var kodeLengths = map[int]int{
+	LevelProvince:    2,
+	LevelRegency:     5,
+	LevelSubdistrict: 8,
+	LevelVillage:     13,
+}
+
+type (
+	// Names are the free text region names of one upstream row, from the
+	// province down. Empty trailing names are not resolved.
+	Names struct {
+		Province    string
+		Regency     string
+		Subdistrict string
+		Village     string
+	}
+
+	LevelMatch struct {
+		Level  int     `json:"level"`
+		Input  string  `json:"input"`
+		Kode   string  `json:"kode"`
+		Nama   string  `json:"nama"`
+		Method string  `json:"method"`
+		Score  float64 `json:"score"`
+	}
+
+	// Resolution is the deepest kode the names resolve to. Matching stops at
+	// the first level that cannot be resolved; Unresolved holds that level.
+	Resolution struct {
+		Kode       string       `json:"kode"`
+		Levels     []LevelMatch `json:"levels"`
+		Unresolved int          `json:"unresolved,omitempty"`
+	}
+
+	node struct {
+		kode string
+		nama string
+		kind string
+		base string
+	}
+
+	aliasKey struct {
+		level  int
+		parent string
+		name   string
+	}
+
+	// Reconciler matches free text region names against ppt_wilayah. The
+	// region tree and the aliases are held in memory and reloaded hourly or
+	// after Invalidate.
+	Reconciler struct {
+		repo RegionRepository
+
+		mu       sync.RWMutex
+		loadedAt time.Time
+		children map[string][]node
+		aliases  map[aliasKey]node
+	}
+)
+
+func NewReconciler(repo RegionRepository) *Reconciler {
+	return &Reconciler{repo: repo}
+}
+
+// Invalidate forces a reload on the next Resolve, e.g. after an alias change.
+func (r *Reconciler) Invalidate() {
+	r.mu.Lock()
+	r.loadedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+func (r *Reconciler) load(ctx context.Context) error {
+	r.mu.RLock()
+	fresh := time.Since(r.loadedAt) < reconcilerTTL
+	r.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	regions, err := r.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	aliases, err := r.repo.GetAliases(ctx)
+	if err != nil {
+		return err
+	}
+
+	children := map[string][]node{}
+	byKode := map[string]node{}
+	for _, w := range regions {
+		level := KodeLevel(w.Kode)
+		if level == 0 {
+			continue
+		}
+		kind, base := NormalizeName(level, w.Nama)
+		n := node{kode: w.Kode, nama: w.Nama, kind: kind, base: base}
+		byKode[w.Kode] = n
+		parent := ParentKode(w.Kode)
+		children[parent] = append(children[parent], n)
+	}
+
+	aliasNodes := map[aliasKey]node{}
+	for _, a := range aliases {
+		n, ok := byKode[a.Kode]
+		if !ok {
+			continue
+		}
+		level := KodeLevel(a.Kode)
+		aliasNodes[aliasKey{level, ParentKode(a.Kode), normalizedKey(level, a.Alias)}] = n
+	}
+
+	r.mu.Lock()
+	r.children = children
+	r.aliases = aliasNodes
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Resolve matches names level by level, each level only among the children
+// of the region matched above it.
+func (r *Reconciler) Resolve(ctx context.Context, names Names) (Resolution, error) {
+	res := Resolution{Levels: []LevelMatch{}}
+
+	if err := r.load(ctx); err != nil {
+		return res, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	inputs := []string{names.Province, names.Regency, names.Subdistrict, names.Village}
+	parent := ""
+	for i, input := range inputs {
+		level := i + 1
+		if strings.TrimSpace(input) == "" {
+			break
+		}
+
+		m, ok := r.match(level, parent, input)
+		if !ok {
+			res.Unresolved = level
+			break
+		}
+
+		res.Levels = append(res.Levels, m)
+		res.Kode = m.Kode
+		parent = m.Kode
+	}
+
+	return res, nil
+}
+
+func (r *Reconciler) match(level int, parent, input string) (LevelMatch, bool) {
+	kind, base := NormalizeName(level, input)
+	m := LevelMatch{Level: level, Input: input}
+
+	if n, ok := r.aliases[aliasKey{level, parent, joinKind(kind, base)}]; ok {
+		m.Kode, m.Nama, m.Method, m.Score = n.kode, n.nama, MatchAlias, 1
+		return m, true
+	}
+
+	var best node
+	bestScore, runnerUp := -1.0, -1.0
+	for _, n := range r.children[parent] {
+		score := similarity(base, n.base)
+		switch {
+		case kind != "" && n.kind != "" && kind != n.kind:
+			// KAB. BOGOR and KOTA BOGOR are different regions.
+			score -= 0.1
+		case kind == "" && n.kind == "KOTA":
+			// A bare name conventionally means the kabupaten.
+			score -= 0.01
+		}
+
+		if score > bestScore {
+			best, runnerUp, bestScore = n, bestScore, score
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+
+	if bestScore < fuzzyThreshold {
+		return m, false
+	}
+
+	m.Kode, m.Nama, m.Score = best.kode, best.nama, bestScore
+	// A name that only matches once its kind is ignored, such as KOTA BOGOR
+	// resolving to KAB. BOGOR, keeps the kind penalty and goes through the
+	// fuzzy margin check like any other near match.
+	if best.base == base && kindAgrees(kind, best.kind) {
+		m.Method = MatchExact
+		return m, true
+	}
+
+	if bestScore-runnerUp < fuzzyMargin {
+		return m, false
+	}
+
+	m.Method = MatchFuzzy
+	return m, true
+}
+
+// kindAgrees tells whether an input kind fits a region's kind. A bare name
+// fits a kabupaten but not a kota.
+func kindAgrees(input, region string) bool {
+	if input == "" {
+		return region != "KOTA"
+	}
+	return region == "" || input == region
+}
+
+// KodeLevel returns the level of a ppt_wilayah kode, or 0 when kode is not
+// one.
+func KodeLevel(kode string) int {
+	for level, length := range kodeLengths {
+		if len(kode) == length {
+			return level
+		}
+	}
+	return 0
+}
+
+// ParentKode returns the kode one level up, or "" for a province.
+func ParentKode(kode string) string {
+	level := KodeLevel(kode)
+	if level <= LevelProvince {
+		return ""
+	}
+	return kode[:kodeLengths[level-1]]
+}
+
+var (
+	// Administrative prefixes stripped per level. The regency prefixes also
+	// yield the kind, since a KAB. and a KOTA can share a name.
+	levelPrefixes = map[int][]struct{ prefix, kind string }{
+		LevelRegency: {
+			{"KABUPATEN ADMINISTRASI ", "KAB"},
+			{"KAB ADM ", "KAB"},
+			{"KABUPATEN ", "KAB"},
+			{"KAB ", "KAB"},
+			{"KOTA ADMINISTRASI ", "KOTA"},
+			{"KOTA ADM ", "KOTA"},
+			{"KOTAMADYA ", "KOTA"},
+			{"KOTA ", "KOTA"},
+		},
+		LevelSubdistrict: {
+			{"KECAMATAN ", ""},
+			{"DISTRIK ", ""},
+		},
+		LevelVillage: {
+			{"KELURAHAN ", ""},
+			{"KEL ", ""},
+			{"DESA ", ""},
+			{"DS ", ""},
+			{"GAMPONG ", ""},
+			{"NAGARI ", ""},
+		},
+	}
+
+	wordVariants = map[string]string{
+		"KEP":  "KEPULAUAN",
+		"KEPL": "KEPULAUAN",
+		"KEC":  "KECAMATAN",
+		"UTR":  "UTARA",
+		"SEL":  "SELATAN",
+		"TENG": "TENGAH",
+		"BRT":  "BARAT",
+		"TIM":  "TIMUR",
+	}
+
+	provinceVariants = map[string]string{
+		"JAKARTA":                       "DKI JAKARTA",
+		"DAERAH KHUSUS IBUKOTA JAKARTA": "DKI JAKARTA",
+		"YOGYAKARTA":                    "DAERAH ISTIMEWA YOGYAKARTA",
+		"DI YOGYAKARTA":                 "DAERAH ISTIMEWA YOGYAKARTA",
+		"D I YOGYAKARTA":                "DAERAH ISTIMEWA YOGYAKARTA",
+		"DIY":                           "DAERAH ISTIMEWA YOGYAKARTA",
+		"NAD":                           "ACEH",
+		"NANGGROE ACEH DARUSSALAM":      "ACEH",
+		"BANGKA BELITUNG":               "KEPULAUAN BANGKA BELITUNG",
+		"BABEL":                         "KEPULAUAN BANGKA BELITUNG",
+		"KEPRI":                         "KEPULAUAN RIAU",
+		"NTB":                           "NUSA TENGGARA BARAT",
+		"NTT":                           "NUSA TENGGARA TIMUR",
+		"SUMUT":                         "SUMATERA UTARA",
+		"SUMATRA UTARA":                 "SUMATERA UTARA",
+		"SUMBAR":                        "SUMATERA BARAT",
+		"SUMATRA BARAT":                 "SUMATERA BARAT",
+		"SUMSEL":                        "SUMATERA SELATAN",
+		"SUMATRA SELATAN":               "SUMATERA SELATAN",
+		"JABAR":                         "JAWA BARAT",
+		"JATENG":                        "JAWA TENGAH",
+		"JATIM":                         "JAWA TIMUR",
+		"KALBAR":                        "KALIMANTAN BARAT",
+		"KALTENG":                       "KALIMANTAN TENGAH",
+		"KALSEL":                        "KALIMANTAN SELATAN",
+		"KALTIM":                        "KALIMANTAN TIMUR",
+		"KALTARA":                       "KALIMANTAN UTARA",
+		"SULUT":                         "SULAWESI UTARA",
+		"SULTENG":                       "SULAWESI TENGAH",
+		"SULSEL":                        "SULAWESI SELATAN",
+		"SULTRA":                        "SULAWESI TENGGARA",
+		"SULBAR":                        "SULAWESI BARAT",
+	}
+)
+
+// NormalizeName upper cases name, drops punctuation and administrative
+// prefixes and expands common abbreviations. For regencies kind is KAB or
+// KOTA when the prefix says so.
+func NormalizeName(level int, name string) (kind, base string) {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return ' '
+	}, name)
+
+	words := strings.Fields(cleaned)
+	for i, w := range words {
+		if v, ok := wordVariants[w]; ok {
+			words[i] = v
+		}
+	}
+	base = strings.Join(words, " ")
+
+	// Abbreviated prefixes such as KEC have been expanded above.
+	for _, p := range levelPrefixes[level] {
+		if strings.HasPrefix(base+" ", p.prefix) {
+			kind = p.kind
+			base = strings.TrimSpace(strings.TrimPrefix(base+" ", p.prefix))
+			break
+		}
+	}
+
+	if level == LevelProvince {
+		if v, ok := provinceVariants[base]; ok {
+			base = v
+		}
+	}
+
+	return kind, base
+}
+
+func normalizedKey(level int, name string) string {
+	return joinKind(NormalizeName(level, name))
+}
+
+func joinKind(kind, base string) string {
+	if kind == "" {
+		return base
+	}
+	return kind + " " + base
+}
+
+// similarity is 1 minus the Levenshtein distance relative to the longer
+// name, so 1 means equal and 0 means nothing in common.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,127 @@
+package region
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type fakeRegions struct {
+	RegionRepository
+	regions []Wilayah
+	aliases []Alias
+}
+
+func (f fakeRegions) GetAll(context.Context) ([]Wilayah, error) {
+	return f.regions, nil
+}
+
+func (f fakeRegions) GetAliases(context.Context) ([]Alias, error) {
+	return f.aliases, nil
+}
+
+func testReconciler() *Reconciler {
+	return NewReconciler(fakeRegions{
+		regions: []Wilayah{
+			{Kode: "31", Nama: "DKI JAKARTA"},
+			{Kode: "31.74", Nama: "KOTA ADM. JAKARTA SELATAN"},
+			{Kode: "32", Nama: "JAWA BARAT"},
+			{Kode: "32.01", Nama: "KABUPATEN BOGOR"},
+			{Kode: "32.01.01", Nama: "CIBINONG"},
+			{Kode: "32.01.01.2001", Nama: "PAKANSARI"},
+			{Kode: "32.01.02", Nama: "SUKAMAJU"},
+			{Kode: "32.01.03", Nama: "SUKAMAJA"},
+			{Kode: "32.05", Nama: "KABUPATEN GARUT"},
+			{Kode: "32.10", Nama: "KABUPATEN MAJALENGKA"},
+			{Kode: "32.71", Nama: "KOTA BOGOR"},
+			{Kode: "32.72", Nama: "KOTA SUKABUMI"},
+		},
+		aliases: []Alias{
+			{Kode: "32.71", Alias: "Buitenzorg"},
+		},
+	})
+}
+
+func TestResolve(t *testing.T) {
+	type level struct {
+		kode, method string
+	}
+
+	tests := []struct {
+		name       string
+		names      Names
+		want       []level
+		unresolved int
+	}{
+		{"exact down to the village", Names{"Jawa Barat", "Kab. Bogor", "Kec. Cibinong", "Desa Pakansari"},
+			[]level{{"32", MatchExact}, {"32.01", MatchExact}, {"32.01.01", MatchExact}, {"32.01.01.2001", MatchExact}}, 0},
+		{"province shorthand", Names{Province: "JABAR"}, []level{{"32", MatchExact}}, 0},
+		{"kota and kabupaten share a name", Names{Province: "JAWA BARAT", Regency: "Kota Bogor"},
+			[]level{{"32", MatchExact}, {"32.71", MatchExact}}, 0},
+		{"bare name means the kabupaten", Names{Province: "JAWA BARAT", Regency: "BOGOR"},
+			[]level{{"32", MatchExact}, {"32.01", MatchExact}}, 0},
+		{"bare name of a kota is only fuzzy", Names{Province: "JAWA BARAT", Regency: "SUKABUMI"},
+			[]level{{"32", MatchExact}, {"32.72", MatchFuzzy}}, 0},
+		{"wrong kind is only fuzzy", Names{Province: "JAWA BARAT", Regency: "KOTA GARUT"},
+			[]level{{"32", MatchExact}, {"32.05", MatchFuzzy}}, 0},
+		{"administrative kota", Names{Province: "DKI Jakarta", Regency: "Kota Administrasi Jakarta Selatan"},
+			[]level{{"31", MatchExact}, {"31.74", MatchExact}}, 0},
+		{"typo", Names{Province: "JAWA BARAT", Regency: "KAB. MAJALENGKAA"},
+			[]level{{"32", MatchExact}, {"32.10", MatchFuzzy}}, 0},
+		{"alias", Names{Province: "JAWA BARAT", Regency: "BUITENZORG"},
+			[]level{{"32", MatchExact}, {"32.71", MatchAlias}}, 0},
+		{"ambiguous typo stops", Names{Province: "JAWA BARAT", Regency: "KAB. BOGOR", Subdistrict: "SUKAMAJO"},
+			[]level{{"32", MatchExact}, {"32.01", MatchExact}}, LevelSubdistrict},
+		{"unknown name stops", Names{Province: "JAWA BARAT", Regency: "ATLANTIS", Subdistrict: "CIBINONG"},
+			[]level{{"32", MatchExact}}, LevelRegency},
+		{"only within the parent", Names{Province: "DKI JAKARTA", Regency: "KOTA BOGOR"},
+			[]level{{"31", MatchExact}}, LevelRegency},
+	}
+
+	r := testReconciler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := r.Resolve(context.Background(), tt.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []level{}
+			for _, m := range res.Levels {
+				got = append(got, level{m.Kode, m.Method})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("levels = %v, want %v", got, tt.want)
+			}
+			if res.Unresolved != tt.unresolved {
+				t.Errorf("unresolved = %d, want %d", res.Unresolved, tt.unresolved)
+			}
+			if want := tt.want[len(tt.want)-1].kode; res.Kode != want {
+				t.Errorf("kode = %q, want %q", res.Kode, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		level      int
+		in         string
+		kind, base string
+	}{
+		{LevelProvince, "Prov. Jawa Barat", "", "PROV JAWA BARAT"},
+		{LevelProvince, "D.I. Yogyakarta", "", "DAERAH ISTIMEWA YOGYAKARTA"},
+		{LevelRegency, "Kab. Kep. Seribu", "KAB", "KEPULAUAN SERIBU"},
+		{LevelRegency, "KOTAMADYA BANDUNG", "KOTA", "BANDUNG"},
+		{LevelRegency, "Kotabaru", "", "KOTABARU"},
+		{LevelSubdistrict, "Kec. Cibinong", "", "CIBINONG"},
+		{LevelVillage, "Ds. Pakansari", "", "PAKANSARI"},
+	}
+
+	for _, tt := range tests {
+		kind, base := NormalizeName(tt.level, tt.in)
+		if kind != tt.kind || base != tt.base {
+			t.Errorf("NormalizeName(%d, %q) = %q, %q, want %q, %q", tt.level, tt.in, kind, base, tt.kind, tt.base)
+		}
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
+
+	"github.com/gigaflex-co/ppt_backend/util"
 )
 
 type (
 	RegionRepository interface {
 		GetList(c context.Context, level, parentCode string) ([]Wilayah, error)
 		GetRegion(c context.Context, code string) (Wilayah, error)
+		GetAll(c context.Context) ([]Wilayah, error)
+		GetAliases(c context.Context) ([]Alias, error)
+		CreateAlias(c context.Context, kode, alias, createdBy string) (Alias, error)
+		DeleteAlias(c context.Context, id int64) error
 	}
 
 	repository struct {
@@ -98,3 +105,105 @@ func (q *repository) GetRegion(c context.Context, code string) (Wilayah, error)
 
 	return r, err
 }
+
+func (q *repository) GetAll(c context.Context) ([]Wilayah, error) {
+	var r Wilayah
+	items := []Wilayah{}
+
+	query := `
+		SELECT kode, nama, bmkg FROM ` + table
+
+	rows, err := q.db.QueryContext(c, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&r.Kode,
+			&r.Nama,
+			&r.BMKG,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+func (q *repository) GetAliases(c context.Context) ([]Alias, error) {
+	items := []Alias{}
+
+	query := `
+		SELECT a.id, a.kode, COALESCE(w.nama, ''), a.alias, a.created_by, a.created_at
+		FROM ` + aliasTable + ` a
+		LEFT JOIN ` + table + ` w ON w.kode = a.kode
+		ORDER BY a.kode, a.alias`
+
+	rows, err := q.db.QueryContext(c, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Alias
+		var id int64
+		if err := rows.Scan(
+			&id,
+			&r.Kode,
+			&r.Nama,
+			&r.Alias,
+			&r.CreatedBy,
+			&r.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		r.ID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+func (q *repository) CreateAlias(c context.Context, kode, alias, createdBy string) (Alias, error) {
+	r := Alias{Kode: kode, Alias: alias, CreatedBy: &createdBy}
+	var id int64
+
+	query := `
+		INSERT INTO ` + aliasTable + ` (kode, alias, created_by)
+		SELECT kode, $2, $3 FROM ` + table + `
+		WHERE kode = $1
+		RETURNING id, (SELECT nama FROM ` + table + ` WHERE kode = $1), created_at`
+
+	err := q.db.QueryRowContext(c, query, kode, alias, createdBy).Scan(&id, &r.Nama, &r.CreatedAt)
+	if err != nil {
+		return r, err
+	}
+	r.ID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+
+	return r, nil
+}
+
+func (q *repository) DeleteAlias(c context.Context, id int64) error {
+	query := `
+		DELETE FROM ` + aliasTable + `
+		WHERE id = $1`
+
+	res, err := q.db.ExecContext(c, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
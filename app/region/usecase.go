@@ -1,26 +1,37 @@
 package region
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 type (
 	RegionUsecase interface {
 		GetList(c *gin.Context)
 		GetRegion(c *gin.Context)
+		Resolve(c *gin.Context)
+		GetAliases(c *gin.Context)
+		CreateAlias(c *gin.Context)
+		DeleteAlias(c *gin.Context)
 	}
 
 	usecase struct {
-		repo RegionRepository
+		repo       RegionRepository
+		reconciler *Reconciler
 	}
 )
 
-func NewUsecase(repo RegionRepository) RegionUsecase {
+func NewUsecase(repo RegionRepository, reconciler *Reconciler) RegionUsecase {
 	return &usecase{
-		repo: repo,
+		repo:       repo,
+		reconciler: reconciler,
 	}
 }
 
@@ -48,3 +59,104 @@ func (uc *usecase) GetRegion(c *gin.Context) {
 
 	util.JOK(c, http.StatusOK, data)
 }
+
+// Resolve shows how the reconciler matches a set of names, so admins can
+// check a spelling before adding an alias for it.
+func (uc *usecase) Resolve(c *gin.Context) {
+	names := Names{
+		Province:    c.Query("province"),
+		Regency:     c.Query("kabupaten"),
+		Subdistrict: c.Query("kecamatan"),
+		Village:     c.Query("desa"),
+	}
+
+	if strings.TrimSpace(names.Province) == "" {
+		util.JERR(c, http.StatusBadRequest, errors.New("province is required"))
+		return
+	}
+
+	data, err := uc.reconciler.Resolve(c, names)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+func (uc *usecase) GetAliases(c *gin.Context) {
+	data, err := uc.repo.GetAliases(c)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+func (uc *usecase) CreateAlias(c *gin.Context) {
+	var req AliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Alias = strings.TrimSpace(req.Alias)
+	if KodeLevel(req.Kode) == 0 || req.Alias == "" {
+		util.JERR(c, http.StatusBadRequest, errors.New("kode and alias are invalid"))
+		return
+	}
+
+	createdBy, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	data, err := uc.repo.CreateAlias(c, req.Kode, req.Alias, createdBy)
+	if err == sql.ErrNoRows {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		util.JERR(c, http.StatusConflict, errors.New("alias already exists"))
+		return
+	}
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	uc.reconciler.Invalidate()
+
+	util.JOK(c, http.StatusCreated, data)
+}
+
+func (uc *usecase) DeleteAlias(c *gin.Context) {
+	decrypted, err := util.Decrypt(c.Param("id"), "f")
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
+
+	id, err := strconv.ParseInt(decrypted, 10, 64)
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
+
+	err = uc.repo.DeleteAlias(c, id)
+	if err == sql.ErrNoRows {
+		util.JERR(c, http.StatusNotFound, errors.New("record not found"))
+		return
+	}
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	uc.reconciler.Invalidate()
+
+	util.JOK(c, http.StatusOK, "success")
+}
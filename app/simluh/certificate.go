@@ -0,0 +1,94 @@
+package simluh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/storage"
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+// certificateCache serves SIMLUH certificates from storage and fetches them
+// from the training site when missing or older than ttl. secret keys the
+// hash of the NIK in object names.
+type certificateCache struct {
+	store   storage.Store
+	baseURL string
+	ttl     time.Duration
+	secret  []byte
+}
+
+// Open returns the certificate PDF and whether it came from the cache.
+func (cc *certificateCache) Open(c context.Context, req CertificateRequest) (io.ReadCloser, int64, bool, error) {
+	key := req.cacheKey(cc.secret)
+
+	obj, err := cc.store.Stat(c, key)
+	if err == nil && time.Since(obj.ModTime) < cc.ttl {
+		body, obj, err := cc.store.Get(c, key)
+		if err == nil {
+			return body, obj.Size, true, nil
+		}
+		log.Printf("reading cached certificate %s: %v", key, err)
+	} else if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("checking cached certificate %s: %v", key, err)
+	}
+
+	pdf, err := cc.fetch(c, req)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if err := cc.store.Put(c, key, bytes.NewReader(pdf), int64(len(pdf)), "application/pdf"); err != nil {
+		log.Printf("caching certificate %s: %v", key, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(pdf)), int64(len(pdf)), false, nil
+}
+
+func (cc *certificateCache) fetch(c context.Context, req CertificateRequest) ([]byte, error) {
+	params := url.Values{}
+	params.Set("id_pel", req.IDPel)
+	params.Set("nik", req.NIK)
+	params.Set("tipe", req.Tipe)
+	rawURL := cc.baseURL + "/print/print_cert_penyuluh1jt.php?" + params.Encode()
+
+	upstream := util.UpstreamFor(rawURL)
+	resp, err := upstream.Get(c, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrCertificateNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstream.Unexpected(resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCertificateSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, ErrCertificateNotFound
+	}
+	if len(body) > maxCertificateSize {
+		return nil, fmt.Errorf("%w: response larger than %d bytes", ErrInvalidCertificate, maxCertificateSize)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/pdf" || !bytes.HasPrefix(body, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w (content type %q)", ErrInvalidCertificate, resp.Header.Get("Content-Type"))
+	}
+
+	return body, nil
+}
@@ -0,0 +1,133 @@
+package simluh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/storage"
+	"github.com/gigaflex-co/ppt_backend/cmd/mockupstream/mock"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+func newMockUpstream(t *testing.T, behavior mock.Behavior) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	srv := httptest.NewServer(mock.NewRouter(mock.Options{Behavior: behavior}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCertificateAgainstMock(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{})
+	root := t.TempDir()
+	plain, err := storage.NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewEncryptedStore(plain, "app-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &certificateCache{store: store, baseURL: srv.URL + "/simluh-training", ttl: time.Hour, secret: []byte("secret")}
+	req := CertificateRequest{NIK: "3201011501900001", IDPel: "153", Tipe: "1"}
+
+	open := func(wantCached bool) []byte {
+		t.Helper()
+
+		body, size, cached, err := cc.Open(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer body.Close()
+
+		pdf, _ := io.ReadAll(body)
+		if cached != wantCached {
+			t.Errorf("cached = %v, want %v", cached, wantCached)
+		}
+		if int64(len(pdf)) != size || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+			t.Errorf("got %d bytes (size %d) starting %q", len(pdf), size, pdf[:8])
+		}
+		return pdf
+	}
+
+	first := open(false)
+	if second := open(true); !bytes.Equal(first, second) {
+		t.Error("the cached certificate differs from the fetched one")
+	}
+
+	raw, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(req.cacheKey(cc.secret))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("%PDF-")) {
+		t.Error("the certificate is cached unencrypted")
+	}
+
+	cc.ttl = 0
+	open(false)
+}
+
+func TestCertificateCacheKey(t *testing.T) {
+	req := CertificateRequest{NIK: "3201011501900001", IDPel: "153", Tipe: "pns"}
+	key := req.cacheKey([]byte("secret"))
+
+	if strings.Contains(key, req.NIK) {
+		t.Errorf("key %q holds the NIK", key)
+	}
+	if !strings.HasPrefix(key, "simluh/certificates/") || !strings.HasSuffix(key, "/153-pns.pdf") {
+		t.Errorf("key = %q", key)
+	}
+	if other := req.cacheKey([]byte("other")); other == key {
+		t.Error("the key does not depend on the secret")
+	}
+	sum := sha256.Sum256([]byte(req.NIK))
+	if strings.Contains(key, hex.EncodeToString(sum[:])) {
+		t.Error("the key holds the unkeyed hash of the NIK")
+	}
+}
+
+func TestCertificateFetchRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		err         error
+	}{
+		{"not found", http.StatusNotFound, "text/html", "", ErrCertificateNotFound},
+		{"empty body", http.StatusOK, "application/pdf", "", ErrCertificateNotFound},
+		{"html page", http.StatusOK, "text/html; charset=UTF-8", "<html>Sertifikat belum tersedia</html>", ErrInvalidCertificate},
+		{"pdf type without a pdf", http.StatusOK, "application/pdf", "Warning: mysqli_connect()", ErrInvalidCertificate},
+		{"refused", http.StatusForbidden, "text/html", "", util.ErrUpstreamResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			cc := &certificateCache{baseURL: srv.URL}
+			_, err := cc.fetch(context.Background(), CertificateRequest{NIK: "3201011501900001", IDPel: "153", Tipe: "1"})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("fetch() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package simluh
+
+import (
+	"database/sql"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+type SimluhHandler struct {
+	Usecase SimluhUsecase
+	rdb     *redis.Client
+	db      *sql.DB
+}
+
+func NewHandler(router *gin.Engine, usecase SimluhUsecase, rdb *redis.Client, db *sql.DB) {
+	handler := &SimluhHandler{
+		Usecase: usecase,
+		rdb:     rdb,
+		db:      db,
+	}
+
+	v1 := router.Group("/v1")
+
+	v1.GET("api-simluh-sertifikat", util.AuthMiddleware(handler.rdb), handler.GetSertifikat)
+	v1.GET("api-simluh-riwayat-pelatihan", util.AuthMiddleware(handler.rdb), handler.GetMyTrainings)
+	v1.GET("simluh-trainings", util.AuthMiddleware(handler.rdb), handler.GetMyTrainings)
+	v1.POST("simluh-trainings/refresh", util.AuthMiddleware(handler.rdb), handler.RefreshMyTrainings)
+	v1.GET("simluh-training-stats", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.GetTrainingStats)
+	v1.POST("simluh-training-sync", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(handler.db), handler.SyncTrainings)
+}
+
+func (handler *SimluhHandler) GetSertifikat(c *gin.Context) {
+	handler.Usecase.GetSertifikat(c)
+}
+
+func (handler *SimluhHandler) GetMyTrainings(c *gin.Context) {
+	handler.Usecase.GetMyTrainings(c)
+}
+
+func (handler *SimluhHandler) RefreshMyTrainings(c *gin.Context) {
+	handler.Usecase.RefreshMyTrainings(c)
+}
+
+func (handler *SimluhHandler) GetTrainingStats(c *gin.Context) {
+	handler.Usecase.GetTrainingStats(c)
+}
+
+func (handler *SimluhHandler) SyncTrainings(c *gin.Context) {
+	handler.Usecase.SyncTrainings(c)
+}
@@ -0,0 +1,169 @@
+package simluh
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	userTable     = "ppt_users"
+	courseTable   = "ppt_simluh_courses"
+	trainingTable = "ppt_user_trainings"
+	syncTable     = "ppt_simluh_syncs"
+	regionTable   = "ppt_wilayah"
+)
+
+// maxCertificateSize bounds how much of an upstream response is read; real
+// certificates are a few hundred kilobytes.
+const maxCertificateSize = 10 << 20
+
+var (
+	nikPattern   = regexp.MustCompile(`^[0-9]{16}$`)
+	idPelPattern = regexp.MustCompile(`^[0-9]{1,10}$`)
+	tipePattern  = regexp.MustCompile(`^[a-z]{1,20}$`)
+
+	ErrCertificateNotFound = errors.New("certificate not found")
+	ErrInvalidCertificate  = errors.New("upstream did not return a PDF certificate")
+	ErrNoNIK               = errors.New("no NIK on profile")
+)
+
+type (
+	CertificateRequest struct {
+		NIK   string
+		IDPel string
+		Tipe  string
+	}
+
+	// UserNIK is a user together with the decrypted NIK SIMLUH knows them by.
+	UserNIK struct {
+		ID  int64
+		NIK string
+	}
+
+	// flexString accepts the strings, numbers and nulls SIMLUH mixes freely
+	// in the same field.
+	flexString string
+
+	// Pelatihan is one entry of pelatihan_status_penyuluh_json.php.
+	Pelatihan struct {
+		StsIkut      flexString `json:"sts_ikut"`
+		IdPel        flexString `json:"id_pel"`
+		KodePel      flexString `json:"kode_pel"`
+		JudulPel     flexString `json:"judul_pel"`
+		LinkMateri   flexString `json:"link_materi"`
+		TanggalMulai flexString `json:"tanggal_mulai"`
+		JamMulai     flexString `json:"jam_mulai"`
+		TanggalAkhir flexString `json:"tanggal_akhir"`
+		JamAkhir     flexString `json:"jam_akhir"`
+		LinkFlyer    flexString `json:"link_flyer"`
+	}
+
+	Training struct {
+		IDPel        string     `json:"id_pel"`
+		KodePel      string     `json:"kode_pel"`
+		Judul        string     `json:"judul_pel"`
+		LinkMateri   string     `json:"link_materi"`
+		LinkFlyer    string     `json:"link_flyer"`
+		StartsAt     *time.Time `json:"starts_at"`
+		EndsAt       *time.Time `json:"ends_at"`
+		Status       string     `json:"status"`
+		Participated bool       `json:"participated"`
+		SyncedAt     time.Time  `json:"synced_at"`
+	}
+
+	SyncState struct {
+		SyncedAt  *time.Time `json:"synced_at"`
+		Trainings int        `json:"trainings"`
+		Error     *string    `json:"error"`
+	}
+
+	MyTrainings struct {
+		Sync      SyncState  `json:"sync"`
+		Trainings []Training `json:"trainings"`
+	}
+
+	TrainingStat struct {
+		Kode         string `json:"kode"`
+		Nama         string `json:"nama"`
+		Users        int    `json:"users"`
+		Trainings    int    `json:"trainings"`
+		Participated int    `json:"participated"`
+	}
+)
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = flexString(strings.TrimSpace(str))
+		return nil
+	}
+
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*s = flexString(num.String())
+	return nil
+}
+
+// Training converts the upstream entry. sts_ikut "1" marks a course the
+// penyuluh took part in.
+func (p Pelatihan) Training(syncedAt time.Time) Training {
+	return Training{
+		IDPel:        string(p.IdPel),
+		KodePel:      string(p.KodePel),
+		Judul:        string(p.JudulPel),
+		LinkMateri:   string(p.LinkMateri),
+		LinkFlyer:    string(p.LinkFlyer),
+		StartsAt:     parseSchedule(string(p.TanggalMulai), string(p.JamMulai)),
+		EndsAt:       parseSchedule(string(p.TanggalAkhir), string(p.JamAkhir)),
+		Status:       string(p.StsIkut),
+		Participated: string(p.StsIkut) == "1",
+		SyncedAt:     syncedAt,
+	}
+}
+
+func parseSchedule(date, clock string) *time.Time {
+	if date == "" || strings.HasPrefix(date, "0000") {
+		return nil
+	}
+	if clock == "" {
+		clock = "00:00"
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "02-01-2006 15:04"} {
+		if t, err := time.Parse(layout, date+" "+clock); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// cacheKey keeps the NIK out of object names, which show up in bucket
+// listings and logs. The hash is keyed with secret since the NIK space is
+// small enough to enumerate.
+func (r CertificateRequest) cacheKey(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(r.NIK))
+	return "simluh/certificates/" + hex.EncodeToString(mac.Sum(nil)) + "/" + r.IDPel + "-" + r.Tipe + ".pdf"
+}
+
+// maskNIK keeps the region and sequence digits of a NIK for audit entries
+// and hides the date of birth in between.
+func maskNIK(nik string) string {
+	if len(nik) != 16 {
+		return "****"
+	}
+	return nik[:6] + "******" + nik[12:]
+}
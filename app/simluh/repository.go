@@ -0,0 +1,281 @@
+package simluh
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+type (
+	SimluhRepository interface {
+		GetUserNIK(c context.Context, email string) (UserNIK, error)
+		IsAdmin(c context.Context, email string) (bool, error)
+
+		UsersDueForSync(c context.Context, staleBefore time.Time, limit int) ([]UserNIK, error)
+		StoreTrainings(c context.Context, userID int64, trainings []Training) error
+		StoreSyncFailure(c context.Context, userID int64, syncErr error) error
+		GetSyncState(c context.Context, userID int64) (SyncState, error)
+		GetUserTrainings(c context.Context, userID int64) ([]Training, error)
+		TrainingStatsByRegion(c context.Context, provinceKode, idPel string) ([]TrainingStat, error)
+		TrainingStatsByCourse(c context.Context, provinceKode string) ([]TrainingStat, error)
+	}
+
+	repository struct {
+		db *sql.DB
+	}
+)
+
+func NewRepository(db *sql.DB) SimluhRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetUserNIK returns the user with their decrypted NIK, which is empty when
+// the profile has none yet.
+func (q *repository) GetUserNIK(c context.Context, email string) (UserNIK, error) {
+	var r UserNIK
+	var nik sql.NullString
+
+	query := `SELECT id, nik FROM ` + userTable + ` WHERE email = $1 AND is_active = true`
+	if err := q.db.QueryRowContext(c, query, email).Scan(&r.ID, &nik); err != nil {
+		return r, err
+	}
+
+	if nik.Valid && nik.String != "" {
+		dec, err := util.Decrypt(nik.String, "f")
+		if err != nil {
+			return r, err
+		}
+		r.NIK = dec
+	}
+
+	return r, nil
+}
+
+func (q *repository) IsAdmin(c context.Context, email string) (bool, error) {
+	return util.IsAdmin(c, q.db, email)
+}
+
+// UsersDueForSync lists active users with a NIK whose training history was
+// never synced or last synced before staleBefore, oldest first.
+func (q *repository) UsersDueForSync(c context.Context, staleBefore time.Time, limit int) ([]UserNIK, error) {
+	query := `
+	SELECT u.id, u.nik FROM ` + userTable + ` u
+	LEFT JOIN ` + syncTable + ` s ON s.user_id = u.id
+	WHERE u.is_active = true AND u.nik IS NOT NULL AND u.nik <> ''
+	AND (s.synced_at IS NULL OR s.synced_at < $1)
+	ORDER BY s.synced_at NULLS FIRST, u.id
+	LIMIT $2`
+
+	rows, err := q.db.QueryContext(c, query, staleBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []UserNIK{}
+	for rows.Next() {
+		var r UserNIK
+		var nik string
+		if err := rows.Scan(&r.ID, &nik); err != nil {
+			return nil, err
+		}
+		if r.NIK, err = util.Decrypt(nik, "f"); err != nil {
+			continue
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+// StoreTrainings replaces the user's training history and refreshes the
+// courses it references.
+func (q *repository) StoreTrainings(c context.Context, userID int64, trainings []Training) error {
+	tx, err := q.db.BeginTx(c, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseQuery := `
+	INSERT INTO ` + courseTable + ` (id_pel, kode_pel, judul_pel, link_materi, link_flyer, starts_at, ends_at)
+	VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+	ON CONFLICT (id_pel) DO UPDATE SET
+		kode_pel = EXCLUDED.kode_pel,
+		judul_pel = EXCLUDED.judul_pel,
+		link_materi = EXCLUDED.link_materi,
+		link_flyer = EXCLUDED.link_flyer,
+		starts_at = EXCLUDED.starts_at,
+		ends_at = EXCLUDED.ends_at,
+		updated_at = NOW()`
+
+	trainingQuery := `
+	INSERT INTO ` + trainingTable + ` (user_id, id_pel, status, participated, synced_at)
+	VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+	ON CONFLICT (user_id, id_pel) DO UPDATE SET
+		status = EXCLUDED.status,
+		participated = EXCLUDED.participated,
+		synced_at = EXCLUDED.synced_at`
+
+	if _, err := tx.ExecContext(c, `DELETE FROM `+trainingTable+` WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, t := range trainings {
+		_, err := tx.ExecContext(c, courseQuery, t.IDPel, t.KodePel, t.Judul, t.LinkMateri, t.LinkFlyer, t.StartsAt, t.EndsAt)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(c, trainingQuery, userID, t.IDPel, t.Status, t.Participated, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	syncQuery := `
+	INSERT INTO ` + syncTable + ` (user_id, synced_at, trainings, error)
+	VALUES ($1, $2, $3, NULL)
+	ON CONFLICT (user_id) DO UPDATE SET
+		synced_at = EXCLUDED.synced_at,
+		trainings = EXCLUDED.trainings,
+		error = NULL`
+
+	if _, err := tx.ExecContext(c, syncQuery, userID, now, len(trainings)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// StoreSyncFailure records a failed sync without touching the history from
+// the last successful one. synced_at still moves so a user whose NIK keeps
+// failing does not block the rest of the queue.
+func (q *repository) StoreSyncFailure(c context.Context, userID int64, syncErr error) error {
+	query := `
+	INSERT INTO ` + syncTable + ` (user_id, synced_at, error)
+	VALUES ($1, NOW(), $2)
+	ON CONFLICT (user_id) DO UPDATE SET
+		synced_at = EXCLUDED.synced_at,
+		error = EXCLUDED.error`
+
+	_, err := q.db.ExecContext(c, query, userID, syncErr.Error())
+	return err
+}
+
+func (q *repository) GetSyncState(c context.Context, userID int64) (SyncState, error) {
+	var r SyncState
+
+	query := `SELECT synced_at, trainings, error FROM ` + syncTable + ` WHERE user_id = $1`
+	err := q.db.QueryRowContext(c, query, userID).Scan(&r.SyncedAt, &r.Trainings, &r.Error)
+	if err == sql.ErrNoRows {
+		return r, nil
+	}
+
+	return r, err
+}
+
+func (q *repository) GetUserTrainings(c context.Context, userID int64) ([]Training, error) {
+	query := `
+	SELECT c.id_pel, COALESCE(c.kode_pel, ''), c.judul_pel, COALESCE(c.link_materi, ''), COALESCE(c.link_flyer, ''),
+		c.starts_at, c.ends_at, COALESCE(t.status, ''), t.participated, t.synced_at
+	FROM ` + trainingTable + ` t
+	JOIN ` + courseTable + ` c ON c.id_pel = t.id_pel
+	WHERE t.user_id = $1
+	ORDER BY c.starts_at DESC NULLS LAST, c.id_pel`
+
+	rows, err := q.db.QueryContext(c, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Training{}
+	for rows.Next() {
+		var r Training
+		err := rows.Scan(&r.IDPel, &r.KodePel, &r.Judul, &r.LinkMateri, &r.LinkFlyer,
+			&r.StartsAt, &r.EndsAt, &r.Status, &r.Participated, &r.SyncedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+// TrainingStatsByRegion groups trainings by the province of the user, or by
+// regency when provinceKode narrows the query to one province.
+func (q *repository) TrainingStatsByRegion(c context.Context, provinceKode, idPel string) ([]TrainingStat, error) {
+	column := "u.province_id"
+	args := []interface{}{}
+	where := "WHERE u.is_active = true"
+
+	if provinceKode != "" {
+		column = "u.regency_id"
+		args = append(args, provinceKode)
+		where += " AND u.province_id = $" + strconv.Itoa(len(args))
+	}
+	if idPel != "" {
+		args = append(args, idPel)
+		where += " AND t.id_pel = $" + strconv.Itoa(len(args))
+	}
+
+	query := `
+	SELECT COALESCE(` + column + `, ''), COALESCE(MAX(w.nama), ''),
+		COUNT(DISTINCT t.user_id), COUNT(*), COUNT(*) FILTER (WHERE t.participated)
+	FROM ` + trainingTable + ` t
+	JOIN ` + userTable + ` u ON u.id = t.user_id
+	LEFT JOIN ` + regionTable + ` w ON w.kode = ` + column + `
+	` + where + `
+	GROUP BY ` + column + `
+	ORDER BY 1`
+
+	return q.stats(c, query, args...)
+}
+
+func (q *repository) TrainingStatsByCourse(c context.Context, provinceKode string) ([]TrainingStat, error) {
+	args := []interface{}{}
+	where := "WHERE u.is_active = true"
+
+	if provinceKode != "" {
+		args = append(args, provinceKode)
+		where += " AND u.province_id = $1"
+	}
+
+	query := `
+	SELECT c.id_pel, c.judul_pel,
+		COUNT(DISTINCT t.user_id), COUNT(*), COUNT(*) FILTER (WHERE t.participated)
+	FROM ` + trainingTable + ` t
+	JOIN ` + userTable + ` u ON u.id = t.user_id
+	JOIN ` + courseTable + ` c ON c.id_pel = t.id_pel
+	` + where + `
+	GROUP BY c.id_pel, c.judul_pel
+	ORDER BY COUNT(*) DESC, c.id_pel`
+
+	return q.stats(c, query, args...)
+}
+
+func (q *repository) stats(c context.Context, query string, args ...interface{}) ([]TrainingStat, error) {
+	rows, err := q.db.QueryContext(c, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []TrainingStat{}
+	for rows.Next() {
+		var r TrainingStat
+		if err := rows.Scan(&r.Kode, &r.Nama, &r.Users, &r.Trainings, &r.Participated); err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,172 @@
+package simluh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// syncLockKey makes sure only one API instance walks the user table.
+	syncLockKey    = "ppt:simluh-sync"
+	syncStaleAfter = 24 * time.Hour
+	syncBatchSize  = 200
+	// syncPause spaces out upstream calls so a full pass never floods
+	// laporanutama.pertanian.go.id.
+	syncPause   = 500 * time.Millisecond
+	syncTimeout = 30 * time.Second
+)
+
+// trainingSyncer copies SIMLUH training history into ppt_user_trainings,
+// periodically for every user with a NIK and on demand for single users.
+type trainingSyncer struct {
+	repo     SimluhRepository
+	rdb      *redis.Client
+	baseURL  string
+	interval time.Duration
+	trigger  chan struct{}
+}
+
+func newTrainingSyncer(repo SimluhRepository, rdb *redis.Client, baseURL string, interval time.Duration) *trainingSyncer {
+	s := &trainingSyncer{
+		repo:     repo,
+		rdb:      rdb,
+		baseURL:  baseURL,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+	if interval > 0 {
+		go s.loop()
+	}
+	return s
+}
+
+// Trigger starts a pass now instead of waiting for the next tick. It
+// returns false when a pass is already pending.
+func (s *trainingSyncer) Trigger() bool {
+	select {
+	case s.trigger <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *trainingSyncer) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+		s.pass()
+	}
+}
+
+// pass syncs every user whose history is stale, a batch at a time.
+func (s *trainingSyncer) pass() {
+	ctx := context.Background()
+
+	ok, err := s.rdb.SetNX(ctx, syncLockKey, time.Now().Format(time.RFC3339), s.interval).Result()
+	if err != nil {
+		log.Printf("simluh sync lock: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer s.rdb.Del(ctx, syncLockKey)
+
+	started := time.Now()
+	synced, failed := 0, 0
+	for {
+		users, err := s.repo.UsersDueForSync(ctx, started.Add(-syncStaleAfter), syncBatchSize)
+		if err != nil {
+			log.Printf("simluh sync: %v", err)
+			return
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, u := range users {
+			if err := s.Sync(ctx, u); err != nil {
+				failed++
+			} else {
+				synced++
+			}
+			time.Sleep(syncPause)
+		}
+	}
+
+	log.Printf("simluh sync: %d users synced, %d failed in %s", synced, failed, time.Since(started).Round(time.Second))
+}
+
+// Sync refreshes the training history of one user. Failures are recorded
+// against the user and returned.
+func (s *trainingSyncer) Sync(ctx context.Context, u UserNIK) error {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	items, err := s.fetch(ctx, u.NIK)
+	if err != nil {
+		if serr := s.repo.StoreSyncFailure(ctx, u.ID, err); serr != nil {
+			log.Printf("simluh sync user %d: %v", u.ID, serr)
+		}
+		return err
+	}
+
+	now := time.Now()
+	trainings := make([]Training, 0, len(items))
+	for _, item := range items {
+		if item.IdPel == "" {
+			continue
+		}
+		trainings = append(trainings, item.Training(now))
+	}
+
+	return s.repo.StoreTrainings(ctx, u.ID, trainings)
+}
+
+func (s *trainingSyncer) fetch(ctx context.Context, nik string) ([]Pelatihan, error) {
+	rawURL := s.baseURL + "/biodata/pelatihan_status_penyuluh_json.php?nik=" + url.QueryEscape(nik)
+
+	upstream := util.UpstreamFor(rawURL)
+	resp, err := upstream.Get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstream.Unexpected(resp)
+	}
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	// The endpoint answers null or an empty body for a NIK without any
+	// training rather than an empty array.
+	raw := bytes.TrimSpace(body.Bytes())
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return []Pelatihan{}, nil
+	}
+
+	var items []Pelatihan
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, &util.UpstreamError{Host: resp.Request.URL.Hostname(), StatusCode: resp.StatusCode, Kind: util.ErrUpstreamResponse, Cause: err}
+	}
+
+	return items, nil
+}
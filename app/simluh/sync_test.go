@@ -0,0 +1,112 @@
+package simluh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gigaflex-co/ppt_backend/cmd/mockupstream/mock"
+	"github.com/gigaflex-co/ppt_backend/util"
+)
+
+type fakeSyncRepo struct {
+	SimluhRepository
+	stored  map[int64][]Training
+	failure map[int64]error
+}
+
+func newFakeSyncRepo() *fakeSyncRepo {
+	return &fakeSyncRepo{stored: map[int64][]Training{}, failure: map[int64]error{}}
+}
+
+func (f *fakeSyncRepo) StoreTrainings(c context.Context, userID int64, trainings []Training) error {
+	f.stored[userID] = trainings
+	return nil
+}
+
+func (f *fakeSyncRepo) StoreSyncFailure(c context.Context, userID int64, syncErr error) error {
+	f.failure[userID] = syncErr
+	return nil
+}
+
+func TestSyncAgainstMock(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{})
+	repo := newFakeSyncRepo()
+	s := &trainingSyncer{repo: repo, baseURL: srv.URL + "/simluh-report"}
+
+	if err := s.Sync(context.Background(), UserNIK{ID: 7, NIK: "3201011501900001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := repo.stored[7]
+	if len(got) != 2 {
+		t.Fatalf("stored %d trainings, want 2", len(got))
+	}
+	if got[0].IDPel != "153" || !got[0].Participated || got[1].IDPel != "171" || got[1].Participated {
+		t.Errorf("trainings = %+v", got)
+	}
+	if got[0].StartsAt == nil || got[0].StartsAt.Format("2006-01-02 15:04") != "2023-07-10 08:00" {
+		t.Errorf("starts at = %v, want 2023-07-10 08:00", got[0].StartsAt)
+	}
+	if len(repo.failure) != 0 {
+		t.Errorf("failures recorded: %v", repo.failure)
+	}
+}
+
+func TestSyncRecordsFailures(t *testing.T) {
+	srv := newMockUpstream(t, mock.Behavior{FailNext: 1, ErrorStatus: http.StatusForbidden})
+	repo := newFakeSyncRepo()
+	s := &trainingSyncer{repo: repo, baseURL: srv.URL + "/simluh-report"}
+
+	err := s.Sync(context.Background(), UserNIK{ID: 7, NIK: "3201011501900001"})
+
+	var ue *util.UpstreamError
+	if !errors.As(err, &ue) || ue.StatusCode != http.StatusForbidden {
+		t.Fatalf("Sync() error = %v, want the upstream's 403", err)
+	}
+	if repo.failure[7] != err {
+		t.Errorf("recorded failure = %v, want %v", repo.failure[7], err)
+	}
+	if _, ok := repo.stored[7]; ok {
+		t.Error("trainings stored after a failed fetch")
+	}
+}
+
+func TestSyncFetchBodies(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		items int
+		err   bool
+	}{
+		{"empty body", "", 0, false},
+		{"null", " null\n", 0, false},
+		{"empty array", "[]", 0, false},
+		{"numbers and nulls", `[{"sts_ikut": 1, "id_pel": 153, "judul_pel": null}]`, 1, false},
+		{"html error page", "<html>Database error</html>", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s := &trainingSyncer{baseURL: srv.URL}
+			items, err := s.fetch(context.Background(), "3201011501900001")
+
+			if (err != nil) != tt.err {
+				t.Fatalf("fetch() error = %v, want error %v", err, tt.err)
+			}
+			if err == nil && len(items) != tt.items {
+				t.Errorf("fetch() returned %d items, want %d", len(items), tt.items)
+			}
+			if err != nil && !errors.Is(err, util.ErrUpstreamResponse) {
+				t.Errorf("fetch() error = %v, want %v", err, util.ErrUpstreamResponse)
+			}
+		})
+	}
+}
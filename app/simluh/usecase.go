@@ -0,0 +1,265 @@
+package simluh
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/audit"
+	"github.com/gigaflex-co/ppt_backend/app/storage"
+	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// refreshCooldown is how long a user waits between manual refreshes of
+// their own training history.
+const refreshCooldown = 15 * time.Minute
+
+type (
+	SimluhUsecase interface {
+		GetSertifikat(c *gin.Context)
+		GetMyTrainings(c *gin.Context)
+		RefreshMyTrainings(c *gin.Context)
+		GetTrainingStats(c *gin.Context)
+		SyncTrainings(c *gin.Context)
+	}
+
+	usecase struct {
+		repo         SimluhRepository
+		audit        audit.AuditRepository
+		certificates *certificateCache
+		syncer       *trainingSyncer
+	}
+)
+
+// NewUsecase caches certificates in store, which should encrypt them at
+// rest as they carry personal data.
+func NewUsecase(repo SimluhRepository, auditRepo audit.AuditRepository, store storage.Store, rdb *redis.Client, cfg config.Config) SimluhUsecase {
+	return &usecase{
+		repo:  repo,
+		audit: auditRepo,
+		certificates: &certificateCache{
+			store:   store,
+			baseURL: cfg.SimluhTrainingBaseURL,
+			ttl:     cfg.SimluhCertificateTTL,
+			secret:  []byte(cfg.SecretKey),
+		},
+		syncer: newTrainingSyncer(repo, rdb, cfg.SimluhReportBaseURL, cfg.SimluhSyncInterval),
+	}
+}
+
+// GetSertifikat streams a training certificate. Users may only fetch their
+// own; admins may fetch anyone's, and every such access is audited.
+func (uc *usecase) GetSertifikat(c *gin.Context) {
+	req := CertificateRequest{
+		NIK:   strings.TrimSpace(c.Query("nik")),
+		IDPel: c.DefaultQuery("id_pel", "153"),
+		Tipe:  c.DefaultQuery("tipe", "pns"),
+	}
+
+	if !idPelPattern.MatchString(req.IDPel) || !tipePattern.MatchString(req.Tipe) {
+		util.JERR(c, http.StatusBadRequest, errors.New("invalid id_pel or tipe"))
+		return
+	}
+
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	user, err := uc.repo.GetUserNIK(c, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if req.NIK == "" {
+		if user.NIK == "" {
+			util.JERR(c, http.StatusNotFound, ErrNoNIK)
+			return
+		}
+		req.NIK = user.NIK
+	}
+
+	if !nikPattern.MatchString(req.NIK) {
+		util.JERR(c, http.StatusBadRequest, errors.New("nik must be 16 digits"))
+		return
+	}
+
+	if req.NIK != user.NIK {
+		admin, err := uc.repo.IsAdmin(c, email)
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !admin {
+			util.JERR(c, http.StatusForbidden, errors.New("certificate belongs to another user"))
+			return
+		}
+
+		err = uc.audit.Record(c, audit.Entry{
+			ActorEmail: email,
+			Action:     "simluh.certificate.download",
+			Target:     maskNIK(req.NIK),
+			Detail:     map[string]interface{}{"id_pel": req.IDPel, "tipe": req.Tipe},
+			IPAddress:  c.ClientIP(),
+		})
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	body, size, cached, err := uc.certificates.Open(c, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrCertificateNotFound):
+			util.JERR(c, http.StatusNotFound, err)
+		case errors.Is(err, ErrInvalidCertificate):
+			util.JERR(c, http.StatusBadGateway, err)
+		default:
+			util.JERR(c, util.UpstreamHTTPStatus(err), err)
+		}
+		return
+	}
+	defer body.Close()
+
+	cache := "MISS"
+	if cached {
+		cache = "HIT"
+	}
+
+	c.DataFromReader(http.StatusOK, size, "application/pdf", body, map[string]string{
+		"Content-Disposition": `attachment; filename="sertifikat-` + req.IDPel + `.pdf"`,
+		"Cache-Control":       "private, no-store",
+		"X-Cache":             cache,
+	})
+}
+
+// GetMyTrainings returns the caller's synced training history. A user that
+// was never synced is synced on the spot so the first visit is not empty.
+func (uc *usecase) GetMyTrainings(c *gin.Context) {
+	user, ok := uc.claimsUser(c)
+	if !ok {
+		return
+	}
+
+	state, err := uc.repo.GetSyncState(c, user.ID)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if state.SyncedAt == nil {
+		if err := uc.syncer.Sync(c, user); err != nil {
+			util.JERR(c, util.UpstreamHTTPStatus(err), err)
+			return
+		}
+	}
+
+	uc.respondTrainings(c, user)
+}
+
+func (uc *usecase) RefreshMyTrainings(c *gin.Context) {
+	user, ok := uc.claimsUser(c)
+	if !ok {
+		return
+	}
+
+	state, err := uc.repo.GetSyncState(c, user.ID)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if state.SyncedAt != nil && time.Since(*state.SyncedAt) < refreshCooldown {
+		util.JERR(c, http.StatusTooManyRequests, errors.New("training history was refreshed recently, try again later"))
+		return
+	}
+
+	if err := uc.syncer.Sync(c, user); err != nil {
+		util.JERR(c, util.UpstreamHTTPStatus(err), err)
+		return
+	}
+
+	uc.respondTrainings(c, user)
+}
+
+// GetTrainingStats summarises synced trainings by=region (default) or
+// by=course, optionally within one province. by=region with id_pel counts a
+// single course per region.
+func (uc *usecase) GetTrainingStats(c *gin.Context) {
+	province := c.Query("province")
+
+	var data []TrainingStat
+	var err error
+
+	switch c.DefaultQuery("by", "region") {
+	case "region":
+		data, err = uc.repo.TrainingStatsByRegion(c, province, c.Query("id_pel"))
+	case "course":
+		data, err = uc.repo.TrainingStatsByCourse(c, province)
+	default:
+		util.JERR(c, http.StatusBadRequest, errors.New("by must be region or course"))
+		return
+	}
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+// SyncTrainings starts a background pass over every user with stale
+// training history.
+func (uc *usecase) SyncTrainings(c *gin.Context) {
+	triggered := uc.syncer.Trigger()
+	util.JOK(c, http.StatusAccepted, gin.H{"triggered": triggered})
+}
+
+func (uc *usecase) claimsUser(c *gin.Context) (UserNIK, bool) {
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return UserNIK{}, false
+	}
+
+	user, err := uc.repo.GetUserNIK(c, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("user not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return user, false
+	}
+
+	if user.NIK == "" {
+		util.JERR(c, http.StatusNotFound, ErrNoNIK)
+		return user, false
+	}
+
+	return user, true
+}
+
+func (uc *usecase) respondTrainings(c *gin.Context, user UserNIK) {
+	state, err := uc.repo.GetSyncState(c, user.ID)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	trainings, err := uc.repo.GetUserTrainings(c, user.ID)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, MyTrainings{Sync: state, Trainings: trainings})
+}
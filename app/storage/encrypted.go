@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// maxEncryptedSize bounds what the encrypted store holds in memory; it is
+// meant for identity documents, not bulk files.
+const maxEncryptedSize = 32 << 20
+
+var ErrTooLarge = errors.New("object too large")
+
+type encryptedStore struct {
+	inner Store
+	aead  cipher.AEAD
+}
+
+// NewEncryptedStore seals every object with AES-256-GCM before handing it
+// to inner, so the files at rest and in the bucket are unreadable without
+// secret. The key is derived from secret; changing it makes existing
+// objects unreadable.
+func NewEncryptedStore(inner Store, secret string) (Store, error) {
+	key := sha256.Sum256([]byte("ppt-storage:" + secret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedStore{inner: inner, aead: aead}, nil
+}
+
+// Put stores nonce || ciphertext. The key is bound as additional data so
+// an object copied to another key fails to open.
+func (s *encryptedStore) Put(c context.Context, key string, r io.Reader, size int64, contentType string) error {
+	plain, err := io.ReadAll(io.LimitReader(r, maxEncryptedSize+1))
+	if err != nil {
+		return err
+	}
+	if len(plain) > maxEncryptedSize {
+		return ErrTooLarge
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, plain, []byte(key))
+
+	return s.inner.Put(c, key, bytes.NewReader(sealed), int64(len(sealed)), contentType)
+}
+
+func (s *encryptedStore) Get(c context.Context, key string) (io.ReadCloser, Object, error) {
+	body, obj, err := s.inner.Get(c, key)
+	if err != nil {
+		return nil, obj, err
+	}
+	defer body.Close()
+
+	sealed, err := io.ReadAll(io.LimitReader(body, maxEncryptedSize+int64(s.aead.NonceSize()+s.aead.Overhead())+1))
+	if err != nil {
+		return nil, obj, err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, obj, errors.New("encrypted object is truncated")
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, obj, err
+	}
+
+	obj.Size = int64(len(plain))
+	return io.NopCloser(bytes.NewReader(plain)), obj, nil
+}
+
+// Stat reports the plaintext size.
+func (s *encryptedStore) Stat(c context.Context, key string) (Object, error) {
+	obj, err := s.inner.Stat(c, key)
+	if err != nil {
+		return obj, err
+	}
+
+	obj.Size -= int64(s.aead.NonceSize() + s.aead.Overhead())
+	if obj.Size < 0 {
+		obj.Size = 0
+	}
+	return obj, nil
+}
+
+func (s *encryptedStore) Delete(c context.Context, key string) error {
+	return s.inner.Delete(c, key)
+}
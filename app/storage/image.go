@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+
+	// Decoders for the accepted upload formats.
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxImagePixels refuses images whose header claims dimensions large
+// enough to exhaust memory when decoded.
+const maxImagePixels = 40_000_000
+
+var ErrNotImage = errors.New("file is not a supported image")
+
+// Sniff returns the content type of data from its first bytes, ignoring
+// whatever name or type the client claimed.
+func Sniff(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// ImageSize returns the dimensions of an encoded image without decoding it.
+func ImageSize(data []byte) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, ErrNotImage
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Thumbnail scales an image to fit within max by max pixels, keeping its
+// aspect ratio, and encodes it as JPEG. Smaller images are re-encoded at
+// their own size.
+func Thumbnail(data []byte, max int) ([]byte, error) {
+	width, height, err := ImageSize(data)
+	if err != nil {
+		return nil, err
+	}
+	if width*height > maxImagePixels {
+		return nil, ErrNotImage
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+
+	w, h := width, height
+	if w > max || h > max {
+		if w >= h {
+			w, h = max, height*max/width
+		} else {
+			w, h = width*max/height, max
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	// JPEG has no alpha; transparent areas become white rather than black.
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// metaSuffix names the sidecar file holding an object's content type, as
+// the filesystem has nowhere else to keep it.
+const metaSuffix = ".meta.json"
+
+type (
+	localStore struct {
+		root string
+	}
+
+	localMeta struct {
+		ContentType string `json:"content_type"`
+	}
+)
+
+func NewLocalStore(root string) (Store, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+
+	return &localStore{root: root}, nil
+}
+
+func (s *localStore) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file first so readers never see a partial
+// object.
+func (s *localStore) Put(c context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+metaSuffix, meta, 0o640); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localStore) Get(c context.Context, key string) (io.ReadCloser, Object, error) {
+	obj, err := s.Stat(c, key)
+	if err != nil {
+		return nil, obj, err
+	}
+
+	p, _ := s.path(key)
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, obj, ErrNotFound
+	}
+	if err != nil {
+		return nil, obj, err
+	}
+
+	return f, obj, nil
+}
+
+func (s *localStore) Stat(c context.Context, key string) (Object, error) {
+	obj := Object{Key: key}
+
+	p, err := s.path(key)
+	if err != nil {
+		return obj, err
+	}
+
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return obj, ErrNotFound
+	}
+	if err != nil {
+		return obj, err
+	}
+	obj.Size = info.Size()
+	obj.ModTime = info.ModTime()
+
+	var meta localMeta
+	if data, err := os.ReadFile(p + metaSuffix); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	obj.ContentType = meta.ContentType
+	if obj.ContentType == "" {
+		obj.ContentType = "application/octet-stream"
+	}
+
+	return obj, nil
+}
+
+func (s *localStore) Delete(c context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(p + metaSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
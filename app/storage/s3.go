@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gigaflex-co/ppt_backend/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type s3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Store connects to an S3 compatible endpoint and creates the bucket
+// when it does not exist yet.
+func NewS3Store(cfg config.Config) (Store, error) {
+	if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 storage driver")
+	}
+
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &s3Store{client: client, bucket: cfg.S3Bucket}, nil
+}
+
+func (s *s3Store) Put(c context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutObject(c, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *s3Store) Get(c context.Context, key string) (io.ReadCloser, Object, error) {
+	obj, err := s.Stat(c, key)
+	if err != nil {
+		return nil, obj, err
+	}
+
+	body, err := s.client.GetObject(c, s.bucket, obj.Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, obj, translateS3Error(err)
+	}
+
+	return body, obj, nil
+}
+
+func (s *s3Store) Stat(c context.Context, key string) (Object, error) {
+	obj := Object{Key: key}
+
+	key, err := cleanKey(key)
+	if err != nil {
+		return obj, err
+	}
+
+	info, err := s.client.StatObject(c, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return obj, translateS3Error(err)
+	}
+
+	obj.Size = info.Size
+	obj.ContentType = info.ContentType
+	obj.ModTime = info.LastModified
+	return obj, nil
+}
+
+func (s *s3Store) Delete(c context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	return translateS3Error(s.client.RemoveObject(c, s.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+func translateS3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
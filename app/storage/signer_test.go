@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerVerify(t *testing.T) {
+	s := NewSigner("secret", "/v1/files/")
+
+	link, err := url.Parse(s.URL("ktp/2023/abc def.jpg", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(link.Path, "/v1/files/") {
+		t.Fatalf("URL() path = %q, want the /v1/files/ prefix", link.Path)
+	}
+	key := strings.TrimPrefix(link.Path, "/v1/files/")
+	expires, sig := link.Query().Get("expires"), link.Query().Get("sig")
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name              string
+		signer            *Signer
+		key, expires, sig string
+		valid             bool
+	}{
+		{"issued link", s, key, expires, sig, true},
+		{"other key", s, "ktp/2023/other.jpg", expires, sig, false},
+		{"extended expiry", s, key, strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10), sig, false},
+		{"expired", s, key, past, s.sign(key, past), false},
+		{"malformed expiry", s, key, "tomorrow", sig, false},
+		{"malformed signature", s, key, expires, "not-hex", false},
+		{"truncated signature", s, key, expires, sig[:32], false},
+		{"other secret", NewSigner("other", "/v1/files/"), key, expires, sig, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.key, tt.expires, tt.sig)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Verify() error = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil && err != ErrInvalidSignature {
+				t.Errorf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/config"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+type (
+	// Object describes a stored file.
+	Object struct {
+		Key         string
+		Size        int64
+		ContentType string
+		ModTime     time.Time
+	}
+
+	// Store keeps files under slash separated keys such as
+	// "simluh/certificates/<hash>/153-pns.pdf". Implementations return
+	// ErrNotFound for missing keys.
+	Store interface {
+		Put(c context.Context, key string, r io.Reader, size int64, contentType string) error
+		Get(c context.Context, key string) (io.ReadCloser, Object, error)
+		Stat(c context.Context, key string) (Object, error)
+		Delete(c context.Context, key string) error
+	}
+)
+
+// NewStore builds the Store selected by STORAGE_DRIVER.
+func NewStore(cfg config.Config) (Store, error) {
+	switch cfg.StorageDriver {
+	case "", "local":
+		return NewLocalStore(cfg.StorageLocalDir)
+	case "s3":
+		return NewS3Store(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// cleanKey rejects keys that are empty, absolute or escape the store root.
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+		return "", ErrInvalidKey
+	}
+
+	return cleaned, nil
+}
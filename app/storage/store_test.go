@@ -0,0 +1,39 @@
+package storage
+
+import "testing"
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"ktp/2023/abc.jpg", true},
+		{"abc.jpg", true},
+		{"a..b/c.jpg", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../etc/passwd", false},
+		{"ktp/../../etc/passwd", false},
+		{"ktp/../abc.jpg", false},
+		{"/etc/passwd", false},
+		{"ktp//abc.jpg", false},
+		{"ktp/./abc.jpg", false},
+		{"ktp/", false},
+		{`ktp\..\abc.jpg`, false},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanKey(tt.key)
+		if (err == nil) != tt.valid {
+			t.Errorf("cleanKey(%q) error = %v, want valid %v", tt.key, err, tt.valid)
+			continue
+		}
+		if err != nil && err != ErrInvalidKey {
+			t.Errorf("cleanKey(%q) error = %v, want %v", tt.key, err, ErrInvalidKey)
+		}
+		if err == nil && got != tt.key {
+			t.Errorf("cleanKey(%q) = %q", tt.key, got)
+		}
+	}
+}
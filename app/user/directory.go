@@ -0,0 +1,558 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/lib/pq"
+)
+
+const (
+	// directoryAlias names the user directory; the concrete index behind
+	// it carries a build timestamp so a reindex never leaves the alias
+	// pointing at a half filled index.
+	directoryAlias       = "ppt_user_directory"
+	directoryBulkSize    = 500
+	directorySyncTimeout = 10 * time.Second
+	maxDirectoryWindow   = 10000
+)
+
+var (
+	ErrDirectoryUnavailable = errors.New("user search is not available")
+	ErrDirectoryWindow      = fmt.Errorf("search results are limited to the first %d matches, narrow the search", maxDirectoryWindow)
+
+	digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+)
+
+type (
+	// Directory is the Elasticsearch index behind user search. Postgres
+	// keeps name, phone, NIK and NIP encrypted with random IVs, so they
+	// cannot be searched there. The directory indexes the name in clear
+	// for fuzzy matching and phone, NIK and NIP only as keyed hashes for
+	// exact lookups. Documents keep no _source and the index is hidden:
+	// hits are user ids, and the rows are loaded from Postgres.
+	Directory struct {
+		db     *sql.DB
+		es     *elasticsearch.Client
+		secret []byte
+	}
+
+	// DirectoryQuery searches the directory. Text matches names fuzzily,
+	// usernames and emails by prefix and, with Identifiers set, NIK, NIP or
+	// phone numbers exactly. Region matches users anywhere within a
+	// ppt_wilayah code.
+	DirectoryQuery struct {
+		Text        string
+		Identifiers bool
+		RoleID      int64
+		Region      string
+		IsActive    *bool
+		IsComplete  *bool
+		IsVerified  *bool
+		Page        int
+		PageSize    int
+	}
+)
+
+// NewDirectory keys the identifier hashes with secret. Changing it makes
+// the index useless until the next reindex. es may be nil, which turns the
+// directory off.
+func NewDirectory(db *sql.DB, es *elasticsearch.Client, secret string) *Directory {
+	return &Directory{
+		db:     db,
+		es:     es,
+		secret: []byte(secret),
+	}
+}
+
+func (d *Directory) enabled() bool {
+	return d != nil && d.es != nil
+}
+
+func (d *Directory) mapping() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword"}
+	boolean := map[string]interface{}{"type": "boolean"}
+
+	return map[string]interface{}{
+		"settings": map[string]interface{}{
+			"number_of_shards": 1,
+			"index.hidden":     true,
+			"analysis": map[string]interface{}{
+				"normalizer": map[string]interface{}{
+					"lowercase": map[string]interface{}{"type": "custom", "filter": []string{"lowercase"}},
+				},
+			},
+		},
+		"mappings": map[string]interface{}{
+			"dynamic": "strict",
+			"_source": map[string]interface{}{"enabled": false},
+			"properties": map[string]interface{}{
+				"name":            map[string]interface{}{"type": "text"},
+				"username":        map[string]interface{}{"type": "keyword", "normalizer": "lowercase"},
+				"email":           map[string]interface{}{"type": "keyword", "normalizer": "lowercase"},
+				"nik_hash":        keyword,
+				"nip_hash":        keyword,
+				"phone_hash":      keyword,
+				"role_id":         keyword,
+				"province_id":     keyword,
+				"regency_id":      keyword,
+				"subdistrict_id":  keyword,
+				"urbanvillage_id": keyword,
+				"is_active":       boolean,
+				"is_complete":     boolean,
+				"is_verified":     boolean,
+				"created_at":      map[string]interface{}{"type": "date"},
+			},
+		},
+	}
+}
+
+func (d *Directory) hash(s string) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// each loads the users matching where and hands their documents to fn.
+func (d *Directory) each(ctx context.Context, where string, args []interface{}, fn func(id int64, doc map[string]interface{}) error) error {
+	query := `
+	SELECT id, role_id, province_id, regency_id, subdistrict_id, urbanvillage_id, name, username, email,
+		phone, nik, nip, is_active, is_complete, is_verified, created_at
+	FROM ` + table + `
+	WHERE ` + where + `
+	ORDER BY id`
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, roleID int64
+		var province, regency, subdistrict, urbanvillage, phone, nik, nip sql.NullString
+		var name, username, email string
+		var isActive, isComplete, isVerified bool
+		var createdAt sql.NullTime
+		if err := rows.Scan(&id, &roleID, &province, &regency, &subdistrict, &urbanvillage, &name, &username, &email,
+			&phone, &nik, &nip, &isActive, &isComplete, &isVerified, &createdAt); err != nil {
+			return err
+		}
+
+		doc := map[string]interface{}{
+			"username":    username,
+			"email":       email,
+			"role_id":     strconv.FormatInt(roleID, 10),
+			"is_active":   isActive,
+			"is_complete": isComplete,
+			"is_verified": isVerified,
+		}
+		if v, err := util.Decrypt(name, "f"); err == nil {
+			doc["name"] = v
+		}
+		for field, v := range map[string]sql.NullString{"nik_hash": nik, "nip_hash": nip, "phone_hash": phone} {
+			if !v.Valid {
+				continue
+			}
+			if plain, err := util.Decrypt(v.String, "f"); err == nil && plain != "" {
+				doc[field] = d.hash(plain)
+			}
+		}
+		for field, v := range map[string]sql.NullString{"province_id": province, "regency_id": regency, "subdistrict_id": subdistrict, "urbanvillage_id": urbanvillage} {
+			if v.Valid && v.String != "" {
+				doc[field] = v.String
+			}
+		}
+		if createdAt.Valid {
+			doc["created_at"] = createdAt.Time
+		}
+
+		if err := fn(id, doc); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// SyncIDs reindexes the given users and drops those that no longer exist.
+// Failures are logged rather than returned, so a write to Postgres never
+// fails on the directory; a reindex repairs any drift.
+func (d *Directory) SyncIDs(ctx context.Context, ids ...int64) {
+	if !d.enabled() || len(ids) == 0 {
+		return
+	}
+
+	if err := d.sync(ctx, "id = ANY($1)", pq.Array(ids), ids); err != nil {
+		log.Printf("syncing user directory: %v", err)
+	}
+}
+
+// SyncEmails reindexes the users with the given emails.
+func (d *Directory) SyncEmails(ctx context.Context, emails ...string) {
+	if !d.enabled() || len(emails) == 0 {
+		return
+	}
+
+	if err := d.sync(ctx, "email = ANY($1)", pq.Array(emails), nil); err != nil {
+		log.Printf("syncing user directory: %v", err)
+	}
+}
+
+// Remove drops deleted users from the directory.
+func (d *Directory) Remove(ctx context.Context, ids ...int64) {
+	if !d.enabled() || len(ids) == 0 {
+		return
+	}
+
+	if err := d.sync(ctx, "false", nil, ids); err != nil {
+		log.Printf("syncing user directory: %v", err)
+	}
+}
+
+// sync indexes the users matching where and deletes the documents of the
+// expected ids that were not found.
+func (d *Directory) sync(ctx context.Context, where string, arg interface{}, expected []int64) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), directorySyncTimeout)
+	defer cancel()
+
+	var args []interface{}
+	if arg != nil {
+		args = append(args, arg)
+	}
+
+	var buf bytes.Buffer
+	found := map[int64]bool{}
+	err := d.each(ctx, where, args, func(id int64, doc map[string]interface{}) error {
+		found[id] = true
+		return writeBulkIndex(&buf, id, doc)
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range expected {
+		if !found[id] {
+			fmt.Fprintf(&buf, `{"delete":{"_id":"%d"}}`+"\n", id)
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	// require_alias keeps a sync before the first reindex from creating an
+	// index with a dynamic mapping under the alias name.
+	return d.bulk(ctx, directoryAlias, &buf, d.es.Bulk.WithRequireAlias(true))
+}
+
+// Reindex rebuilds the directory from ppt_users and points the alias at
+// the new index once every user is in. Changes made while it runs reach
+// the old index only and are repaired by the next reindex.
+func (d *Directory) Reindex(ctx context.Context) (int, error) {
+	if !d.enabled() {
+		return 0, ErrDirectoryUnavailable
+	}
+
+	index := directoryAlias + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	mapping, err := json.Marshal(d.mapping())
+	if err != nil {
+		return 0, err
+	}
+	res, err := d.es.Indices.Create(index, d.es.Indices.Create.WithBody(bytes.NewReader(mapping)), d.es.Indices.Create.WithContext(ctx))
+	if err := directoryError(res, err); err != nil {
+		return 0, fmt.Errorf("creating index %s: %w", index, err)
+	}
+	res.Body.Close()
+
+	count, err := d.fill(ctx, index)
+	if err != nil {
+		d.drop(ctx, index)
+		return 0, err
+	}
+
+	previous, err := d.aliasIndices(ctx)
+	if err != nil {
+		d.drop(ctx, index)
+		return 0, err
+	}
+
+	actions := []map[string]interface{}{{"add": map[string]interface{}{"index": index, "alias": directoryAlias, "is_hidden": true}}}
+	for _, old := range previous {
+		actions = append(actions, map[string]interface{}{"remove": map[string]string{"index": old, "alias": directoryAlias}})
+	}
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return 0, err
+	}
+	res, err = d.es.Indices.UpdateAliases(bytes.NewReader(body), d.es.Indices.UpdateAliases.WithContext(ctx))
+	if err := directoryError(res, err); err != nil {
+		d.drop(ctx, index)
+		return 0, fmt.Errorf("switching alias %s: %w", directoryAlias, err)
+	}
+	res.Body.Close()
+
+	d.drop(ctx, previous...)
+
+	return count, nil
+}
+
+func (d *Directory) fill(ctx context.Context, index string) (int, error) {
+	var buf bytes.Buffer
+	count, pending := 0, 0
+
+	err := d.each(ctx, "true", nil, func(id int64, doc map[string]interface{}) error {
+		if err := writeBulkIndex(&buf, id, doc); err != nil {
+			return err
+		}
+		count++
+		pending++
+
+		if pending >= directoryBulkSize {
+			pending = 0
+			return d.bulk(ctx, index, &buf)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if pending > 0 {
+		if err := d.bulk(ctx, index, &buf); err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := d.es.Indices.Refresh(d.es.Indices.Refresh.WithIndex(index), d.es.Indices.Refresh.WithContext(ctx))
+	if err := directoryError(res, err); err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return count, nil
+}
+
+func writeBulkIndex(buf *bytes.Buffer, id int64, doc map[string]interface{}) error {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, `{"index":{"_id":"%d"}}`+"\n", id)
+	buf.Write(raw)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// bulk sends and empties buf, failing on the first rejected item.
+func (d *Directory) bulk(ctx context.Context, index string, buf *bytes.Buffer, opts ...func(*esapi.BulkRequest)) error {
+	opts = append(opts, d.es.Bulk.WithIndex(index), d.es.Bulk.WithContext(ctx))
+	res, err := d.es.Bulk(bytes.NewReader(buf.Bytes()), opts...)
+	buf.Reset()
+	if err := directoryError(res, err); err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var out struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return err
+	}
+	if out.Errors {
+		for _, item := range out.Items {
+			for action, result := range item {
+				// Deleting a user that was never indexed is fine.
+				if action == "delete" && result.Status == http.StatusNotFound {
+					continue
+				}
+				if result.Error != nil {
+					return fmt.Errorf("bulk indexing %s: %s", index, result.Error.Reason)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Directory) aliasIndices(ctx context.Context) ([]string, error) {
+	res, err := d.es.Indices.GetAlias(d.es.Indices.GetAlias.WithName(directoryAlias), d.es.Indices.GetAlias.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, directoryError(res, nil)
+	}
+
+	var out map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(out))
+	for index := range out {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+func (d *Directory) drop(ctx context.Context, indices ...string) {
+	if len(indices) == 0 {
+		return
+	}
+	res, err := d.es.Indices.Delete(indices, d.es.Indices.Delete.WithContext(ctx))
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+// Search returns the ids of the matching users, best match first, and the
+// total number of matches.
+func (d *Directory) Search(ctx context.Context, q DirectoryQuery) ([]int64, int, error) {
+	if !d.enabled() {
+		return nil, 0, ErrDirectoryUnavailable
+	}
+	if q.Page*q.PageSize > maxDirectoryWindow {
+		return nil, 0, ErrDirectoryWindow
+	}
+
+	must := []interface{}{}
+	text := strings.TrimSpace(q.Text)
+	if text != "" {
+		lower := strings.ToLower(text)
+		should := []interface{}{
+			map[string]interface{}{"match": map[string]interface{}{
+				"name": map[string]interface{}{"query": text, "fuzziness": "AUTO", "operator": "and", "boost": 3},
+			}},
+			map[string]interface{}{"prefix": map[string]interface{}{"username": map[string]interface{}{"value": lower, "boost": 2}}},
+			map[string]interface{}{"prefix": map[string]interface{}{"email": map[string]interface{}{"value": lower}}},
+		}
+		if q.Identifiers && digitsPattern.MatchString(text) {
+			hashed := d.hash(text)
+			for _, field := range []string{"nik_hash", "nip_hash", "phone_hash"} {
+				should = append(should, map[string]interface{}{"term": map[string]interface{}{field: map[string]interface{}{"value": hashed, "boost": 10}}})
+			}
+		}
+		must = append(must, map[string]interface{}{"bool": map[string]interface{}{"should": should, "minimum_should_match": 1}})
+	} else {
+		must = append(must, map[string]interface{}{"match_all": map[string]interface{}{}})
+	}
+
+	filters := []interface{}{}
+	if q.RoleID > 0 {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"role_id": strconv.FormatInt(q.RoleID, 10)}})
+	}
+	if q.Region != "" {
+		province, regency, subdistrict, urbanvillage := regionLevels(q.Region)
+		field, code := "province_id", province
+		switch {
+		case urbanvillage != "":
+			field, code = "urbanvillage_id", urbanvillage
+		case subdistrict != "":
+			field, code = "subdistrict_id", subdistrict
+		case regency != "":
+			field, code = "regency_id", regency
+		}
+		filters = append(filters, map[string]interface{}{"term": map[string]string{field: code}})
+	}
+	for field, v := range map[string]*bool{"is_active": q.IsActive, "is_complete": q.IsComplete, "is_verified": q.IsVerified} {
+		if v != nil {
+			filters = append(filters, map[string]interface{}{"term": map[string]bool{field: *v}})
+		}
+	}
+
+	sort := []interface{}{"_score", map[string]string{"created_at": "desc"}}
+	if text == "" {
+		sort = []interface{}{map[string]string{"created_at": "desc"}}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"from":             (q.Page - 1) * q.PageSize,
+		"size":             q.PageSize,
+		"track_total_hits": true,
+		"query":            map[string]interface{}{"bool": map[string]interface{}{"must": must, "filter": filters}},
+		"sort":             sort,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := d.es.Search(
+		d.es.Search.WithContext(ctx),
+		d.es.Search.WithIndex(directoryAlias),
+		d.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err := directoryError(res, err); err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	var out struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]int64, 0, len(out.Hits.Hits))
+	for _, h := range out.Hits.Hits {
+		if id, err := strconv.ParseInt(h.ID, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, out.Hits.Total.Value, nil
+}
+
+// directoryError turns a failed call or an error response into an error.
+// The body of an error response is consumed.
+func directoryError(res *esapi.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	if !res.IsError() {
+		return nil
+	}
+	defer res.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode == http.StatusNotFound {
+		// The alias only exists after the first reindex.
+		return fmt.Errorf("%w: %s", ErrDirectoryUnavailable, bytes.TrimSpace(msg))
+	}
+	return fmt.Errorf("elasticsearch %s: %s", res.Status(), bytes.TrimSpace(msg))
+}
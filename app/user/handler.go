@@ -1,6 +1,8 @@
 package user
 
 import (
+	"database/sql"
+
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -11,7 +13,7 @@ type UserHandler struct {
 	rdb     *redis.Client
 }
 
-func NewHandler(router *gin.Engine, usecase UserUsecase, rdb *redis.Client) {
+func NewHandler(router *gin.Engine, usecase UserUsecase, rdb *redis.Client, db *sql.DB) {
 	handler := &UserHandler{
 		Usecase: usecase,
 		rdb:     rdb,
@@ -27,10 +29,11 @@ func NewHandler(router *gin.Engine, usecase UserUsecase, rdb *redis.Client) {
 	v1.POST("user/register", handler.Register)
 	v1.POST("user/verify-recaptcha", handler.VerifyReCaptcha)
 	v1.POST("user/verify-email", handler.VerifyEmail)
+	v1.POST("user/accept-invitation", handler.AcceptInvitation)
 
 	v1.GET("8asd87asd98/7asd8a7sd68as7", util.AuthMiddleware(handler.rdb), handler.IsVerified) // get data by session
-	v1.GET("user-is-complete/:email", util.AuthMiddleware(handler.rdb), handler.IsComplete)
-	v1.GET("user-is-verified/:email", util.AuthMiddleware(handler.rdb), handler.IsVerified)
+	v1.GET("user-is-complete/:email", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.IsComplete)
+	v1.GET("user-is-verified/:email", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.IsVerified)
 	v1.GET("user-list", util.AuthMiddleware(handler.rdb), handler.Read)
 	v1.GET("user-table", util.AuthMiddleware(handler.rdb), handler.GetTable)
 	v1.PUT("user-update", util.AuthMiddleware(handler.rdb), handler.Update)
@@ -38,9 +41,24 @@ func NewHandler(router *gin.Engine, usecase UserUsecase, rdb *redis.Client) {
 	v1.POST("user/12389dsa-9982783", util.AuthMiddleware(handler.rdb), handler.IsNullPassword)
 	v1.POST("user/logout", util.AuthMiddleware(handler.rdb), handler.Logout)
 	v1.POST("user/refresh", util.AuthMiddleware(handler.rdb), handler.Refresh)
-	v1.POST("user/do-completion", util.AuthMiddleware(handler.rdb), handler.DoCompletion)
-	v1.POST("user/get-completion", util.AuthMiddleware(handler.rdb), handler.GetCompletion)
+	v1.POST("user/do-completion", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.DoCompletion)
+	v1.POST("user/get-completion", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.GetCompletion)
 	v1.DELETE("user-delete", util.AuthMiddleware(handler.rdb), handler.Delete)
+
+	// The caller's own profile, identified by the token alone.
+	v1.GET("me", util.AuthMiddleware(handler.rdb), handler.GetMe)
+	v1.PATCH("me", util.AuthMiddleware(handler.rdb), handler.UpdateMe)
+	v1.GET("me/completion", util.AuthMiddleware(handler.rdb), handler.GetMyCompletion)
+	v1.POST("me/completion", util.AuthMiddleware(handler.rdb), handler.DoMyCompletion)
+	v1.GET("me/status", util.AuthMiddleware(handler.rdb), handler.GetMyStatus)
+
+	v1.GET("user-dukcapil-reviews", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.GetDukcapilReviews)
+	v1.GET("user-dukcapil-review/:id", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.GetDukcapilReview)
+	v1.PUT("user-dukcapil-review/:id", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.DecideDukcapilReview)
+	v1.POST("user-import", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.Import)
+	v1.GET("user-import/:id/report", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.GetImportReport)
+	v1.GET("user-search", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.Search)
+	v1.POST("user-directory/reindex", util.AuthMiddleware(handler.rdb), util.AdminMiddleware(db), handler.ReindexDirectory)
 }
 
 func (handler *UserHandler) InitCreate(c *gin.Context) {
@@ -122,3 +140,55 @@ func (handler *UserHandler) IsNullPassword(c *gin.Context) {
 func (handler *UserHandler) Delete(c *gin.Context) {
 	handler.Usecase.Delete(c)
 }
+
+func (handler *UserHandler) GetDukcapilReviews(c *gin.Context) {
+	handler.Usecase.GetDukcapilReviews(c)
+}
+
+func (handler *UserHandler) GetDukcapilReview(c *gin.Context) {
+	handler.Usecase.GetDukcapilReview(c)
+}
+
+func (handler *UserHandler) DecideDukcapilReview(c *gin.Context) {
+	handler.Usecase.DecideDukcapilReview(c)
+}
+
+func (handler *UserHandler) GetMe(c *gin.Context) {
+	handler.Usecase.GetMe(c)
+}
+
+func (handler *UserHandler) UpdateMe(c *gin.Context) {
+	handler.Usecase.UpdateMe(c)
+}
+
+func (handler *UserHandler) GetMyCompletion(c *gin.Context) {
+	handler.Usecase.GetMyCompletion(c)
+}
+
+func (handler *UserHandler) DoMyCompletion(c *gin.Context) {
+	handler.Usecase.DoMyCompletion(c)
+}
+
+func (handler *UserHandler) GetMyStatus(c *gin.Context) {
+	handler.Usecase.GetMyStatus(c)
+}
+
+func (handler *UserHandler) Import(c *gin.Context) {
+	handler.Usecase.Import(c)
+}
+
+func (handler *UserHandler) GetImportReport(c *gin.Context) {
+	handler.Usecase.GetImportReport(c)
+}
+
+func (handler *UserHandler) AcceptInvitation(c *gin.Context) {
+	handler.Usecase.AcceptInvitation(c)
+}
+
+func (handler *UserHandler) Search(c *gin.Context) {
+	handler.Usecase.Search(c)
+}
+
+func (handler *UserHandler) ReindexDirectory(c *gin.Context) {
+	handler.Usecase.ReindexDirectory(c)
+}
@@ -0,0 +1,601 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gigaflex-co/ppt_backend/app/dukcapil"
+	"github.com/gigaflex-co/ppt_backend/app/email"
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	maxImportSize = 5 << 20
+	maxImportRows = 1000
+
+	importKeyPrefix = "ppt:user-import:"
+	importTTL       = 24 * time.Hour
+
+	invitationKeyPrefix = "ppt:user-invitation:"
+	invitationTTL       = 7 * 24 * time.Hour
+)
+
+var (
+	ErrImportEmpty    = errors.New("import file has no rows")
+	ErrImportTooLarge = fmt.Errorf("import file must not exceed %d rows", maxImportRows)
+
+	nipPattern    = regexp.MustCompile(`^[0-9]{18}$`)
+	phonePattern  = regexp.MustCompile(`^[0-9]{9,15}$`)
+	regionPattern = regexp.MustCompile(`^[0-9]{2}(\.[0-9]{2}(\.[0-9]{2}(\.[0-9]{4})?)?)?$`)
+
+	// importHeaders maps the accepted column names, in English or as
+	// regional offices label them, to the field they fill.
+	importHeaders = map[string]string{
+		"name":         "name",
+		"nama":         "name",
+		"email":        "email",
+		"username":     "username",
+		"nip":          "nip",
+		"nik":          "nik",
+		"role":         "role",
+		"peran":        "role",
+		"region":       "region",
+		"wilayah":      "region",
+		"kode_wilayah": "region",
+		"phone":        "phone",
+		"no_hp":        "phone",
+		"telepon":      "phone",
+	}
+
+	importReportHeader = []string{"Baris", "Nama", "Email", "Username", "Status", "Keterangan"}
+)
+
+// importRecord is one parsed row; fields holds the cells by field name.
+type importRecord struct {
+	line   int
+	fields map[string]string
+}
+
+// Import creates the accounts listed in an uploaded XLSX or CSV file. Every
+// row is validated first; with dry_run=true, or when any row is invalid,
+// nothing is created. Otherwise all accounts are created in one
+// transaction and each user is sent an invitation to set a password, in
+// the language given by locale.
+func (uc *usecase) Import(c *gin.Context) {
+	actor, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", c.DefaultQuery("dry_run", "false")))
+	locale := c.DefaultPostForm("locale", email.DefaultLocale)
+	if !email.SupportedLocale(locale) {
+		util.JERR(c, http.StatusBadRequest, errors.New("locale must be id or en"))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, errors.New("file is required"))
+		return
+	}
+	if header.Size > maxImportSize {
+		util.JERR(c, http.StatusBadRequest, fmt.Errorf("file must not exceed %d MB", maxImportSize>>20))
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxImportSize))
+	f.Close()
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	records, err := parseImport(data)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	users, rows, err := uc.validateImport(c, records)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	id, err := randomToken(12)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+	result := ImportResult{
+		ID:        id,
+		DryRun:    dryRun,
+		Total:     len(rows),
+		CreatedBy: actor,
+		CreatedAt: time.Now(),
+		Rows:      rows,
+	}
+	for _, row := range rows {
+		if row.Status == ImportValid {
+			result.Valid++
+		} else {
+			result.Invalid++
+		}
+	}
+
+	status := http.StatusOK
+	switch {
+	case result.Invalid > 0:
+		status = http.StatusUnprocessableEntity
+	case !dryRun:
+		mails, err := uc.invitations(c, rows, locale)
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		err = uc.repo.ImportUsers(c, users, mails)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			util.JERR(c, http.StatusConflict, errors.New("an account in the file was created meanwhile, run the import again"))
+			return
+		}
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		for i := range result.Rows {
+			result.Rows[i].Status = ImportCreated
+		}
+		result.Created = len(users)
+		status = http.StatusCreated
+	}
+
+	if raw, err := json.Marshal(result); err == nil {
+		if err := uc.rdb.Set(c, importKeyPrefix+id, raw, importTTL).Err(); err != nil {
+			log.Printf("storing user import report %s: %v", id, err)
+		}
+	}
+
+	util.JOK(c, status, result)
+}
+
+// GetImportReport downloads the per-row report of an import of the last
+// day as CSV or, with format=xlsx, as a workbook.
+func (uc *usecase) GetImportReport(c *gin.Context) {
+	raw, err := uc.rdb.Get(c, importKeyPrefix+c.Param("id")).Bytes()
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("import report not found or expired"))
+		return
+	}
+
+	var result ImportResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	format := c.DefaultQuery("format", "csv")
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case "csv":
+		err = writeCSVReport(buf, result)
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = writeXLSXReport(buf, result)
+	default:
+		util.JERR(c, http.StatusBadRequest, errors.New("format must be csv or xlsx"))
+		return
+	}
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="import-`+result.ID+`.`+format+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// AcceptInvitation sets the password of an imported account. The
+// invitation reached the user's inbox, so it also verifies their email.
+func (uc *usecase) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	email, err := uc.rdb.GetDel(c, invitationKeyPrefix+req.Token).Result()
+	if err != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("invitation not found or expired"))
+		return
+	}
+
+	password, err := util.HashPassword(req.Password)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := uc.repo.AcceptInvitation(c, email, password); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusConflict, errors.New("account already has a password"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	util.JOK(c, http.StatusOK, gin.H{"email": email})
+}
+
+// parseImport reads the rows of an XLSX workbook's first sheet or of a
+// comma or semicolon separated CSV file. The first row names the columns.
+func parseImport(data []byte) ([]importRecord, error) {
+	var sheet [][]string
+	var err error
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		sheet, err = readXLSX(data)
+	} else {
+		sheet, err = readCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(sheet) < 2 {
+		return nil, ErrImportEmpty
+	}
+
+	columns := make([]string, len(sheet[0]))
+	seen := map[string]bool{}
+	for i, label := range sheet[0] {
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(label)), " ", "_")
+		if field, ok := importHeaders[key]; ok && !seen[field] {
+			columns[i] = field
+			seen[field] = true
+		}
+	}
+	for _, field := range []string{"name", "email", "username", "role", "region"} {
+		if !seen[field] {
+			return nil, fmt.Errorf("column %q is missing", field)
+		}
+	}
+	if !seen["nip"] && !seen["nik"] {
+		return nil, errors.New("column \"nip\" or \"nik\" is missing")
+	}
+
+	var records []importRecord
+	for i, cells := range sheet[1:] {
+		r := importRecord{line: i + 2, fields: map[string]string{}}
+		blank := true
+		for j, cell := range cells {
+			if j < len(columns) && columns[j] != "" {
+				r.fields[columns[j]] = strings.TrimSpace(cell)
+				if r.fields[columns[j]] != "" {
+					blank = false
+				}
+			}
+		}
+		if blank {
+			continue
+		}
+		records = append(records, r)
+	}
+
+	if len(records) == 0 {
+		return nil, ErrImportEmpty
+	}
+	if len(records) > maxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	return records, nil
+}
+
+func readXLSX(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("file is not a valid xlsx workbook")
+	}
+	defer f.Close()
+
+	return f.GetRows(f.GetSheetName(0))
+}
+
+// readCSV accepts the semicolon separated files spreadsheet software
+// writes in Indonesian locales as well as plain CSV.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("file is not a valid csv file: %w", err)
+	}
+	return rows, nil
+}
+
+// validateImport checks every row on its own, against the other rows and
+// against existing accounts, and returns the accounts to create when all
+// rows are valid.
+func (uc *usecase) validateImport(c context.Context, records []importRecord) ([]UserCreate, []ImportRow, error) {
+	roles, err := uc.repo.GetRoleIDs(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var codes, emails, usernames []string
+	for _, r := range records {
+		codes = append(codes, r.fields["region"])
+		emails = append(emails, strings.ToLower(r.fields["email"]))
+		usernames = append(usernames, r.fields["username"])
+	}
+	regions, err := uc.repo.ExistingRegions(c, codes)
+	if err != nil {
+		return nil, nil, err
+	}
+	takenEmails, takenUsernames, err := uc.repo.ExistingAccounts(c, emails, usernames)
+	if err != nil {
+		return nil, nil, err
+	}
+	takenNIKs, takenNIPs, err := uc.repo.ExistingIdentifiers(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// first remembers the line each value was first seen on.
+	first := map[string]map[string]int{"email": {}, "username": {}, "nik": {}, "nip": {}}
+	duplicate := func(field, value string, line int) string {
+		if value == "" {
+			return ""
+		}
+		if prev, ok := first[field][value]; ok {
+			return fmt.Sprintf("%s duplicates row %d", field, prev)
+		}
+		first[field][value] = line
+		return ""
+	}
+
+	users := make([]UserCreate, 0, len(records))
+	rows := make([]ImportRow, 0, len(records))
+	for _, r := range records {
+		f := r.fields
+		email := strings.ToLower(f["email"])
+		row := ImportRow{Row: r.line, Name: f["name"], Email: email, Username: f["username"]}
+		fail := func(msg string) {
+			if msg != "" {
+				row.Errors = append(row.Errors, msg)
+			}
+		}
+
+		if n := len([]rune(f["name"])); n < 3 || n > 100 {
+			fail("name must be 3 to 100 characters")
+		}
+
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email || len(email) > 150 {
+			fail("email is not a valid address")
+		} else if takenEmails[email] {
+			fail("email is already registered")
+		}
+		fail(duplicate("email", email, r.line))
+
+		if n := len(f["username"]); n < 8 || n > 150 {
+			fail("username must be 8 to 150 characters")
+		} else if takenUsernames[f["username"]] {
+			fail("username is already taken")
+		}
+		fail(duplicate("username", f["username"], r.line))
+
+		if f["nik"] == "" && f["nip"] == "" {
+			fail("nik or nip is required")
+		}
+		if f["nik"] != "" {
+			if err := dukcapil.ValidateNIK(f["nik"]); err != nil {
+				fail(err.Error())
+			} else if takenNIKs[f["nik"]] {
+				fail("nik is already registered")
+			}
+			fail(duplicate("nik", f["nik"], r.line))
+		}
+		if f["nip"] != "" {
+			if !nipPattern.MatchString(f["nip"]) {
+				fail("nip must be 18 digits")
+			} else if takenNIPs[f["nip"]] {
+				fail("nip is already registered")
+			}
+			fail(duplicate("nip", f["nip"], r.line))
+		}
+
+		roleID, ok := roles[strings.ToLower(f["role"])]
+		switch {
+		case f["role"] == "":
+			fail("role is required")
+		case strings.EqualFold(f["role"], util.AdminRole):
+			fail("admin accounts cannot be imported")
+		case !ok:
+			fail(fmt.Sprintf("role %q does not exist", f["role"]))
+		}
+
+		if !regionPattern.MatchString(f["region"]) {
+			fail("region must be a ppt_wilayah code such as 11.01.01.2001")
+		} else if !regions[f["region"]] {
+			fail(fmt.Sprintf("region %q does not exist", f["region"]))
+		}
+
+		if f["phone"] != "" && !phonePattern.MatchString(f["phone"]) {
+			fail("phone must be 9 to 15 digits")
+		}
+
+		if len(row.Errors) > 0 {
+			row.Status = ImportInvalid
+			rows = append(rows, row)
+			continue
+		}
+		row.Status = ImportValid
+		rows = append(rows, row)
+
+		u := UserCreate{
+			RoleID:   roleID,
+			Username: f["username"],
+			Email:    email,
+			IsActive: true,
+		}
+		u.ProvinceID, u.RegencyID, u.SubdistrictID, u.UrbanvillageID = regionLevels(f["region"])
+		u.Name, _ = util.Encrypt(f["name"], "f")
+		if f["nik"] != "" {
+			u.NIK, _ = util.Encrypt(f["nik"], "f")
+		}
+		if f["nip"] != "" {
+			u.NIP, _ = util.Encrypt(f["nip"], "f")
+		}
+		if f["phone"] != "" {
+			u.Phone, _ = util.Encrypt(f["phone"], "f")
+		}
+		users = append(users, u)
+	}
+
+	return users, rows, nil
+}
+
+// regionLevels splits a region code into the province, regency, district
+// and village codes it lies in; levels below the code are empty.
+func regionLevels(code string) (province, regency, subdistrict, urbanvillage string) {
+	levels := []*string{&province, &regency, &subdistrict, &urbanvillage}
+	for i, end := range []int{2, 5, 8, 13} {
+		if len(code) >= end {
+			*levels[i] = code[:end]
+		}
+	}
+	return
+}
+
+func writeCSVReport(w io.Writer, result ImportResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(importReportHeader); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		record := []string{strconv.Itoa(row.Row), row.Name, row.Email, row.Username, row.Status, strings.Join(row.Errors, "; ")}
+		for i := range record {
+			record[i] = util.EscapeCSVFormula(record[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeXLSXReport(w io.Writer, result ImportResult) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Laporan"
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return err
+	}
+	style, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(importReportHeader))
+	for i, label := range importReportHeader {
+		header[i] = label
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	if err := f.SetRowStyle(sheet, 1, 1, style); err != nil {
+		return err
+	}
+
+	for i, row := range result.Rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		values := []interface{}{row.Row, row.Name, row.Email, row.Username, row.Status, strings.Join(row.Errors, "; ")}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return err
+		}
+	}
+
+	return f.Write(w)
+}
+
+// invitations creates a single-use link to set a password for every valid
+// row and returns the emails carrying them. The links are stored before
+// the accounts exist; if the import fails they expire unused.
+func (uc *usecase) invitations(c context.Context, rows []ImportRow, locale string) ([]email.Outgoing, error) {
+	mails := []email.Outgoing{}
+	for _, row := range rows {
+		if row.Status != ImportValid {
+			continue
+		}
+
+		token, err := randomToken(24)
+		if err != nil {
+			return nil, err
+		}
+		if err := uc.rdb.Set(c, invitationKeyPrefix+token, row.Email, invitationTTL).Err(); err != nil {
+			return nil, err
+		}
+
+		mails = append(mails, email.Outgoing{
+			Template:  "invitation",
+			Locale:    locale,
+			Recipient: row.Email,
+			Data: map[string]interface{}{
+				"Name": row.Name,
+				"Link": uc.cfg.AllowOrigin + "/auth/invitation/" + token,
+			},
+		})
+	}
+
+	return mails, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
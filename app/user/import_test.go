@@ -0,0 +1,25 @@
+package user
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSVReportEscapesFormulas(t *testing.T) {
+	result := ImportResult{Rows: []ImportRow{
+		{Row: 2, Name: "=HYPERLINK(\"http://x\",\"klik\")", Email: "@a.id", Username: "-budi", Status: "invalid", Errors: []string{"email tidak valid"}},
+		{Row: 3, Name: "Budi", Email: "budi@a.id", Username: "budi", Status: "valid"},
+	}}
+
+	var out bytes.Buffer
+	if err := writeCSVReport(&out, result); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Baris,Nama,Email,Username,Status,Keterangan\n" +
+		"2,\"'=HYPERLINK(\"\"http://x\"\",\"\"klik\"\")\",'@a.id,'-budi,invalid,email tidak valid\n" +
+		"3,Budi,budi@a.id,budi,valid,\n"
+	if got := out.String(); got != want {
+		t.Errorf("report = %q, want %q", got, want)
+	}
+}
@@ -4,11 +4,29 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/gigaflex-co/ppt_backend/app/dukcapil"
 	"github.com/gigaflex-co/ppt_backend/util"
 )
 
 var table = "ppt_users"
 var subtable = "ppt_roles"
+var reviewTable = "ppt_dukcapil_reviews"
+var fileTable = "ppt_files"
+var regionTable = "ppt_wilayah"
+
+// Import row statuses.
+const (
+	ImportValid   = "valid"
+	ImportInvalid = "invalid"
+	ImportCreated = "created"
+)
+
+// Dukcapil review statuses.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
 
 type (
 	DataWithPagination struct {
@@ -16,71 +34,6 @@ type (
 		Pagination util.PaginationResponse `json:"pagination"`
 	}
 
-	ConfigData struct {
-		Name  string
-		Value string
-	}
-
-	DukcapilPayload struct {
-		NIK          string `json:"nik_val"`
-		NamaLengkap  string `json:"NAMA_LGKP"`
-		JenisKelamin string `json:"JENIS_KLMIN"`
-		TempatLahir  string `json:"TMPT_LHR"`
-		TanggalLahir string `json:"TGL_LHR"`
-		TRESHOLD     int    `json:"TRESHOLD"`
-		UserID       string `json:"user_id"`
-		Password     string `json:"password"`
-		IPUser       string `json:"ip_user"`
-	}
-
-	DukcapilDataRequest struct {
-		NIK          string `json:"nik" binding:"required,min=16,max=16"`
-		NamaLengkap  string `json:"nama_lengkap" binding:"required"`
-		JenisKelamin string `json:"jenis_kelamin"  binding:"required"`
-		TempatLahir  string `json:"tempat_lahir" binding:"required"`
-	}
-
-	DukcapilContent struct {
-		NOKK           string `json:"NO_KK"`
-		NamaLengkap    string `json:"NAMA_LGKP"`
-		TempatLahir    string `json:"TMPT_LHR"`
-		TanggalLahir   string `json:"TGL_LHR"`
-		PropName       string `json:"PROP_NAME"`
-		KabName        string `json:"KAB_NAME"`
-		KecName        string `json:"KEC_NAME"`
-		KelName        string `json:"KEL_NAME"`
-		NoRT           string `json:"NO_RT"`
-		NoRW           string `json:"NO_RW"`
-		Alamat         string `json:"ALAMAT"`
-		NamaIbu        string `json:"NAMA_LGKP_IBU"`
-		StatusKawin    string `json:"STATUS_KAWIN"`
-		JenisPekerjaan string `json:"JENIS_PKRJN"`
-		JenisKelamin   string `json:"JENIS_KLMIN"`
-		NoProp         string `json:"NO_PROP"`
-		NoKab          string `json:"NO_KAB"`
-		NoKec          string `json:"NO_KEC"`
-		NoKel          string `json:"NO_KEL"`
-		NIK            string `json:"NIK"`
-	}
-
-	DukcapilResponse struct {
-		Content          []DukcapilContent `json:"content"`
-		LastPage         bool              `json:"lastPage"`
-		NumberOfElements int               `json:"numberOfElements"`
-		Sort             string            `json:"sort"`
-		TotalElements    int               `json:"totalElements"`
-		FirstPage        bool              `json:"firstPage"`
-		Number           int               `json:"number"`
-		Size             int               `json:"size"`
-	}
-
-	SMTPData struct {
-		SMTPServer   string
-		SMTPPort     int
-		SMTPEmail    string
-		SMTPPassword string
-	}
-
 	UserLoginRequest struct {
 		Username string `json:"username" binding:"required,min=8,max=150"`
 		Password string `json:"password" binding:"required,min=8,max=150"`
@@ -109,17 +62,50 @@ type (
 		Email    string `json:"email" binding:"required,min=8,max=150"`
 		Password string `json:"password"`
 		IsGoogle bool   `json:"is_google"`
+		// Locale is the language of the activation email.
+		Locale string `json:"locale" binding:"omitempty,oneof=id en"`
 	}
 
+	// CompletionRequest fills in one section of a profile. Email names the
+	// user on the admin endpoint and is ignored on /v1/me/completion.
 	CompletionRequest struct {
 		BioData     UserBioRequest     `json:"bio_data"`
 		AddressData UserAddressRequest `json:"address_data"`
-		Section     string             `json:"section" binding:"required"`
-		Email       string             `json:"email" binding:"required"`
+		Section     string             `json:"section" binding:"required,oneof=bio address"`
+		Email       string             `json:"email"`
+	}
+
+	// MeUpdateRequest holds the profile fields a user may change on their
+	// own; omitted fields are left as they are. Name, NIK and birth data
+	// are checked against Dukcapil and only change through completion.
+	MeUpdateRequest struct {
+		Username *string `json:"username" binding:"omitempty,min=8,max=150"`
+		Phone    *string `json:"phone" binding:"omitempty,min=9,max=15,numeric"`
+		ImgUser  *string `json:"img_user" binding:"omitempty,startswith=files/user/,max=100"`
+		Address  *string `json:"address" binding:"omitempty,min=8,max=150"`
 	}
 
+	// Completion tells which profile sections are filled in. PendingReview
+	// is set while submitted bio data waits for an admin.
+	Completion struct {
+		Bio           bool `json:"bio"`
+		Address       bool `json:"address"`
+		IsComplete    bool `json:"is_complete"`
+		PendingReview bool `json:"pending_review"`
+	}
+
+	MeStatus struct {
+		Name       string `json:"name"`
+		Email      string `json:"email"`
+		IsActive   bool   `json:"is_active"`
+		IsComplete bool   `json:"is_complete"`
+		IsVerified bool   `json:"is_verified"`
+	}
+
+	// UserBioRequest is the bio section of a profile. RoleID is only
+	// honoured on the admin completion route.
 	UserBioRequest struct {
-		RoleID      string   `json:"role_id_val" binding:"required"`
+		RoleID      string   `json:"role_id_val"`
 		SubSectorID []string `json:"sub_sector_id" binding:"required"`
 		Name        string   `json:"name_val" binding:"required,min=3,max=100"`
 		NIK         string   `json:"nik_val" binding:"required,min=16,max=16"`
@@ -293,4 +279,59 @@ type (
 	IsRegistered struct {
 		Email string `json:"email" binding:"required,min=8,max=150"`
 	}
+
+	// DukcapilReview puts the bio data a user submitted next to the
+	// Dukcapil verdict on each element.
+	DukcapilReview struct {
+		HashedID    string                         `json:"id"`
+		UserEmail   string                         `json:"user_email"`
+		SubmittedBy string                         `json:"submitted_by"`
+		Submitted   UserBioRequest                 `json:"submitted"`
+		Matches     map[string]dukcapil.FieldMatch `json:"matches"`
+		Status      string                         `json:"status"`
+		Reason      *string                        `json:"reason"`
+		ReviewedBy  *string                        `json:"reviewed_by"`
+		ReviewedAt  *time.Time                     `json:"reviewed_at"`
+		CreatedAt   time.Time                      `json:"created_at"`
+	}
+
+	DukcapilReviewsWithPagination struct {
+		Row        []DukcapilReview        `json:"row"`
+		Pagination util.PaginationResponse `json:"pagination"`
+	}
+
+	DukcapilReviewDecision struct {
+		Decision string `json:"decision" binding:"required,oneof=approve reject"`
+		Reason   string `json:"reason" binding:"required,max=1000"`
+	}
+
+	// ImportRow is the outcome of one row of a user import. Row is the
+	// line number in the uploaded sheet, counting the header as line 1.
+	ImportRow struct {
+		Row      int      `json:"row"`
+		Name     string   `json:"name"`
+		Email    string   `json:"email"`
+		Username string   `json:"username"`
+		Status   string   `json:"status"`
+		Errors   []string `json:"errors"`
+	}
+
+	// ImportResult is returned by the import and kept for a day so the
+	// per-row report can be downloaded.
+	ImportResult struct {
+		ID        string      `json:"id"`
+		DryRun    bool        `json:"dry_run"`
+		Total     int         `json:"total"`
+		Valid     int         `json:"valid"`
+		Invalid   int         `json:"invalid"`
+		Created   int         `json:"created"`
+		CreatedBy string      `json:"created_by"`
+		CreatedAt time.Time   `json:"created_at"`
+		Rows      []ImportRow `json:"rows"`
+	}
+
+	AcceptInvitationRequest struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8,max=150"`
+	}
 )
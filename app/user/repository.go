@@ -3,12 +3,16 @@ package user
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 
-	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/gigaflex-co/ppt_backend/app/dukcapil"
+	"github.com/gigaflex-co/ppt_backend/app/email"
 	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -20,30 +24,47 @@ type (
 		GetDataBy(c context.Context, field string, value string) (UserResponse, error)
 		GetTable(c context.Context, arg util.DataFilter) ([]UserResponse, error)
 		CountRecords(c context.Context, arg util.DataFilter) (int, error)
+		SearchDirectory(c context.Context, q DirectoryQuery) ([]UserResponse, int, error)
+		ReindexDirectory(c context.Context) (int, error)
 		IsNullPassword(c context.Context, field string, value string) (UserResponse, error)
 		IsComplete(c context.Context, email string) (UserResponse, error)
 		IsVerified(c context.Context, email string) (UserResponse, error)
 		DoVerify(c context.Context, email string) (UserResponse, error)
-		GetConfig(c context.Context) (SMTPData, error)
 		GetDefaultRole(c context.Context) (int, error)
-		Create(c context.Context, arg UserCreate) (UserResponse, error)
+		Create(c context.Context, arg UserCreate, mails ...email.Outgoing) (UserResponse, error)
 		Read(c context.Context) ([]UserResponse, error)
 		Update(c context.Context, field, id string, arg UserUpdate, status string) (UserResponse, error)
+		UpdateProfile(c context.Context, email string, arg UserUpdate) error
+		GetCompletion(c context.Context, email string) (Completion, error)
+		OwnsFile(c context.Context, email, key string) (bool, error)
+
+		GetRoleIDs(c context.Context) (map[string]int, error)
+		ExistingRegions(c context.Context, codes []string) (map[string]bool, error)
+		ExistingAccounts(c context.Context, emails, usernames []string) (map[string]bool, map[string]bool, error)
+		ExistingIdentifiers(c context.Context) (map[string]bool, map[string]bool, error)
+		ImportUsers(c context.Context, users []UserCreate, mails []email.Outgoing) error
+		AcceptInvitation(c context.Context, email, password string) error
 		Delete(c context.Context, ids []string) ([]string, []string, error)
+
+		StoreDukcapilReview(c context.Context, email, submittedBy string, bio UserBioRequest, matches map[string]dukcapil.FieldMatch) error
+		CountDukcapilReviews(c context.Context, status string) (int, error)
+		GetDukcapilReviews(c context.Context, status string, page, pageSize int) ([]DukcapilReview, error)
+		GetDukcapilReview(c context.Context, id int64) (DukcapilReview, error)
+		DecideDukcapilReview(c context.Context, id int64, status, reason, reviewer string) error
 	}
 
 	repository struct {
 		db  *sql.DB
 		rdb *redis.Client
-		edb *elasticsearch.Client
+		dir *Directory
 	}
 )
 
-func NewRepository(db *sql.DB, rdb *redis.Client, edb *elasticsearch.Client) UserRepository {
+func NewRepository(db *sql.DB, rdb *redis.Client, dir *Directory) UserRepository {
 	return &repository{
 		db:  db,
 		rdb: rdb,
-		edb: edb,
+		dir: dir,
 	}
 }
 
@@ -186,63 +207,11 @@ func (q *repository) DoVerify(c context.Context, email string) (UserResponse, er
 	err := row.Scan(
 		&r.IsVerified,
 	)
-
-	return r, err
-}
-
-func (q *repository) GetConfig(c context.Context) (SMTPData, error) {
-	var cfg ConfigData
-	var smtp SMTPData
-	query := `
-	SELECT name, value
-	FROM ppt_configurations ORDER BY id`
-
-	rows, err := q.db.QueryContext(c, query)
-	if err != nil {
-		return smtp, err
+	if err == nil {
+		q.dir.SyncEmails(c, email)
 	}
 
-	defer rows.Close()
-
-	items := []ConfigData{}
-
-	for rows.Next() {
-		if err := rows.Scan(
-			&cfg.Name,
-			&cfg.Value,
-		); err != nil {
-			return smtp, err
-		}
-
-		items = append(items, cfg)
-	}
-
-	if err := rows.Close(); err != nil {
-		return smtp, err
-	}
-
-	if err := rows.Err(); err != nil {
-		return smtp, err
-	}
-
-	for _, val := range items {
-		switch val.Name {
-		case "smtp_server":
-			smtp.SMTPServer = val.Value
-		case "smtp_port":
-			port, err := strconv.Atoi(val.Value)
-			if err != nil {
-				return SMTPData{}, err
-			}
-			smtp.SMTPPort = port
-		case "smtp_email":
-			smtp.SMTPEmail = val.Value
-		case "smtp_email_password":
-			smtp.SMTPPassword = val.Value
-		}
-	}
-
-	return smtp, nil
+	return r, err
 }
 
 func (q *repository) GetDefaultRole(c context.Context) (int, error) {
@@ -384,7 +353,7 @@ func (q *repository) CountRecords(c context.Context, arg util.DataFilter) (int,
 	query := `SELECT COUNT(*) FROM ` + table + ``
 
 	if arg.Search != "" {
-		query += ` WHERE lower(` + table + `.name) LIKE CONCAT('%%',$1::text,'%%')`
+		query += ` WHERE lower(` + table + `.username) LIKE CONCAT('%%',$1::text,'%%')`
 		args = append(args, arg.Search)
 	}
 
@@ -396,6 +365,79 @@ func (q *repository) CountRecords(c context.Context, arg util.DataFilter) (int,
 	return totalRecords, nil
 }
 
+// SearchDirectory loads the users found in the directory from Postgres, in
+// the order the directory ranked them.
+func (q *repository) SearchDirectory(c context.Context, arg DirectoryQuery) ([]UserResponse, int, error) {
+	ids, total, err := q.dir.Search(c, arg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := []UserResponse{}
+	if len(ids) == 0 {
+		return items, total, nil
+	}
+
+	query := `
+		SELECT
+		u.id,
+		u.name,
+		u.username,
+		u.email,
+		r.name AS role_name,
+		u.is_active,
+		u.is_complete,
+		u.is_verified,
+		u.created_at,
+		u.updated_at
+		FROM ` + table + ` AS u
+		LEFT JOIN ` + subtable + ` AS r ON r.id = u.role_id
+		WHERE u.id = ANY($1)
+		ORDER BY array_position($1, u.id)`
+
+	rows, err := q.db.QueryContext(c, query, pq.Array(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r UserResponse
+		var enc_id, dec_name string
+
+		if err := rows.Scan(
+			&enc_id,
+			&dec_name,
+			&r.Username,
+			&r.Email,
+			&r.RoleName,
+			&r.IsActive,
+			&r.IsComplete,
+			&r.IsVerified,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		r.HashedID, _ = util.Encrypt(enc_id, "f")
+		r.Name, _ = util.Decrypt(dec_name, "f")
+
+		items = append(items, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
+
+func (q *repository) ReindexDirectory(c context.Context) (int, error) {
+	return q.dir.Reindex(c)
+}
+
 func (q *repository) InitCreate(c context.Context) (bool, error) {
 	queries := []string{
 		"DELETE FROM ppt_configurations",
@@ -530,10 +572,15 @@ func (q *repository) InitCreate(c context.Context) (bool, error) {
 		{"name": "smtp_port", "value": "587", "is_lock": true},
 		{"name": "smtp_email", "value": "portal.pertanian@ghalyf.com", "is_lock": true},
 		{"name": "smtp_email_password", "value": smtp_password, "is_lock": true},
+		{"name": "dukcapil_url", "value": "", "is_lock": true},
 		{"name": "dukcapil_treshold", "value": "100", "is_lock": true},
 		{"name": "dukcapil_user_id", "value": "26082022160454BADAN_PENYULUHAN_SDM8370", "is_lock": true},
 		{"name": "dukcapil_password", "value": "TH854Y", "is_lock": true},
 		{"name": "dukcapil_ip_user", "value": "10.160.84.10", "is_lock": true},
+		{"name": "dukcapil_review_threshold", "value": "80", "is_lock": true},
+		{"name": "dukcapil_cache_ttl", "value": "24h", "is_lock": true},
+		{"name": "dukcapil_user_daily_quota", "value": "10", "is_lock": true},
+		{"name": "dukcapil_daily_quota", "value": "1000", "is_lock": true},
 		{"name": "api_token_sipdps_jawa_barat", "value": "FE9C98E3B93FE119DB275F93C761D", "is_lock": false},
 		{"name": "api_token_sipdps_jawa_tengah", "value": "7D1D23FF6BCD27AD411A4EC624391", "is_lock": false},
 		{"name": "api_token_perbenihan", "value": "PMAT-01H9KCS5K0Q15HZ3YWFTB7WPNE", "is_lock": false},
@@ -551,6 +598,14 @@ func (q *repository) InitCreate(c context.Context) (bool, error) {
 		}
 	}
 
+	// Every user was replaced, so the directory is rebuilt rather than
+	// patched.
+	if q.dir.enabled() {
+		if _, err := q.dir.Reindex(c); err != nil {
+			log.Printf("rebuilding user directory: %v", err)
+		}
+	}
+
 	return true, nil
 }
 
@@ -562,38 +617,72 @@ func (q *repository) CleanUser(c context.Context) (bool, error) {
 	AND subdistrict_id IS NULL
 	AND urbanvillage_id IS NULL
 	AND address IS NULL
-	AND is_active = false`
+	AND is_active = false
+	RETURNING id`
 
-	_, err := q.db.Exec(query)
+	rows, err := q.db.QueryContext(c, query)
 	if err != nil {
 		return false, err
 	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return false, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	q.dir.Remove(c, ids...)
 
 	return true, nil
 }
 
-func (q *repository) Create(c context.Context, arg UserCreate) (UserResponse, error) {
+// Create inserts a user and queues mails in the same transaction.
+func (q *repository) Create(c context.Context, arg UserCreate, mails ...email.Outgoing) (UserResponse, error) {
 	var r UserResponse
 	var enc_role_id string
 
+	tx, err := q.db.BeginTx(c, nil)
+	if err != nil {
+		return r, err
+	}
+	defer tx.Rollback()
+
 	query := `
 	INSERT INTO ` + table + ` (
 		role_id, name, username, email, password, google_id, is_active
 	) VALUES ($1, $2, $3, $4, $5, $6, $7)
 	RETURNING role_id, name, username, email`
 
-	row := q.db.QueryRowContext(c, query, arg.RoleID, arg.Name, arg.Username, arg.Email, arg.Password, arg.GoogleID, arg.IsActive)
+	row := tx.QueryRowContext(c, query, arg.RoleID, arg.Name, arg.Username, arg.Email, arg.Password, arg.GoogleID, arg.IsActive)
 
-	err := row.Scan(
+	err = row.Scan(
 		&enc_role_id,
 		&r.Name,
 		&r.Username,
 		&r.Email,
 	)
+	if err != nil {
+		return r, err
+	}
+
+	if err := email.Enqueue(c, tx, mails...); err != nil {
+		return r, err
+	}
+	if err := tx.Commit(); err != nil {
+		return r, err
+	}
+	q.dir.SyncEmails(c, arg.Email)
 
 	r.RoleID, _ = util.Encrypt(enc_role_id, "f")
 
-	return r, err
+	return r, nil
 }
 
 func (q *repository) Read(c context.Context) ([]UserResponse, error) {
@@ -661,7 +750,7 @@ func (q *repository) Update(c context.Context, field, id string, arg UserUpdate,
 			username = COALESCE($13, username),
 			email = COALESCE($14, email)
 		WHERE ` + field + ` = $1
-		RETURNING role_id, name`
+		RETURNING id, role_id, name`
 
 		row = q.db.QueryRowContext(c, query,
 			id,
@@ -692,7 +781,7 @@ func (q *repository) Update(c context.Context, field, id string, arg UserUpdate,
 			longitude = COALESCE($8, longitude),
 			is_complete = COALESCE($9, is_complete)
 		WHERE ` + field + ` = $1
-		RETURNING role_id, name`
+		RETURNING id, role_id, name`
 
 		row = q.db.QueryRowContext(c, query,
 			id,
@@ -707,14 +796,70 @@ func (q *repository) Update(c context.Context, field, id string, arg UserUpdate,
 		)
 	}
 
+	var userID int64
 	err = row.Scan(
+		&userID,
 		&r.RoleID,
 		&r.Name,
 	)
+	if err == nil {
+		q.dir.SyncIDs(c, userID)
+	}
+
+	return r, err
+}
+
+// UpdateProfile changes the self-service fields of a profile; null fields
+// are left as they are.
+func (q *repository) UpdateProfile(c context.Context, email string, arg UserUpdate) error {
+	query := `
+	UPDATE ` + table + `
+	SET
+		username = COALESCE($2, username),
+		phone = COALESCE($3, phone),
+		img_user = COALESCE($4, img_user),
+		address = COALESCE($5, address),
+		updated_at = NOW()
+	WHERE email = $1`
+
+	res, err := q.db.ExecContext(c, query, email, arg.Username, arg.Phone, arg.ImgUser, arg.Address)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	q.dir.SyncEmails(c, email)
+
+	return nil
+}
+
+func (q *repository) GetCompletion(c context.Context, email string) (Completion, error) {
+	var r Completion
+
+	query := `
+	SELECT
+		u.nik IS NOT NULL,
+		u.province_id IS NOT NULL AND u.address IS NOT NULL,
+		u.is_complete,
+		EXISTS (SELECT 1 FROM ` + reviewTable + ` d WHERE d.user_email = u.email AND d.status = 'pending')
+	FROM ` + table + ` u
+	WHERE u.email = $1`
+	err := q.db.QueryRowContext(c, query, email).Scan(&r.Bio, &r.Address, &r.IsComplete, &r.PendingReview)
 
 	return r, err
 }
 
+// OwnsFile tells whether key is an upload of email's.
+func (q *repository) OwnsFile(c context.Context, email, key string) (bool, error) {
+	var owned bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM ` + fileTable + ` WHERE key = $1 AND owner_email = $2)`
+	err := q.db.QueryRowContext(c, query, key, email).Scan(&owned)
+
+	return owned, err
+}
+
 func (q *repository) UpdateSubSector(c context.Context, user_id int, subsectorIDs []string) error {
 	query1 := `DELETE FROM ppt_sub_sector_accesses WHERE user_id = $1`
 	_, err := q.db.ExecContext(c, query1, user_id)
@@ -738,6 +883,7 @@ func (q *repository) UpdateSubSector(c context.Context, user_id int, subsectorID
 
 func (q *repository) Delete(c context.Context, ids []string) ([]string, []string, error) {
 	var successIDs, failedIDs []string
+	var removed []int64
 
 	for _, id := range ids {
 		decryptedID, _ := util.Decrypt(id, "f")
@@ -747,8 +893,304 @@ func (q *repository) Delete(c context.Context, ids []string) ([]string, []string
 			failedIDs = append(failedIDs, id)
 		} else {
 			successIDs = append(successIDs, id)
+			if n, err := strconv.ParseInt(decryptedID, 10, 64); err == nil {
+				removed = append(removed, n)
+			}
 		}
 	}
 
+	q.dir.Remove(c, removed...)
+
 	return successIDs, failedIDs, nil
 }
+
+// StoreDukcapilReview queues bio for review, replacing a pending review of
+// the same user.
+func (q *repository) StoreDukcapilReview(c context.Context, email, submittedBy string, bio UserBioRequest, matches map[string]dukcapil.FieldMatch) error {
+	raw, err := json.Marshal(bio)
+	if err != nil {
+		return err
+	}
+	submitted, err := util.Encrypt(string(raw), "f")
+	if err != nil {
+		return err
+	}
+	matchData, err := json.Marshal(matches)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO ` + reviewTable + ` (user_email, submitted_by, submitted, matches)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_email) WHERE status = 'pending' DO UPDATE SET
+		submitted_by = EXCLUDED.submitted_by,
+		submitted = EXCLUDED.submitted,
+		matches = EXCLUDED.matches,
+		updated_at = NOW()`
+
+	_, err = q.db.ExecContext(c, query, email, submittedBy, submitted, matchData)
+	return err
+}
+
+func (q *repository) CountDukcapilReviews(c context.Context, status string) (int, error) {
+	query := `SELECT COUNT(*) FROM ` + reviewTable
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE status = $1`
+		args = append(args, status)
+	}
+
+	var total int
+	err := q.db.QueryRowContext(c, query, args...).Scan(&total)
+	return total, err
+}
+
+func (q *repository) GetDukcapilReviews(c context.Context, status string, page, pageSize int) ([]DukcapilReview, error) {
+	args := []interface{}{pageSize, (page - 1) * pageSize}
+	where := ""
+	if status != "" {
+		where = `WHERE status = $3`
+		args = append(args, status)
+	}
+
+	query := `
+	SELECT id, user_email, submitted_by, submitted, matches, status, reason, reviewed_by, reviewed_at, created_at
+	FROM ` + reviewTable + `
+	` + where + `
+	ORDER BY created_at, id
+	LIMIT $1 OFFSET $2`
+
+	rows, err := q.db.QueryContext(c, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []DukcapilReview{}
+	for rows.Next() {
+		r, err := scanDukcapilReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, r)
+	}
+
+	return items, rows.Err()
+}
+
+func (q *repository) GetDukcapilReview(c context.Context, id int64) (DukcapilReview, error) {
+	query := `
+	SELECT id, user_email, submitted_by, submitted, matches, status, reason, reviewed_by, reviewed_at, created_at
+	FROM ` + reviewTable + `
+	WHERE id = $1`
+
+	return scanDukcapilReview(q.db.QueryRowContext(c, query, id))
+}
+
+// DecideDukcapilReview closes a pending review. It returns sql.ErrNoRows
+// when the review does not exist or was already decided.
+func (q *repository) DecideDukcapilReview(c context.Context, id int64, status, reason, reviewer string) error {
+	query := `
+	UPDATE ` + reviewTable + `
+	SET status = $2, reason = $3, reviewed_by = $4, reviewed_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND status = 'pending'`
+
+	res, err := q.db.ExecContext(c, query, id, status, reason, reviewer)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanDukcapilReview(row interface{ Scan(...interface{}) error }) (DukcapilReview, error) {
+	var r DukcapilReview
+	var id int64
+	var submitted string
+	var matches []byte
+
+	err := row.Scan(&id, &r.UserEmail, &r.SubmittedBy, &submitted, &matches, &r.Status, &r.Reason, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt)
+	if err != nil {
+		return r, err
+	}
+
+	raw, err := util.Decrypt(submitted, "f")
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal([]byte(raw), &r.Submitted); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(matches, &r.Matches); err != nil {
+		return r, err
+	}
+	r.HashedID, _ = util.Encrypt(strconv.FormatInt(id, 10), "f")
+
+	return r, nil
+}
+
+// GetRoleIDs maps lower-cased role names to their ids.
+func (q *repository) GetRoleIDs(c context.Context) (map[string]int, error) {
+	rows, err := q.db.QueryContext(c, `SELECT id, name FROM `+subtable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := map[string]int{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		r[strings.ToLower(name)] = id
+	}
+
+	return r, rows.Err()
+}
+
+func (q *repository) ExistingRegions(c context.Context, codes []string) (map[string]bool, error) {
+	rows, err := q.db.QueryContext(c, `SELECT kode FROM `+regionTable+` WHERE kode = ANY($1)`, pq.Array(codes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := map[string]bool{}
+	for rows.Next() {
+		var kode string
+		if err := rows.Scan(&kode); err != nil {
+			return nil, err
+		}
+		r[kode] = true
+	}
+
+	return r, rows.Err()
+}
+
+// ExistingAccounts returns which of the emails and usernames are taken.
+// Emails are compared case-insensitively and returned lower-cased.
+func (q *repository) ExistingAccounts(c context.Context, emails, usernames []string) (map[string]bool, map[string]bool, error) {
+	query := `
+	SELECT LOWER(email), username FROM ` + table + `
+	WHERE LOWER(email) = ANY($1) OR username = ANY($2)`
+	rows, err := q.db.QueryContext(c, query, pq.Array(emails), pq.Array(usernames))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	takenEmails, takenUsernames := map[string]bool{}, map[string]bool{}
+	for rows.Next() {
+		var email, username string
+		if err := rows.Scan(&email, &username); err != nil {
+			return nil, nil, err
+		}
+		takenEmails[email] = true
+		takenUsernames[username] = true
+	}
+
+	return takenEmails, takenUsernames, rows.Err()
+}
+
+// ExistingIdentifiers returns every NIK and NIP on record. They are
+// encrypted with a random IV, so they can only be compared decrypted.
+func (q *repository) ExistingIdentifiers(c context.Context) (map[string]bool, map[string]bool, error) {
+	query := `SELECT nik, nip FROM ` + table + ` WHERE nik IS NOT NULL OR nip IS NOT NULL`
+	rows, err := q.db.QueryContext(c, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	niks, nips := map[string]bool{}, map[string]bool{}
+	for rows.Next() {
+		var nik, nip sql.NullString
+		if err := rows.Scan(&nik, &nip); err != nil {
+			return nil, nil, err
+		}
+		if nik.Valid {
+			if v, err := util.Decrypt(nik.String, "f"); err == nil {
+				niks[v] = true
+			}
+		}
+		if nip.Valid {
+			if v, err := util.Decrypt(nip.String, "f"); err == nil {
+				nips[v] = true
+			}
+		}
+	}
+
+	return niks, nips, rows.Err()
+}
+
+// ImportUsers creates all accounts or none, and queues their invitations
+// in the same transaction. They have no password until the user accepts
+// their invitation.
+func (q *repository) ImportUsers(c context.Context, users []UserCreate, mails []email.Outgoing) error {
+	tx, err := q.db.BeginTx(c, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(c, `
+	INSERT INTO `+table+` (
+		role_id, province_id, regency_id, subdistrict_id, urbanvillage_id, name, username, email, phone, nik, nip, is_active
+	) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, u := range users {
+		_, err := stmt.ExecContext(c, u.RoleID, u.ProvinceID, u.RegencyID, u.SubdistrictID, u.UrbanvillageID,
+			u.Name, u.Username, u.Email, u.Phone, u.NIK, u.NIP, u.IsActive)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := email.Enqueue(c, tx, mails...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	emails := make([]string, len(users))
+	for i, u := range users {
+		emails[i] = u.Email
+	}
+	q.dir.SyncEmails(c, emails...)
+
+	return nil
+}
+
+// AcceptInvitation sets the first password of an account and marks its
+// email verified. It returns sql.ErrNoRows when the account already has a
+// password.
+func (q *repository) AcceptInvitation(c context.Context, email, password string) error {
+	query := `
+	UPDATE ` + table + `
+	SET password = $2, is_verified = true, updated_at = NOW()
+	WHERE email = $1 AND password IS NULL`
+	res, err := q.db.ExecContext(c, query, email, password)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	q.dir.SyncEmails(c, email)
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gigaflex-co/ppt_backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Search looks users up in the directory. q matches names fuzzily,
+// usernames and emails by prefix and NIK, NIP or phone numbers exactly;
+// role_id, region, is_active, is_complete and is_verified narrow the
+// results. Lookups by identifier are audited.
+func (uc *usecase) Search(c *gin.Context) {
+	arg, err := directoryQuery(c)
+	if err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+	arg.Identifiers = true
+
+	if digitsPattern.MatchString(arg.Text) {
+		if !uc.recordAccess(c, "user.search.identifier", "", nil) {
+			return
+		}
+	}
+
+	data, totalRecords, err := uc.repo.SearchDirectory(c, arg)
+	if err != nil {
+		if errors.Is(err, ErrDirectoryUnavailable) {
+			util.JERR(c, http.StatusServiceUnavailable, err)
+		} else if errors.Is(err, ErrDirectoryWindow) {
+			util.JERR(c, http.StatusBadRequest, err)
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	util.JOK(c, http.StatusOK, DataWithPagination{
+		Row: data,
+		Pagination: util.PaginationResponse{
+			CurrentPage:  arg.Page,
+			PageSize:     arg.PageSize,
+			TotalPages:   int(math.Ceil(float64(totalRecords) / float64(arg.PageSize))),
+			TotalRecords: totalRecords,
+		},
+	})
+}
+
+// ReindexDirectory rebuilds the user directory from the database.
+func (uc *usecase) ReindexDirectory(c *gin.Context) {
+	count, err := uc.repo.ReindexDirectory(c)
+	if err != nil {
+		if errors.Is(err, ErrDirectoryUnavailable) {
+			util.JERR(c, http.StatusServiceUnavailable, err)
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if !uc.recordAccess(c, "user.directory.reindex", "", map[string]interface{}{"count": count}) {
+		return
+	}
+
+	util.JOK(c, http.StatusOK, gin.H{"count": count})
+}
+
+func directoryQuery(c *gin.Context) (DirectoryQuery, error) {
+	arg := DirectoryQuery{
+		Text:   strings.TrimSpace(c.Query("q")),
+		Region: c.Query("region"),
+	}
+
+	arg.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	arg.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if arg.Page < 1 {
+		arg.Page = 1
+	}
+	if arg.PageSize < 1 || arg.PageSize > 100 {
+		arg.PageSize = 10
+	}
+
+	if v := c.Query("role_id"); v != "" {
+		raw, err := util.Decrypt(v, "f")
+		id, perr := strconv.ParseInt(raw, 10, 64)
+		if err != nil || perr != nil {
+			return arg, errors.New("role_id is not a valid role")
+		}
+		arg.RoleID = id
+	}
+
+	if arg.Region != "" && !regionPattern.MatchString(arg.Region) {
+		return arg, errors.New("region must be a ppt_wilayah code such as 32 or 32.04.11")
+	}
+
+	for name, dst := range map[string]**bool{"is_active": &arg.IsActive, "is_complete": &arg.IsComplete, "is_verified": &arg.IsVerified} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return arg, fmt.Errorf("%s must be true or false", name)
+		}
+		*dst = &b
+	}
+
+	return arg, nil
+}
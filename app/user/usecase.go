@@ -1,22 +1,21 @@
 package user
 
 import (
-	"bytes"
 	"database/sql"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
 	"math"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/gigaflex-co/ppt_backend/app/audit"
+	"github.com/gigaflex-co/ppt_backend/app/dukcapil"
+	"github.com/gigaflex-co/ppt_backend/app/email"
 	"github.com/gigaflex-co/ppt_backend/config"
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
-	"gopkg.in/gomail.v2"
 )
 
 type (
@@ -41,20 +40,37 @@ type (
 		IsRegistered(c *gin.Context)
 		IsNullPassword(c *gin.Context)
 		Delete(c *gin.Context)
+		GetDukcapilReviews(c *gin.Context)
+		GetDukcapilReview(c *gin.Context)
+		DecideDukcapilReview(c *gin.Context)
+		GetMe(c *gin.Context)
+		UpdateMe(c *gin.Context)
+		GetMyCompletion(c *gin.Context)
+		DoMyCompletion(c *gin.Context)
+		GetMyStatus(c *gin.Context)
+		Import(c *gin.Context)
+		GetImportReport(c *gin.Context)
+		AcceptInvitation(c *gin.Context)
+		Search(c *gin.Context)
+		ReindexDirectory(c *gin.Context)
 	}
 
 	usecase struct {
-		repo UserRepository
-		rdb  *redis.Client
-		cfg  config.Config
+		repo     UserRepository
+		rdb      *redis.Client
+		dukcapil *dukcapil.Verifier
+		audit    audit.AuditRepository
+		cfg      config.Config
 	}
 )
 
-func NewUsecase(repo UserRepository, rdb *redis.Client, config config.Config) UserUsecase {
+func NewUsecase(repo UserRepository, rdb *redis.Client, dukcapilVerifier *dukcapil.Verifier, auditRepo audit.AuditRepository, config config.Config) UserUsecase {
 	return &usecase{
-		repo: repo,
-		rdb:  rdb,
-		cfg:  config,
+		repo:     repo,
+		rdb:      rdb,
+		dukcapil: dukcapilVerifier,
+		audit:    auditRepo,
+		cfg:      config,
 	}
 }
 
@@ -105,6 +121,32 @@ func (uc *usecase) GetTable(c *gin.Context) {
 		PageSize:  pageSizeInt,
 	}
 
+	// Names are encrypted in the database, so a search goes to the
+	// directory when it is up and only matches usernames otherwise.
+	if search != "" {
+		data, totalRecords, err := uc.repo.SearchDirectory(c, DirectoryQuery{Text: search, Page: pageInt, PageSize: pageSizeInt})
+		if err == nil {
+			util.JOK(c, http.StatusOK, DataWithPagination{
+				Row: data,
+				Pagination: util.PaginationResponse{
+					CurrentPage:  pageInt,
+					PageSize:     pageSizeInt,
+					TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pageSizeInt))),
+					TotalRecords: totalRecords,
+				},
+			})
+			return
+		}
+		if errors.Is(err, ErrDirectoryWindow) {
+			util.JERR(c, http.StatusBadRequest, err)
+			return
+		}
+		if !errors.Is(err, ErrDirectoryUnavailable) {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	totalRecords, err := uc.repo.CountRecords(c, arg)
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
@@ -256,8 +298,14 @@ func (uc *usecase) Login(c *gin.Context) {
 	util.JOK(c, http.StatusOK, data)
 }
 
+// IsComplete tells an admin whether a user finished their profile; users
+// ask /v1/me/status instead.
 func (uc *usecase) IsComplete(c *gin.Context) {
 	email := c.Param("email")
+	if !uc.recordAccess(c, "user.status.view", email, nil) {
+		return
+	}
+
 	userResponse, err := uc.repo.IsComplete(c, email)
 	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
@@ -284,8 +332,20 @@ func (uc *usecase) IsComplete(c *gin.Context) {
 	}
 }
 
+// IsVerified tells an admin whether a user is verified. Without an email
+// in the path it answers for the caller, as the session route expects.
 func (uc *usecase) IsVerified(c *gin.Context) {
 	email := c.Param("email")
+	if email == "" {
+		var err error
+		if email, err = util.ClaimsEmail(c); err != nil {
+			util.JERR(c, http.StatusUnauthorized, err)
+			return
+		}
+	} else if !uc.recordAccess(c, "user.status.view", email, nil) {
+		return
+	}
+
 	userResponse, err := uc.repo.IsVerified(c, email)
 	if err != nil && err != sql.ErrNoRows {
 		util.JERR(c, http.StatusInternalServerError, err)
@@ -388,158 +448,91 @@ func (uc *usecase) Refresh(c *gin.Context) {
 	util.JOK(c, http.StatusOK, gin.H{"token": token})
 }
 
-func DukcapilValidation(payload DukcapilPayload) ([]DukcapilContent, error) {
-	originalDate, err := time.Parse("2006-01-02", payload.TanggalLahir)
-	if err != nil {
-		fmt.Println("Error parsing date:", err)
-		return nil, err
-	}
-
-	formattedDate := originalDate.Format("02-01-2006")
-
-	pd := []byte(`{
-		"NIK": "` + payload.NIK + `",
-		"NAMA_LGKP": "` + payload.NamaLengkap + `",
-		"JENIS_KLMIN": "` + payload.JenisKelamin + `",
-		"TMPT_LHR": "` + payload.TempatLahir + `",
-		"TGL_LHR": "` + formattedDate + `",
-		"TRESHOLD": 100,
-		"user_id": "26082022160454BADAN_PENYULUHAN_SDM8370",
-		"password": "TH854Y",
-		"ip_user": "10.160.84.10"
-	}`)
+// DoCompletion fills in a section of another user's profile for an admin.
+func (uc *usecase) DoCompletion(c *gin.Context) {
+	var r CompletionRequest
 
-	req, err := http.NewRequest("POST", "http://10.1.241.250/api/nik_verifby_elemen.php", bytes.NewBuffer(pd))
-	if err != nil {
-		return nil, err
+	if err := c.ShouldBindJSON(&r); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+	if r.Email == "" {
+		util.JERR(c, http.StatusBadRequest, errors.New("email is required"))
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	actor, err := util.ClaimsEmail(c)
 	if err != nil {
-		return nil, err
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
 	}
-	defer resp.Body.Close()
-
-	var response DukcapilResponse
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(&response); err != nil {
-		return nil, err
+	if !uc.recordAccess(c, "user.completion.update", r.Email, map[string]interface{}{"section": r.Section}) {
+		return
 	}
 
-	content := response.Content
-
-	return content, nil
+	uc.complete(c, actor, r.Email, r)
 }
 
-func (uc *usecase) DoCompletion(c *gin.Context) {
+// DoMyCompletion fills in a section of the caller's own profile. The role
+// is not the caller's to choose, so a role in the bio data is ignored.
+func (uc *usecase) DoMyCompletion(c *gin.Context) {
 	var r CompletionRequest
 
 	if err := c.ShouldBindJSON(&r); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusBadRequest, err)
 		return
 	}
+	r.BioData.RoleID = ""
 
-	var payload DukcapilPayload
-	var gender string
-
-	if r.BioData.Gender == "l" {
-		gender = "Laki-laki"
-	} else {
-		gender = "Perempuan"
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
 	}
 
+	uc.complete(c, email, email, r)
+}
+
+// complete fills in a section of target's profile. Bio data is checked
+// against Dukcapil on behalf of actor, whose quota it counts against.
+func (uc *usecase) complete(c *gin.Context, actor, target string, r CompletionRequest) {
 	if r.Section == "bio" {
-		payload = DukcapilPayload{
+		birthDate, err := time.Parse("2006-01-02", r.BioData.DOB)
+		if err != nil {
+			util.JERR(c, http.StatusBadRequest, errors.New("dob must be YYYY-MM-DD"))
+			return
+		}
+
+		gender := "Perempuan"
+		if r.BioData.Gender == "l" {
+			gender = "Laki-laki"
+		}
+
+		result, err := uc.dukcapil.Verify(c, actor, dukcapil.SourceCompletion, dukcapil.Identity{
 			NIK:          r.BioData.NIK,
 			NamaLengkap:  r.BioData.Name,
 			JenisKelamin: gender,
 			TempatLahir:  r.BioData.POB,
-			TanggalLahir: r.BioData.DOB,
-			TRESHOLD:     100,
-			UserID:       "26082022160454BADAN_PENYULUHAN_SDM8370",
-			Password:     "TH854Y",
-			IPUser:       "10.160.84.10",
-		}
-
-		content, err := DukcapilValidation(payload)
+			TanggalLahir: birthDate,
+		})
 		if err != nil {
-			util.JERR(c, http.StatusInternalServerError, err)
+			util.JERR(c, dukcapil.HTTPStatus(err), err)
 			return
 		}
 
-		namaLengkapSesuai := true
-		tempatLahirSesuai := true
-		tanggalLahirSesuai := true
-		nikSesuai := true
-		jenisKelaminSesuai := true
-
-		for _, item := range content {
-			if item.NamaLengkap != "Sesuai (100)" {
-				namaLengkapSesuai = false
-			}
-			if item.TempatLahir != "Sesuai (100)" {
-				tempatLahirSesuai = false
-			}
-			if item.TanggalLahir != "Sesuai" {
-				tanggalLahirSesuai = false
-			}
-			if item.NIK != "Sesuai" {
-				nikSesuai = false
-			}
-			if item.JenisKelamin != "Sesuai" {
-				jenisKelaminSesuai = false
-			}
-		}
-
-		if namaLengkapSesuai && tempatLahirSesuai && tanggalLahirSesuai && nikSesuai && jenisKelaminSesuai {
-			name, _ := util.Encrypt(payload.NamaLengkap, "f")
-			pob, _ := util.Encrypt(payload.TempatLahir, "f")
-			dob, _ := util.Encrypt(payload.TanggalLahir, "f")
-			nik, _ := util.Encrypt(r.BioData.NIK, "f")
-			role_id, _ := util.Decrypt(r.BioData.RoleID, "f")
-			phone, _ := util.Encrypt(r.BioData.Phone, "f")
-
-			dataToUpdate := UserUpdate{
-				RoleID: sql.NullString{
-					String: role_id,
-					Valid:  true,
-				},
-				Name: sql.NullString{
-					String: name,
-					Valid:  true,
-				},
-				POB: sql.NullString{
-					String: pob,
-					Valid:  true,
-				},
-				DOB: sql.NullString{
-					String: dob,
-					Valid:  true,
-				},
-				NIK: sql.NullString{
-					String: nik,
-					Valid:  true,
-				},
-				Gender: sql.NullString{
-					String: r.BioData.Gender,
-					Valid:  true,
-				},
-				Phone: sql.NullString{
-					String: phone,
-					Valid:  true,
-				},
+		switch result.Verdict {
+		case dukcapil.VerdictMatched:
+			if err := uc.applyBio(c, target, r.BioData); err != nil {
+				util.JERR(c, http.StatusInternalServerError, err)
+				return
 			}
-
-			_, err := uc.repo.Update(c, "email", r.Email, dataToUpdate, r.Section)
-			if err != nil {
+		case dukcapil.VerdictReview:
+			if err := uc.repo.StoreDukcapilReview(c, target, actor, r.BioData, result.Fields()); err != nil {
 				util.JERR(c, http.StatusInternalServerError, err)
 				return
 			}
 		}
-		util.JOK(c, http.StatusOK, content)
+		util.JOK(c, http.StatusOK, result)
 		return
 	} else if r.Section == "address" {
 		dataToUpdate := UserUpdate{
@@ -573,7 +566,7 @@ func (uc *usecase) DoCompletion(c *gin.Context) {
 			},
 		}
 
-		data, err := uc.repo.Update(c, "email", r.Email, dataToUpdate, r.Section)
+		data, err := uc.repo.Update(c, "email", target, dataToUpdate, r.Section)
 		if err != nil {
 			util.JERR(c, http.StatusInternalServerError, err)
 			return
@@ -583,15 +576,182 @@ func (uc *usecase) DoCompletion(c *gin.Context) {
 	}
 }
 
+// applyBio stores bio data that passed verification, by Dukcapil or by an
+// admin. Without a role in bio the user keeps their current role.
+func (uc *usecase) applyBio(c *gin.Context, email string, bio UserBioRequest) error {
+	name, _ := util.Encrypt(bio.Name, "f")
+	pob, _ := util.Encrypt(bio.POB, "f")
+	dob, _ := util.Encrypt(bio.DOB, "f")
+	nik, _ := util.Encrypt(bio.NIK, "f")
+	role_id, _ := util.Decrypt(bio.RoleID, "f")
+	phone, _ := util.Encrypt(bio.Phone, "f")
+
+	dataToUpdate := UserUpdate{
+		RoleID: sql.NullString{
+			String: role_id,
+			Valid:  role_id != "",
+		},
+		Name: sql.NullString{
+			String: name,
+			Valid:  true,
+		},
+		POB: sql.NullString{
+			String: pob,
+			Valid:  true,
+		},
+		DOB: sql.NullString{
+			String: dob,
+			Valid:  true,
+		},
+		NIK: sql.NullString{
+			String: nik,
+			Valid:  true,
+		},
+		Gender: sql.NullString{
+			String: bio.Gender,
+			Valid:  true,
+		},
+		Phone: sql.NullString{
+			String: phone,
+			Valid:  true,
+		},
+	}
+
+	_, err := uc.repo.Update(c, "email", email, dataToUpdate, "bio")
+	return err
+}
+
+// GetDukcapilReviews lists completions waiting for review, oldest first;
+// status=all includes decided ones.
+func (uc *usecase) GetDukcapilReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", ReviewPending)
+	if status == "all" {
+		status = ""
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	totalRecords, err := uc.repo.CountDukcapilReviews(c, status)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, err := uc.repo.GetDukcapilReviews(c, status, page, pageSize)
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	util.JOK(c, http.StatusOK, DukcapilReviewsWithPagination{
+		Row: data,
+		Pagination: util.PaginationResponse{
+			CurrentPage:  page,
+			PageSize:     pageSize,
+			TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+			TotalRecords: totalRecords,
+		},
+	})
+}
+
+func (uc *usecase) GetDukcapilReview(c *gin.Context) {
+	_, review, ok := uc.dukcapilReview(c)
+	if !ok {
+		return
+	}
+
+	util.JOK(c, http.StatusOK, review)
+}
+
+// DecideDukcapilReview approves or rejects a pending review. Approval
+// stores the submitted bio data as if Dukcapil had matched it.
+func (uc *usecase) DecideDukcapilReview(c *gin.Context) {
+	var req DukcapilReviewDecision
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	reviewer, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, review, ok := uc.dukcapilReview(c)
+	if !ok {
+		return
+	}
+	if review.Status != ReviewPending {
+		util.JERR(c, http.StatusConflict, errors.New("review was already decided"))
+		return
+	}
+
+	status := ReviewRejected
+	if req.Decision == "approve" {
+		status = ReviewApproved
+		if err := uc.applyBio(c, review.UserEmail, review.Submitted); err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if err := uc.repo.DecideDukcapilReview(c, id, status, req.Reason, reviewer); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusConflict, errors.New("review was already decided"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	review.Status = status
+	review.Reason = &req.Reason
+	review.ReviewedBy = &reviewer
+	util.JOK(c, http.StatusOK, review)
+}
+
+func (uc *usecase) dukcapilReview(c *gin.Context) (int64, DukcapilReview, bool) {
+	raw, err := util.Decrypt(c.Param("id"), "f")
+	id, perr := strconv.ParseInt(raw, 10, 64)
+	if err != nil || perr != nil {
+		util.JERR(c, http.StatusNotFound, errors.New("review not found"))
+		return 0, DukcapilReview{}, false
+	}
+
+	review, err := uc.repo.GetDukcapilReview(c, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("review not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return id, review, false
+	}
+
+	return id, review, true
+}
+
+// GetCompletion returns another user's profile to an admin.
 func (uc *usecase) GetCompletion(c *gin.Context) {
 	type User struct {
-		Email string `json:"email"`
+		Email string `json:"email" binding:"required"`
 	}
 
 	var r User
 
 	if err := c.ShouldBindJSON(&r); err != nil {
-		util.JERR(c, http.StatusInternalServerError, err)
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+	if !uc.recordAccess(c, "user.completion.view", r.Email, nil) {
 		return
 	}
 
@@ -600,10 +760,160 @@ func (uc *usecase) GetCompletion(c *gin.Context) {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
+	data.Password = ""
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+// GetMe returns the caller's profile.
+func (uc *usecase) GetMe(c *gin.Context) {
+	data, ok := uc.me(c)
+	if !ok {
+		return
+	}
+
+	util.JOK(c, http.StatusOK, data)
+}
+
+// UpdateMe changes the self-service fields of the caller's profile. A
+// new avatar must be an image the caller uploaded through /v1/files.
+func (uc *usecase) UpdateMe(c *gin.Context) {
+	var r MeUpdateRequest
+
+	if err := c.ShouldBindJSON(&r); err != nil {
+		util.JERR(c, http.StatusBadRequest, err)
+		return
+	}
+
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var dataToUpdate UserUpdate
+	if r.Username != nil {
+		dataToUpdate.Username = sql.NullString{String: *r.Username, Valid: true}
+	}
+	if r.Phone != nil {
+		phone, _ := util.Encrypt(*r.Phone, "f")
+		dataToUpdate.Phone = sql.NullString{String: phone, Valid: true}
+	}
+	if r.ImgUser != nil {
+		owned, err := uc.repo.OwnsFile(c, email, *r.ImgUser)
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !owned {
+			util.JERR(c, http.StatusBadRequest, errors.New("img_user must be an image you uploaded"))
+			return
+		}
+		dataToUpdate.ImgUser = sql.NullString{String: *r.ImgUser, Valid: true}
+	}
+	if r.Address != nil {
+		dataToUpdate.Address = sql.NullString{String: *r.Address, Valid: true}
+	}
+
+	err = uc.repo.UpdateProfile(c, email, dataToUpdate)
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		util.JERR(c, http.StatusNotFound, errors.New("user not found"))
+		return
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		util.JERR(c, http.StatusConflict, errors.New("username or phone is already taken"))
+		return
+	case err != nil:
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	uc.GetMe(c)
+}
+
+// GetMyCompletion tells which sections of the caller's profile are filled
+// in.
+func (uc *usecase) GetMyCompletion(c *gin.Context) {
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	data, err := uc.repo.GetCompletion(c, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("user not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
 
 	util.JOK(c, http.StatusOK, data)
 }
 
+func (uc *usecase) GetMyStatus(c *gin.Context) {
+	data, ok := uc.me(c)
+	if !ok {
+		return
+	}
+
+	util.JOK(c, http.StatusOK, MeStatus{
+		Name:       data.Name,
+		Email:      data.Email,
+		IsActive:   data.IsActive,
+		IsComplete: data.IsComplete,
+		IsVerified: data.IsVerified,
+	})
+}
+
+// me loads the caller's profile, identified by the token alone.
+func (uc *usecase) me(c *gin.Context) (UserResponse, bool) {
+	email, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return UserResponse{}, false
+	}
+
+	data, err := uc.repo.GetDataBy(c, "email", email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			util.JERR(c, http.StatusNotFound, errors.New("user not found"))
+		} else {
+			util.JERR(c, http.StatusInternalServerError, err)
+		}
+		return data, false
+	}
+	data.Password = ""
+
+	return data, true
+}
+
+// recordAccess audits an admin reading or changing another user's profile.
+func (uc *usecase) recordAccess(c *gin.Context, action, target string, detail map[string]interface{}) bool {
+	actor, err := util.ClaimsEmail(c)
+	if err != nil {
+		util.JERR(c, http.StatusUnauthorized, err)
+		return false
+	}
+
+	err = uc.audit.Record(c, audit.Entry{
+		ActorEmail: actor,
+		Action:     action,
+		Target:     target,
+		Detail:     detail,
+		IPAddress:  c.ClientIP(),
+	})
+	if err != nil {
+		util.JERR(c, http.StatusInternalServerError, err)
+		return false
+	}
+
+	return true
+}
+
 func (uc *usecase) Logout(c *gin.Context) {
 	c.SetCookie("token", "", -1, "", "", false, true)
 	email, err := util.RevokeToken(c, uc.rdb)
@@ -682,47 +992,28 @@ func (uc *usecase) Register(c *gin.Context) {
 		GoogleID: google_id,
 	}
 
-	data, err := uc.repo.Create(c, arg)
+	code, err := util.Encrypt(req.Email, "f")
 	if err != nil {
 		util.JERR(c, http.StatusInternalServerError, err)
 		return
 	}
-
-	go sendActivationEmail(uc, c, uc.cfg.AllowOrigin, req.Email)
-
-	util.JOK(c, http.StatusOK, data)
-}
-
-func sendActivationEmail(uc *usecase, c *gin.Context, origin, toEmail string) {
-	m := gomail.NewMessage()
-
-	data, err := uc.repo.GetConfig(c)
-	if err != nil {
-		log.Printf("failed to retrieve config: %v", err)
+	activation := email.Outgoing{
+		Template:  "activation",
+		Locale:    req.Locale,
+		Recipient: req.Email,
+		Data: map[string]interface{}{
+			"Name": req.Name,
+			"Link": uc.cfg.AllowOrigin + "/auth/email-verification/" + code,
+		},
 	}
 
-	smtpServer := data.SMTPServer
-	smtpPort := data.SMTPPort
-	smtpEmail := data.SMTPEmail
-	smtpPassword, _ := util.Decrypt(data.SMTPPassword, "f")
-
-	m.SetHeader("From", smtpEmail)
-	m.SetHeader("To", toEmail)
-	m.SetHeader("Subject", "Verifikasi Email")
-
-	code, err := util.Encrypt(toEmail, "f")
+	data, err := uc.repo.Create(c, arg, activation)
 	if err != nil {
-		log.Printf("to encrypt email: %v", err)
+		util.JERR(c, http.StatusInternalServerError, err)
+		return
 	}
-	activationLink := origin + "/auth/email-verification/" + code
-	emailBody := fmt.Sprintf("Silahkan klik link berikut untuk mengaktifkan email akun anda :\n<a href=\"%s\">Aktifkan Email</a>", activationLink)
-	m.SetBody("text/html", emailBody)
-
-	d := gomail.NewDialer(smtpServer, smtpPort, smtpEmail, smtpPassword)
 
-	if err := d.DialAndSend(m); err != nil {
-		log.Printf("Failed to send email: %v", err)
-	}
+	util.JOK(c, http.StatusOK, data)
 }
 
 func (uc *usecase) Read(c *gin.Context) {
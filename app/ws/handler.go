@@ -1,6 +1,9 @@
 package ws
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
@@ -8,12 +11,14 @@ import (
 	"github.com/gigaflex-co/ppt_backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
 )
 
-func NewHandler(router *gin.Engine) {
+func NewHandler(router *gin.Engine, db *sql.DB, rdb *redis.Client) {
 	v1 := router.Group("/v1")
 	v1.GET("get-notification", wsHandler)
 	v1.GET("get-kafka", kfHandler)
+	v1.GET("get-ingest-jobs", ingestJobHandler(db, rdb))
 }
 
 var appConfig, _ = config.LoadConfig("./.")
@@ -69,3 +74,83 @@ func kfHandler(c *gin.Context) {
 	}()
 	select {}
 }
+
+// ingestJobHandler relays ingestion job progress to admins. Browsers cannot
+// set headers on a WebSocket handshake, so the access token may also be
+// passed as the token query parameter. An optional job_id narrows the feed
+// to a single job.
+func ingestJobHandler(db *sql.DB, rdb *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if token := c.Query("token"); token != "" {
+			authHeader = "Bearer " + token
+		}
+
+		claims, status, err := util.Authenticate(c, rdb, authHeader)
+		if err != nil {
+			util.JERR(c, status, err)
+			return
+		}
+
+		email, err := util.Decrypt(claims.Email, "s")
+		if err != nil {
+			util.JERR(c, http.StatusUnauthorized, errors.New("invalid token claims"))
+			return
+		}
+
+		admin, err := util.IsAdmin(c, db, email)
+		if err != nil {
+			util.JERR(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !admin {
+			util.JERR(c, http.StatusForbidden, errors.New("admin access required"))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Printf("Could not upgrade WebSocket connection: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		ctx := c.Request.Context()
+		pubsub := rdb.Subscribe(ctx, util.IngestJobChannel)
+		defer pubsub.Close()
+
+		// The client never sends anything; reading only notices it leaving.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		jobID := c.Query("job_id")
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-closed:
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				if jobID != "" {
+					var job util.IngestJob
+					if err := json.Unmarshal([]byte(msg.Payload), &job); err != nil || job.ID != jobID {
+						continue
+					}
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(msg.Payload)); err != nil {
+					log.Printf("Error writing WebSocket message: %v", err)
+					return
+				}
+			}
+		}
+	}
+}
@@ -7,10 +7,14 @@ import (
 	"log"
 
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/gigaflex-co/ppt_backend/app/audit"
 	"github.com/gigaflex-co/ppt_backend/app/configuration"
 	"github.com/gigaflex-co/ppt_backend/app/dukcapil"
+	"github.com/gigaflex-co/ppt_backend/app/email"
 	"github.com/gigaflex-co/ppt_backend/app/encdec"
 	"github.com/gigaflex-co/ppt_backend/app/external_api"
+	"github.com/gigaflex-co/ppt_backend/app/file"
+	"github.com/gigaflex-co/ppt_backend/app/identity"
 	"github.com/gigaflex-co/ppt_backend/app/internal_api"
 	"github.com/gigaflex-co/ppt_backend/app/land_status"
 	"github.com/gigaflex-co/ppt_backend/app/menu"
@@ -18,6 +22,8 @@ import (
 	"github.com/gigaflex-co/ppt_backend/app/report_category"
 	"github.com/gigaflex-co/ppt_backend/app/role"
 	"github.com/gigaflex-co/ppt_backend/app/service"
+	"github.com/gigaflex-co/ppt_backend/app/simluh"
+	"github.com/gigaflex-co/ppt_backend/app/storage"
 	"github.com/gigaflex-co/ppt_backend/app/sub_sector"
 	"github.com/gigaflex-co/ppt_backend/app/user"
 	"github.com/gigaflex-co/ppt_backend/app/ws"
@@ -79,13 +85,13 @@ func main() {
 
 	util.NewKafkaClient(config)
 	go GinServer(config, psql, rdb, es)
-	SocketServer(config)
+	SocketServer(config, psql, rdb)
 }
 
 func CORSMiddleware(config config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		allowHeaders := "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With"
-		allowMethods := "POST, GET, PUT, DELETE, OPTIONS"
+		allowMethods := "POST, GET, PUT, PATCH, DELETE, OPTIONS"
 
 		c.Header("Access-Control-Allow-Origin", config.AllowOrigin)
 		c.Header("Access-Control-Allow-Credentials", "true")
@@ -108,9 +114,24 @@ func GinServer(config config.Config, db *sql.DB, rdb *redis.Client, edb *elastic
 	router.ForwardedByClientIP = false
 	router.Use(CORSMiddleware(config))
 
-	userRepo := user.NewRepository(db, rdb, edb)
-	userUsecase := user.NewUsecase(userRepo, rdb, config)
-	user.NewHandler(router, userUsecase, rdb)
+	store, err := storage.NewStore(config)
+	if err != nil {
+		log.Fatal("cannot initialise file storage:", err)
+	}
+	documentStore, err := storage.NewEncryptedStore(store, config.APPKey)
+	if err != nil {
+		log.Fatal("cannot initialise document storage:", err)
+	}
+	auditRepo := audit.NewRepository(db)
+
+	dukcapilRepo := dukcapil.NewRepository(db)
+	dukcapilClient := dukcapil.NewClient(dukcapilRepo, config.DukcapilBaseURL)
+	dukcapilVerifier := dukcapil.NewVerifier(dukcapilClient, dukcapilRepo, rdb, config.SecretKey)
+
+	userDirectory := user.NewDirectory(db, edb, config.SecretKey)
+	userRepo := user.NewRepository(db, rdb, userDirectory)
+	userUsecase := user.NewUsecase(userRepo, rdb, dukcapilVerifier, auditRepo, config)
+	user.NewHandler(router, userUsecase, rdb, db)
 
 	roleRepo := role.NewRepository(db)
 	roleUsecase := role.NewUsecase(roleRepo)
@@ -137,30 +158,53 @@ func GinServer(config config.Config, db *sql.DB, rdb *redis.Client, edb *elastic
 	report_category.NewHandler(router, reportCategoryUsecase, rdb)
 
 	regionRepo := region.NewRepository(db)
-	regionUsecase := region.NewUsecase(regionRepo)
-	region.NewHandler(router, regionUsecase, rdb)
+	regionReconciler := region.NewReconciler(regionRepo)
+	regionUsecase := region.NewUsecase(regionRepo, regionReconciler)
+	region.NewHandler(router, regionUsecase, rdb, db)
 
 	configurationRepo := configuration.NewRepository(db)
 	configurationUsecase := configuration.NewUsecase(configurationRepo)
 	configuration.NewHandler(router, configurationUsecase, rdb)
 
 	InternalApiRepo := internal_api.NewRepository(db)
-	InternalApiUsecase := internal_api.NewUsecase(InternalApiRepo)
-	internal_api.NewHandler(router, InternalApiUsecase, rdb)
+	InternalApiUsecase := internal_api.NewUsecase(InternalApiRepo, rdb, edb, regionReconciler)
+	internal_api.NewHandler(router, InternalApiUsecase, rdb, db)
+
+	simluhRepo := simluh.NewRepository(db)
+	simluhUsecase := simluh.NewUsecase(simluhRepo, auditRepo, documentStore, rdb, config)
+	simluh.NewHandler(router, simluhUsecase, rdb, db)
+
+	identityRepo := identity.NewRepository(db)
+	identityUsecase := identity.NewUsecase(identityRepo, auditRepo, documentStore, userDirectory)
+	identity.NewHandler(router, identityUsecase, rdb, db)
+
+	fileRepo := file.NewRepository(db)
+	fileUsecase := file.NewUsecase(fileRepo, store, rdb, config)
+	file.NewHandler(router, fileUsecase, rdb, db)
+
+	emailRepo := email.NewRepository(db)
+	emailTransport, err := email.NewTransport(config, emailRepo)
+	if err != nil {
+		log.Fatal("cannot initialise email transport:", err)
+	}
+	emailUsecase := email.NewUsecase(emailRepo, auditRepo, emailTransport, config)
+	email.NewHandler(router, emailUsecase, rdb, db)
 
 	ExternalApiUsecase := external_api.NewUsecase()
 	external_api.NewHandler(router, ExternalApiUsecase, rdb)
 
-	dukcapil.NewHandler(router, rdb)
+	dukcapilUsecase := dukcapil.NewUsecase(dukcapilRepo, dukcapilVerifier)
+	dukcapil.NewHandler(router, dukcapilUsecase, rdb, db)
+
 	encdec.NewHandler(router, rdb)
 
 	router.Run(config.HTTPServerAddress)
 }
 
-func SocketServer(config config.Config) {
+func SocketServer(config config.Config, db *sql.DB, rdb *redis.Client) {
 	wsRouter := gin.Default()
 	wsRouter.Use(CORSMiddleware(config))
 
-	ws.NewHandler(wsRouter)
+	ws.NewHandler(wsRouter, db, rdb)
 	wsRouter.Run(config.WebSocketServerAddress)
 }
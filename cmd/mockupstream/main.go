@@ -0,0 +1,62 @@
+// Command mockupstream serves recorded fixtures in place of the Kementan,
+// SPLP, Dukcapil, SIMLUH and OpenWeatherMap APIs so the backend can be run
+// and exercised locally without VPN access or production credentials.
+//
+// Point the backend at it by overriding the upstream base URLs in
+// app.dev.env:
+//
+//	SIPDPS_BASE_URL=http://localhost:8090/sipdps
+//	SPLP_TOKEN_URL=http://localhost:8090/splp/oauth2/token
+//	PERBENIHAN_BASE_URL=http://localhost:8090/perbenihan
+//	SIMLUH_TRAINING_BASE_URL=http://localhost:8090/simluh-training
+//	SIMLUH_REPORT_BASE_URL=http://localhost:8090/simluh-report
+//	DUKCAPIL_BASE_URL=http://localhost:8090/dukcapil
+//	OWM_BASE_URL=http://localhost:8090/owm
+//
+// Latency, error injection and page size are set with flags and can be
+// changed while running through GET/PUT /__mock/config.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/gigaflex-co/ppt_backend/cmd/mockupstream/mock"
+)
+
+func main() {
+	var (
+		addr        = flag.String("addr", ":8090", "listen address")
+		fixturesDir = flag.String("fixtures", "", "directory of recorded fixtures overriding the embedded ones")
+		latency     = flag.Duration("latency", 0, "delay added to every response")
+		jitter      = flag.Duration("jitter", 0, "random extra delay up to this duration")
+		errorRate   = flag.Float64("error-rate", 0, "fraction of requests answered with -error-status (0..1)")
+		errorStatus = flag.Int("error-status", 503, "status code of injected errors")
+		pageSize    = flag.Int("page-size", 10, "rows per page of paged SIPDPS responses")
+		clientID    = flag.String("client-id", "mock-client", "SPLP OAuth client id")
+		secret      = flag.String("client-secret", "mock-secret", "SPLP OAuth client secret")
+		tokenTTL    = flag.Int("token-ttl", 3600, "expires_in of issued SPLP access tokens, in seconds")
+		staticToken = flag.String("static-token", "mock-static-token", "token always accepted by SIPDPS and Perbenihan, empty accepts any")
+	)
+	flag.Parse()
+
+	router := mock.NewRouter(mock.Options{
+		FixturesDir: *fixturesDir,
+		Behavior: mock.Behavior{
+			LatencyMS:   int(latency.Milliseconds()),
+			JitterMS:    int(jitter.Milliseconds()),
+			ErrorRate:   *errorRate,
+			ErrorStatus: *errorStatus,
+			PageSize:    *pageSize,
+		},
+		ClientID:     *clientID,
+		ClientSecret: *secret,
+		TokenTTL:     *tokenTTL,
+		StaticToken:  *staticToken,
+	})
+
+	log.Printf("mock upstream listening on %s", *addr)
+	if err := router.Run(*addr); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Behavior controls how an upstream answers: how slow it is, how often it
+// fails and how it pages. FailNext forces the next n requests to fail
+// regardless of ErrorRate, which makes retry paths easy to hit on demand.
+type Behavior struct {
+	LatencyMS   int     `json:"latency_ms"`
+	JitterMS    int     `json:"jitter_ms"`
+	ErrorRate   float64 `json:"error_rate"`
+	ErrorStatus int     `json:"error_status"`
+	PageSize    int     `json:"page_size"`
+	FailNext    int     `json:"fail_next"`
+}
+
+type behaviorUpdate struct {
+	Upstream string `json:"upstream"`
+	Behavior
+}
+
+// behaviorStore holds the default behavior plus per upstream overrides,
+// keyed by the route group name (sipdps, splp, perbenihan, ...).
+type behaviorStore struct {
+	mu        sync.Mutex
+	initial   Behavior
+	defaults  Behavior
+	overrides map[string]Behavior
+}
+
+func newBehaviorStore(initial Behavior) *behaviorStore {
+	return &behaviorStore{
+		initial:   initial,
+		defaults:  initial,
+		overrides: map[string]Behavior{},
+	}
+}
+
+// take returns the behavior for one request to upstream and consumes a
+// FailNext slot if one is pending.
+func (s *behaviorStore) take(upstream string) (Behavior, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.overrides[upstream]
+	if !ok {
+		b = s.defaults
+	}
+	if b.FailNext > 0 {
+		next := b
+		next.FailNext--
+		if ok {
+			s.overrides[upstream] = next
+		} else {
+			s.defaults = next
+		}
+		return b, true
+	}
+	return b, b.ErrorRate > 0 && rand.Float64() < b.ErrorRate
+}
+
+// middleware applies latency and error injection for upstream and leaves
+// the behavior in the context for handlers that page.
+func (s *behaviorStore) middleware(upstream string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		b, fail := s.take(upstream)
+
+		delay := time.Duration(b.LatencyMS) * time.Millisecond
+		if b.JitterMS > 0 {
+			delay += time.Duration(rand.Intn(b.JitterMS)) * time.Millisecond
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+		}
+
+		if fail {
+			status := b.ErrorStatus
+			if status == 0 {
+				status = http.StatusServiceUnavailable
+			}
+			c.AbortWithStatusJSON(status, gin.H{"message": "injected failure", "status": status})
+			return
+		}
+
+		c.Set("behavior", b)
+		c.Next()
+	}
+}
+
+func (s *behaviorStore) handleGet(c *gin.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{"default": s.defaults, "overrides": s.overrides})
+}
+
+// handlePut replaces the default behavior, or the override of one upstream
+// when the body names it.
+func (s *behaviorStore) handlePut(c *gin.Context) {
+	var req behaviorUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if req.ErrorRate < 0 || req.ErrorRate > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error_rate must be between 0 and 1"})
+		return
+	}
+
+	s.mu.Lock()
+	if req.Upstream == "" {
+		s.defaults = req.Behavior
+	} else {
+		s.overrides[req.Upstream] = req.Behavior
+	}
+	s.mu.Unlock()
+
+	s.handleGet(c)
+}
+
+func (s *behaviorStore) handleReset(c *gin.Context) {
+	s.mu.Lock()
+	s.defaults = s.initial
+	s.overrides = map[string]Behavior{}
+	s.mu.Unlock()
+
+	s.handleGet(c)
+}
+
+func behaviorFrom(c *gin.Context) Behavior {
+	if b, ok := c.Get("behavior"); ok {
+		return b.(Behavior)
+	}
+	return Behavior{}
+}
@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// fixtureStore reads recorded responses by name, preferring a file in the
+// override directory so real recordings can replace the embedded samples
+// without rebuilding.
+type fixtureStore struct {
+	embedded fs.FS
+	dir      string
+}
+
+func newFixtureStore(embedded fs.FS, dir string) *fixtureStore {
+	return &fixtureStore{embedded: embedded, dir: dir}
+}
+
+func (s *fixtureStore) raw(name string) ([]byte, error) {
+	if s.dir != "" {
+		data, err := os.ReadFile(filepath.Join(s.dir, name+".json"))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return fs.ReadFile(s.embedded, "fixtures/"+name+".json")
+}
+
+// load decodes fixture name into v. A missing or broken fixture is logged
+// and reported as false so the handler can answer 404 like the upstream
+// does for an unknown report.
+func (s *fixtureStore) load(name string, v interface{}) bool {
+	data, err := s.raw(name)
+	if err != nil {
+		log.Printf("fixture %s: %v", name, err)
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("fixture %s: %v", name, err)
+		return false
+	}
+	return true
+}
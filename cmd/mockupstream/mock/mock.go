@@ -0,0 +1,45 @@
+// Package mock serves recorded fixtures in place of the Kementan, SPLP,
+// Dukcapil, SIMLUH and OpenWeatherMap APIs. cmd/mockupstream runs it as a
+// standalone server; tests mount it on an httptest.Server.
+package mock
+
+import (
+	"embed"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed fixtures/*.json
+var embeddedFixtures embed.FS
+
+// Options configures a mock. FixturesDir holds recordings overriding the
+// embedded ones. Tokens issued by the SPLP token endpoint expire after
+// TokenTTL seconds; StaticToken is always accepted by SIPDPS and
+// Perbenihan, and an empty one accepts any token.
+type Options struct {
+	FixturesDir  string
+	Behavior     Behavior
+	ClientID     string
+	ClientSecret string
+	TokenTTL     int
+	StaticToken  string
+}
+
+// NewRouter returns the mock upstreams, the SPLP token endpoint and the
+// /__mock control endpoints on one router. Each upstream lives under its
+// own prefix: /sipdps, /splp, /perbenihan, /dukcapil, /simluh-training,
+// /simluh-report and /owm.
+func NewRouter(opts Options) *gin.Engine {
+	fixtures := newFixtureStore(embeddedFixtures, opts.FixturesDir)
+	behavior := newBehaviorStore(opts.Behavior)
+	tokens := newTokenStore(opts.ClientID, opts.ClientSecret, opts.TokenTTL, opts.StaticToken)
+
+	router := gin.Default()
+	router.GET("/__mock/config", behavior.handleGet)
+	router.PUT("/__mock/config", behavior.handlePut)
+	router.POST("/__mock/reset", behavior.handleReset)
+
+	registerUpstreams(router, fixtures, behavior, tokens)
+
+	return router
+}
@@ -0,0 +1,85 @@
+package mock
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// laravelPage mirrors the paginator envelope SIPDPS answers with.
+type laravelPage struct {
+	CurrentPage int                      `json:"current_page"`
+	LastPage    int                      `json:"last_page"`
+	PerPage     int                      `json:"per_page"`
+	Total       int                      `json:"total"`
+	From        int                      `json:"from"`
+	To          int                      `json:"to"`
+	Data        []map[string]interface{} `json:"data"`
+}
+
+func paginate(rows []map[string]interface{}, pageParam string, size int) laravelPage {
+	if size <= 0 {
+		size = len(rows)
+		if size == 0 {
+			size = 1
+		}
+	}
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	lastPage := (len(rows) + size - 1) / size
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	res := laravelPage{
+		CurrentPage: page,
+		LastPage:    lastPage,
+		PerPage:     size,
+		Total:       len(rows),
+		Data:        []map[string]interface{}{},
+	}
+
+	start := (page - 1) * size
+	if start >= len(rows) {
+		return res
+	}
+	end := start + size
+	if end > len(rows) {
+		end = len(rows)
+	}
+	res.From, res.To = start+1, end
+	res.Data = rows[start:end]
+	return res
+}
+
+// certificatePDF renders a one page PDF so the certificate proxy has a
+// real document to stream.
+func certificatePDF(nik, idPel string) []byte {
+	text := fmt.Sprintf("Sertifikat Pelatihan %s - NIK %s", idPel, nik)
+	stream := fmt.Sprintf("BT /F1 18 Tf 72 720 Td (%s) Tj ET", text)
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	out := []byte("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = len(out)
+		out = append(out, fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, obj)...)
+	}
+
+	xref := len(out)
+	out = append(out, fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)...)
+	for _, off := range offsets {
+		out = append(out, fmt.Sprintf("%010d 00000 n \n", off)...)
+	}
+	out = append(out, fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)...)
+	return out
+}
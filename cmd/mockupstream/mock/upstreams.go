@@ -0,0 +1,193 @@
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenStore issues and checks SPLP access tokens. Tokens expire after ttl
+// so the backend's refresh and 401 retry paths are exercised too.
+type tokenStore struct {
+	mu           sync.Mutex
+	clientID     string
+	clientSecret string
+	ttl          int
+	static       string
+	issued       map[string]time.Time
+}
+
+func newTokenStore(clientID, clientSecret string, ttl int, static string) *tokenStore {
+	return &tokenStore{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		ttl:          ttl,
+		static:       static,
+		issued:       map[string]time.Time{},
+	}
+}
+
+func (s *tokenStore) issue() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.issued[token] = time.Now().Add(time.Duration(s.ttl) * time.Second)
+	s.mu.Unlock()
+
+	return token
+}
+
+func (s *tokenStore) valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	if s.static == "" || token == s.static {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.issued[token]
+	return ok && time.Now().Before(expiry)
+}
+
+func (s *tokenStore) revoke() {
+	s.mu.Lock()
+	s.issued = map[string]time.Time{}
+	s.mu.Unlock()
+}
+
+func (s *tokenStore) handleToken(c *gin.Context) {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		id, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if c.PostForm("grant_type") != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+	if id != s.clientID || secret != s.clientSecret {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": "Client Authentication failed."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": s.issue(),
+		"token_type":   "Bearer",
+		"expires_in":   s.ttl,
+		"scope":        c.PostForm("scope"),
+	})
+}
+
+func (s *tokenStore) handleRevoke(c *gin.Context) {
+	s.revoke()
+	c.Status(http.StatusNoContent)
+}
+
+func registerUpstreams(router *gin.Engine, fixtures *fixtureStore, behavior *behaviorStore, tokens *tokenStore) {
+	router.POST("/__mock/revoke-tokens", tokens.handleRevoke)
+
+	splp := router.Group("/splp", behavior.middleware("splp"))
+	splp.POST("/oauth2/token", tokens.handleToken)
+
+	sipdps := router.Group("/sipdps", behavior.middleware("sipdps"))
+	sipdps.GET("/:report", func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !tokens.valid(token) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid Credentials"})
+			return
+		}
+
+		var rows []map[string]interface{}
+		name := "sipdps_" + strings.TrimPrefix(c.Param("report"), "data-laporan-")
+		if !fixtures.load(name, &rows) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Not Found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginate(rows, c.DefaultQuery("page", "1"), behaviorFrom(c).PageSize))
+	})
+
+	perbenihan := router.Group("/perbenihan", behavior.middleware("perbenihan"))
+	perbenihan.GET("", func(c *gin.Context) {
+		if !tokens.valid(c.Query("token")) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "token tidak valid"})
+			return
+		}
+
+		var res map[string]interface{}
+		if !fixtures.load("perbenihan_"+c.Query("rekap")+"_"+c.Query("jenis"), &res) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "data tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	})
+
+	dukcapil := router.Group("/dukcapil", behavior.middleware("dukcapil"))
+	dukcapil.POST("/api/nik_verifby_elemen.php", func(c *gin.Context) {
+		var req struct {
+			NIK string `json:"NIK"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.NIK) != 16 {
+			c.JSON(http.StatusOK, gin.H{"content": []interface{}{gin.H{"RESPON": "NIK tidak valid"}}})
+			return
+		}
+
+		var res map[string]interface{}
+		if !fixtures.load("dukcapil_verify", &res) {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "fixture missing"})
+			return
+		}
+		// NIKs ending in 0000 never match, so the rejection path can be
+		// tried without editing fixtures.
+		if strings.HasSuffix(req.NIK, "0000") {
+			if content, ok := res["content"].([]interface{}); ok {
+				for _, item := range content {
+					if row, ok := item.(map[string]interface{}); ok {
+						row["NAMA_LGKP"] = "Tidak Sesuai (42)"
+						row["NIK"] = "Tidak Sesuai"
+					}
+				}
+			}
+		}
+		c.JSON(http.StatusOK, res)
+	})
+
+	simluhTraining := router.Group("/simluh-training", behavior.middleware("simluh-training"))
+	simluhTraining.GET("/print/print_cert_penyuluh1jt.php", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/pdf", certificatePDF(c.Query("nik"), c.Query("id_pel")))
+	})
+
+	simluhReport := router.Group("/simluh-report", behavior.middleware("simluh-report"))
+	simluhReport.GET("/biodata/pelatihan_status_penyuluh_json.php", func(c *gin.Context) {
+		var rows []map[string]interface{}
+		if c.Query("nik") == "" || !fixtures.load("simluh_pelatihan", &rows) {
+			c.JSON(http.StatusOK, []interface{}{})
+			return
+		}
+		c.JSON(http.StatusOK, rows)
+	})
+
+	owm := router.Group("/owm", behavior.middleware("owm"))
+	owm.GET("/data/2.5/weather", func(c *gin.Context) {
+		if c.Query("appid") == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"cod": 401, "message": "Invalid API key."})
+			return
+		}
+
+		var res map[string]interface{}
+		if !fixtures.load("owm_weather", &res) {
+			c.JSON(http.StatusNotFound, gin.H{"cod": "404", "message": "city not found"})
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	})
+}
@@ -0,0 +1,50 @@
+// Command userindex rebuilds the Elasticsearch user directory from
+// ppt_users. Run it from the repository root, where app.env lives, after
+// the first deployment, after a change of SECRET_KEY and whenever the
+// directory has drifted from the database:
+//
+//	go run ./cmd/userindex
+//
+// The rebuild fills a new index and only then switches the alias over, so
+// search keeps working while it runs.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/gigaflex-co/ppt_backend/app/user"
+	"github.com/gigaflex-co/ppt_backend/config"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	config, err := config.LoadConfig("./.")
+	if err != nil {
+		log.Fatal("cannot load config: ", err)
+	}
+
+	psql, err := sql.Open(config.PSQLDBDriver, config.PSQLDBSource)
+	if err != nil {
+		log.Fatal("cannot connect to PostgreSQL database:", err)
+	}
+	defer psql.Close()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{config.ElasticDBAddress},
+		Username:  config.ElasticDBUser,
+		Password:  config.ElasticDBPassword,
+	})
+	if err != nil {
+		log.Fatal("cannot connect to Elastic database:", err)
+	}
+
+	count, err := user.NewDirectory(psql, es, config.SecretKey).Reindex(context.Background())
+	if err != nil {
+		log.Fatal("cannot rebuild user directory: ", err)
+	}
+
+	log.Printf("indexed %d users", count)
+}
@@ -32,6 +32,82 @@ type Config struct {
 	ElasticDBPassword      string        `mapstructure:"ELASTIC_DB_PASSWORD"`
 	ElasticIVKey           string        `mapstructure:"ELASTIC_IV_KEY"`
 	KafkaBroker            string        `mapstructure:"KAFKA_BROKER"`
+
+	// Upstream base URLs. They default to the production endpoints and are
+	// overridden to point the backend at cmd/mockupstream.
+	SIPDPSBaseURL         string `mapstructure:"SIPDPS_BASE_URL"`
+	SPLPTokenURL          string `mapstructure:"SPLP_TOKEN_URL"`
+	PerbenihanBaseURL     string `mapstructure:"PERBENIHAN_BASE_URL"`
+	SimluhTrainingBaseURL string `mapstructure:"SIMLUH_TRAINING_BASE_URL"`
+	SimluhReportBaseURL   string `mapstructure:"SIMLUH_REPORT_BASE_URL"`
+	DukcapilBaseURL       string `mapstructure:"DUKCAPIL_BASE_URL"`
+	OWMBaseURL            string `mapstructure:"OWM_BASE_URL"`
+
+	// File storage. StorageDriver is "local" or "s3"; the S3 settings also
+	// work against MinIO.
+	StorageDriver   string `mapstructure:"STORAGE_DRIVER"`
+	StorageLocalDir string `mapstructure:"STORAGE_LOCAL_DIR"`
+	S3Endpoint      string `mapstructure:"S3_ENDPOINT"`
+	S3AccessKey     string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey     string `mapstructure:"S3_SECRET_KEY"`
+	S3Bucket        string `mapstructure:"S3_BUCKET"`
+	S3Region        string `mapstructure:"S3_REGION"`
+	S3UseSSL        bool   `mapstructure:"S3_USE_SSL"`
+
+	SimluhCertificateTTL time.Duration `mapstructure:"SIMLUH_CERTIFICATE_TTL"`
+	// SimluhSyncInterval is how often training history is synced; zero
+	// disables the background sync.
+	SimluhSyncInterval time.Duration `mapstructure:"SIMLUH_SYNC_INTERVAL"`
+
+	// Uploads. FileURLTTL is how long a signed download link works;
+	// orphaned uploads older than FileOrphanGrace are deleted every
+	// FileCleanupInterval, and zero disables the background cleanup.
+	FileURLTTL          time.Duration `mapstructure:"FILE_URL_TTL"`
+	FileOrphanGrace     time.Duration `mapstructure:"FILE_ORPHAN_GRACE"`
+	FileCleanupInterval time.Duration `mapstructure:"FILE_CLEANUP_INTERVAL"`
+
+	// Email. EmailTransport is "smtp", which uses the SMTP settings in
+	// ppt_configurations, or "file", which writes .eml files into
+	// EmailFileDir. The outbox is polled every EmailPollInterval, and zero
+	// disables delivery; a message fails after EmailMaxAttempts tries.
+	EmailTransport    string        `mapstructure:"EMAIL_TRANSPORT"`
+	EmailFileDir      string        `mapstructure:"EMAIL_FILE_DIR"`
+	EmailPollInterval time.Duration `mapstructure:"EMAIL_POLL_INTERVAL"`
+	EmailMaxAttempts  int           `mapstructure:"EMAIL_MAX_ATTEMPTS"`
+}
+
+var upstreamDefaults = map[string]string{
+	"SIPDPS_BASE_URL":          "https://api-splp.layanan.go.id/t/pertanian.go.id/TP-SIPDPS/1.0/v2",
+	"SPLP_TOKEN_URL":           "https://api-splp.layanan.go.id/oauth2/token",
+	"PERBENIHAN_BASE_URL":      "https://apps.tanamanpangan.pertanian.go.id/api/perbenihan",
+	"SIMLUH_TRAINING_BASE_URL": "https://latihanonline.pertanian.go.id",
+	"SIMLUH_REPORT_BASE_URL":   "https://laporanutama.pertanian.go.id",
+	"DUKCAPIL_BASE_URL":        "http://10.1.241.250",
+	"OWM_BASE_URL":             "https://api.openweathermap.org",
+}
+
+var storageDefaults = map[string]string{
+	"STORAGE_DRIVER":    "local",
+	"STORAGE_LOCAL_DIR": "./storage",
+	"S3_BUCKET":         "ppt-backend",
+}
+
+var simluhDefaults = map[string]string{
+	"SIMLUH_CERTIFICATE_TTL": "24h",
+	"SIMLUH_SYNC_INTERVAL":   "6h",
+}
+
+var fileDefaults = map[string]string{
+	"FILE_URL_TTL":          "1h",
+	"FILE_ORPHAN_GRACE":     "24h",
+	"FILE_CLEANUP_INTERVAL": "6h",
+}
+
+var emailDefaults = map[string]string{
+	"EMAIL_TRANSPORT":     "smtp",
+	"EMAIL_FILE_DIR":      "./storage/mail",
+	"EMAIL_POLL_INTERVAL": "10s",
+	"EMAIL_MAX_ATTEMPTS":  "8",
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -40,6 +116,12 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
 
+	for _, defaults := range []map[string]string{upstreamDefaults, storageDefaults, simluhDefaults, fileDefaults, emailDefaults} {
+		for key, value := range defaults {
+			viper.SetDefault(key, value)
+		}
+	}
+
 	err = viper.ReadInConfig()
 	if err != nil {
 		return
@@ -0,0 +1,5 @@
+DROP FUNCTION IF EXISTS ppt_sync_legacy_records();
+DROP FUNCTION IF EXISTS ppt_legacy_record(ppt_api_data_storages);
+DROP TABLE IF EXISTS ppt_dataset_records;
+DROP TABLE IF EXISTS ppt_datasets;
+DROP FUNCTION IF EXISTS ppt_legacy_jsonb(TEXT, BOOLEAN);
@@ -0,0 +1,308 @@
+CREATE TABLE ppt_datasets (
+    identifier BIGINT PRIMARY KEY,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    json_schema JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- json_schema mirrors Dataset.JSONSchema; every fetch rewrites it.
+INSERT INTO ppt_datasets (identifier, slug, name, json_schema)
+VALUES
+(1,'sipdps-laporan-tanam','SIPDPS Laporan Tanam','{"$id":"ppt:dataset:sipdps-laporan-tanam","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"hst":{"title":"Hari Setelah Tanam","type":["number","null"]},"jenis_bantuan":{"title":"Jenis Bantuan","type":["string","null"]},"jenis_kelompok":{"title":"Jenis Kelompok","type":["string","null"]},"jenis_lahan":{"title":"Jenis Lahan","type":["string","null"]},"jenis_tanaman_pangan":{"title":"Jenis Tanaman Pangan","type":["string","null"]},"kategori_lahan":{"title":"Kategori Lahan","type":["string","null"]},"lat":{"title":"Latitude","type":["number","null"]},"lng":{"title":"Longitude","type":["number","null"]},"luas_area":{"title":"Luas Area (ha)","type":["number","null"]},"nip_reporter":{"title":"NIP Pelapor","type":["string","null"]},"nm_desa":{"title":"Desa","type":["string","null"]},"nm_kab":{"title":"Kabupaten/Kota","type":["string","null"]},"nm_kec":{"title":"Kecamatan","type":["string","null"]},"nm_prov":{"title":"Provinsi","type":["string","null"]},"nm_reporter":{"title":"Nama Pelapor","type":["string","null"]},"nm_varietas":{"title":"Varietas","type":["string","null"]},"photos":{"title":"Foto","type":["string","null"]},"status":{"title":"Status","type":["string","null"]},"sumber_bantuan":{"title":"Sumber Bantuan","type":["string","null"]},"tahun_bantuan":{"title":"Tahun Bantuan","type":["number","null"]},"tgl_kunjungan":{"format":"date","title":"Tanggal Kunjungan","type":["string","null"]},"tgl_lapor":{"format":"date","title":"Tanggal Laporan","type":["string","null"]}},"title":"SIPDPS Laporan Tanam","type":"object"}'),
+(2,'sipdps-laporan-produktivitas','SIPDPS Laporan Produktivitas','{"$id":"ppt:dataset:sipdps-laporan-produktivitas","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"jenis_lahan":{"title":"Jenis Lahan","type":["string","null"]},"jenis_tanaman_pangan":{"title":"Jenis Tanaman Pangan","type":["string","null"]},"jumlah":{"title":"Produktivitas (ku/ha)","type":["number","null"]},"kategori_lahan":{"title":"Kategori Lahan","type":["string","null"]},"lat":{"title":"Latitude","type":["number","null"]},"lng":{"title":"Longitude","type":["number","null"]},"nip_reporter":{"title":"NIP Pelapor","type":["string","null"]},"nm_desa":{"title":"Desa","type":["string","null"]},"nm_kab":{"title":"Kabupaten/Kota","type":["string","null"]},"nm_kec":{"title":"Kecamatan","type":["string","null"]},"nm_prov":{"title":"Provinsi","type":["string","null"]},"nm_reporter":{"title":"Nama Pelapor","type":["string","null"]},"nm_verifikator":{"title":"Verifikator","type":["string","null"]},"photos":{"title":"Foto","type":["string","null"]},"status":{"title":"Status","type":["string","null"]},"teknik_pengukuran":{"title":"Teknik Pengukuran","type":["string","null"]},"tgl_kunjungan":{"format":"date","title":"Tanggal Kunjungan","type":["string","null"]},"tgl_lapor":{"format":"date","title":"Tanggal Laporan","type":["string","null"]}},"title":"SIPDPS Laporan Produktivitas","type":"object"}'),
+(3,'sipdps-laporan-puso','SIPDPS Laporan Puso','{"$id":"ppt:dataset:sipdps-laporan-puso","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"jenis_tanaman_pangan":{"title":"Jenis Tanaman Pangan","type":["string","null"]},"lat":{"title":"Latitude","type":["number","null"]},"lng":{"title":"Longitude","type":["number","null"]},"nip_reporter":{"title":"NIP Pelapor","type":["string","null"]},"nm_desa":{"title":"Desa","type":["string","null"]},"nm_kab":{"title":"Kabupaten/Kota","type":["string","null"]},"nm_kec":{"title":"Kecamatan","type":["string","null"]},"nm_prov":{"title":"Provinsi","type":["string","null"]},"nm_reporter":{"title":"Nama Pelapor","type":["string","null"]},"nm_verifikator":{"title":"Verifikator","type":["string","null"]},"penyebab_puso":{"title":"Penyebab Puso","type":["string","null"]},"photos":{"title":"Foto","type":["string","null"]},"status":{"title":"Status","type":["string","null"]},"tgl_kejadian":{"format":"date","title":"Tanggal Kejadian","type":["string","null"]},"tgl_lapor":{"format":"date","title":"Tanggal Laporan","type":["string","null"]}},"title":"SIPDPS Laporan Puso","type":"object"}'),
+(4,'sipdps-laporan-panen','SIPDPS Laporan Panen','{"$id":"ppt:dataset:sipdps-laporan-panen","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"jenis_tanaman_pangan":{"title":"Jenis Tanaman Pangan","type":["string","null"]},"kategori_pengelola":{"title":"Kategori Pengelola","type":["string","null"]},"lat":{"title":"Latitude","type":["number","null"]},"lng":{"title":"Longitude","type":["number","null"]},"luas":{"title":"Luas Panen (ha)","type":["number","null"]},"nama_pengelola":{"title":"Nama Pengelola","type":["string","null"]},"nip_reporter":{"title":"NIP Pelapor","type":["string","null"]},"nm_desa":{"title":"Desa","type":["string","null"]},"nm_kab":{"title":"Kabupaten/Kota","type":["string","null"]},"nm_kec":{"title":"Kecamatan","type":["string","null"]},"nm_prov":{"title":"Provinsi","type":["string","null"]},"nm_reporter":{"title":"Nama Pelapor","type":["string","null"]},"nm_varietas":{"title":"Varietas","type":["string","null"]},"nm_verifikator":{"title":"Verifikator","type":["string","null"]},"perkiraan":{"title":"Perkiraan Hasil (ton)","type":["number","null"]},"photos":{"title":"Foto","type":["string","null"]},"status":{"title":"Status","type":["string","null"]},"tgl_kunjungan":{"format":"date","title":"Tanggal Kunjungan","type":["string","null"]},"tgl_lapor":{"format":"date","title":"Tanggal Laporan","type":["string","null"]}},"title":"SIPDPS Laporan Panen","type":"object"}'),
+(5,'perbenihan-produsen','Perbenihan Produsen','{"$id":"ppt:dataset:perbenihan-produsen","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"ALAMAT_PIMPINAN":{"title":"Alamat Pimpinan","type":["string","null"]},"ALAMAT_PRODUSEN":{"title":"Alamat Produsen","type":["string","null"]},"BENIH":{"title":"Benih","type":["string","null"]},"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"EMAIL":{"title":"Email","type":["string","null"]},"IDSIMLUH":{"title":"ID SIMLUH","type":["string","null"]},"KABUPATENKOTA":{"title":"Kabupaten/Kota","type":["string","null"]},"KECAMATAN":{"title":"Kecamatan","type":["string","null"]},"KELURAHAN":{"title":"Kelurahan","type":["string","null"]},"KODE_PROVINSI":{"title":"Kode Provinsi","type":["string","null"]},"LAT":{"title":"Latitude","type":["number","null"]},"LNG":{"title":"Longitude","type":["number","null"]},"NAMA":{"title":"Nama Produsen","type":["string","null"]},"NAMA_PIMPINAN":{"title":"Nama Pimpinan","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"NOMOR_REGISTRASI":{"title":"Nomor Registrasi","type":["string","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"TELEPON":{"title":"Telepon","type":["string","null"]},"TIPE_PRODUSEN":{"title":"Tipe Produsen","type":["string","null"]},"TOTAL_LUAS_LAHAN":{"title":"Total Luas Lahan (ha)","type":["number","null"]},"USERNAME":{"title":"Username","type":["string","null"]}},"title":"Perbenihan Produsen","type":"object"}'),
+(6,'perbenihan-rek-nas','Perbenihan Rekapitulasi Nasional','{"$id":"ppt:dataset:perbenihan-rek-nas","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"JENIS":{"title":"Jenis","type":["string","null"]},"JENIS_BENIH":{"title":"Jenis Benih","type":["string","null"]},"KELAS_BENIH":{"title":"Kelas Benih","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"REALISASI_LUAS":{"title":"Realisasi Luas (ha)","type":["number","null"]},"REALISASI_PRODUKSI":{"title":"Realisasi Produksi (ton)","type":["number","null"]},"VARIETAS":{"title":"Varietas","type":["string","null"]},"VOLUME":{"title":"Volume (ton)","type":["number","null"]}},"title":"Perbenihan Rekapitulasi Nasional","type":"object"}'),
+(7,'perbenihan-rek-bpsb','Perbenihan Rekapitulasi BPSB','{"$id":"ppt:dataset:perbenihan-rek-bpsb","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"JENIS":{"title":"Jenis","type":["string","null"]},"JENIS_BENIH":{"title":"Jenis Benih","type":["string","null"]},"KELAS_BENIH":{"title":"Kelas Benih","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"REALISASI_LUAS":{"title":"Realisasi Luas (ha)","type":["number","null"]},"REALISASI_PRODUKSI":{"title":"Realisasi Produksi (ton)","type":["number","null"]},"VARIETAS":{"title":"Varietas","type":["string","null"]},"VOLUME":{"title":"Volume (ton)","type":["number","null"]}},"title":"Perbenihan Rekapitulasi BPSB","type":"object"}'),
+(8,'perbenihan-rek-lssm','Perbenihan Rekapitulasi LSSM','{"$id":"ppt:dataset:perbenihan-rek-lssm","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"JENIS":{"title":"Jenis","type":["string","null"]},"JENIS_BENIH":{"title":"Jenis Benih","type":["string","null"]},"KELAS_BENIH":{"title":"Kelas Benih","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"REALISASI_LUAS":{"title":"Realisasi Luas (ha)","type":["number","null"]},"REALISASI_PRODUKSI":{"title":"Realisasi Produksi (ton)","type":["number","null"]},"VARIETAS":{"title":"Varietas","type":["string","null"]},"VOLUME":{"title":"Volume (ton)","type":["number","null"]}},"title":"Perbenihan Rekapitulasi LSSM","type":"object"}'),
+(9,'perbenihan-rek-penyaluran','Perbenihan Rekapitulasi Penyaluran','{"$id":"ppt:dataset:perbenihan-rek-penyaluran","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"APBD":{"title":"APBD (ton)","type":["number","null"]},"APBN":{"title":"APBN (ton)","type":["number","null"]},"BULAN":{"title":"Bulan","type":["number","null"]},"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"FREE_MARKET":{"title":"Pasar Bebas (ton)","type":["number","null"]},"JUMLAH_SALUR":{"title":"Jumlah Salur (ton)","type":["number","null"]},"JUMLAH_STOK":{"title":"Jumlah Stok (ton)","type":["number","null"]},"KABUPATENKOTA":{"title":"Kabupaten/Kota","type":["string","null"]},"KECAMATAN":{"title":"Kecamatan","type":["string","null"]},"KELAS_BENIH":{"title":"Kelas Benih","type":["string","null"]},"KOMODITI":{"title":"Komoditas","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"PENGADAAN":{"title":"Pengadaan (ton)","type":["number","null"]},"PENYALURAN":{"title":"Penyaluran (ton)","type":["number","null"]},"PRODUKSI_BENIH":{"title":"Produksi Benih (ton)","type":["number","null"]},"PRODUSEN_BENIH":{"title":"Produsen Benih","type":["string","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"SISA_STOK":{"title":"Sisa Stok (ton)","type":["number","null"]},"STOK_LALU":{"title":"Stok Bulan Lalu (ton)","type":["number","null"]},"TAHUN":{"title":"Tahun","type":["number","null"]},"TOTAL":{"title":"Total (ton)","type":["number","null"]},"VARIETAS":{"title":"Varietas","type":["string","null"]}},"title":"Perbenihan Rekapitulasi Penyaluran","type":"object"}'),
+(10,'perbenihan-rek-penyebaran','Perbenihan Rekapitulasi Penyebaran','{"$id":"ppt:dataset:perbenihan-rek-penyebaran","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"BENIH":{"title":"Benih","type":["string","null"]},"BULAN":{"title":"Bulan","type":["number","null"]},"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"JENIS_BENIH":{"title":"Jenis Benih","type":["string","null"]},"KABUPATENKOTA":{"title":"Kabupaten/Kota","type":["string","null"]},"KECAMATAN":{"title":"Kecamatan","type":["string","null"]},"KELURAHAN":{"title":"Kelurahan","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"PETA":{"title":"Peta","type":["string","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"REALISASI_TANAM_LUAS":{"title":"Realisasi Luas Tanam (ha)","type":["number","null"]},"TAHUN":{"title":"Tahun","type":["number","null"]},"TOTAL_LUAS":{"title":"Total Luas (ha)","type":["number","null"]},"VARIETAS":{"title":"Varietas","type":["string","null"]}},"title":"Perbenihan Rekapitulasi Penyebaran","type":"object"}'),
+(11,'perbenihan-rek-produsen','Perbenihan Rekapitulasi Produsen','{"$id":"ppt:dataset:perbenihan-rek-produsen","$schema":"http://json-schema.org/draft-07/schema#","additionalProperties":false,"properties":{"ALAMAT_PIMPINAN":{"title":"Alamat Pimpinan","type":["string","null"]},"ALAMAT_PRODUSEN":{"title":"Alamat Produsen","type":["string","null"]},"BENIH":{"title":"Benih","type":["string","null"]},"DICATAT":{"format":"date-time","title":"Dicatat","type":["string","null"]},"DIPERBARUI":{"format":"date-time","title":"Diperbarui","type":["string","null"]},"EMAIL":{"title":"Email","type":["string","null"]},"IDSIMLUH":{"title":"ID SIMLUH","type":["string","null"]},"KABUPATENKOTA":{"title":"Kabupaten/Kota","type":["string","null"]},"KECAMATAN":{"title":"Kecamatan","type":["string","null"]},"KELURAHAN":{"title":"Kelurahan","type":["string","null"]},"KODE_PROVINSI":{"title":"Kode Provinsi","type":["string","null"]},"LAT":{"title":"Latitude","type":["number","null"]},"LNG":{"title":"Longitude","type":["number","null"]},"NAMA":{"title":"Nama Produsen","type":["string","null"]},"NAMA_PIMPINAN":{"title":"Nama Pimpinan","type":["string","null"]},"NO":{"title":"No","type":["number","null"]},"NOMOR_REGISTRASI":{"title":"Nomor Registrasi","type":["string","null"]},"PROVINSI":{"title":"Provinsi","type":["string","null"]},"TELEPON":{"title":"Telepon","type":["string","null"]},"TIPE_PRODUSEN":{"title":"Tipe Produsen","type":["string","null"]},"TOTAL_LUAS_LAHAN":{"title":"Total Luas Lahan (ha)","type":["number","null"]},"USERNAME":{"title":"Username","type":["string","null"]}},"title":"Perbenihan Rekapitulasi Produsen","type":"object"}');
+
+CREATE TABLE ppt_dataset_records (
+    id BIGSERIAL PRIMARY KEY,
+    identifier BIGINT NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_dataset_records" ("identifier");
+ALTER TABLE "ppt_dataset_records" ADD FOREIGN KEY ("identifier") REFERENCES "ppt_datasets" ("identifier");
+CREATE INDEX ON "ppt_dataset_records" USING GIN ("data" jsonb_path_ops);
+
+-- SIPDPS: region, date and commodity lookups
+CREATE INDEX ppt_dataset_records_sipdps_region_idx ON ppt_dataset_records ((data->>'nm_prov'), (data->>'nm_kab'), (data->>'nm_kec')) WHERE identifier BETWEEN 1 AND 4;
+CREATE INDEX ppt_dataset_records_sipdps_tgl_lapor_idx ON ppt_dataset_records ((data->>'tgl_lapor')) WHERE identifier BETWEEN 1 AND 4;
+CREATE INDEX ppt_dataset_records_sipdps_komoditas_idx ON ppt_dataset_records ((data->>'jenis_tanaman_pangan')) WHERE identifier BETWEEN 1 AND 4;
+-- Perbenihan: region and variety lookups
+CREATE INDEX ppt_dataset_records_perbenihan_region_idx ON ppt_dataset_records ((data->>'PROVINSI'), (data->>'KABUPATENKOTA')) WHERE identifier BETWEEN 5 AND 11;
+CREATE INDEX ppt_dataset_records_perbenihan_varietas_idx ON ppt_dataset_records ((data->>'VARIETAS')) WHERE identifier BETWEEN 5 AND 11;
+CREATE INDEX ppt_dataset_records_perbenihan_diperbarui_idx ON ppt_dataset_records ((data->>'DIPERBARUI')) WHERE identifier BETWEEN 5 AND 11;
+
+-- Converts a legacy VARCHAR value into the JSONB value the application writes:
+-- empty strings become null and numeric strings become numbers.
+CREATE FUNCTION ppt_legacy_jsonb(v TEXT, numeric_type BOOLEAN) RETURNS JSONB AS $$
+BEGIN
+    IF v IS NULL OR btrim(v) = '' THEN
+        RETURN 'null'::jsonb;
+    END IF;
+    IF numeric_type AND btrim(v) ~ '^-?[0-9]+(\.[0-9]+)?$' THEN
+        RETURN to_jsonb(btrim(v)::numeric);
+    END IF;
+    RETURN to_jsonb(btrim(v));
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Maps a legacy row onto the document the application writes for its
+-- dataset.
+CREATE FUNCTION ppt_legacy_record(s ppt_api_data_storages) RETURNS JSONB AS $$
+SELECT CASE s.identifier
+    WHEN 1 THEN jsonb_build_object(
+        'nip_reporter', ppt_legacy_jsonb(s.f1, false),
+        'nm_reporter', ppt_legacy_jsonb(s.f2, false),
+        'tgl_lapor', ppt_legacy_jsonb(s.f3, false),
+        'tgl_kunjungan', ppt_legacy_jsonb(s.f4, false),
+        'jenis_kelompok', ppt_legacy_jsonb(s.f5, false),
+        'nm_prov', ppt_legacy_jsonb(s.f6, false),
+        'nm_kab', ppt_legacy_jsonb(s.f7, false),
+        'nm_kec', ppt_legacy_jsonb(s.f8, false),
+        'nm_desa', ppt_legacy_jsonb(s.f9, false),
+        'kategori_lahan', ppt_legacy_jsonb(s.f10, false),
+        'jenis_lahan', ppt_legacy_jsonb(s.f11, false),
+        'jenis_tanaman_pangan', ppt_legacy_jsonb(s.f12, false),
+        'nm_varietas', ppt_legacy_jsonb(s.f13, false),
+        'jenis_bantuan', ppt_legacy_jsonb(s.f14, false),
+        'sumber_bantuan', ppt_legacy_jsonb(s.f15, false),
+        'tahun_bantuan', ppt_legacy_jsonb(s.f16, true),
+        'luas_area', ppt_legacy_jsonb(s.f17, true),
+        'hst', ppt_legacy_jsonb(s.f18, true),
+        'lat', ppt_legacy_jsonb(s.f19, true),
+        'lng', ppt_legacy_jsonb(s.f20, true),
+        'photos', ppt_legacy_jsonb(s.f21, false),
+        'status', ppt_legacy_jsonb(s.f22, false)
+    )
+    WHEN 2 THEN jsonb_build_object(
+        'nip_reporter', ppt_legacy_jsonb(s.f1, false),
+        'nm_reporter', ppt_legacy_jsonb(s.f2, false),
+        'tgl_lapor', ppt_legacy_jsonb(s.f3, false),
+        'tgl_kunjungan', ppt_legacy_jsonb(s.f4, false),
+        'nm_prov', ppt_legacy_jsonb(s.f5, false),
+        'nm_kab', ppt_legacy_jsonb(s.f6, false),
+        'nm_kec', ppt_legacy_jsonb(s.f7, false),
+        'nm_desa', ppt_legacy_jsonb(s.f8, false),
+        'kategori_lahan', ppt_legacy_jsonb(s.f9, false),
+        'jenis_lahan', ppt_legacy_jsonb(s.f10, false),
+        'jenis_tanaman_pangan', ppt_legacy_jsonb(s.f11, false),
+        'teknik_pengukuran', ppt_legacy_jsonb(s.f12, false),
+        'jumlah', ppt_legacy_jsonb(s.f13, true),
+        'lat', ppt_legacy_jsonb(s.f14, true),
+        'lng', ppt_legacy_jsonb(s.f15, true),
+        'photos', ppt_legacy_jsonb(s.f16, false),
+        'nm_verifikator', ppt_legacy_jsonb(s.f17, false),
+        'status', ppt_legacy_jsonb(s.f18, false)
+    )
+    WHEN 3 THEN jsonb_build_object(
+        'nip_reporter', ppt_legacy_jsonb(s.f1, false),
+        'nm_reporter', ppt_legacy_jsonb(s.f2, false),
+        'tgl_lapor', ppt_legacy_jsonb(s.f3, false),
+        'tgl_kejadian', ppt_legacy_jsonb(s.f4, false),
+        'nm_prov', ppt_legacy_jsonb(s.f5, false),
+        'nm_kab', ppt_legacy_jsonb(s.f6, false),
+        'nm_kec', ppt_legacy_jsonb(s.f7, false),
+        'nm_desa', ppt_legacy_jsonb(s.f8, false),
+        'jenis_tanaman_pangan', ppt_legacy_jsonb(s.f9, false),
+        'penyebab_puso', ppt_legacy_jsonb(s.f10, false),
+        'lat', ppt_legacy_jsonb(s.f11, true),
+        'lng', ppt_legacy_jsonb(s.f12, true),
+        'photos', ppt_legacy_jsonb(s.f13, false),
+        'nm_verifikator', ppt_legacy_jsonb(s.f14, false),
+        'status', ppt_legacy_jsonb(s.f15, false)
+    )
+    WHEN 4 THEN jsonb_build_object(
+        'nip_reporter', ppt_legacy_jsonb(s.f1, false),
+        'nm_reporter', ppt_legacy_jsonb(s.f2, false),
+        'tgl_lapor', ppt_legacy_jsonb(s.f3, false),
+        'tgl_kunjungan', ppt_legacy_jsonb(s.f4, false),
+        'nm_prov', ppt_legacy_jsonb(s.f5, false),
+        'nm_kab', ppt_legacy_jsonb(s.f6, false),
+        'nm_kec', ppt_legacy_jsonb(s.f7, false),
+        'nm_desa', ppt_legacy_jsonb(s.f8, false),
+        'jenis_tanaman_pangan', ppt_legacy_jsonb(s.f9, false),
+        'nm_varietas', ppt_legacy_jsonb(s.f10, false),
+        'kategori_pengelola', ppt_legacy_jsonb(s.f11, false),
+        'nama_pengelola', ppt_legacy_jsonb(s.f12, false),
+        'luas', ppt_legacy_jsonb(s.f13, true),
+        'perkiraan', ppt_legacy_jsonb(s.f14, true),
+        'lat', ppt_legacy_jsonb(s.f15, true),
+        'lng', ppt_legacy_jsonb(s.f16, true),
+        'photos', ppt_legacy_jsonb(s.f17, false),
+        'nm_verifikator', ppt_legacy_jsonb(s.f18, false),
+        'status', ppt_legacy_jsonb(s.f19, false)
+    )
+    WHEN 5 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'KODE_PROVINSI', ppt_legacy_jsonb(s.f2, false),
+        'PROVINSI', ppt_legacy_jsonb(s.f3, false),
+        'KABUPATENKOTA', ppt_legacy_jsonb(s.f4, false),
+        'KECAMATAN', ppt_legacy_jsonb(s.f5, false),
+        'KELURAHAN', ppt_legacy_jsonb(s.f6, false),
+        'USERNAME', ppt_legacy_jsonb(s.f7, false),
+        'IDSIMLUH', ppt_legacy_jsonb(s.f8, false),
+        'NOMOR_REGISTRASI', ppt_legacy_jsonb(s.f9, false),
+        'TIPE_PRODUSEN', ppt_legacy_jsonb(s.f10, false),
+        'NAMA', ppt_legacy_jsonb(s.f11, false),
+        'NAMA_PIMPINAN', ppt_legacy_jsonb(s.f12, false),
+        'ALAMAT_PIMPINAN', ppt_legacy_jsonb(s.f13, false),
+        'ALAMAT_PRODUSEN', ppt_legacy_jsonb(s.f14, false),
+        'TELEPON', ppt_legacy_jsonb(s.f15, false),
+        'EMAIL', ppt_legacy_jsonb(s.f16, false),
+        'BENIH', ppt_legacy_jsonb(s.f17, false),
+        'TOTAL_LUAS_LAHAN', ppt_legacy_jsonb(s.f18, true),
+        'LAT', ppt_legacy_jsonb(s.f19, true),
+        'LNG', ppt_legacy_jsonb(s.f20, true),
+        'DICATAT', ppt_legacy_jsonb(s.f21, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f22, false)
+    )
+    WHEN 6 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'JENIS', ppt_legacy_jsonb(s.f2, false),
+        'PROVINSI', ppt_legacy_jsonb(s.f3, false),
+        'JENIS_BENIH', ppt_legacy_jsonb(s.f4, false),
+        'KELAS_BENIH', ppt_legacy_jsonb(s.f5, false),
+        'VARIETAS', ppt_legacy_jsonb(s.f6, false),
+        'REALISASI_LUAS', ppt_legacy_jsonb(s.f7, true),
+        'REALISASI_PRODUKSI', ppt_legacy_jsonb(s.f8, true),
+        'VOLUME', ppt_legacy_jsonb(s.f9, true),
+        'DICATAT', ppt_legacy_jsonb(s.f10, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f11, false)
+    )
+    WHEN 7 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'JENIS', ppt_legacy_jsonb(s.f2, false),
+        'PROVINSI', ppt_legacy_jsonb(s.f3, false),
+        'JENIS_BENIH', ppt_legacy_jsonb(s.f4, false),
+        'KELAS_BENIH', ppt_legacy_jsonb(s.f5, false),
+        'VARIETAS', ppt_legacy_jsonb(s.f6, false),
+        'REALISASI_LUAS', ppt_legacy_jsonb(s.f7, true),
+        'REALISASI_PRODUKSI', ppt_legacy_jsonb(s.f8, true),
+        'VOLUME', ppt_legacy_jsonb(s.f9, true),
+        'DICATAT', ppt_legacy_jsonb(s.f10, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f11, false)
+    )
+    WHEN 8 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'JENIS', ppt_legacy_jsonb(s.f2, false),
+        'PROVINSI', ppt_legacy_jsonb(s.f3, false),
+        'JENIS_BENIH', ppt_legacy_jsonb(s.f4, false),
+        'KELAS_BENIH', ppt_legacy_jsonb(s.f5, false),
+        'VARIETAS', ppt_legacy_jsonb(s.f6, false),
+        'REALISASI_LUAS', ppt_legacy_jsonb(s.f7, true),
+        'REALISASI_PRODUKSI', ppt_legacy_jsonb(s.f8, true),
+        'VOLUME', ppt_legacy_jsonb(s.f9, true),
+        'DICATAT', ppt_legacy_jsonb(s.f10, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f11, false)
+    )
+    WHEN 9 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'TAHUN', ppt_legacy_jsonb(s.f2, true),
+        'BULAN', ppt_legacy_jsonb(s.f3, true),
+        'PROVINSI', ppt_legacy_jsonb(s.f4, false),
+        'KABUPATENKOTA', ppt_legacy_jsonb(s.f5, false),
+        'KECAMATAN', ppt_legacy_jsonb(s.f6, false),
+        'PRODUSEN_BENIH', ppt_legacy_jsonb(s.f7, false),
+        'KELAS_BENIH', ppt_legacy_jsonb(s.f8, false),
+        'KOMODITI', ppt_legacy_jsonb(s.f9, false),
+        'VARIETAS', ppt_legacy_jsonb(s.f10, false),
+        'STOK_LALU', ppt_legacy_jsonb(s.f11, true),
+        'PRODUKSI_BENIH', ppt_legacy_jsonb(s.f12, true),
+        'PENGADAAN', ppt_legacy_jsonb(s.f13, true),
+        'JUMLAH_STOK', ppt_legacy_jsonb(s.f14, true),
+        'PENYALURAN', ppt_legacy_jsonb(s.f15, true),
+        'APBN', ppt_legacy_jsonb(s.f16, true),
+        'APBD', ppt_legacy_jsonb(s.f17, true),
+        'FREE_MARKET', ppt_legacy_jsonb(s.f18, true),
+        'JUMLAH_SALUR', ppt_legacy_jsonb(s.f19, true),
+        'TOTAL', ppt_legacy_jsonb(s.f20, true),
+        'SISA_STOK', ppt_legacy_jsonb(s.f21, true),
+        'DICATAT', ppt_legacy_jsonb(s.f22, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f23, false)
+    )
+    WHEN 10 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'TAHUN', ppt_legacy_jsonb(s.f2, true),
+        'BULAN', ppt_legacy_jsonb(s.f3, true),
+        'PROVINSI', ppt_legacy_jsonb(s.f4, false),
+        'KABUPATENKOTA', ppt_legacy_jsonb(s.f5, false),
+        'KECAMATAN', ppt_legacy_jsonb(s.f6, false),
+        'KELURAHAN', ppt_legacy_jsonb(s.f7, false),
+        'PETA', ppt_legacy_jsonb(s.f8, false),
+        'REALISASI_TANAM_LUAS', ppt_legacy_jsonb(s.f9, true),
+        'BENIH', ppt_legacy_jsonb(s.f10, false),
+        'JENIS_BENIH', ppt_legacy_jsonb(s.f11, false),
+        'VARIETAS', ppt_legacy_jsonb(s.f12, false),
+        'TOTAL_LUAS', ppt_legacy_jsonb(s.f13, true),
+        'DICATAT', ppt_legacy_jsonb(s.f14, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f15, false)
+    )
+    WHEN 11 THEN jsonb_build_object(
+        'NO', ppt_legacy_jsonb(s.f1, true),
+        'KODE_PROVINSI', ppt_legacy_jsonb(s.f2, false),
+        'PROVINSI', ppt_legacy_jsonb(s.f3, false),
+        'KABUPATENKOTA', ppt_legacy_jsonb(s.f4, false),
+        'KECAMATAN', ppt_legacy_jsonb(s.f5, false),
+        'KELURAHAN', ppt_legacy_jsonb(s.f6, false),
+        'USERNAME', ppt_legacy_jsonb(s.f7, false),
+        'IDSIMLUH', ppt_legacy_jsonb(s.f8, false),
+        'NOMOR_REGISTRASI', ppt_legacy_jsonb(s.f9, false),
+        'TIPE_PRODUSEN', ppt_legacy_jsonb(s.f10, false),
+        'NAMA', ppt_legacy_jsonb(s.f11, false),
+        'NAMA_PIMPINAN', ppt_legacy_jsonb(s.f12, false),
+        'ALAMAT_PIMPINAN', ppt_legacy_jsonb(s.f13, false),
+        'ALAMAT_PRODUSEN', ppt_legacy_jsonb(s.f14, false),
+        'TELEPON', ppt_legacy_jsonb(s.f15, false),
+        'EMAIL', ppt_legacy_jsonb(s.f16, false),
+        'BENIH', ppt_legacy_jsonb(s.f17, false),
+        'TOTAL_LUAS_LAHAN', ppt_legacy_jsonb(s.f18, true),
+        'LAT', ppt_legacy_jsonb(s.f19, true),
+        'LNG', ppt_legacy_jsonb(s.f20, true),
+        'DICATAT', ppt_legacy_jsonb(s.f21, false),
+        'DIPERBARUI', ppt_legacy_jsonb(s.f22, false)
+    )
+END;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Copies ppt_api_data_storages into ppt_dataset_records for every dataset the
+-- legacy table holds newer rows for, replacing that dataset's records the
+-- same way a fetch does. The legacy table is left untouched so the previous
+-- release keeps working while this one rolls out; a fetch it makes after
+-- this migration is picked up by running
+--     SELECT ppt_sync_legacy_records();
+-- again once the previous release is stopped. Copied rows get their
+-- region_kode on the next fetch. It returns the number of datasets copied.
+CREATE FUNCTION ppt_sync_legacy_records() RETURNS INTEGER AS $$
+DECLARE
+    ds RECORD;
+    copied INTEGER := 0;
+BEGIN
+    FOR ds IN
+        SELECT d.identifier FROM ppt_datasets AS d
+        WHERE (SELECT MAX(s.created_at) FROM ppt_api_data_storages AS s WHERE s.identifier = d.identifier)
+            > COALESCE((SELECT MAX(r.created_at) FROM ppt_dataset_records AS r WHERE r.identifier = d.identifier), '-infinity')
+    LOOP
+        DELETE FROM ppt_dataset_records WHERE identifier = ds.identifier;
+        INSERT INTO ppt_dataset_records (identifier, data, created_at, updated_at)
+        SELECT s.identifier, ppt_legacy_record(s), s.created_at, s.updated_at
+        FROM ppt_api_data_storages AS s
+        WHERE s.identifier = ds.identifier
+        ORDER BY s.id;
+        copied := copied + 1;
+    END LOOP;
+    RETURN copied;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT ppt_sync_legacy_records();
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS ppt_dataset_records_sipdps_region_lower_idx;
+DROP INDEX IF EXISTS ppt_dataset_records_sipdps_komoditas_lower_idx;
+DROP INDEX IF EXISTS ppt_dataset_records_sipdps_tgl_lapor_day_idx;
+DROP INDEX IF EXISTS ppt_dataset_records_perbenihan_region_lower_idx;
+DROP INDEX IF EXISTS ppt_dataset_records_perbenihan_varietas_lower_idx;
+DROP INDEX IF EXISTS ppt_dataset_records_perbenihan_dicatat_day_idx;
@@ -0,0 +1,9 @@
+-- The dataset query API matches region, commodity and variety filters case
+-- insensitively, which the raw expression indexes cannot serve.
+CREATE INDEX ppt_dataset_records_sipdps_region_lower_idx ON ppt_dataset_records ((lower(data->>'nm_prov')), (lower(data->>'nm_kab')), (lower(data->>'nm_kec'))) WHERE identifier BETWEEN 1 AND 4;
+CREATE INDEX ppt_dataset_records_sipdps_komoditas_lower_idx ON ppt_dataset_records ((lower(data->>'jenis_tanaman_pangan'))) WHERE identifier BETWEEN 1 AND 4;
+CREATE INDEX ppt_dataset_records_sipdps_tgl_lapor_day_idx ON ppt_dataset_records ((left(data->>'tgl_lapor', 10))) WHERE identifier BETWEEN 1 AND 4;
+
+CREATE INDEX ppt_dataset_records_perbenihan_region_lower_idx ON ppt_dataset_records ((lower(data->>'PROVINSI')), (lower(data->>'KABUPATENKOTA')), (lower(data->>'KECAMATAN'))) WHERE identifier BETWEEN 5 AND 11;
+CREATE INDEX ppt_dataset_records_perbenihan_varietas_lower_idx ON ppt_dataset_records ((lower(data->>'VARIETAS'))) WHERE identifier BETWEEN 5 AND 11;
+CREATE INDEX ppt_dataset_records_perbenihan_dicatat_day_idx ON ppt_dataset_records ((left(data->>'DICATAT', 10))) WHERE identifier BETWEEN 5 AND 11;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ppt_dataset_quarantine;
+DROP TABLE IF EXISTS ppt_dataset_quality_reports;
+DROP TABLE IF EXISTS ppt_varieties;
@@ -0,0 +1,41 @@
+-- Reference list of seed varieties per commodity. A commodity without any
+-- entry is not checked, so the list can be filled in gradually.
+CREATE TABLE ppt_varieties (
+    id BIGSERIAL PRIMARY KEY,
+    commodity VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX ON "ppt_varieties" (lower("commodity"), lower("name"));
+
+CREATE TABLE ppt_dataset_quality_reports (
+    id BIGSERIAL PRIMARY KEY,
+    job_id VARCHAR(64) NULL,
+    identifier BIGINT NOT NULL,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    valid_rows INTEGER NOT NULL DEFAULT 0,
+    quarantined_rows INTEGER NOT NULL DEFAULT 0,
+    violations JSONB NOT NULL DEFAULT '{}'::jsonb,
+    reviewed_by VARCHAR(255) NULL,
+    reviewed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_dataset_quality_reports" ("identifier", "created_at");
+ALTER TABLE "ppt_dataset_quality_reports" ADD FOREIGN KEY ("identifier") REFERENCES "ppt_datasets" ("identifier");
+
+CREATE TABLE ppt_dataset_quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL,
+    identifier BIGINT NOT NULL,
+    data JSONB NOT NULL,
+    violations JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_dataset_quarantine" ("report_id");
+ALTER TABLE "ppt_dataset_quarantine" ADD FOREIGN KEY ("report_id") REFERENCES "ppt_dataset_quality_reports" ("id") ON DELETE CASCADE;
+CREATE INDEX ON "ppt_dataset_quarantine" ("identifier");
+ALTER TABLE "ppt_dataset_quarantine" ADD FOREIGN KEY ("identifier") REFERENCES "ppt_datasets" ("identifier");
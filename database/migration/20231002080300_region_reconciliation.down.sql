@@ -0,0 +1,3 @@
+ALTER TABLE ppt_dataset_records DROP COLUMN IF EXISTS region_kode;
+DROP INDEX IF EXISTS ppt_wilayah_kode_idx;
+DROP TABLE IF EXISTS ppt_region_aliases;
@@ -0,0 +1,17 @@
+-- Admin maintained spellings of ppt_wilayah names that the reconciler cannot
+-- match on its own. An alias only applies below the parent of its kode.
+CREATE TABLE ppt_region_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    kode VARCHAR(13) NOT NULL,
+    alias VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX ON "ppt_region_aliases" ("kode", lower("alias"));
+
+CREATE INDEX IF NOT EXISTS ppt_wilayah_kode_idx ON ppt_wilayah ("kode");
+
+ALTER TABLE ppt_dataset_records ADD COLUMN region_kode VARCHAR(13) NULL;
+CREATE INDEX ON "ppt_dataset_records" ("identifier", "region_kode" varchar_pattern_ops);
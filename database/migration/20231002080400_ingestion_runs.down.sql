@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ppt_ingestion_run_rows;
+DROP TABLE IF EXISTS ppt_ingestion_runs;
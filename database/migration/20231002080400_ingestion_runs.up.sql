@@ -0,0 +1,35 @@
+CREATE TABLE ppt_ingestion_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job_id VARCHAR(64) NULL,
+    identifier BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    error TEXT NULL,
+    source_url TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}'::jsonb,
+    http_status INTEGER NULL,
+    response_hash VARCHAR(64) NULL,
+    pages INTEGER NOT NULL DEFAULT 0,
+    rows_fetched INTEGER NOT NULL DEFAULT 0,
+    rows_stored INTEGER NOT NULL DEFAULT 0,
+    rows_quarantined INTEGER NOT NULL DEFAULT 0,
+    requested_by VARCHAR(255) NULL,
+    started_at TIMESTAMP NOT NULL,
+    fetched_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    rows_pruned BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_ingestion_runs" ("identifier", "started_at");
+ALTER TABLE "ppt_ingestion_runs" ADD FOREIGN KEY ("identifier") REFERENCES "ppt_datasets" ("identifier");
+
+-- One fingerprint per fetched row, used to diff two runs. row_key is the
+-- readable identity of the row and row_hash a digest of its content. Only
+-- the latest runs of each dataset keep them; see rows_pruned.
+CREATE TABLE ppt_ingestion_run_rows (
+    run_id BIGINT NOT NULL REFERENCES ppt_ingestion_runs (id) ON DELETE CASCADE,
+    row_key TEXT NOT NULL,
+    row_hash BYTEA NOT NULL,
+    PRIMARY KEY (run_id, row_key)
+);
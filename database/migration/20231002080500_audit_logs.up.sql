@@ -0,0 +1,14 @@
+-- Append only record of privileged actions, such as an admin reading
+-- another user's documents.
+CREATE TABLE ppt_audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    actor_email VARCHAR(255) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    target VARCHAR(255) NULL,
+    detail JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ip_address VARCHAR(64) NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_audit_logs" ("action", "created_at");
+CREATE INDEX ON "ppt_audit_logs" ("actor_email", "created_at");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ppt_simluh_syncs;
+DROP TABLE IF EXISTS ppt_user_trainings;
+DROP TABLE IF EXISTS ppt_simluh_courses;
@@ -0,0 +1,34 @@
+-- SIMLUH training courses as last seen on laporanutama.pertanian.go.id.
+CREATE TABLE ppt_simluh_courses (
+    id_pel VARCHAR(20) PRIMARY KEY,
+    kode_pel VARCHAR(100) NULL,
+    judul_pel TEXT NOT NULL,
+    link_materi TEXT NULL,
+    link_flyer TEXT NULL,
+    starts_at TIMESTAMP NULL,
+    ends_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Training history per user, replaced on every successful sync.
+-- status is the raw sts_ikut value, participated its reading.
+CREATE TABLE ppt_user_trainings (
+    user_id BIGINT NOT NULL REFERENCES ppt_users (id) ON DELETE CASCADE,
+    id_pel VARCHAR(20) NOT NULL REFERENCES ppt_simluh_courses (id_pel),
+    status VARCHAR(20) NULL,
+    participated BOOLEAN NOT NULL DEFAULT false,
+    synced_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, id_pel)
+);
+
+CREATE INDEX ON "ppt_user_trainings" ("id_pel");
+
+CREATE TABLE ppt_simluh_syncs (
+    user_id BIGINT PRIMARY KEY REFERENCES ppt_users (id) ON DELETE CASCADE,
+    synced_at TIMESTAMP NOT NULL,
+    trainings INTEGER NOT NULL DEFAULT 0,
+    error TEXT NULL
+);
+
+CREATE INDEX ON "ppt_simluh_syncs" ("synced_at");
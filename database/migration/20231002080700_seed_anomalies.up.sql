@@ -0,0 +1,32 @@
+-- Findings of the Perbenihan supply-chain reconciliation. fingerprint
+-- identifies a finding across passes so its review survives re-ingestion;
+-- findings no longer detected are marked resolved.
+CREATE TABLE ppt_seed_anomalies (
+    id BIGSERIAL PRIMARY KEY,
+    fingerprint VARCHAR(64) NOT NULL UNIQUE,
+    check_name VARCHAR(50) NOT NULL,
+    severity VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    region_kode VARCHAR(13) NULL,
+    region VARCHAR(255) NULL,
+    producer VARCHAR(255) NULL,
+    commodity VARCHAR(100) NULL,
+    variety VARCHAR(100) NULL,
+    year INTEGER NULL,
+    month INTEGER NULL,
+    expected NUMERIC NOT NULL DEFAULT 0,
+    actual NUMERIC NOT NULL DEFAULT 0,
+    difference NUMERIC NOT NULL DEFAULT 0,
+    message TEXT NOT NULL,
+    detail JSONB NOT NULL DEFAULT '{}'::jsonb,
+    note TEXT NULL,
+    reviewed_by VARCHAR(255) NULL,
+    reviewed_at TIMESTAMP NULL,
+    detected_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP NULL
+);
+
+CREATE INDEX ON "ppt_seed_anomalies" ("status", "severity");
+CREATE INDEX ON "ppt_seed_anomalies" ("check_name");
+CREATE INDEX ON "ppt_seed_anomalies" ("region_kode" varchar_pattern_ops);
@@ -0,0 +1,18 @@
+-- One row per Dukcapil verification attempt, including the ones answered
+-- from cache or refused by a quota. The NIK is only kept as a keyed hash.
+CREATE TABLE ppt_dukcapil_verifications (
+    id BIGSERIAL PRIMARY KEY,
+    nik_hash VARCHAR(64) NOT NULL,
+    actor_email VARCHAR(255) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    requested JSONB NOT NULL DEFAULT '[]'::jsonb,
+    status VARCHAR(20) NOT NULL,
+    matches JSONB NULL,
+    cached BOOLEAN NOT NULL DEFAULT false,
+    error TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_dukcapil_verifications" ("created_at");
+CREATE INDEX ON "ppt_dukcapil_verifications" ("nik_hash", "created_at");
+CREATE INDEX ON "ppt_dukcapil_verifications" ("actor_email", "created_at");
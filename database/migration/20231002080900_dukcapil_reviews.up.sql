@@ -0,0 +1,19 @@
+-- Profile completions whose Dukcapil match was borderline, waiting for an
+-- admin. submitted holds the encrypted bio data as sent by the user; a
+-- user has at most one pending review, a resubmission replaces it.
+CREATE TABLE ppt_dukcapil_reviews (
+    id BIGSERIAL PRIMARY KEY,
+    user_email VARCHAR(255) NOT NULL,
+    submitted_by VARCHAR(255) NOT NULL,
+    submitted TEXT NOT NULL,
+    matches JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason TEXT NULL,
+    reviewed_by VARCHAR(255) NULL,
+    reviewed_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX ppt_dukcapil_reviews_pending_idx ON "ppt_dukcapil_reviews" ("user_email") WHERE status = 'pending';
+CREATE INDEX ON "ppt_dukcapil_reviews" ("status", "created_at");
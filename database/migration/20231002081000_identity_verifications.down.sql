@@ -0,0 +1,4 @@
+ALTER TABLE ppt_users DROP COLUMN IF EXISTS verified_at;
+ALTER TABLE ppt_users DROP COLUMN IF EXISTS verified_by;
+
+DROP TABLE IF EXISTS ppt_identity_verifications;
@@ -0,0 +1,22 @@
+-- Manual identity verification from an uploaded KTP photo and selfie, for
+-- users Dukcapil could not match. The documents themselves live encrypted
+-- in file storage under the keys kept here; a user has at most one pending
+-- request, a new upload replaces it.
+CREATE TABLE ppt_identity_verifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES ppt_users (id) ON DELETE CASCADE,
+    ktp_key VARCHAR(255) NOT NULL,
+    selfie_key VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason TEXT NULL,
+    reviewed_by VARCHAR(255) NULL,
+    reviewed_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX ppt_identity_verifications_pending_idx ON "ppt_identity_verifications" ("user_id") WHERE status = 'pending';
+CREATE INDEX ON "ppt_identity_verifications" ("status", "created_at");
+
+ALTER TABLE ppt_users ADD COLUMN verified_by VARCHAR(255) NULL;
+ALTER TABLE ppt_users ADD COLUMN verified_at TIMESTAMP NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ppt_files;
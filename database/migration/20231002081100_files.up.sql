@@ -0,0 +1,19 @@
+-- Files uploaded through /v1/files. The image columns of ppt_users,
+-- ppt_user_lands, ppt_services, ppt_service_details and ppt_modules hold
+-- keys from here; a file no column refers to is removed by the orphan
+-- cleanup once it is older than the grace period.
+CREATE TABLE ppt_files (
+    id BIGSERIAL PRIMARY KEY,
+    key VARCHAR(100) NOT NULL UNIQUE,
+    thumb_key VARCHAR(100) NULL,
+    purpose VARCHAR(20) NOT NULL,
+    owner_email VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_files" ("created_at");
+CREATE INDEX ON "ppt_files" ("owner_email");
@@ -0,0 +1,20 @@
+-- Transactional email. Rows are written in the same transaction as the
+-- change that triggers them and delivered by the outbox worker, which
+-- retries with backoff until max attempts and then marks them failed.
+CREATE TABLE ppt_email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    template VARCHAR(50) NOT NULL,
+    locale VARCHAR(5) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX ON "ppt_email_outbox" ("status", "next_attempt_at");
+CREATE INDEX ON "ppt_email_outbox" ("recipient");
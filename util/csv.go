@@ -0,0 +1,14 @@
+package util
+
+import "strings"
+
+// EscapeCSVFormula stops spreadsheet applications opening a CSV from
+// evaluating text as a formula by prefixing values that start with =, +,
+// -, @, a tab or a carriage return with a quote. Only text cells need it,
+// and XLSX needs none of this: its string cells are never evaluated.
+func EscapeCSVFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,25 @@
+package util
+
+import "testing"
+
+func TestEscapeCSVFormula(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+62812", "'+62812"},
+		{"-", "'-"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"PADI", "PADI"},
+		{"a=b", "a=b"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeCSVFormula(tt.in); got != tt.want {
+			t.Errorf("EscapeCSVFormula(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package util
+
+import "time"
+
+// IngestJobChannel is the Redis pub/sub channel carrying ingestion job
+// snapshots; the WebSocket server relays it to subscribed admins.
+const IngestJobChannel = "ppt:ingest-jobs"
+
+// IngestJob is a snapshot of an ingestion job as it is stored and
+// published.
+type IngestJob struct {
+	ID           string     `json:"id"`
+	DatasetID    int        `json:"identifier"`
+	Dataset      string     `json:"dataset"`
+	Status       string     `json:"status"`
+	PagesFetched int        `json:"pages_fetched"`
+	RowsFetched  int        `json:"rows_fetched"`
+	RowsStored   int        `json:"rows_stored"`
+	Quarantined  int        `json:"rows_quarantined"`
+	Unresolved   int        `json:"rows_region_unresolved"`
+	ReportID     string     `json:"report_id"`
+	RunID        string     `json:"run_id"`
+	Errors       []string   `json:"errors"`
+	RequestedBy  string     `json:"requested_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRole is the role created by InitCreate with full access.
+const AdminRole = "superadmin"
+
+// ClaimsEmail returns the plaintext email of the user authenticated by
+// AuthMiddleware.
+func ClaimsEmail(c *gin.Context) (string, error) {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return "", errors.New("failed to retrieve claims")
+	}
+
+	userClaims, ok := claims.(*Claims)
+	if !ok {
+		return "", errors.New("invalid claims")
+	}
+
+	return Decrypt(userClaims.Email, "s")
+}
+
+// IsAdmin reports whether email belongs to an active user holding AdminRole.
+func IsAdmin(ctx context.Context, db *sql.DB, email string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM ppt_users u
+		JOIN ppt_roles r ON r.id = u.role_id
+		WHERE u.email = $1 AND u.is_active = true AND r.name = $2
+	)`
+
+	var admin bool
+	if err := db.QueryRowContext(ctx, query, email, AdminRole).Scan(&admin); err != nil {
+		return false, err
+	}
+
+	return admin, nil
+}
+
+// AdminMiddleware rejects requests from non admin users. It must run after
+// AuthMiddleware.
+func AdminMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, err := ClaimsEmail(c)
+		if err != nil {
+			JERR(c, http.StatusUnauthorized, err)
+			c.Abort()
+			return
+		}
+
+		admin, err := IsAdmin(c, db, email)
+		if err != nil {
+			JERR(c, http.StatusInternalServerError, err)
+			c.Abort()
+			return
+		}
+
+		if !admin {
+			JERR(c, http.StatusForbidden, errors.New("admin access required"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
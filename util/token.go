@@ -67,50 +67,64 @@ func AuthMiddleware(rdb *redis.Client) gin.HandlerFunc {
 			return
 		}
 
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 {
-			JERR(c, http.StatusUnauthorized, errors.New("invalid token format"))
+		claims, status, err := Authenticate(c, rdb, authHeader)
+		if err != nil {
+			JERR(c, status, err)
 			c.Abort()
 			return
 		}
 
-		tokenString := tokenParts[1]
-		email := tokenParts[0]
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
 
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(appConfig.SecretKey), nil
-		})
+// Authenticate checks an Authorization header value and its session the
+// way AuthMiddleware does, for handlers that cannot use the middleware.
+// On failure it returns the status to answer with.
+func Authenticate(ctx context.Context, rdb *redis.Client, authHeader string) (*Claims, int, error) {
+	tokenParts := strings.Split(authHeader, " ")
+	if len(tokenParts) != 2 {
+		return nil, http.StatusUnauthorized, errors.New("invalid token format")
+	}
 
-		if err != nil || !token.Valid {
-			JERR(c, http.StatusUnauthorized, errors.New("invalid token"))
-			c.Abort()
-			return
-		}
+	tokenString := tokenParts[1]
+	email := tokenParts[0]
 
-		claims, ok := token.Claims.(*Claims)
-		if !ok {
-			JERR(c, http.StatusUnauthorized, errors.New("invalid token claims"))
-			c.Abort()
-			return
-		}
+	claims, err := ParseClaims(tokenString)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
 
-		encEmail, _ := Encrypt(email, "s")
-		exists, err := rdb.Exists(context.Background(), encEmail).Result()
-		if err != nil {
-			JERR(c, http.StatusUnauthorized, errors.New("failed to check session identifier in Redis"))
-			c.Abort()
-			return
-		}
+	encEmail, _ := Encrypt(email, "s")
+	exists, err := rdb.Exists(ctx, encEmail).Result()
+	if err != nil {
+		return nil, http.StatusUnauthorized, errors.New("failed to check session identifier in Redis")
+	}
 
-		if exists > 0 {
-			JERR(c, http.StatusForbidden, errors.New("user already logged in"))
-			c.Abort()
-			return
-		}
+	if exists > 0 {
+		return nil, http.StatusForbidden, errors.New("user already logged in")
+	}
 
-		c.Set("claims", claims)
-		c.Next()
+	return claims, http.StatusOK, nil
+}
+
+// ParseClaims validates a signed access token and returns its claims.
+func ParseClaims(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(appConfig.SecretKey), nil
+	})
+
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
 }
 
 func Refresh(c *gin.Context) (string, error) {
@@ -0,0 +1,363 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+var (
+	ErrUpstreamTimeout     = errors.New("upstream timed out")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrUpstreamResponse    = errors.New("unexpected upstream response")
+	ErrCircuitOpen         = errors.New("upstream circuit is open")
+)
+
+type (
+	// UpstreamOptions tunes one upstream host. Zero members fall back to
+	// defaultUpstreamOptions; NoRetry turns retries off for hosts whose
+	// calls must not be repeated, such as metered verifications.
+	UpstreamOptions struct {
+		Timeout          time.Duration
+		Retries          int
+		NoRetry          bool
+		BaseDelay        time.Duration
+		MaxDelay         time.Duration
+		FailureThreshold int
+		OpenFor          time.Duration
+	}
+
+	// Upstream is a shared HTTP client for one government API host. It
+	// retries 5xx and network failures with jittered backoff and stops
+	// calling the host for a while once it keeps failing.
+	Upstream struct {
+		host    string
+		opts    UpstreamOptions
+		client  *http.Client
+		breaker *breaker
+	}
+
+	// UpstreamError is returned for every failure attributable to the
+	// upstream; Kind is one of the ErrUpstream* or ErrCircuitOpen values.
+	UpstreamError struct {
+		Host       string
+		StatusCode int
+		Kind       error
+		Cause      error
+	}
+
+	UpstreamState struct {
+		Host                string     `json:"host"`
+		State               string     `json:"state"`
+		ConsecutiveFailures int        `json:"consecutive_failures"`
+		OpenedAt            *time.Time `json:"opened_at"`
+		Timeout             string     `json:"timeout"`
+	}
+
+	breaker struct {
+		mu        sync.Mutex
+		state     string
+		failures  int
+		openedAt  time.Time
+		threshold int
+		openFor   time.Duration
+	}
+)
+
+var defaultUpstreamOptions = UpstreamOptions{
+	Timeout:          15 * time.Second,
+	Retries:          2,
+	BaseDelay:        500 * time.Millisecond,
+	MaxDelay:         5 * time.Second,
+	FailureThreshold: 5,
+	OpenFor:          30 * time.Second,
+}
+
+// upstreamHostOptions holds the per-host overrides. The bulk dataset APIs
+// answer slowly, the interactive ones should fail fast.
+var upstreamHostOptions = map[string]UpstreamOptions{
+	"api-splp.layanan.go.id":             {Timeout: 60 * time.Second},
+	"apps.tanamanpangan.pertanian.go.id": {Timeout: 60 * time.Second},
+	"latihanonline.pertanian.go.id":      {Timeout: 30 * time.Second},
+	"laporanutama.pertanian.go.id":       {Timeout: 15 * time.Second},
+	"api.openweathermap.org":             {Timeout: 10 * time.Second, Retries: 1},
+}
+
+var (
+	upstreamsMu sync.Mutex
+	upstreams   = map[string]*Upstream{}
+)
+
+func (e *UpstreamError) Error() string {
+	msg := e.Host + ": " + e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// UpstreamHTTPStatus maps err onto the status to answer the client with:
+// 504 when the upstream timed out, 502 for any other upstream failure and
+// 500 for errors that did not come from an upstream.
+func UpstreamHTTPStatus(err error) int {
+	var ue *UpstreamError
+	if !errors.As(err, &ue) {
+		return http.StatusInternalServerError
+	}
+	if errors.Is(ue.Kind, ErrUpstreamTimeout) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// UpstreamFor returns the shared client for the host of rawURL.
+func UpstreamFor(rawURL string) *Upstream {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+
+	if u, ok := upstreams[host]; ok {
+		return u
+	}
+
+	opts := upstreamHostOptions[host]
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultUpstreamOptions.Timeout
+	}
+	if opts.NoRetry {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = defaultUpstreamOptions.Retries
+	}
+	if opts.BaseDelay == 0 {
+		opts.BaseDelay = defaultUpstreamOptions.BaseDelay
+	}
+	if opts.MaxDelay == 0 {
+		opts.MaxDelay = defaultUpstreamOptions.MaxDelay
+	}
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = defaultUpstreamOptions.FailureThreshold
+	}
+	if opts.OpenFor == 0 {
+		opts.OpenFor = defaultUpstreamOptions.OpenFor
+	}
+
+	u := &Upstream{
+		host:   host,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		breaker: &breaker{
+			state:     CircuitClosed,
+			threshold: opts.FailureThreshold,
+			openFor:   opts.OpenFor,
+		},
+	}
+	upstreams[host] = u
+
+	return u
+}
+
+// UpstreamStates reports the circuit state of every upstream used so far.
+func UpstreamStates() []UpstreamState {
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+
+	states := make([]UpstreamState, 0, len(upstreams))
+	for _, u := range upstreams {
+		states = append(states, u.State())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Host < states[j].Host })
+
+	return states
+}
+
+func (u *Upstream) State() UpstreamState {
+	u.breaker.mu.Lock()
+	defer u.breaker.mu.Unlock()
+
+	state := UpstreamState{
+		Host:                u.host,
+		State:               u.breaker.state,
+		ConsecutiveFailures: u.breaker.failures,
+		Timeout:             u.opts.Timeout.String(),
+	}
+	if u.breaker.state != CircuitClosed {
+		openedAt := u.breaker.openedAt
+		state.OpenedAt = &openedAt
+	}
+
+	return state
+}
+
+func (u *Upstream) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return u.Do(req)
+}
+
+// Do sends req, retrying network errors and 5xx answers. Any other status
+// is handed back to the caller, who can turn it into an error with
+// Unexpected. A request body is only resent when req.GetBody is set, which
+// http.NewRequest does for in-memory bodies.
+func (u *Upstream) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := u.breaker.allow(); err != nil {
+			return nil, &UpstreamError{Host: u.host, Kind: ErrCircuitOpen}
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := u.client.Do(req)
+
+		// The caller went away; that says nothing about the upstream.
+		if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+			u.breaker.release()
+			return nil, err
+		}
+
+		var failure *UpstreamError
+		switch {
+		case err != nil:
+			failure = &UpstreamError{Host: u.host, Kind: ErrUpstreamUnavailable, Cause: err}
+			if isTimeout(err) {
+				failure.Kind = ErrUpstreamTimeout
+			}
+		case resp.StatusCode >= http.StatusInternalServerError:
+			failure = &UpstreamError{Host: u.host, StatusCode: resp.StatusCode, Kind: ErrUpstreamUnavailable}
+			if resp.StatusCode == http.StatusGatewayTimeout {
+				failure.Kind = ErrUpstreamTimeout
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if failure == nil {
+			u.breaker.success()
+			return resp, nil
+		}
+
+		u.breaker.failure()
+
+		canResend := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if attempt >= u.opts.Retries || !canResend {
+			return nil, failure
+		}
+
+		select {
+		case <-time.After(u.backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, failure
+		}
+	}
+}
+
+// Unexpected turns a non-OK response into an UpstreamError and closes it.
+func (u *Upstream) Unexpected(resp *http.Response) error {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return &UpstreamError{Host: u.host, StatusCode: resp.StatusCode, Kind: ErrUpstreamResponse}
+}
+
+// backoff uses full jitter so retries from many requests do not line up.
+func (u *Upstream) backoff(attempt int) time.Duration {
+	ceiling := u.opts.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > u.opts.MaxDelay {
+		ceiling = u.opts.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// allow lets a call through unless the circuit is open. Once the open
+// period has passed a single trial call is let through (half-open); its
+// outcome closes or reopens the circuit.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.openFor {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		return nil
+	case CircuitHalfOpen:
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release hands the half-open trial back when its call was abandoned.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.state = CircuitOpen
+		b.openedAt = time.Now().Add(-b.openFor)
+	}
+}
@@ -0,0 +1,277 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testUpstream(rawURL string, opts UpstreamOptions) *Upstream {
+	u, _ := url.Parse(rawURL)
+	return &Upstream{
+		host:   u.Hostname(),
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		breaker: &breaker{
+			state:     CircuitClosed,
+			threshold: opts.FailureThreshold,
+			openFor:   opts.OpenFor,
+		},
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		do   string // allow, success, failure, release or wait
+		want string // state after the step
+		err  bool   // whether allow is refused
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"opens at the threshold", []step{
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"allow", CircuitClosed, false},
+			{"failure", CircuitOpen, false},
+			{"allow", CircuitOpen, true},
+		}},
+		{"success resets the count", []step{
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"success", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"allow", CircuitClosed, false},
+		}},
+		{"one trial once the open period passed", []step{
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitOpen, false},
+			{"wait", CircuitOpen, false},
+			{"allow", CircuitHalfOpen, false},
+			{"allow", CircuitHalfOpen, true},
+		}},
+		{"a successful trial closes", []step{
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitOpen, false},
+			{"wait", CircuitOpen, false},
+			{"allow", CircuitHalfOpen, false},
+			{"success", CircuitClosed, false},
+			{"allow", CircuitClosed, false},
+		}},
+		{"a failed trial reopens", []step{
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitOpen, false},
+			{"wait", CircuitOpen, false},
+			{"allow", CircuitHalfOpen, false},
+			{"failure", CircuitOpen, false},
+			{"allow", CircuitOpen, true},
+		}},
+		{"an abandoned trial is handed back", []step{
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitClosed, false},
+			{"failure", CircuitOpen, false},
+			{"wait", CircuitOpen, false},
+			{"allow", CircuitHalfOpen, false},
+			{"release", CircuitOpen, false},
+			{"allow", CircuitHalfOpen, false},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &breaker{state: CircuitClosed, threshold: 3, openFor: time.Minute}
+
+			for i, s := range tt.steps {
+				var err error
+				switch s.do {
+				case "allow":
+					err = b.allow()
+				case "success":
+					b.success()
+				case "failure":
+					b.failure()
+				case "release":
+					b.release()
+				case "wait":
+					b.openedAt = b.openedAt.Add(-b.openFor)
+				}
+
+				if (err != nil) != s.err {
+					t.Fatalf("step %d (%s): allow error = %v, want refused %v", i, s.do, err, s.err)
+				}
+				if b.state != s.want {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, s.do, b.state, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	u := testUpstream("http://upstream.test", UpstreamOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		// The shift overflows; the delay must still be capped.
+		{70, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 200; i++ {
+			if d := u.backoff(tt.attempt); d < 0 || d > tt.ceiling {
+				t.Fatalf("backoff(%d) = %v, want 0..%v", tt.attempt, d, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestUpstreamDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		retries   int
+		wantCalls int32
+		wantErr   error
+		wantCode  int
+		wantState string
+	}{
+		{"ok", []int{200}, 2, 1, nil, http.StatusOK, CircuitClosed},
+		{"retries server errors", []int{503, 502, 200}, 2, 3, nil, http.StatusOK, CircuitClosed},
+		{"gives up after the retries", []int{503, 503, 503, 200}, 2, 3, ErrUpstreamUnavailable, http.StatusBadGateway, CircuitOpen},
+		{"gateway timeout", []int{504}, 0, 1, ErrUpstreamTimeout, http.StatusGatewayTimeout, CircuitClosed},
+		{"client errors are the caller's", []int{404, 200}, 2, 1, nil, http.StatusNotFound, CircuitClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[int(n)-1])
+			}))
+			defer srv.Close()
+
+			u := testUpstream(srv.URL, UpstreamOptions{
+				Timeout:          time.Second,
+				Retries:          tt.retries,
+				BaseDelay:        time.Millisecond,
+				MaxDelay:         time.Millisecond,
+				FailureThreshold: 3,
+				OpenFor:          time.Minute,
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := u.Do(req)
+
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("upstream called %d times, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantCode {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+				}
+			} else {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Do() error = %v, want %v", err, tt.wantErr)
+				}
+				if got := UpstreamHTTPStatus(err); got != tt.wantCode {
+					t.Errorf("UpstreamHTTPStatus() = %d, want %d", got, tt.wantCode)
+				}
+			}
+			if got := u.State().State; got != tt.wantState {
+				t.Errorf("circuit = %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestUpstreamForOptions(t *testing.T) {
+	tests := []struct {
+		host    string
+		opts    UpstreamOptions
+		retries int
+		timeout time.Duration
+	}{
+		{"defaults.test", UpstreamOptions{}, defaultUpstreamOptions.Retries, defaultUpstreamOptions.Timeout},
+		{"fewer.test", UpstreamOptions{Retries: 1, Timeout: time.Second}, 1, time.Second},
+		{"noretry.test", UpstreamOptions{NoRetry: true}, 0, defaultUpstreamOptions.Timeout},
+		{"noretry-wins.test", UpstreamOptions{NoRetry: true, Retries: 3}, 0, defaultUpstreamOptions.Timeout},
+	}
+
+	for _, tt := range tests {
+		upstreamHostOptions[tt.host] = tt.opts
+		u := UpstreamFor("https://" + tt.host + "/path")
+
+		upstreamsMu.Lock()
+		delete(upstreamHostOptions, tt.host)
+		delete(upstreams, tt.host)
+		upstreamsMu.Unlock()
+
+		if u.opts.Retries != tt.retries || u.opts.Timeout != tt.timeout {
+			t.Errorf("%s: retries/timeout = %d/%v, want %d/%v", tt.host, u.opts.Retries, u.opts.Timeout, tt.retries, tt.timeout)
+		}
+	}
+}
+
+func TestUpstreamDoRefusesWhileOpen(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer srv.Close()
+
+	u := testUpstream(srv.URL, UpstreamOptions{Timeout: time.Second, FailureThreshold: 1, OpenFor: time.Minute})
+	u.breaker.failure()
+
+	_, err := u.Get(context.Background(), srv.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if calls != 0 {
+		t.Errorf("upstream called %d times while the circuit was open", calls)
+	}
+	if got := UpstreamHTTPStatus(errors.New("local")); got != http.StatusInternalServerError {
+		t.Errorf("UpstreamHTTPStatus(local error) = %d, want 500", got)
+	}
+}
+
+func TestUpstreamTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	u := testUpstream(srv.URL, UpstreamOptions{Timeout: 20 * time.Millisecond, FailureThreshold: 5, OpenFor: time.Minute})
+
+	_, err := u.Get(context.Background(), srv.URL)
+	if !errors.Is(err, ErrUpstreamTimeout) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrUpstreamTimeout)
+	}
+	if got := UpstreamHTTPStatus(err); got != http.StatusGatewayTimeout {
+		t.Errorf("UpstreamHTTPStatus() = %d, want 504", got)
+	}
+}